@@ -4,16 +4,19 @@
 package comid
 
 import (
-	"fmt"
-	"reflect"
-
 	cbor "github.com/fxamacker/cbor/v2"
+	"github.com/jraman567/corim/encoding"
 )
 
 var (
-	em, emError = initCBOREncMode()
-	dm, dmError = initCBORDecMode()
-
+	// em and dm encode and decode using the CBOR tags of DefaultRegistry.
+	// The types depending on the Registry use the modes of the one selected
+	// by their encoding.Encoder or encoding.Decoder instead (see encModeFor
+	// and decModeFor).
+	em cbor.EncMode = defaultEncMode{}
+	dm cbor.DecMode = defaultDecMode{}
+
+	// comidTagsMap contains the CBOR tags every Registry starts with
 	comidTagsMap = map[uint64]interface{}{
 		32:  TaggedURI(""),
 		37:  TaggedUUID{},
@@ -38,66 +41,18 @@ var (
 	}
 )
 
-func comidTags() cbor.TagSet {
-	opts := cbor.TagOptions{
-		EncTag: cbor.EncTagRequired,
-		DecTag: cbor.DecTagRequired,
-	}
-
-	tags := cbor.NewTagSet()
-
-	for tag, typ := range comidTagsMap {
-		if err := tags.Add(opts, reflect.TypeOf(typ), tag); err != nil {
-			panic(err)
-		}
-	}
-
-	return tags
-}
-
-func initCBOREncMode() (en cbor.EncMode, err error) {
+func initCBOREncModes(tags cbor.TagSet) (*encoding.EncModes, error) {
 	encOpt := cbor.EncOptions{
 		Sort:        cbor.SortCoreDeterministic,
 		IndefLength: cbor.IndefLengthForbidden,
 		TimeTag:     cbor.EncTagRequired,
 	}
-	return encOpt.EncModeWithTags(comidTags())
+	return encoding.NewEncModes(encOpt, tags)
 }
 
-func initCBORDecMode() (dm cbor.DecMode, err error) {
+func initCBORDecModes(tags cbor.TagSet) (*encoding.DecModes, error) {
 	decOpt := cbor.DecOptions{
 		IndefLength: cbor.IndefLengthForbidden,
 	}
-	return decOpt.DecModeWithTags(comidTags())
-}
-
-func registerCOMIDTag(tag uint64, t interface{}) error {
-	if _, exists := comidTagsMap[tag]; exists {
-		return fmt.Errorf("tag %d is already registered", tag)
-	}
-
-	comidTagsMap[tag] = t
-
-	var err error
-
-	em, err = initCBOREncMode()
-	if err != nil {
-		return err
-	}
-
-	dm, err = initCBORDecMode()
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func init() {
-	if emError != nil {
-		panic(emError)
-	}
-	if dmError != nil {
-		panic(dmError)
-	}
+	return encoding.NewDecModes(decOpt, tags)
 }
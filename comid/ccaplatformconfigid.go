@@ -8,6 +8,8 @@ import (
 	"errors"
 	"fmt"
 	"unicode/utf8"
+
+	"github.com/jraman567/corim/validation"
 )
 
 var CCAPlatformConfigIDType = "cca.platform-config-id"
@@ -67,7 +69,7 @@ func NewTaggedCCAPlatformConfigID(val any) (*TaggedCCAPlatformConfigID, error) {
 
 func (o TaggedCCAPlatformConfigID) Valid() error {
 	if o == "" {
-		return errors.New("empty value")
+		return validation.New(validation.CodeEmpty, "empty value")
 	}
 
 	return nil
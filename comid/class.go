@@ -5,7 +5,14 @@ package comid
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jraman567/corim/encoding"
+	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 )
 
 // Class represents the class of the (target / attesting) environment.  The only
@@ -18,6 +25,8 @@ type Class struct {
 	Model   *string  `cbor:"2,keyasint,omitempty" json:"model,omitempty"`
 	Layer   *uint64  `cbor:"3,keyasint,omitempty" json:"layer,omitempty"`
 	Index   *uint64  `cbor:"4,keyasint,omitempty" json:"index,omitempty"`
+
+	Extensions
 }
 
 // NewClassUUID instantiates a new Class object with the specified UUID as
@@ -52,6 +61,36 @@ func NewClassOID(oid string) *Class {
 	return &Class{ClassID: classID}
 }
 
+// RegisterExtensions registers a struct as a collections of extensions
+func (o *Class) RegisterExtensions(exts extensions.Map) error {
+	for p, v := range exts {
+		switch p {
+		case ExtClass:
+			o.Extensions.Register(v)
+		default:
+			return fmt.Errorf("%w: %q", extensions.ErrUnexpectedPoint, p)
+		}
+	}
+
+	return nil
+}
+
+// GetExtensions returns previously registered extension
+func (o *Class) GetExtensions() extensions.IMapValue {
+	return o.Extensions.IMapValue
+}
+
+// IsEmpty returns true if none of the fields of the target Class, including
+// its extensions, are set
+func (o Class) IsEmpty() bool {
+	if (o.ClassID != nil && o.ClassID.IsSet()) ||
+		o.Vendor != nil || o.Model != nil || o.Layer != nil || o.Index != nil {
+		return false
+	}
+
+	return o.Extensions.IsEmpty()
+}
+
 // SetVendor sets the vendor metadata to the supplied string
 func (o *Class) SetVendor(vendor string) *Class {
 	if o != nil {
@@ -125,13 +164,97 @@ func (o *Class) SetIndex(index uint64) *Class {
 // Valid checks the non-empty<> constraint on the map
 func (o Class) Valid() error {
 	// check non-empty<{ ... }>
-	if (o.ClassID == nil || !o.ClassID.IsSet()) &&
-		o.Vendor == nil && o.Model == nil && o.Layer == nil && o.Index == nil {
-		return fmt.Errorf("class must not be empty")
+	if o.IsEmpty() {
+		return validation.New(validation.CodeEmpty, "class must not be empty")
+	}
+
+	return o.Extensions.validClass(&o)
+}
+
+// UnmarshalCBOR deserializes from CBOR
+func (o *Class) UnmarshalCBOR(data []byte) error {
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *Class) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromCBOR(decModeFor(d), data, o)
+}
+
+// MarshalCBOR serializes to CBOR
+func (o Class) MarshalCBOR() ([]byte, error) {
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o Class) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return e.SerializeStructToCBOR(encModeFor(e), o)
+}
+
+// UnmarshalJSON deserializes from JSON
+func (o *Class) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Class) DecodeJSON(d *encoding.Decoder, data []byte) error {
+	if err := d.PopulateStructFromJSON(data, o); err != nil {
+		return jsonTypeError(err, o)
 	}
+
 	return nil
 }
 
+// jsonTypeError reports the type mismatches found while populating the struct
+// pointed to by dest from JSON the way encoding/json does: naming the struct,
+// rather than the map of its members, or the struct field whose value has the
+// wrong type. Other errors are returned as they are.
+func jsonTypeError(err error, dest any) error {
+	structType := reflect.TypeOf(dest).Elem()
+
+	// the JSON value is not an object
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok &&
+		typeErr.Type == reflect.TypeOf(map[string]json.RawMessage{}) {
+		return &json.UnmarshalTypeError{Value: typeErr.Value, Type: structType, Offset: typeErr.Offset}
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Field != "" {
+		return err
+	}
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+
+		if fieldType != typeErr.Type {
+			continue
+		}
+
+		ret := *typeErr
+		ret.Struct = structType.Name()
+		ret.Field = strings.Split(field.Tag.Get("json"), ",")[0]
+
+		return &ret
+	}
+
+	return err
+}
+
+// MarshalJSON serializes to JSON
+func (o Class) MarshalJSON() ([]byte, error) {
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o Class) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
+	return e.SerializeStructToJSON(o)
+}
+
 // ToCBOR serializes the target Class to CBOR (if the Class is "valid")
 func (o Class) ToCBOR() ([]byte, error) {
 	if err := o.Valid(); err != nil {
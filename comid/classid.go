@@ -7,12 +7,12 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"strconv"
 
 	"github.com/jraman567/corim/encoding"
 	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 )
 
 // ClassID identifies the environment via a well-known identifier. This can be
@@ -23,7 +23,13 @@ type ClassID struct {
 
 // NewClassID creates a new ClassID of the specified type using the specified value.
 func NewClassID(val any, typ string) (*ClassID, error) {
-	factory, ok := classIDValueRegister[typ]
+	return DefaultRegistry.NewClassID(val, typ)
+}
+
+// NewClassID is like the package-level NewClassID, but uses the type choices
+// registered with the target Registry.
+func (o *Registry) NewClassID(val any, typ string) (*ClassID, error) {
+	factory, ok := lookupTypeChoice(o, o.classIDs, typ)
 	if !ok {
 		return nil, fmt.Errorf("unknown class id type: %s", typ)
 	}
@@ -35,10 +41,10 @@ func NewClassID(val any, typ string) (*ClassID, error) {
 // problem, if it is not.
 func (o ClassID) Valid() error {
 	if o.Value == nil {
-		return errors.New("nil value")
+		return validation.New(validation.CodeMissing, "nil value")
 	}
 
-	return o.Value.Valid()
+	return validation.Wrap(o.Value.Valid())
 }
 
 // Type returns the type of the ClassID
@@ -65,14 +71,24 @@ func (o ClassID) IsSet() bool {
 
 // MarshalCBOR serializes the target ClassID to CBOR
 func (o ClassID) MarshalCBOR() ([]byte, error) {
-	return em.Marshal(o.Value)
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o ClassID) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return encModeFor(e).Marshal(o.Value)
 }
 
 // UnmarshalCBOR deserializes the supplied CBOR buffer into the target ClassID.
 // It is undefined behavior to try and inspect the target ClassID in case this
 // method returns an error.
 func (o *ClassID) UnmarshalCBOR(data []byte) error {
-	return dm.Unmarshal(data, &o.Value)
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *ClassID) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return decModeFor(d).Unmarshal(data, &o.Value)
 }
 
 // UnmarshalJSON deserializes the supplied JSON object into the target ClassID
@@ -95,13 +111,18 @@ func (o *ClassID) UnmarshalCBOR(data []byte) error {
 //		int: an integer value, e.g. 7
 //	 bytes: a variable length opaque bytes, example {0x07, 0x12, 0x34}
 func (o *ClassID) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *ClassID) DecodeJSON(d *encoding.Decoder, data []byte) error {
 	var tnv encoding.TypeAndValue
 
 	if err := json.Unmarshal(data, &tnv); err != nil {
 		return fmt.Errorf("class id decoding failure: %w", err)
 	}
 
-	decoded, err := NewClassID(nil, tnv.Type)
+	decoded, err := RegistryFrom(d.Registry).NewClassID(nil, tnv.Type)
 	if err != nil {
 		return err
 	}
@@ -438,7 +459,8 @@ func NewBytesClassID(val any) (*ClassID, error) {
 // See also https://go.dev/ref/spec#The_zero_value
 type IClassIDFactory func(any) (*ClassID, error)
 
-var classIDValueRegister = map[string]IClassIDFactory{
+// builtinClassIDTypes contains the class ID types every Registry starts with
+var builtinClassIDTypes = map[string]IClassIDFactory{
 	OIDType:    NewOIDClassID,
 	UUIDType:   NewUUIDClassID,
 	IntType:    NewIntClassID,
@@ -449,21 +471,23 @@ var classIDValueRegister = map[string]IClassIDFactory{
 // RegisterClassIDType registers a new IClassIDValue implementation (created
 // by the provided IClassIDFactory) under the specified CBOR tag.
 func RegisterClassIDType(tag uint64, factory IClassIDFactory) error {
+	return DefaultRegistry.RegisterClassIDType(tag, factory)
+}
+
+// RegisterClassIDType is like the package-level RegisterClassIDType, but
+// registers with the target Registry.
+func (o *Registry) RegisterClassIDType(tag uint64, factory IClassIDFactory) error {
 	nilVal, err := factory(nil)
 	if err != nil {
 		return err
 	}
 
-	typ := nilVal.Type()
-	if _, exists := classIDValueRegister[typ]; exists {
-		return fmt.Errorf("class ID type with name %q already exists", typ)
-	}
-
-	if err := registerCOMIDTag(tag, nilVal.Value); err != nil {
-		return err
-	}
-
-	classIDValueRegister[typ] = factory
+	return registerTypeChoice(o, o.classIDs, "class ID", nilVal.Type(), tag, nilVal.Value, factory)
+}
 
-	return nil
+// HasClassIDType returns true if a class ID type with the specified name (see
+// ClassID.Type) is registered with the target Registry
+func (o *Registry) HasClassIDType(typ string) bool {
+	_, ok := lookupTypeChoice(o, o.classIDs, typ)
+	return ok
 }
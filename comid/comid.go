@@ -5,11 +5,14 @@ package comid
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/url"
 
 	"github.com/jraman567/corim/encoding"
 	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 	"github.com/veraison/swid"
 )
 
@@ -90,6 +93,25 @@ func (o *Comid) SetTagIdentity(tagID interface{}, tagIDVersion uint) *Comid {
 	return o
 }
 
+// BumpTagVersion increments the tag-version of the target Comid and returns
+// its CBOR encoding with the new version. The Comid is left unchanged if the
+// re-encoding fails.
+func (o *Comid) BumpTagVersion() ([]byte, error) {
+	if o.TagIdentity.TagVersion == math.MaxUint {
+		return nil, errors.New("tag-version overflow")
+	}
+
+	o.TagIdentity.TagVersion++
+
+	data, err := o.ToCBOR()
+	if err != nil {
+		o.TagIdentity.TagVersion--
+		return nil, err
+	}
+
+	return data, nil
+}
+
 func IsAbsoluteURI(s string) error {
 	var (
 		u   *url.URL
@@ -244,30 +266,32 @@ func (o *Comid) AddDevIdentityKey(val KeyTriple) *Comid {
 
 func (o Comid) Valid() error {
 	if err := o.TagIdentity.Valid(); err != nil {
-		return fmt.Errorf("tag-identity validation failed: %w", err)
+		return validation.Errorf("tag-identity", "tag-identity validation failed: %w", err)
 	}
 
 	if o.Entities != nil {
 		if err := o.Entities.Valid(); err != nil {
-			return fmt.Errorf("entities validation failed: %w", err)
+			return validation.Errorf("entities", "entities validation failed: %w", err)
 		}
 	}
 
 	if o.LinkedTags != nil {
 		if err := o.LinkedTags.Valid(); err != nil {
-			return fmt.Errorf("linked-tags validation failed: %w", err)
+			return validation.Errorf("linked-tags", "linked-tags validation failed: %w", err)
 		}
 	}
 
 	if err := o.Triples.Valid(); err != nil {
-		return fmt.Errorf("triples validation failed: %w", err)
+		return validation.Errorf("triples", "triples validation failed: %w", err)
 	}
 
 	return o.Extensions.validComid(&o)
 }
 
-// ToCBOR serializes the target Comid to CBOR
-func (o Comid) ToCBOR() ([]byte, error) {
+// ToCBOR serializes the target Comid to CBOR. Use encoding.WithDeterministic
+// to obtain core deterministic encoding, e.g. for computing content hashes,
+// and WithEncodeRegistry to encode with a Registry other than DefaultRegistry.
+func (o Comid) ToCBOR(opts ...encoding.EncodeOption) ([]byte, error) {
 	if err := o.Valid(); err != nil {
 		return nil, err
 	}
@@ -282,12 +306,24 @@ func (o Comid) ToCBOR() ([]byte, error) {
 		o.Entities = nil
 	}
 
-	return encoding.SerializeStructToCBOR(em, &o)
+	e := encoding.NewEncoder(opts...)
+
+	return e.SerializeStructToCBOR(encModeFor(e), &o)
 }
 
-// FromCBOR deserializes a CBOR-encoded CoMID into the target Comid
-func (o *Comid) FromCBOR(data []byte) error {
-	return encoding.PopulateStructFromCBOR(dm, data, o)
+// FromCBOR deserializes a CBOR-encoded CoMID into the target Comid. The input
+// is first checked against the decoding limits (encoding.DefaultLimits unless
+// encoding.WithLimits is used). Use encoding.WithStrict to reject anything
+// that does not conform exactly to the schema, and WithRegistry to decode with
+// a Registry other than DefaultRegistry.
+func (o *Comid) FromCBOR(data []byte, opts ...encoding.DecodeOption) error {
+	if err := encoding.CheckLimitsCBOR(data, opts...); err != nil {
+		return err
+	}
+
+	decOpts := encoding.NewDecodeOptions(opts...)
+
+	return encoding.PopulateStructFromCBOR(RegistryFrom(decOpts.Registry).decMode(decOpts), data, o, opts...)
 }
 
 // ToJSON serializes the target Comid to JSON
@@ -309,9 +345,12 @@ func (o Comid) ToJSON() ([]byte, error) {
 	return encoding.SerializeStructToJSON(&o)
 }
 
-// FromJSON deserializes a JSON-encoded CoMID into the target Comid
-func (o *Comid) FromJSON(data []byte) error {
-	return encoding.PopulateStructFromJSON(data, o)
+// FromJSON deserializes a JSON-encoded CoMID into the target Comid. Use
+// encoding.WithStrict to reject anything that does not conform exactly to the
+// schema, and WithRegistry to decode with a Registry other than
+// DefaultRegistry.
+func (o *Comid) FromJSON(data []byte, opts ...encoding.DecodeOption) error {
+	return encoding.PopulateStructFromJSON(data, o, opts...)
 }
 
 func (o Comid) ToJSONPretty(indent string) ([]byte, error) {
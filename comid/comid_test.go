@@ -3,11 +3,15 @@
 package comid
 
 import (
+	"math"
 	"testing"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/jraman567/corim/encoding"
 	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 	"github.com/veraison/swid"
 )
 
@@ -92,3 +96,121 @@ func Test_String2URI_nok(t *testing.T) {
 	_, err := String2URI(&s)
 	assert.EqualError(t, err, `expecting an absolute URI: "@@@" is not an absolute URI`)
 }
+
+func Test_Comid_BumpTagVersion(t *testing.T) {
+	c := NewComid().SetTagIdentity("test", 1)
+
+	// re-encoding fails on an invalid CoMID, which is left untouched
+	_, err := c.BumpTagVersion()
+	assert.EqualError(t, err, "triples validation failed: triples struct must not be empty")
+	assert.Equal(t, uint(1), c.TagIdentity.TagVersion)
+
+	c.Triples = Triples{
+		ReferenceValues: NewValueTriples().Add(&ValueTriple{
+			Environment: Environment{
+				Instance: MustNewUUIDInstance(TestUUID),
+			},
+			Measurement: Measurement{
+				Val: Mval{
+					RawValue: NewRawValue().SetBytes(MustHexDecode(t, "deadbeef")),
+				},
+			},
+		}),
+	}
+
+	data, err := c.BumpTagVersion()
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), c.TagIdentity.TagVersion)
+
+	var decoded Comid
+	require.NoError(t, decoded.FromCBOR(data))
+	assert.Equal(t, uint(2), decoded.TagIdentity.TagVersion)
+
+	c.TagIdentity.TagVersion = math.MaxUint
+	_, err = c.BumpTagVersion()
+	assert.EqualError(t, err, "tag-version overflow")
+}
+
+func Test_Comid_ToCBOR_deterministic(t *testing.T) {
+	var c Comid
+	require.NoError(t, c.FromJSON([]byte(PSARefValJSONTemplate)))
+
+	expected, err := c.ToCBOR(encoding.WithDeterministic())
+	require.NoError(t, err)
+	assert.True(t, encoding.IsCanonical(expected))
+
+	// re-encode the top-level map with its entries in reverse order, as a
+	// different tool might
+	data, err := c.ToCBOR()
+	require.NoError(t, err)
+
+	var m map[int]cbor.RawMessage
+	require.NoError(t, dm.Unmarshal(data, &m))
+
+	reversed := []byte{0xa0 | byte(len(m))}
+	for k := len(m) + 10; k >= 0; k-- {
+		if v, ok := m[k]; ok {
+			reversed = append(reversed, byte(k))
+			reversed = append(reversed, v...)
+		}
+	}
+	require.False(t, encoding.IsCanonical(reversed))
+
+	var other Comid
+	require.NoError(t, other.FromCBOR(reversed))
+
+	actual, err := other.ToCBOR(encoding.WithDeterministic())
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func Test_Comid_FromCBOR_limits(t *testing.T) {
+	var c Comid
+	require.NoError(t, c.FromJSON([]byte(PSARefValJSONTemplate)))
+
+	data, err := c.ToCBOR()
+	require.NoError(t, err)
+
+	assert.NoError(t, c.FromCBOR(data))
+
+	err = c.FromCBOR(data, encoding.WithLimits(encoding.Limits{MaxNestingLevels: 4}))
+	assert.EqualError(t, err, "max-nesting-levels exceeded: 4")
+	assert.ErrorIs(t, err, encoding.ErrLimitExceeded)
+}
+
+func Test_Comid_Valid_path(t *testing.T) {
+	vendor := "ACME"
+	env := Environment{Class: &Class{Vendor: &vendor}}
+
+	c := NewComid().
+		SetTagIdentity("test", 0).
+		AddReferenceValue(ValueTriple{
+			Environment: env,
+			Measurement: Measurement{Val: Mval{Ver: &Version{Version: "1.0"}}},
+		}).
+		AddReferenceValue(ValueTriple{
+			Environment: env,
+			Measurement: Measurement{Val: Mval{
+				Digests: &Digests{{HashAlgID: swid.Sha256, HashValue: []byte{0x01}}},
+			}},
+		})
+	require.NotNil(t, c)
+
+	err := c.Valid()
+	assert.EqualError(t, err, "triples validation failed: reference values: error at index 1: "+
+		"measurement validation failed: digest at index 0: "+
+		"length mismatch for hash algorithm sha-256: want 32 bytes, got 1")
+
+	var vErr *validation.Error
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "triples.reference-values[1].measurement.value.digests[0].hash-value", vErr.Path)
+	assert.Equal(t, validation.CodeInvalid, vErr.Code)
+	assert.Equal(t, "length mismatch for hash algorithm sha-256: want 32 bytes, got 1", vErr.Message)
+
+	c.Triples = Triples{}
+	err = c.Valid()
+
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "triples", vErr.Path)
+	assert.Equal(t, validation.CodeEmpty, vErr.Code)
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/fxamacker/cbor/v2"
 	"github.com/jraman567/corim/encoding"
 	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 	"github.com/veraison/go-cose"
 	"github.com/veraison/swid"
 )
@@ -60,7 +61,13 @@ type CryptoKey struct {
 // specified crypto key type. For PKIX types, k must be a string. For COSE_Key,
 // k must be a []byte. For thumbprint types, k must be a swid.HashEntry.
 func NewCryptoKey(k any, typ string) (*CryptoKey, error) {
-	factory, ok := cryptoKeyValueRegister[typ]
+	return DefaultRegistry.NewCryptoKey(k, typ)
+}
+
+// NewCryptoKey is like the package-level NewCryptoKey, but uses the type choices
+// registered with the target Registry.
+func (o *Registry) NewCryptoKey(k any, typ string) (*CryptoKey, error) {
+	factory, ok := lookupTypeChoice(o, o.cryptoKeys, typ)
 	if !ok {
 		return nil, fmt.Errorf("unexpected CryptoKey type: %s", typ)
 	}
@@ -87,7 +94,7 @@ func (o CryptoKey) String() string {
 // Valid returns an error if validation of the CryptoKey fails, or nil if it
 // succeeds.
 func (o CryptoKey) Valid() error {
-	return o.Value.Valid()
+	return validation.Wrap(o.Value.Valid())
 }
 
 // Type returns the type of the CryptoKey value
@@ -121,6 +128,11 @@ func (o CryptoKey) MarshalJSON() ([]byte, error) {
 // UnmarshalJSON populates the CryptoKey from the JSON representation inside
 // the provided []byte.
 func (o *CryptoKey) UnmarshalJSON(b []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), b)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *CryptoKey) DecodeJSON(d *encoding.Decoder, b []byte) error {
 	var value encoding.TypeAndValue
 
 	if err := json.Unmarshal(b, &value); err != nil {
@@ -131,7 +143,9 @@ func (o *CryptoKey) UnmarshalJSON(b []byte) error {
 		return errors.New("key type not set")
 	}
 
-	factory, ok := cryptoKeyValueRegister[value.Type]
+	r := RegistryFrom(d.Registry)
+
+	factory, ok := lookupTypeChoice(r, r.cryptoKeys, value.Type)
 	if !ok {
 		return fmt.Errorf("unexpected ICryptoKeyValue type: %q", value.Type)
 	}
@@ -154,13 +168,23 @@ func (o *CryptoKey) UnmarshalJSON(b []byte) error {
 // MarshalCBOR returns a []byte containing the CBOR representation of the
 // CryptoKey.
 func (o CryptoKey) MarshalCBOR() ([]byte, error) {
-	return em.Marshal(o.Value)
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o CryptoKey) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return encModeFor(e).Marshal(o.Value)
 }
 
 // UnmarshalCBOR populates the CryptoKey from the CBOR representation inside
 // the provided []byte.
 func (o *CryptoKey) UnmarshalCBOR(b []byte) error {
-	return dm.Unmarshal(b, &o.Value)
+	return o.DecodeCBOR(encoding.NewDecoder(), b)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *CryptoKey) DecodeCBOR(d *encoding.Decoder, b []byte) error {
+	return decModeFor(d).Unmarshal(b, &o.Value)
 }
 
 // ICryptoKeyValue is the interface implemented by the concrete CryptoKey value
@@ -205,7 +229,7 @@ func (o TaggedPKIXBase64Key) String() string {
 
 func (o TaggedPKIXBase64Key) Valid() error {
 	_, err := o.PublicKey()
-	return err
+	return validation.Wrap(err)
 }
 
 func (o TaggedPKIXBase64Key) Type() string {
@@ -272,7 +296,7 @@ func (o TaggedPKIXBase64Cert) String() string {
 
 func (o TaggedPKIXBase64Cert) Valid() error {
 	_, err := o.cert()
-	return err
+	return validation.Wrap(err)
 }
 
 func (o TaggedPKIXBase64Cert) Type() string {
@@ -357,7 +381,7 @@ func (o TaggedPKIXBase64CertPath) String() string {
 
 func (o TaggedPKIXBase64CertPath) Valid() error {
 	_, err := o.certPath()
-	return err
+	return validation.Wrap(err)
 }
 
 func (o TaggedPKIXBase64CertPath) Type() string {
@@ -469,7 +493,7 @@ func (o TaggedCOSEKey) String() string {
 
 func (o TaggedCOSEKey) Valid() error {
 	if len(o) == 0 {
-		return errors.New("empty COSE_Key bytes")
+		return validation.New(validation.CodeEmpty, "empty COSE_Key bytes")
 	}
 
 	var err error
@@ -481,7 +505,7 @@ func (o TaggedCOSEKey) Valid() error {
 	} else {
 		_, err = o.coseKey()
 	}
-	return err
+	return validation.Wrap(err)
 }
 
 func (o TaggedCOSEKey) Type() string {
@@ -573,7 +597,7 @@ func (o digest) String() string {
 }
 
 func (o digest) Valid() error {
-	return swid.ValidHashEntry(o.HashAlgID, o.HashValue)
+	return validation.Wrap(swid.ValidHashEntry(o.HashAlgID, o.HashValue))
 }
 
 func (o digest) PublicKey() (crypto.PublicKey, error) {
@@ -727,7 +751,8 @@ func (o TaggedCertPathThumbprint) Type() string {
 // See also https://go.dev/ref/spec#The_zero_value
 type ICryptoKeyFactory func(any) (*CryptoKey, error)
 
-var cryptoKeyValueRegister = map[string]ICryptoKeyFactory{
+// builtinCryptoKeyTypes contains the crypto key types every Registry starts with
+var builtinCryptoKeyTypes = map[string]ICryptoKeyFactory{
 	// types defined by the core spec
 	PKIXBase64KeyType:      NewPKIXBase64Key,
 	PKIXBase64CertType:     NewPKIXBase64Cert,
@@ -742,22 +767,16 @@ var cryptoKeyValueRegister = map[string]ICryptoKeyFactory{
 // (created by the provided ICryptoKeyFactory) under the specified type name
 // and CBOR tag.
 func RegisterCryptoKeyType(tag uint64, factory ICryptoKeyFactory) error {
+	return DefaultRegistry.RegisterCryptoKeyType(tag, factory)
+}
 
+// RegisterCryptoKeyType is like the package-level RegisterCryptoKeyType, but
+// registers with the target Registry.
+func (o *Registry) RegisterCryptoKeyType(tag uint64, factory ICryptoKeyFactory) error {
 	nilVal, err := factory(nil)
 	if err != nil {
 		return err
 	}
 
-	typ := nilVal.Type()
-	if _, exists := cryptoKeyValueRegister[typ]; exists {
-		return fmt.Errorf("crypto key type with name %q already exists", typ)
-	}
-
-	if err := registerCOMIDTag(tag, nilVal.Value); err != nil {
-		return err
-	}
-
-	cryptoKeyValueRegister[typ] = factory
-
-	return nil
+	return registerTypeChoice(o, o.cryptoKeys, "crypto key", nilVal.Type(), tag, nilVal.Value, factory)
 }
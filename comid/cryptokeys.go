@@ -3,7 +3,7 @@
 
 package comid
 
-import "fmt"
+import "github.com/jraman567/corim/validation"
 
 // CryptoKeys is an array of *CryptoKey
 type CryptoKeys []*CryptoKey
@@ -25,12 +25,12 @@ func (o *CryptoKeys) Add(v *CryptoKey) *CryptoKeys {
 // CryptoKeys is empty
 func (o CryptoKeys) Valid() error {
 	if len(o) == 0 {
-		return fmt.Errorf("no keys to validate")
+		return validation.New(validation.CodeEmpty, "no keys to validate")
 	}
 
 	for i, vk := range o {
 		if err := vk.Valid(); err != nil {
-			return fmt.Errorf("invalid key at index %d: %w", i, err)
+			return validation.Errorf(validation.Index(i), "invalid key at index %d: %w", i, err)
 		}
 	}
 	return nil
@@ -0,0 +1,584 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package comid
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ChangeKind is the type of a difference between two values
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "changed"
+)
+
+// Symbol returns the prefix used for the change in the textual diff format
+func (o ChangeKind) Symbol() string {
+	switch o {
+	case ChangeAdded:
+		return "+"
+	case ChangeRemoved:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+// FieldChange is a change to a single field. Old and New are printable
+// representations of the values, and are empty if the field is absent.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// String returns a printable representation of the FieldChange
+func (o FieldChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", o.Field, orNone(o.Old), orNone(o.New))
+}
+
+// EntityChange is an entity that was added, removed or changed. Entities are
+// matched by name.
+type EntityChange struct {
+	Kind    ChangeKind    `json:"kind"`
+	Name    string        `json:"name"`
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// LinkedTagChange is a linked tag that was added or removed
+type LinkedTagChange struct {
+	Kind   ChangeKind `json:"kind"`
+	Target string     `json:"target"`
+	Rel    string     `json:"rel"`
+}
+
+// TripleChange is a triple that was added, removed or changed. Value triples
+// are matched by triple type, environment and measurement key; key triples by
+// triple type and environment. Environment and Mkey are the JSON encoding of
+// the matched environment and measurement key.
+type TripleChange struct {
+	Kind        ChangeKind    `json:"kind"`
+	Triple      string        `json:"triple"`
+	Environment string        `json:"environment"`
+	Mkey        string        `json:"mkey,omitempty"`
+	Changes     []FieldChange `json:"changes,omitempty"`
+}
+
+// ComidDiff is the semantic difference between two CoMIDs
+type ComidDiff struct {
+	// Fields lists changes to the top-level fields (lang, tag-identity)
+	Fields     []FieldChange     `json:"fields,omitempty"`
+	Entities   []EntityChange    `json:"entities,omitempty"`
+	LinkedTags []LinkedTagChange `json:"linked-tags,omitempty"`
+	Triples    []TripleChange    `json:"triples,omitempty"`
+}
+
+// IsEmpty returns true if the compared CoMIDs are semantically equivalent
+func (o ComidDiff) IsEmpty() bool {
+	return len(o.Fields) == 0 && len(o.Entities) == 0 &&
+		len(o.LinkedTags) == 0 && len(o.Triples) == 0
+}
+
+// ToJSON serializes the target ComidDiff to JSON
+func (o ComidDiff) ToJSON() ([]byte, error) {
+	return json.Marshal(&o)
+}
+
+// String returns the textual diff format: one line per change, prefixed with
+// "+" (added), "-" (removed) or "~" (changed), with changed fields listed on
+// indented lines below
+func (o ComidDiff) String() string {
+	var b strings.Builder
+	o.write(&b, "")
+	return b.String()
+}
+
+// WriteIndented writes the textual diff format, with each line prefixed by
+// indent. It is used to nest CoMID diffs in other diff formats.
+func (o ComidDiff) WriteIndented(b *strings.Builder, indent string) {
+	o.write(b, indent)
+}
+
+func (o ComidDiff) write(b *strings.Builder, indent string) {
+	for _, f := range o.Fields {
+		fmt.Fprintf(b, "%s~ %s\n", indent, f)
+	}
+
+	for _, e := range o.Entities {
+		fmt.Fprintf(b, "%s%s entity %q\n", indent, e.Kind.Symbol(), e.Name)
+		writeFieldChanges(b, indent+"    ", e.Changes)
+	}
+
+	for _, l := range o.LinkedTags {
+		fmt.Fprintf(b, "%s%s linked-tag %s %s\n", indent, l.Kind.Symbol(), l.Rel, l.Target)
+	}
+
+	for _, t := range o.Triples {
+		fmt.Fprintf(b, "%s%s %s environment=%s", indent, t.Kind.Symbol(), t.Triple, t.Environment)
+		if t.Mkey != "" {
+			fmt.Fprintf(b, " mkey=%s", t.Mkey)
+		}
+		b.WriteString("\n")
+		writeFieldChanges(b, indent+"    ", t.Changes)
+	}
+}
+
+func writeFieldChanges(b *strings.Builder, indent string, changes []FieldChange) {
+	for _, c := range changes {
+		fmt.Fprintf(b, "%s%s\n", indent, c)
+	}
+}
+
+// Diff compares two CoMIDs semantically, i.e., independently of the order of
+// entities, linked tags and triples, and of their encoding. Measurement values
+// are compared field by field, with digests compared by algorithm.
+func Diff(a, b *Comid) (*ComidDiff, error) {
+	if a == nil || b == nil {
+		return nil, errors.New("nil comid")
+	}
+
+	var ret ComidDiff
+
+	if la, lb := stringOrEmpty(a.Language), stringOrEmpty(b.Language); la != lb {
+		ret.Fields = append(ret.Fields, FieldChange{Field: "lang", Old: la, New: lb})
+	}
+
+	if ta, tb := a.TagIdentity.TagID.String(), b.TagIdentity.TagID.String(); ta != tb {
+		ret.Fields = append(ret.Fields, FieldChange{Field: "tag-id", Old: ta, New: tb})
+	}
+
+	if va, vb := a.TagIdentity.TagVersion, b.TagIdentity.TagVersion; va != vb {
+		ret.Fields = append(ret.Fields, FieldChange{
+			Field: "tag-version",
+			Old:   fmt.Sprint(va),
+			New:   fmt.Sprint(vb),
+		})
+	}
+
+	var err error
+
+	if ret.Entities, err = DiffEntities(a.Entities, b.Entities); err != nil {
+		return nil, err
+	}
+
+	ret.LinkedTags = diffLinkedTags(a.LinkedTags, b.LinkedTags)
+
+	if ret.Triples, err = diffTriples(&a.Triples, &b.Triples); err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// DiffEntities compares two sets of entities, matching them by name
+func DiffEntities(a, b *Entities) ([]EntityChange, error) {
+	ea, order, err := entitiesByName(a, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	eb, order, err := entitiesByName(b, order)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []EntityChange
+
+	for _, name := range order {
+		fa, inA := ea[name]
+		fb, inB := eb[name]
+
+		switch {
+		case !inA:
+			ret = append(ret, EntityChange{Kind: ChangeAdded, Name: name})
+		case !inB:
+			ret = append(ret, EntityChange{Kind: ChangeRemoved, Name: name})
+		default:
+			if changes := DiffFields(fa, fb); len(changes) > 0 {
+				ret = append(ret, EntityChange{Kind: ChangeModified, Name: name, Changes: changes})
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// entitiesByName returns the printable fields of each entity, keyed by name,
+// and appends the names not already in order to it
+func entitiesByName(ents *Entities, order []string) (map[string]map[string]string, []string, error) {
+	ret := make(map[string]map[string]string)
+
+	if ents == nil {
+		return ret, order, nil
+	}
+
+	for i, e := range ents.Values {
+		name := ""
+		if e.Name != nil {
+			name = e.Name.String()
+		}
+
+		fields := map[string]string{
+			"regid": "",
+			"roles": "",
+		}
+
+		if e.RegID != nil {
+			fields["regid"] = string(*e.RegID)
+		}
+
+		roles := make([]string, 0, len(e.Roles))
+		for _, r := range e.Roles {
+			roles = append(roles, r.String())
+		}
+		sort.Strings(roles)
+		fields["roles"] = strings.Join(roles, ",")
+
+		if e.Extensions.IMapValue != nil {
+			ext, err := json.Marshal(e.Extensions.IMapValue)
+			if err != nil {
+				return nil, nil, fmt.Errorf("encoding extensions of entity %d: %w", i, err)
+			}
+			fields["extensions"] = string(ext)
+		}
+
+		if _, ok := ret[name]; !ok && !containsString(order, name) {
+			order = append(order, name)
+		}
+
+		ret[name] = fields
+	}
+
+	return ret, order, nil
+}
+
+func diffLinkedTags(a, b *LinkedTags) []LinkedTagChange {
+	key := func(l LinkedTag) LinkedTagChange {
+		return LinkedTagChange{Target: l.LinkedTagID.String(), Rel: l.Rel.String()}
+	}
+
+	var la, lb []LinkedTagChange
+
+	if a != nil {
+		for _, l := range *a {
+			la = append(la, key(l))
+		}
+	}
+
+	if b != nil {
+		for _, l := range *b {
+			lb = append(lb, key(l))
+		}
+	}
+
+	var ret []LinkedTagChange
+
+	for _, l := range la {
+		if !containsLinkedTag(lb, l) {
+			l.Kind = ChangeRemoved
+			ret = append(ret, l)
+		}
+	}
+
+	for _, l := range lb {
+		if !containsLinkedTag(la, l) {
+			l.Kind = ChangeAdded
+			ret = append(ret, l)
+		}
+	}
+
+	return ret
+}
+
+func containsLinkedTag(tags []LinkedTagChange, l LinkedTagChange) bool {
+	for _, t := range tags {
+		if t.Target == l.Target && t.Rel == l.Rel {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tripleEntry is a triple reduced to its matching key and printable fields
+type tripleEntry struct {
+	triple      string
+	environment string
+	mkey        string
+	fields      map[string]string
+}
+
+func (o tripleEntry) key() string {
+	return o.triple + "\x00" + o.environment + "\x00" + o.mkey
+}
+
+func diffTriples(a, b *Triples) ([]TripleChange, error) {
+	ta, err := flattenTriples(a)
+	if err != nil {
+		return nil, err
+	}
+
+	tb, err := flattenTriples(b)
+	if err != nil {
+		return nil, err
+	}
+
+	// triples sharing the same key are matched in order of occurrence
+	byKey := make(map[string][]tripleEntry)
+	for _, t := range tb {
+		byKey[t.key()] = append(byKey[t.key()], t)
+	}
+
+	var ret []TripleChange
+
+	for _, t := range ta {
+		k := t.key()
+		candidates := byKey[k]
+
+		if len(candidates) == 0 {
+			ret = append(ret, t.change(ChangeRemoved, nil))
+			continue
+		}
+
+		match := candidates[0]
+		byKey[k] = candidates[1:]
+
+		if changes := DiffFields(t.fields, match.fields); len(changes) > 0 {
+			ret = append(ret, t.change(ChangeModified, changes))
+		}
+	}
+
+	for _, t := range tb {
+		k := t.key()
+		if len(byKey[k]) > 0 {
+			ret = append(ret, byKey[k][0].change(ChangeAdded, nil))
+			byKey[k] = byKey[k][1:]
+		}
+	}
+
+	return ret, nil
+}
+
+func (o tripleEntry) change(kind ChangeKind, changes []FieldChange) TripleChange {
+	return TripleChange{
+		Kind:        kind,
+		Triple:      o.triple,
+		Environment: o.environment,
+		Mkey:        o.mkey,
+		Changes:     changes,
+	}
+}
+
+func flattenTriples(t *Triples) ([]tripleEntry, error) {
+	var ret []tripleEntry
+
+	valueTriples := []struct {
+		name    string
+		triples *ValueTriples
+	}{
+		{"reference-value", t.ReferenceValues},
+		{"endorsed-value", t.EndorsedValues},
+	}
+
+	for _, vt := range valueTriples {
+		if vt.triples == nil {
+			continue
+		}
+
+		for i, v := range vt.triples.Values {
+			e, err := valueTripleEntry(vt.name, v)
+			if err != nil {
+				return nil, fmt.Errorf("%s triple %d: %w", vt.name, i, err)
+			}
+			ret = append(ret, *e)
+		}
+	}
+
+	keyTriples := []struct {
+		name    string
+		triples *KeyTriples
+	}{
+		{"dev-identity-key", t.DevIdentityKeys},
+		{"attester-verification-key", t.AttestVerifKeys},
+	}
+
+	for _, kt := range keyTriples {
+		if kt.triples == nil {
+			continue
+		}
+
+		for i, k := range kt.triples.Values {
+			e, err := keyTripleEntry(kt.name, k)
+			if err != nil {
+				return nil, fmt.Errorf("%s triple %d: %w", kt.name, i, err)
+			}
+			ret = append(ret, *e)
+		}
+	}
+
+	return ret, nil
+}
+
+func valueTripleEntry(name string, v ValueTriple) (*tripleEntry, error) {
+	env, err := json.Marshal(&v.Environment)
+	if err != nil {
+		return nil, fmt.Errorf("encoding environment: %w", err)
+	}
+
+	ret := tripleEntry{
+		triple:      name,
+		environment: string(env),
+		fields:      make(map[string]string),
+	}
+
+	m := v.Measurement
+
+	if m.Key != nil && m.Key.IsSet() {
+		mkey, err := json.Marshal(m.Key)
+		if err != nil {
+			return nil, fmt.Errorf("encoding mkey: %w", err)
+		}
+		ret.mkey = string(mkey)
+	}
+
+	if m.AuthorizedBy != nil {
+		ret.fields["authorized-by"] = m.AuthorizedBy.String()
+	}
+
+	if err := mvalFields(&m.Val, ret.fields); err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+func keyTripleEntry(name string, k KeyTriple) (*tripleEntry, error) {
+	env, err := json.Marshal(&k.Environment)
+	if err != nil {
+		return nil, fmt.Errorf("encoding environment: %w", err)
+	}
+
+	keys := make([]string, 0, len(k.VerifKeys))
+	for _, ck := range k.VerifKeys {
+		keys = append(keys, ck.String())
+	}
+	sort.Strings(keys)
+
+	return &tripleEntry{
+		triple:      name,
+		environment: string(env),
+		fields:      map[string]string{"verification-keys": strings.Join(keys, ",")},
+	}, nil
+}
+
+// mvalFields adds the printable fields of the supplied Mval to fields. The
+// SVN and each digest (keyed by algorithm) are reported individually, the
+// other fields using their JSON encoding.
+func mvalFields(v *Mval, fields map[string]string) error {
+	if v.SVN != nil && v.SVN.Value != nil {
+		fields["svn"] = fmt.Sprintf("%s %s", v.SVN.Value.Type(), v.SVN.Value.String())
+	}
+
+	if v.Digests != nil {
+		for _, d := range *v.Digests {
+			d := d
+			fields["digests/"+d.AlgIDToString()] = hex.EncodeToString(d.HashValue)
+		}
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+
+		if f.Anonymous || f.Name == "SVN" || f.Name == "Digests" {
+			continue
+		}
+
+		fv := rv.Field(i)
+		if fv.IsNil() {
+			continue
+		}
+
+		data, err := json.Marshal(fv.Interface())
+		if err != nil {
+			return fmt.Errorf("encoding %s: %w", f.Name, err)
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		fields[name] = string(data)
+	}
+
+	if v.Extensions.IMapValue != nil {
+		data, err := json.Marshal(v.Extensions.IMapValue)
+		if err != nil {
+			return fmt.Errorf("encoding extensions: %w", err)
+		}
+		fields["extensions"] = string(data)
+	}
+
+	return nil
+}
+
+// DiffFields compares two sets of printable fields, keyed by field name, and
+// returns the changes sorted by field name. A field missing from one of the
+// sets is treated as empty.
+func DiffFields(a, b map[string]string) []FieldChange {
+	names := make([]string, 0, len(a)+len(b))
+
+	for k := range a {
+		names = append(names, k)
+	}
+
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			names = append(names, k)
+		}
+	}
+
+	sort.Strings(names)
+
+	var ret []FieldChange
+
+	for _, n := range names {
+		if a[n] != b[n] {
+			ret = append(ret, FieldChange{Field: n, Old: a[n], New: b[n]})
+		}
+	}
+
+	return ret
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package comid
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func psaRefValComid(t *testing.T) *Comid {
+	var c Comid
+	require.NoError(t, c.FromJSON([]byte(PSARefValJSONTemplate)))
+	return &c
+}
+
+func TestDiff_identical(t *testing.T) {
+	d, err := Diff(psaRefValComid(t), psaRefValComid(t))
+	require.NoError(t, err)
+	assert.True(t, d.IsEmpty())
+	assert.Equal(t, "", d.String())
+}
+
+func TestDiff_triples(t *testing.T) {
+	a := psaRefValComid(t)
+	b := psaRefValComid(t)
+
+	refVals := b.Triples.ReferenceValues.Values
+	require.Len(t, refVals, 3)
+
+	// change the digest of the first triple and add an SVN to the second
+	(*refVals[0].Measurement.Val.Digests)[0].HashValue = MustHexDecode(t, "deadbeef")
+	refVals[1].Measurement.Val.SVN = MustNewTaggedSVN(2)
+
+	// swap the first two triples (not a change) and remove the third one
+	b.Triples.ReferenceValues.Values = []ValueTriple{refVals[1], refVals[0]}
+
+	d, err := Diff(a, b)
+	require.NoError(t, err)
+	require.Len(t, d.Triples, 3)
+
+	assert.Equal(t, ChangeModified, d.Triples[0].Kind)
+	assert.Equal(t, "reference-value", d.Triples[0].Triple)
+	assert.Contains(t, d.Triples[0].Mkey, `"label":"BL"`)
+	assert.Equal(t, []FieldChange{{
+		Field: "digests/sha-256",
+		Old:   "87428fc522803d31065e7bce3cf03fe475096631e5e07bbd7a0fde60c4cf25c7",
+		New:   "deadbeef",
+	}}, d.Triples[0].Changes)
+
+	assert.Equal(t, ChangeModified, d.Triples[1].Kind)
+	assert.Equal(t, []FieldChange{{Field: "svn", New: "exact-value 2"}}, d.Triples[1].Changes)
+
+	assert.Equal(t, ChangeRemoved, d.Triples[2].Kind)
+	assert.Empty(t, d.Triples[2].Changes)
+
+	// the reverse diff swaps old and new
+	d, err = Diff(b, a)
+	require.NoError(t, err)
+	require.Len(t, d.Triples, 3)
+	assert.Equal(t, "svn: exact-value 2 -> <none>", d.Triples[0].Changes[0].String())
+	assert.Equal(t, ChangeAdded, d.Triples[2].Kind)
+}
+
+func TestDiff_key_triples(t *testing.T) {
+	a := psaRefValComid(t)
+	b := psaRefValComid(t)
+
+	env := Environment{Instance: MustNewUUIDInstance(TestUUID)}
+	a.AddAttestVerifKey(KeyTriple{
+		Environment: env,
+		VerifKeys:   *NewCryptoKeys().Add(MustNewPKIXBase64Key(TestECPubKey)),
+	})
+	b.AddAttestVerifKey(KeyTriple{
+		Environment: env,
+		VerifKeys:   *NewCryptoKeys().Add(MustNewPKIXBase64Cert(TestCert)),
+	})
+
+	d, err := Diff(a, b)
+	require.NoError(t, err)
+	require.Len(t, d.Triples, 1)
+
+	assert.Equal(t, ChangeModified, d.Triples[0].Kind)
+	assert.Equal(t, "attester-verification-key", d.Triples[0].Triple)
+	assert.Empty(t, d.Triples[0].Mkey)
+	require.Len(t, d.Triples[0].Changes, 1)
+	assert.Equal(t, "verification-keys", d.Triples[0].Changes[0].Field)
+}
+
+func TestDiff_metadata(t *testing.T) {
+	a := psaRefValComid(t)
+	b := psaRefValComid(t)
+
+	b.SetLanguage("en-US")
+	b.TagIdentity.TagVersion = 1
+
+	regID := TaggedURI("https://acme.example/new")
+	b.Entities.Values[0].RegID = &regID
+	b.AddEntity("EMCA Corp.", nil, RoleCreator)
+
+	b.AddLinkedTag("previous-tag", RelReplaces)
+
+	d, err := Diff(a, b)
+	require.NoError(t, err)
+
+	assert.Equal(t, []FieldChange{
+		{Field: "lang", Old: "en-GB", New: "en-US"},
+		{Field: "tag-version", Old: "0", New: "1"},
+	}, d.Fields)
+
+	assert.Equal(t, []EntityChange{
+		{
+			Kind: ChangeModified,
+			Name: "ACME Ltd.",
+			Changes: []FieldChange{{
+				Field: "regid",
+				Old:   "https://acme.example",
+				New:   "https://acme.example/new",
+			}},
+		},
+		{Kind: ChangeAdded, Name: "EMCA Corp."},
+	}, d.Entities)
+
+	assert.Equal(t, []LinkedTagChange{
+		{Kind: ChangeAdded, Target: "previous-tag", Rel: "replaces"},
+	}, d.LinkedTags)
+
+	expected := `~ lang: en-GB -> en-US
+~ tag-version: 0 -> 1
+~ entity "ACME Ltd."
+    regid: https://acme.example -> https://acme.example/new
++ entity "EMCA Corp."
++ linked-tag replaces previous-tag
+`
+	assert.Equal(t, expected, d.String())
+
+	data, err := d.ToJSON()
+	require.NoError(t, err)
+
+	var back ComidDiff
+	require.NoError(t, json.Unmarshal(data, &back))
+	assert.Equal(t, *d, back)
+}
+
+func TestDiff_nil(t *testing.T) {
+	_, err := Diff(nil, psaRefValComid(t))
+	assert.EqualError(t, err, "nil comid")
+}
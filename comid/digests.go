@@ -4,8 +4,10 @@
 package comid
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/jraman567/corim/validation"
 	"github.com/veraison/swid"
 )
 
@@ -32,20 +34,68 @@ func (o *Digests) AddDigest(algID uint64, value []byte) *Digests {
 	return o
 }
 
+// Valid checks that each digest uses a registered hash algorithm (see
+// RegisterHashAlgorithm) and has the length expected for it
 func (o Digests) Valid() error {
 	for i, m := range o {
-		if err := swid.ValidHashEntry(m.HashAlgID, m.HashValue); err != nil {
-			return fmt.Errorf("digest at index %d: %w", i, err)
+		if err := ValidHashEntry(m); err != nil {
+			return validation.Errorf(validation.Index(i), "digest at index %d: %w", i, err)
 		}
 	}
 	return nil
 }
 
+// Compute appends to the target Digests the digests of the supplied data,
+// computed with each of the supplied hash algorithms
+func (o *Digests) Compute(data []byte, algs ...uint64) error {
+	if len(algs) == 0 {
+		return errors.New("no hash algorithm specified")
+	}
+
+	computed := make(Digests, 0, len(algs))
+
+	for _, id := range algs {
+		alg, ok := LookupHashAlgorithm(id)
+		if !ok {
+			return fmt.Errorf("unknown hash algorithm %d", id)
+		}
+
+		computed = append(computed, swid.HashEntry{HashAlgID: id, HashValue: alg.Sum(data)})
+	}
+
+	*o = append(*o, computed...)
+
+	return nil
+}
+
+// MatchesData returns true if all the digests are digests of the supplied
+// data. An error is returned if there are no digests, or if any of them is
+// invalid.
+func (o Digests) MatchesData(data []byte) (bool, error) {
+	if len(o) == 0 {
+		return false, errors.New("no digests")
+	}
+
+	for i, m := range o {
+		ok, err := MatchHashEntry(m, data)
+		if err != nil {
+			return false, fmt.Errorf("digest at index %d: %w", i, err)
+		}
+
+		if !ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// NewHashEntry returns a HashEntry with the supplied algorithm and value, or
+// nil if the value is not valid for the algorithm (see ValidHashEntry)
 func NewHashEntry(algID uint64, value []byte) *swid.HashEntry {
-	var he swid.HashEntry
+	he := swid.HashEntry{HashAlgID: algID, HashValue: value}
 
-	err := he.Set(algID, value)
-	if err != nil {
+	if err := ValidHashEntry(he); err != nil {
 		return nil
 	}
 
@@ -149,3 +149,43 @@ func TestDigests_UnmarshalCBOR(t *testing.T) {
 	assert.Equal(t, swid.Sha256_64, actual[1].HashAlgID)
 	assert.Equal(t, MustHexDecode(t, "e45b72f5c0c0b572"), actual[1].HashValue)
 }
+
+func TestDigests_Compute(t *testing.T) {
+	data := []byte("abc")
+
+	d := NewDigests()
+	require.NoError(t, d.Compute(data, swid.Sha256, swid.Sha256_32, swid.Sha3_256))
+	require.Len(t, *d, 3)
+	assert.NoError(t, d.Valid())
+
+	assert.Equal(t,
+		MustHexDecode(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"),
+		(*d)[0].HashValue)
+	assert.Equal(t, MustHexDecode(t, "ba7816bf"), (*d)[1].HashValue)
+	assert.Equal(t,
+		MustHexDecode(t, "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532"),
+		(*d)[2].HashValue)
+
+	ok, err := d.MatchesData(data)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = d.MatchesData([]byte("abd"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.EqualError(t, d.Compute(data, 666), "unknown hash algorithm 666")
+	assert.Len(t, *d, 3)
+
+	assert.EqualError(t, d.Compute(data), "no hash algorithm specified")
+}
+
+func TestDigests_MatchesData_NOK(t *testing.T) {
+	_, err := Digests{}.MatchesData(nil)
+	assert.EqualError(t, err, "no digests")
+
+	d := Digests{{HashAlgID: swid.Sha384, HashValue: MustHexDecode(t, "deadbeef")}}
+	_, err = d.MatchesData(nil)
+	assert.EqualError(t, err,
+		"digest at index 0: length mismatch for hash algorithm sha-384: want 48 bytes, got 4")
+}
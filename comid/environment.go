@@ -6,6 +6,10 @@ package comid
 import (
 	"encoding/json"
 	"fmt"
+
+	"github.com/jraman567/corim/encoding"
+	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 )
 
 // Environment stores the identifying information about a target or attesting
@@ -15,36 +19,136 @@ type Environment struct {
 	Class    *Class    `cbor:"0,keyasint,omitempty" json:"class,omitempty"`
 	Instance *Instance `cbor:"1,keyasint,omitempty" json:"instance,omitempty"`
 	Group    *Group    `cbor:"2,keyasint,omitempty" json:"group,omitempty"`
+
+	Extensions
+}
+
+// RegisterExtensions registers a struct as a collections of extensions. The
+// extensions of the Class are registered with a new Class, if the target
+// Environment does not have one.
+func (o *Environment) RegisterExtensions(exts extensions.Map) error {
+	for p, v := range exts {
+		switch p {
+		case ExtEnvironment:
+			o.Extensions.Register(v)
+		case ExtClass:
+			if o.Class == nil {
+				o.Class = new(Class)
+			}
+
+			o.Class.Extensions.Register(v)
+		default:
+			return fmt.Errorf("%w: %q", extensions.ErrUnexpectedPoint, p)
+		}
+	}
+
+	return nil
+}
+
+// GetExtensions returns previously registered extension
+func (o *Environment) GetExtensions() extensions.IMapValue {
+	return o.Extensions.IMapValue
+}
+
+// hasClass returns true if the target Environment has a Class, as opposed to
+// an empty one created to hold the Class extensions (see RegisterExtensions)
+func (o Environment) hasClass() bool {
+	return o.Class != nil && !(o.Class.HaveExtensions() && o.Class.IsEmpty())
 }
 
 // Valid checks the validity (according to the spec) of the target Environment
 func (o Environment) Valid() error {
 	// non-empty<>
-	if o.Class == nil && o.Instance == nil && o.Group == nil {
-		return fmt.Errorf("environment must not be empty")
+	if !o.hasClass() && o.Instance == nil && o.Group == nil {
+		return validation.New(validation.CodeEmpty, "environment must not be empty")
 	}
 
-	if o.Class != nil {
+	if o.hasClass() {
 		if err := o.Class.Valid(); err != nil {
-			return fmt.Errorf("class validation failed: %w", err)
+			return validation.Errorf("class", "class validation failed: %w", err)
 		}
 	}
 
 	if o.Instance != nil {
 		if err := o.Instance.Valid(); err != nil {
-			return fmt.Errorf("instance validation failed: %w", err)
+			return validation.Errorf("instance", "instance validation failed: %w", err)
 		}
 	}
 
 	if o.Group != nil {
 		if err := o.Group.Valid(); err != nil {
-			return fmt.Errorf("group validation failed: %w", err)
+			return validation.Errorf("group", "group validation failed: %w", err)
 		}
 	}
 
+	return o.Extensions.validEnvironment(&o)
+}
+
+// UnmarshalCBOR deserializes from CBOR
+func (o *Environment) UnmarshalCBOR(data []byte) error {
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *Environment) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	if err := d.PopulateStructFromCBOR(decModeFor(d), data, o); err != nil {
+		return err
+	}
+
+	o.dropEmptyClass()
+
 	return nil
 }
 
+// MarshalCBOR serializes to CBOR
+func (o Environment) MarshalCBOR() ([]byte, error) {
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o Environment) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	o.dropEmptyClass()
+
+	return e.SerializeStructToCBOR(encModeFor(e), o)
+}
+
+// UnmarshalJSON deserializes from JSON
+func (o *Environment) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Environment) DecodeJSON(d *encoding.Decoder, data []byte) error {
+	if err := d.PopulateStructFromJSON(data, o); err != nil {
+		return jsonTypeError(err, o)
+	}
+
+	o.dropEmptyClass()
+
+	return nil
+}
+
+// MarshalJSON serializes to JSON
+func (o Environment) MarshalJSON() ([]byte, error) {
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o Environment) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
+	o.dropEmptyClass()
+
+	return e.SerializeStructToJSON(o)
+}
+
+// dropEmptyClass unsets the Class if it was only created to hold the Class
+// extensions, so that an absent class is not encoded as an empty map, nor
+// reported as present once decoded
+func (o *Environment) dropEmptyClass() {
+	if o.Class != nil && !o.hasClass() {
+		o.Class = nil
+	}
+}
+
 // ToCBOR serializes the target Environment to CBOR (if the Environment is "valid")
 func (o Environment) ToCBOR() ([]byte, error) {
 	if err := o.Valid(); err != nil {
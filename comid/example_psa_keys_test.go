@@ -34,7 +34,7 @@ func extractKeys(c *Comid) error {
 		return fmt.Errorf("no reference values triples")
 	}
 
-	for i, k := range *c.Triples.AttestVerifKeys {
+	for i, k := range c.Triples.AttestVerifKeys.Values {
 		if err := extractPSAKey(k); err != nil {
 			return fmt.Errorf("bad PSA verification key value at index %d: %w", i, err)
 		}
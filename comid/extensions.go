@@ -4,6 +4,7 @@ package comid
 
 import (
 	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 )
 
 const (
@@ -14,8 +15,13 @@ const (
 	ExtReferenceValueFlags extensions.Point = "ReferenceValueFlags"
 	ExtEndorsedValue       extensions.Point = "EndorsedValue"
 	ExtEndorsedValueFlags  extensions.Point = "EndorsedValueFlags"
+	ExtDevIdentityKey      extensions.Point = "DevIdentityKey"
+	ExtAttestVerifKey      extensions.Point = "AttestVerifKey"
+	ExtEnvironment         extensions.Point = "Environment"
+	ExtClass               extensions.Point = "Class"
 	ExtMval                extensions.Point = "Mval"
 	ExtFlags               extensions.Point = "Flags"
+	ExtKeyTriple           extensions.Point = "KeyTriple"
 )
 
 type IComidConstrainer interface {
@@ -38,6 +44,18 @@ type IFlagsMapConstrainer interface {
 	ConstrainFlagsMap(*FlagsMap) error
 }
 
+type IKeyTripleConstrainer interface {
+	ConstrainKeyTriple(*KeyTriple) error
+}
+
+type IEnvironmentConstrainer interface {
+	ConstrainEnvironment(*Environment) error
+}
+
+type IClassConstrainer interface {
+	ConstrainClass(*Class) error
+}
+
 type IFlagSetter interface {
 	AnySet() bool
 	SetTrue(Flag)
@@ -58,7 +76,7 @@ func (o *Extensions) validComid(comid *Comid) error {
 	ev, ok := o.IMapValue.(IComidConstrainer)
 	if ok {
 		if err := ev.ConstrainComid(comid); err != nil {
-			return err
+			return validation.New(validation.CodeConstraint, "%w", err)
 		}
 	}
 
@@ -73,7 +91,7 @@ func (o *Extensions) validTriples(triples *Triples) error {
 	ev, ok := o.IMapValue.(ITriplesConstrainer)
 	if ok {
 		if err := ev.ValidTriples(triples); err != nil {
-			return err
+			return validation.New(validation.CodeConstraint, "%w", err)
 		}
 	}
 
@@ -88,7 +106,7 @@ func (o *Extensions) validMval(triples *Mval) error {
 	ev, ok := o.IMapValue.(IMvalConstrainer)
 	if ok {
 		if err := ev.ConstrainMval(triples); err != nil {
-			return err
+			return validation.New(validation.CodeConstraint, "%w", err)
 		}
 	}
 
@@ -103,7 +121,7 @@ func (o *Extensions) validEntity(triples *Entity) error {
 	ev, ok := o.IMapValue.(IEntityConstrainer)
 	if ok {
 		if err := ev.ConstrainEntity(triples); err != nil {
-			return err
+			return validation.New(validation.CodeConstraint, "%w", err)
 		}
 	}
 
@@ -118,7 +136,52 @@ func (o *Extensions) validFlagsMap(triples *FlagsMap) error {
 	ev, ok := o.IMapValue.(IFlagsMapConstrainer)
 	if ok {
 		if err := ev.ConstrainFlagsMap(triples); err != nil {
-			return err
+			return validation.New(validation.CodeConstraint, "%w", err)
+		}
+	}
+
+	return nil
+}
+
+func (o *Extensions) validKeyTriple(triple *KeyTriple) error {
+	if !o.HaveExtensions() {
+		return nil
+	}
+
+	ev, ok := o.IMapValue.(IKeyTripleConstrainer)
+	if ok {
+		if err := ev.ConstrainKeyTriple(triple); err != nil {
+			return validation.New(validation.CodeConstraint, "%w", err)
+		}
+	}
+
+	return nil
+}
+
+func (o *Extensions) validEnvironment(env *Environment) error {
+	if !o.HaveExtensions() {
+		return nil
+	}
+
+	ev, ok := o.IMapValue.(IEnvironmentConstrainer)
+	if ok {
+		if err := ev.ConstrainEnvironment(env); err != nil {
+			return validation.New(validation.CodeConstraint, "%w", err)
+		}
+	}
+
+	return nil
+}
+
+func (o *Extensions) validClass(class *Class) error {
+	if !o.HaveExtensions() {
+		return nil
+	}
+
+	ev, ok := o.IMapValue.(IClassConstrainer)
+	if ok {
+		if err := ev.ConstrainClass(class); err != nil {
+			return validation.New(validation.CodeConstraint, "%w", err)
 		}
 	}
 
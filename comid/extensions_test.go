@@ -35,6 +35,18 @@ func (o *TestExtension) ConstrainEntity(_ *Entity) error {
 	return errors.New("invalid")
 }
 
+func (o *TestExtension) ConstrainKeyTriple(_ *KeyTriple) error {
+	return errors.New("invalid")
+}
+
+func (o *TestExtension) ConstrainEnvironment(_ *Environment) error {
+	return errors.New("invalid")
+}
+
+func (o *TestExtension) ConstrainClass(_ *Class) error {
+	return errors.New("invalid")
+}
+
 func (o *TestExtension) SetTrue(flag Flag) {
 	if flag == FlagTestFlag {
 		o.TestFlag = &True
@@ -83,6 +95,15 @@ func Test_Extensions(t *testing.T) {
 	err = exts.validFlagsMap(nil)
 	assert.EqualError(t, err, "invalid")
 
+	err = exts.validKeyTriple(nil)
+	assert.EqualError(t, err, "invalid")
+
+	err = exts.validEnvironment(nil)
+	assert.EqualError(t, err, "invalid")
+
+	err = exts.validClass(nil)
+	assert.EqualError(t, err, "invalid")
+
 	assert.False(t, exts.anySet())
 
 	exts.setTrue(FlagTestFlag)
@@ -214,22 +214,42 @@ func (o *FlagsMap) GetExtensions() extensions.IMapValue {
 
 // UnmarshalCBOR deserializes from CBOR
 func (o *FlagsMap) UnmarshalCBOR(data []byte) error {
-	return encoding.PopulateStructFromCBOR(dm, data, o)
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *FlagsMap) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromCBOR(decModeFor(d), data, o)
 }
 
 // MarshalCBOR serializes to CBOR
 func (o FlagsMap) MarshalCBOR() ([]byte, error) {
-	return encoding.SerializeStructToCBOR(em, o)
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o FlagsMap) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return e.SerializeStructToCBOR(encModeFor(e), o)
 }
 
 // UnmarshalJSON deserializes from JSON
 func (o *FlagsMap) UnmarshalJSON(data []byte) error {
-	return encoding.PopulateStructFromJSON(data, o)
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *FlagsMap) DecodeJSON(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromJSON(data, o)
 }
 
 // MarshalJSON serializes to JSON
 func (o FlagsMap) MarshalJSON() ([]byte, error) {
-	return encoding.SerializeStructToJSON(o)
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o FlagsMap) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
+	return e.SerializeStructToJSON(o)
 }
 
 // Valid returns an error if the FlagsMap is invalid.
@@ -5,11 +5,11 @@ package comid
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 
 	"github.com/jraman567/corim/encoding"
 	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 )
 
 // Group stores a group identity. The supported formats are UUID and variable-length opaque bytes.
@@ -19,7 +19,13 @@ type Group struct {
 
 // NewGroup instantiates an empty group
 func NewGroup(val any, typ string) (*Group, error) {
-	factory, ok := groupValueRegister[typ]
+	return DefaultRegistry.NewGroup(val, typ)
+}
+
+// NewGroup is like the package-level NewGroup, but uses the type choices
+// registered with the target Registry.
+func (o *Registry) NewGroup(val any, typ string) (*Group, error) {
+	factory, ok := lookupTypeChoice(o, o.groups, typ)
 	if !ok {
 		return nil, fmt.Errorf("unknown group type: %s", typ)
 	}
@@ -30,10 +36,10 @@ func NewGroup(val any, typ string) (*Group, error) {
 // Valid checks for the validity of given group
 func (o Group) Valid() error {
 	if o.Value == nil {
-		return errors.New("no value set")
+		return validation.New(validation.CodeMissing, "no value set")
 	}
 
-	return o.Value.Valid()
+	return validation.Wrap(o.Value.Valid())
 }
 
 // String returns a printable string of the Group value.  UUIDs use the
@@ -61,12 +67,22 @@ func (o Group) Bytes() []byte {
 
 // MarshalCBOR serializes the target group to CBOR
 func (o Group) MarshalCBOR() ([]byte, error) {
-	return em.Marshal(o.Value)
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o Group) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return encModeFor(e).Marshal(o.Value)
 }
 
 // UnmarshalCBOR deserializes the supplied CBOR into the target group
 func (o *Group) UnmarshalCBOR(data []byte) error {
-	return dm.Unmarshal(data, &o.Value)
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *Group) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return decModeFor(d).Unmarshal(data, &o.Value)
 }
 
 // UnmarshalJSON deserializes the supplied JSON type/value object into the Group
@@ -85,13 +101,18 @@ func (o *Group) UnmarshalCBOR(data []byte) error {
 //	  "value": "MTIzNDU2Nzg5"
 //	}
 func (o *Group) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Group) DecodeJSON(d *encoding.Decoder, data []byte) error {
 	var tnv encoding.TypeAndValue
 
 	if err := json.Unmarshal(data, &tnv); err != nil {
 		return fmt.Errorf("group decoding failure: %w", err)
 	}
 
-	decoded, err := NewGroup(nil, tnv.Type)
+	decoded, err := RegistryFrom(d.Registry).NewGroup(nil, tnv.Type)
 	if err != nil {
 		return err
 	}
@@ -165,7 +186,8 @@ func NewBytesGroup(val any) (*Group, error) {
 // See also https://go.dev/ref/spec#The_zero_value
 type IGroupFactory func(any) (*Group, error)
 
-var groupValueRegister = map[string]IGroupFactory{
+// builtinGroupTypes contains the group types every Registry starts with
+var builtinGroupTypes = map[string]IGroupFactory{
 	UUIDType:  NewUUIDGroup,
 	BytesType: NewBytesGroup,
 }
@@ -174,22 +196,16 @@ var groupValueRegister = map[string]IGroupFactory{
 // (created by the provided IGroupFactory) under the specified type name
 // and CBOR tag.
 func RegisterGroupType(tag uint64, factory IGroupFactory) error {
+	return DefaultRegistry.RegisterGroupType(tag, factory)
+}
 
+// RegisterGroupType is like the package-level RegisterGroupType, but
+// registers with the target Registry.
+func (o *Registry) RegisterGroupType(tag uint64, factory IGroupFactory) error {
 	nilVal, err := factory(nil)
 	if err != nil {
 		return err
 	}
 
-	typ := nilVal.Value.Type()
-	if _, exists := groupValueRegister[typ]; exists {
-		return fmt.Errorf("Group type with name %q already exists", typ)
-	}
-
-	if err := registerCOMIDTag(tag, nilVal.Value); err != nil {
-		return err
-	}
-
-	groupValueRegister[typ] = factory
-
-	return nil
+	return registerTypeChoice(o, o.groups, "Group", nilVal.Value.Type(), tag, nilVal.Value, factory)
 }
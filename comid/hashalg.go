@@ -0,0 +1,161 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package comid
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"fmt"
+	"hash"
+	"sync"
+
+	"github.com/jraman567/corim/validation"
+	"github.com/veraison/swid"
+	"golang.org/x/crypto/sha3"
+)
+
+// HashAlgorithm describes a hash algorithm from the IANA Named Information
+// Hash Algorithm Registry
+type HashAlgorithm struct {
+	// ID is the named information hash algorithm identifier
+	ID uint64
+	// Name is the named information hash name string, e.g. "sha-256"
+	Name string
+	// Size is the length in bytes of the digest value
+	Size int
+	// New returns the hash function. For truncated algorithms (e.g.
+	// sha-256-128) the output is truncated to Size.
+	New func() hash.Hash
+}
+
+// Valid checks that the HashAlgorithm is fully specified
+func (o HashAlgorithm) Valid() error {
+	if o.Name == "" {
+		return validation.New(validation.CodeEmpty, "empty name")
+	}
+
+	if o.Size <= 0 {
+		return validation.New(validation.CodeInvalid, "invalid size %d", o.Size)
+	}
+
+	if o.New == nil {
+		return validation.New(validation.CodeMissing, "nil hash constructor")
+	}
+
+	return nil
+}
+
+// Sum returns the digest of the supplied data
+func (o HashAlgorithm) Sum(data []byte) []byte {
+	h := o.New()
+	h.Write(data) // nolint:errcheck
+	return h.Sum(nil)[:o.Size]
+}
+
+var (
+	hashAlgorithms   = map[uint64]HashAlgorithm{}
+	hashAlgorithmsMu sync.RWMutex
+)
+
+func init() {
+	for _, alg := range []HashAlgorithm{
+		{swid.Sha256, "sha-256", 32, sha256.New},
+		{swid.Sha256_128, "sha-256-128", 16, sha256.New},
+		{swid.Sha256_120, "sha-256-120", 15, sha256.New},
+		{swid.Sha256_96, "sha-256-96", 12, sha256.New},
+		{swid.Sha256_64, "sha-256-64", 8, sha256.New},
+		{swid.Sha256_32, "sha-256-32", 4, sha256.New},
+		{swid.Sha384, "sha-384", 48, sha512.New384},
+		{swid.Sha512, "sha-512", 64, sha512.New},
+		{swid.Sha3_224, "sha3-224", 28, sha3.New224},
+		{swid.Sha3_256, "sha3-256", 32, sha3.New256},
+		{swid.Sha3_384, "sha3-384", 48, sha3.New384},
+		{swid.Sha3_512, "sha3-512", 64, sha3.New512},
+	} {
+		if err := RegisterHashAlgorithm(alg); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterHashAlgorithm adds the supplied algorithm to the registry used to
+// validate and compute digests. An error is returned if an algorithm with the
+// same ID or name is already registered.
+func RegisterHashAlgorithm(alg HashAlgorithm) error {
+	if err := alg.Valid(); err != nil {
+		return fmt.Errorf("invalid hash algorithm %d: %w", alg.ID, err)
+	}
+
+	hashAlgorithmsMu.Lock()
+	defer hashAlgorithmsMu.Unlock()
+
+	if _, exists := hashAlgorithms[alg.ID]; exists {
+		return fmt.Errorf("hash algorithm with ID %d already registered", alg.ID)
+	}
+
+	if _, exists := lookupHashAlgorithmByName(alg.Name); exists {
+		return fmt.Errorf("hash algorithm with name %q already registered", alg.Name)
+	}
+
+	hashAlgorithms[alg.ID] = alg
+
+	return nil
+}
+
+// LookupHashAlgorithm returns the registered algorithm with the supplied ID
+func LookupHashAlgorithm(id uint64) (HashAlgorithm, bool) {
+	hashAlgorithmsMu.RLock()
+	defer hashAlgorithmsMu.RUnlock()
+
+	alg, ok := hashAlgorithms[id]
+	return alg, ok
+}
+
+// LookupHashAlgorithmByName returns the registered algorithm with the supplied
+// name
+func LookupHashAlgorithmByName(name string) (HashAlgorithm, bool) {
+	hashAlgorithmsMu.RLock()
+	defer hashAlgorithmsMu.RUnlock()
+
+	return lookupHashAlgorithmByName(name)
+}
+
+func lookupHashAlgorithmByName(name string) (HashAlgorithm, bool) {
+	for _, alg := range hashAlgorithms {
+		if alg.Name == name {
+			return alg, true
+		}
+	}
+
+	return HashAlgorithm{}, false
+}
+
+// ValidHashEntry checks that the algorithm of the supplied digest is
+// registered, and that the length of the digest value matches it
+func ValidHashEntry(he swid.HashEntry) error {
+	alg, ok := LookupHashAlgorithm(he.HashAlgID)
+	if !ok {
+		return validation.NewAt("hash-alg-id", validation.CodeUnknown, "unknown hash algorithm %d", he.HashAlgID)
+	}
+
+	if len(he.HashValue) != alg.Size {
+		return validation.Errorf("hash-value", "length mismatch for hash algorithm %s: want %d bytes, got %d",
+			alg.Name, alg.Size, len(he.HashValue))
+	}
+
+	return nil
+}
+
+// MatchHashEntry checks that the supplied digest is valid and is the digest of
+// the supplied data. The comparison is done in constant time.
+func MatchHashEntry(he swid.HashEntry, data []byte) (bool, error) {
+	if err := ValidHashEntry(he); err != nil {
+		return false, err
+	}
+
+	alg, _ := LookupHashAlgorithm(he.HashAlgID)
+
+	return subtle.ConstantTimeCompare(alg.Sum(data), he.HashValue) == 1, nil
+}
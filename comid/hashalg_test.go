@@ -0,0 +1,74 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package comid
+
+import (
+	"crypto/sha1" // nolint:gosec
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/swid"
+)
+
+func TestLookupHashAlgorithm(t *testing.T) {
+	alg, ok := LookupHashAlgorithm(swid.Sha384)
+	require.True(t, ok)
+	assert.Equal(t, "sha-384", alg.Name)
+	assert.Equal(t, 48, alg.Size)
+	assert.Len(t, alg.Sum([]byte("abc")), 48)
+
+	alg, ok = LookupHashAlgorithmByName("sha-256-120")
+	require.True(t, ok)
+	assert.Equal(t, swid.Sha256_120, alg.ID)
+	assert.Len(t, alg.Sum([]byte("abc")), 15)
+
+	_, ok = LookupHashAlgorithm(666)
+	assert.False(t, ok)
+
+	_, ok = LookupHashAlgorithmByName("md5")
+	assert.False(t, ok)
+}
+
+func TestRegisterHashAlgorithm(t *testing.T) {
+	// private-use ID, not in the IANA registry
+	const sha1ID = 65000
+
+	err := RegisterHashAlgorithm(HashAlgorithm{ID: sha1ID, Name: "x-sha-1", Size: 20, New: sha1.New})
+	require.NoError(t, err)
+	defer delete(hashAlgorithms, sha1ID)
+
+	d := NewDigests()
+	require.NoError(t, d.Compute([]byte("abc"), sha1ID))
+	assert.Equal(t, MustHexDecode(t, "a9993e364706816aba3e25717850c26c9cd0d89d"), (*d)[0].HashValue)
+	assert.NoError(t, d.Valid())
+	assert.NotNil(t, NewHashEntry(sha1ID, (*d)[0].HashValue))
+
+	err = RegisterHashAlgorithm(HashAlgorithm{ID: sha1ID, Name: "x-sha-1-again", Size: 20, New: sha1.New})
+	assert.EqualError(t, err, "hash algorithm with ID 65000 already registered")
+
+	err = RegisterHashAlgorithm(HashAlgorithm{ID: 65001, Name: "sha-256", Size: 32, New: sha1.New})
+	assert.EqualError(t, err, `hash algorithm with name "sha-256" already registered`)
+
+	err = RegisterHashAlgorithm(HashAlgorithm{ID: 65001, Name: "x", Size: 0, New: sha1.New})
+	assert.EqualError(t, err, "invalid hash algorithm 65001: invalid size 0")
+
+	err = RegisterHashAlgorithm(HashAlgorithm{ID: 65001, Name: "x", Size: 1})
+	assert.EqualError(t, err, "invalid hash algorithm 65001: nil hash constructor")
+
+	err = RegisterHashAlgorithm(HashAlgorithm{ID: 65001, Size: 1, New: sha1.New})
+	assert.EqualError(t, err, "invalid hash algorithm 65001: empty name")
+}
+
+func TestMval_Valid_integrity_registers(t *testing.T) {
+	reg := NewIntegrityRegisters()
+	require.NoError(t, reg.AddDigest(uint(0), swid.HashEntry{
+		HashAlgID: swid.Sha256,
+		HashValue: MustHexDecode(t, "deadbeef"),
+	}))
+
+	mval := Mval{IntegrityRegisters: reg}
+	assert.EqualError(t, mval.Valid(), "integrity registers: register 0: digest at index 0: "+
+		"length mismatch for hash algorithm sha-256: want 32 bytes, got 4")
+}
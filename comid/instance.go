@@ -9,6 +9,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/jraman567/corim/encoding"
 	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 	"github.com/veraison/eat"
 )
 
@@ -20,7 +21,13 @@ type Instance struct {
 // NewInstance creates a new instance with the value of the specified type
 // populated using the provided value.
 func NewInstance(val any, typ string) (*Instance, error) {
-	factory, ok := instanceValueRegister[typ]
+	return DefaultRegistry.NewInstance(val, typ)
+}
+
+// NewInstance is like the package-level NewInstance, but uses the type choices
+// registered with the target Registry.
+func (o *Registry) NewInstance(val any, typ string) (*Instance, error) {
+	factory, ok := lookupTypeChoice(o, o.instances, typ)
 	if !ok {
 		return nil, fmt.Errorf("unknown instance type: %s", typ)
 	}
@@ -31,7 +38,7 @@ func NewInstance(val any, typ string) (*Instance, error) {
 // Valid checks for the validity of given instance
 func (o Instance) Valid() error {
 	if o.String() == "" {
-		return fmt.Errorf("invalid instance id")
+		return validation.New(validation.CodeInvalid, "invalid instance id")
 	}
 	return nil
 }
@@ -59,11 +66,21 @@ func (o Instance) Bytes() []byte {
 
 // MarshalCBOR serializes the target instance to CBOR
 func (o Instance) MarshalCBOR() ([]byte, error) {
-	return em.Marshal(o.Value)
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o Instance) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return encModeFor(e).Marshal(o.Value)
 }
 
 func (o *Instance) UnmarshalCBOR(data []byte) error {
-	return dm.Unmarshal(data, &o.Value)
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *Instance) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return decModeFor(d).Unmarshal(data, &o.Value)
 }
 
 // UnmarshalJSON deserializes the supplied JSON object into the target Instance
@@ -83,13 +100,18 @@ func (o *Instance) UnmarshalCBOR(data []byte) error {
 //	uuid: standard UUID string representation, e.g. "550e8400-e29b-41d4-a716-446655440000"
 //	bytes: a variable-length opaque byte string, example {0x07, 0x12, 0x34}
 func (o *Instance) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Instance) DecodeJSON(d *encoding.Decoder, data []byte) error {
 	var tnv encoding.TypeAndValue
 
 	if err := json.Unmarshal(data, &tnv); err != nil {
 		return fmt.Errorf("instance decoding failure: %w", err)
 	}
 
-	decoded, err := NewInstance(nil, tnv.Type)
+	decoded, err := RegistryFrom(d.Registry).NewInstance(nil, tnv.Type)
 	if err != nil {
 		return err
 	}
@@ -245,7 +267,8 @@ func MustNewUUIDInstance(val any) *Instance {
 // See also https://go.dev/ref/spec#The_zero_value
 type IInstanceFactory func(any) (*Instance, error)
 
-var instanceValueRegister = map[string]IInstanceFactory{
+// builtinInstanceTypes contains the instance types every Registry starts with
+var builtinInstanceTypes = map[string]IInstanceFactory{
 	UEIDType:  NewUEIDInstance,
 	UUIDType:  NewUUIDInstance,
 	BytesType: NewBytesInstance,
@@ -254,21 +277,16 @@ var instanceValueRegister = map[string]IInstanceFactory{
 // RegisterInstanceType registers a new IInstanceValue implementation (created
 // by the provided IInstanceFactory) under the specified CBOR tag.
 func RegisterInstanceType(tag uint64, factory IInstanceFactory) error {
+	return DefaultRegistry.RegisterInstanceType(tag, factory)
+}
+
+// RegisterInstanceType is like the package-level RegisterInstanceType, but
+// registers with the target Registry.
+func (o *Registry) RegisterInstanceType(tag uint64, factory IInstanceFactory) error {
 	nilVal, err := factory(nil)
 	if err != nil {
 		return err
 	}
 
-	typ := nilVal.Type()
-	if _, exists := instanceValueRegister[typ]; exists {
-		return fmt.Errorf("class ID type with name %q already exists", typ)
-	}
-
-	if err := registerCOMIDTag(tag, nilVal.Value); err != nil {
-		return err
-	}
-
-	instanceValueRegister[typ] = factory
-
-	return nil
+	return registerTypeChoice(o, o.instances, "class ID", nilVal.Type(), tag, nilVal.Value, factory)
 }
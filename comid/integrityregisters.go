@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/jraman567/corim/validation"
 	"github.com/veraison/swid"
 )
 
@@ -54,6 +55,28 @@ func (i *IntegrityRegisters) AddDigest(index IRegisterIndex, digest swid.HashEnt
 	return nil
 }
 
+// Valid checks that the registers have a supported index type and contain
+// valid digests (see Digests.Valid)
+func (i IntegrityRegisters) Valid() error {
+	for index, digests := range i.IndexMap {
+		switch index.(type) {
+		case string, uint, uint64:
+		default:
+			return validation.New(validation.CodeInvalid, "unexpected type for index: %T", index)
+		}
+
+		if len(digests) == 0 {
+			return validation.NewAt(fmt.Sprint(index), validation.CodeEmpty, "register %v: no digests", index)
+		}
+
+		if err := digests.Valid(); err != nil {
+			return validation.Errorf(fmt.Sprint(index), "register %v: %w", index, err)
+		}
+	}
+
+	return nil
+}
+
 func (i IntegrityRegisters) MarshalCBOR() ([]byte, error) {
 	return em.Marshal(i.IndexMap)
 }
@@ -300,3 +300,21 @@ func TestIntegrityRegisters_UnmarshalJSON_NOK(t *testing.T) {
 		})
 	}
 }
+
+func TestIntegrityRegisters_Valid(t *testing.T) {
+	reg, err := prepareRegister(t, "text")
+	require.NoError(t, err)
+	assert.NoError(t, reg.Valid())
+
+	reg.IndexMap["0"] = Digests{{HashAlgID: swid.Sha256, HashValue: MustHexDecode(t, "deadbeef")}}
+	assert.EqualError(t, reg.Valid(),
+		"register 0: digest at index 0: length mismatch for hash algorithm sha-256: want 32 bytes, got 4")
+
+	reg = NewIntegrityRegisters()
+	reg.IndexMap[uint(1)] = Digests{}
+	assert.EqualError(t, reg.Valid(), "register 1: no digests")
+
+	reg = NewIntegrityRegisters()
+	reg.IndexMap[true] = Digests{}
+	assert.EqualError(t, reg.Valid(), "unexpected type for index: bool")
+}
@@ -3,31 +3,327 @@
 
 package comid
 
-import "fmt"
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	cbor "github.com/fxamacker/cbor/v2"
+	"github.com/jraman567/corim/encoding"
+	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
+)
 
 // KeyTriple stores a cryptographic key triple record (identity-triple-record
 // or attest-key-triple-record) with CBOR and JSON serializations.  Note that
 // the CBOR serialization packs the structure into an array.  Instead, when
-// serializing to JSON, the structure is converted into an object.
+// serializing to JSON, the structure is converted into an object.  The
+// optional Conditions are the third element of the CBOR array.  As the array
+// has no other place for them, extension fields are encoded in that same
+// conditions map in CBOR, and alongside the other members of the JSON object.
 type KeyTriple struct {
-	_           struct{}    `cbor:",toarray"`
-	Environment Environment `json:"environment"`
-	VerifKeys   CryptoKeys  `json:"verification-keys"`
+	Environment Environment          `json:"environment"`
+	VerifKeys   CryptoKeys           `json:"verification-keys"`
+	Conditions  *KeyTripleConditions `json:"conditions,omitempty"`
+
+	Extensions
+}
+
+// RegisterExtensions registers a struct as a collections of extensions. The
+// extensions of the environment (ExtEnvironment and ExtClass) are passed on
+// to the Environment. As the extension fields are encoded in the
+// key-triple-conditions-map, they cannot use the keys of the conditions.
+func (o *KeyTriple) RegisterExtensions(exts extensions.Map) error {
+	envExts := extensions.NewMap()
+
+	for p, v := range exts {
+		switch p {
+		case ExtKeyTriple:
+			if err := checkKeyTripleExtensions(v); err != nil {
+				return err
+			}
+
+			o.Extensions.Register(v)
+		case ExtEnvironment, ExtClass:
+			envExts[p] = v
+		default:
+			return fmt.Errorf("%w: %q", extensions.ErrUnexpectedPoint, p)
+		}
+	}
+
+	return o.Environment.RegisterExtensions(envExts)
+}
+
+// GetExtensions returns previously registered extension
+func (o *KeyTriple) GetExtensions() extensions.IMapValue {
+	return o.Extensions.IMapValue
 }
 
 func (o KeyTriple) Valid() error {
 	if err := o.Environment.Valid(); err != nil {
-		return fmt.Errorf("environment validation failed: %w", err)
+		return validation.Errorf("environment", "environment validation failed: %w", err)
 	}
 
 	if err := o.VerifKeys.Valid(); err != nil {
-		return fmt.Errorf("verification keys validation failed: %w", err)
+		return validation.Errorf("verification-keys", "verification keys validation failed: %w", err)
+	}
+
+	if o.Conditions != nil {
+		if err := o.Conditions.Valid(); err != nil {
+			return validation.Errorf("conditions", "conditions validation failed: %w", err)
+		}
+	}
+
+	return o.Extensions.validKeyTriple(&o)
+}
+
+// MarshalCBOR serializes to CBOR
+func (o KeyTriple) MarshalCBOR() ([]byte, error) {
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o KeyTriple) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	arr := []any{o.Environment, o.VerifKeys}
+
+	if o.Conditions != nil || !o.Extensions.IsEmpty() || o.HaveUnknownFields() {
+		conds := keyTripleConditionsMap{Extensions: o.Extensions}
+		if o.Conditions != nil {
+			conds.KeyTripleConditions = *o.Conditions
+		}
+
+		data, err := e.SerializeStructToCBOR(encModeFor(e), conds)
+		if err != nil {
+			return nil, fmt.Errorf("conditions: %w", err)
+		}
+
+		arr = append(arr, cbor.RawMessage(data))
+	}
+
+	return e.EncodeCBORValue(encModeFor(e), arr)
+}
+
+// UnmarshalCBOR deserializes from CBOR
+func (o *KeyTriple) UnmarshalCBOR(data []byte) error {
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *KeyTriple) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	var arr []cbor.RawMessage
+
+	if err := decModeFor(d).Unmarshal(data, &arr); err != nil {
+		return err
+	}
+
+	if len(arr) != 2 && len(arr) != 3 {
+		return fmt.Errorf("expecting a key triple array of 2 or 3 elements, got %d", len(arr))
+	}
+
+	if err := d.DecodeCBORValue(decModeFor(d), arr[0], &o.Environment); err != nil {
+		return fmt.Errorf("environment: %w", err)
+	}
+
+	if err := d.DecodeCBORValue(decModeFor(d), arr[1], &o.VerifKeys); err != nil {
+		return fmt.Errorf("verification keys: %w", err)
 	}
+
+	o.Conditions = nil
+
+	if len(arr) == 3 {
+		conds := keyTripleConditionsMap{Extensions: o.Extensions}
+
+		if err := d.PopulateStructFromCBOR(decModeFor(d), arr[2], &conds); err != nil {
+			return fmt.Errorf("conditions: %w", err)
+		}
+
+		o.Extensions = conds.Extensions
+
+		if conds.KeyTripleConditions != (KeyTripleConditions{}) {
+			o.Conditions = &conds.KeyTripleConditions
+		}
+	}
+
 	return nil
 }
 
-type KeyTriples []KeyTriple
+// UnmarshalJSON deserializes from JSON
+func (o *KeyTriple) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *KeyTriple) DecodeJSON(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromJSON(data, o)
+}
+
+// MarshalJSON serializes to JSON
+func (o KeyTriple) MarshalJSON() ([]byte, error) {
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o KeyTriple) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
+	return e.SerializeStructToJSON(o)
+}
+
+// KeyTripleConditions stores the key-triple-conditions-map of a KeyTriple,
+// which restricts the use of the keys to the measured element identified by
+// Mkey, and to the holders of the AuthorizedBy keys.
+type KeyTripleConditions struct {
+	Mkey         *Mkey       `cbor:"0,keyasint,omitempty" json:"mkey,omitempty"`
+	AuthorizedBy *CryptoKeys `cbor:"1,keyasint,omitempty" json:"authorized-by,omitempty"`
+}
+
+// Valid checks that the KeyTripleConditions is valid as per the specification
+func (o KeyTripleConditions) Valid() error {
+	// non-empty<>
+	if o.Mkey == nil && o.AuthorizedBy == nil {
+		return validation.New(validation.CodeEmpty, "no conditions set")
+	}
+
+	if o.Mkey != nil {
+		if err := o.Mkey.Valid(); err != nil {
+			return validation.Errorf("mkey", "measurement key validation failed: %w", err)
+		}
+	}
+
+	if o.AuthorizedBy != nil {
+		if err := o.AuthorizedBy.Valid(); err != nil {
+			return validation.Errorf("authorized-by", "authorized-by validation failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// checkKeyTripleExtensions returns an error if any of the fields of the
+// extensions struct pointed to by v uses the CBOR key of one of the
+// KeyTripleConditions
+func checkKeyTripleExtensions(v extensions.IMapValue) error {
+	reserved := cborKeys(reflect.TypeOf(KeyTripleConditions{}))
+
+	for key, name := range cborKeys(reflect.TypeOf(v)) {
+		if _, ok := reserved[key]; ok {
+			return fmt.Errorf(
+				"key triple extension field %q uses key %d of the key-triple-conditions-map",
+				name, key,
+			)
+		}
+	}
+
+	return nil
+}
+
+// cborKeys maps the integer CBOR keys of the fields of the struct type t (or
+// pointed to by t), including those of its embedded structs, onto the names
+// of the fields
+func cborKeys(t reflect.Type) map[int]string {
+	ret := make(map[int]string)
+
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return ret
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("cbor")
+
+		if !ok && field.Anonymous {
+			for key, name := range cborKeys(field.Type) {
+				ret[key] = name
+			}
+
+			continue
+		}
+
+		key, err := strconv.Atoi(strings.Split(tag, ",")[0])
+		if err != nil {
+			continue
+		}
+
+		ret[key] = field.Name
+	}
+
+	return ret
+}
+
+// keyTripleConditionsMap is the CBOR map holding the conditions of a
+// KeyTriple, along with its extension fields and unknown entries
+type keyTripleConditionsMap struct {
+	KeyTripleConditions
+	Extensions
+}
+
+// KeyTriples is a container for KeyTriple instances and their extensions.
+// It is a thin wrapper around extensions.Collection, so that the extensions
+// registered for the key triples are registered with each one before it is
+// decoded.  Note that it used to be a plain []KeyTriple: the triples are now
+// in Values, and are appended with Add.
+type KeyTriples extensions.Collection[KeyTriple, *KeyTriple]
 
 func NewKeyTriples() *KeyTriples {
-	return &KeyTriples{}
+	return (*KeyTriples)(extensions.NewCollection[KeyTriple]())
+}
+
+func (o *KeyTriples) RegisterExtensions(exts extensions.Map) error {
+	return (*extensions.Collection[KeyTriple, *KeyTriple])(o).RegisterExtensions(exts)
+}
+
+func (o *KeyTriples) GetExtensions() extensions.IMapValue {
+	return (*extensions.Collection[KeyTriple, *KeyTriple])(o).GetExtensions()
+}
+
+func (o KeyTriples) Valid() error {
+	return (extensions.Collection[KeyTriple, *KeyTriple])(o).Valid()
+}
+
+func (o *KeyTriples) IsEmpty() bool {
+	return (*extensions.Collection[KeyTriple, *KeyTriple])(o).IsEmpty()
+}
+
+func (o *KeyTriples) Add(val *KeyTriple) *KeyTriples {
+	ret := (*extensions.Collection[KeyTriple, *KeyTriple])(o).Add(val)
+	return (*KeyTriples)(ret)
+}
+
+func (o KeyTriples) MarshalCBOR() ([]byte, error) {
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o KeyTriples) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return (extensions.Collection[KeyTriple, *KeyTriple])(o).EncodeCBOR(e)
+}
+
+func (o *KeyTriples) UnmarshalCBOR(data []byte) error {
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *KeyTriples) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return (*extensions.Collection[KeyTriple, *KeyTriple])(o).DecodeCBOR(d, data)
+}
+
+func (o KeyTriples) MarshalJSON() ([]byte, error) {
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o KeyTriples) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
+	return (extensions.Collection[KeyTriple, *KeyTriple])(o).EncodeJSON(e)
+}
+
+func (o *KeyTriples) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *KeyTriples) DecodeJSON(d *encoding.Decoder, data []byte) error {
+	return (*extensions.Collection[KeyTriple, *KeyTriple])(o).DecodeJSON(d, data)
 }
@@ -4,9 +4,12 @@
 package comid
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/jraman567/corim/extensions"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestVerificationKeys_Valid_empty(t *testing.T) {
@@ -39,3 +42,149 @@ func TestVerificationKeys_Valid_empty(t *testing.T) {
 		assert.EqualError(t, err, tv.testerr)
 	}
 }
+
+type testKeyTripleExtensions struct {
+	Purpose *string `cbor:"-1,keyasint,omitempty" json:"purpose,omitempty"`
+}
+
+func (o *testKeyTripleExtensions) ConstrainKeyTriple(kt *KeyTriple) error {
+	if o.Purpose == nil {
+		return errors.New("missing purpose")
+	}
+
+	return nil
+}
+
+type testClassExtensions struct {
+	FirmwareFamily *string `cbor:"-1,keyasint,omitempty" json:"firmware-family,omitempty"`
+}
+
+func TestKeyTriple_extensions(t *testing.T) {
+	triples := Triples{}
+	require.NoError(t, triples.RegisterExtensions(extensions.NewMap().
+		Add(ExtAttestVerifKey, &testKeyTripleExtensions{}).
+		Add(ExtClass, &testClassExtensions{})))
+
+	triples.AddAttestVerifKey(KeyTriple{
+		Environment: Environment{Instance: MustNewUEIDInstance(TestUEID)},
+		VerifKeys:   CryptoKeys{MustNewPKIXBase64Key(TestECPubKey)},
+	})
+
+	kt := &triples.AttestVerifKeys.Values[0]
+	assert.False(t, kt.Environment.hasClass())
+
+	err := kt.Valid()
+	assert.EqualError(t, err, "missing purpose")
+
+	require.NoError(t, kt.Set("purpose", "AK"))
+	assert.NoError(t, kt.Valid())
+
+	require.NoError(t, kt.Environment.Class.Set("firmware-family", "fw"))
+	assert.True(t, kt.Environment.hasClass())
+
+	data, err := kt.MarshalCBOR()
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x83), data[0]) // array(3)
+
+	var (
+		out     KeyTriple
+		outExts testKeyTripleExtensions
+	)
+	require.NoError(t, out.RegisterExtensions(extensions.NewMap().
+		Add(ExtKeyTriple, &outExts).
+		Add(ExtClass, &testClassExtensions{})))
+	require.NoError(t, out.UnmarshalCBOR(data))
+	assert.Equal(t, "AK", *outExts.Purpose)
+	assert.Equal(t, "fw", out.Environment.Class.MustGetString("firmware-family"))
+	assert.NoError(t, out.Valid())
+
+	data, err = out.MarshalJSON()
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"purpose":"AK"`)
+	assert.Contains(t, string(data), `"class":{"firmware-family":"fw"}`)
+
+	// without any extensions, the triple is still encoded as a pair
+	data, err = KeyTriple{
+		Environment: Environment{Instance: MustNewUEIDInstance(TestUEID)},
+		VerifKeys:   CryptoKeys{MustNewPKIXBase64Key(TestECPubKey)},
+	}.MarshalCBOR()
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x82), data[0]) // array(2)
+
+	err = out.UnmarshalCBOR([]byte{0x81, 0xa0})
+	assert.EqualError(t, err, "expecting a key triple array of 2 or 3 elements, got 1")
+}
+
+func TestKeyTriple_conditions(t *testing.T) {
+	mkey, err := NewMkeyUint(uint64(7))
+	require.NoError(t, err)
+
+	kt := KeyTriple{
+		Environment: Environment{Instance: MustNewUEIDInstance(TestUEID)},
+		VerifKeys:   CryptoKeys{MustNewPKIXBase64Key(TestECPubKey)},
+		Conditions: &KeyTripleConditions{
+			Mkey:         mkey,
+			AuthorizedBy: &CryptoKeys{MustNewPKIXBase64Key(TestECPubKey)},
+		},
+	}
+	require.NoError(t, kt.Valid())
+
+	data, err := kt.MarshalCBOR()
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x83), data[0]) // array(3)
+
+	var out KeyTriple
+	require.NoError(t, out.UnmarshalCBOR(data))
+	require.NotNil(t, out.Conditions)
+	assert.Equal(t, kt.Conditions.Mkey, out.Conditions.Mkey)
+
+	outData, err := out.MarshalCBOR()
+	require.NoError(t, err)
+	assert.Equal(t, data, outData)
+
+	data, err = kt.MarshalJSON()
+	require.NoError(t, err)
+
+	out = KeyTriple{}
+	require.NoError(t, out.UnmarshalJSON(data))
+	require.NotNil(t, out.Conditions)
+	assert.Equal(t, kt.Conditions.Mkey, out.Conditions.Mkey)
+
+	outData, err = out.MarshalJSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, string(data), string(outData))
+
+	kt.Conditions = &KeyTripleConditions{}
+	assert.EqualError(t, kt.Valid(), "conditions validation failed: no conditions set")
+
+	// the third element must be a conditions map
+	kt.Conditions = nil
+	data, err = kt.MarshalCBOR()
+	require.NoError(t, err)
+
+	data = append([]byte{0x83}, append(data[1:], 0x01)...)
+	err = out.UnmarshalCBOR(data)
+	assert.ErrorContains(t, err, "conditions: ")
+}
+
+type testReservedKeyTripleExtensions struct {
+	Purpose *string `cbor:"1,keyasint,omitempty" json:"purpose,omitempty"`
+}
+
+func TestKeyTriple_RegisterExtensions_reserved_keys(t *testing.T) {
+	var kt KeyTriple
+
+	err := kt.RegisterExtensions(extensions.NewMap().
+		Add(ExtKeyTriple, &testReservedKeyTripleExtensions{}))
+	assert.EqualError(t, err,
+		`key triple extension field "Purpose" uses key 1 of the key-triple-conditions-map`)
+	assert.False(t, kt.HaveExtensions())
+
+	triples := Triples{}
+	err = triples.RegisterExtensions(extensions.NewMap().
+		Add(ExtAttestVerifKey, &testReservedKeyTripleExtensions{}))
+	assert.ErrorContains(t, err, "uses key 1 of the key-triple-conditions-map")
+
+	assert.NoError(t, kt.RegisterExtensions(extensions.NewMap().
+		Add(ExtKeyTriple, &testKeyTripleExtensions{})))
+}
@@ -4,8 +4,7 @@
 package comid
 
 import (
-	"fmt"
-
+	"github.com/jraman567/corim/validation"
 	"github.com/veraison/swid"
 )
 
@@ -41,11 +40,11 @@ func (o *LinkedTag) SetRel(r Rel) *LinkedTag {
 
 func (o LinkedTag) Valid() error {
 	if o.LinkedTagID == (swid.TagID{}) {
-		return fmt.Errorf("tag-id must be set in linked-tag")
+		return validation.NewAt("target", validation.CodeMissing, "tag-id must be set in linked-tag")
 	}
 
 	if err := o.Rel.Valid(); err != nil {
-		return fmt.Errorf("rel validation failed: %w", err)
+		return validation.Errorf("rel", "rel validation failed: %w", err)
 	}
 
 	return nil
@@ -69,7 +68,7 @@ func (o *LinkedTags) AddLinkedTag(lt LinkedTag) *LinkedTags {
 func (o LinkedTags) Valid() error {
 	for i, l := range o {
 		if err := l.Valid(); err != nil {
-			return fmt.Errorf("invalid linked-tag entry at index %d: %w", i, err)
+			return validation.Errorf(validation.Index(i), "invalid linked-tag entry at index %d: %w", i, err)
 		}
 	}
 
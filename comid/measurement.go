@@ -12,6 +12,7 @@ import (
 
 	"github.com/jraman567/corim/encoding"
 	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 	"github.com/veraison/eat"
 	"github.com/veraison/swid"
 	"github.com/virtee/sev-snp-measure-go/ovmf"
@@ -28,7 +29,13 @@ type Mkey struct {
 
 // NewMkey creates a new Mkey of the specfied type using the provided value.
 func NewMkey(val any, typ string) (*Mkey, error) {
-	factory, ok := mkeyValueRegister[typ]
+	return DefaultRegistry.NewMkey(val, typ)
+}
+
+// NewMkey is like the package-level NewMkey, but uses the type choices
+// registered with the target Registry.
+func (o *Registry) NewMkey(val any, typ string) (*Mkey, error) {
+	factory, ok := lookupTypeChoice(o, o.mkeys, typ)
 	if !ok {
 		return nil, fmt.Errorf("unexpected measurement key type: %q", typ)
 	}
@@ -61,11 +68,11 @@ func (o Mkey) Type() string {
 // if it is not.
 func (o Mkey) Valid() error {
 	if o.Value == nil {
-		return errors.New("Mkey value not set")
+		return validation.New(validation.CodeMissing, "Mkey value not set")
 	}
 
 	if err := o.Value.Valid(); err != nil {
-		return fmt.Errorf("invalid %s: %w", o.Value.Type(), err)
+		return validation.Errorf("", "invalid %s: %w", o.Value.Type(), err)
 	}
 
 	return nil
@@ -128,13 +135,18 @@ func (o Mkey) GetKeyUint() (uint64, error) {
 //	uuid: standard UUID string representation, e.g. "550e8400-e29b-41d4-a716-446655440000"
 //	psa.refval-id: JSON representation of the PSA refval-id
 func (o *Mkey) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Mkey) DecodeJSON(d *encoding.Decoder, data []byte) error {
 	var tnv encoding.TypeAndValue
 
 	if err := json.Unmarshal(data, &tnv); err != nil {
 		return err
 	}
 
-	decoded, err := NewMkey(nil, tnv.Type)
+	decoded, err := RegistryFrom(d.Registry).NewMkey(nil, tnv.Type)
 	if err != nil {
 		return err
 	}
@@ -169,24 +181,34 @@ func (o Mkey) MarshalJSON() ([]byte, error) {
 
 // MarshalCBOR serializes the taret mkey into  CBOR-encoded bytes.
 func (o Mkey) MarshalCBOR() ([]byte, error) {
-	return em.Marshal(o.Value)
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o Mkey) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return encModeFor(e).Marshal(o.Value)
 }
 
 // UnmarshalCBOR deserializes the Mkey from the provided CBOR bytes.
 func (o *Mkey) UnmarshalCBOR(data []byte) error {
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *Mkey) DecodeCBOR(d *encoding.Decoder, data []byte) error {
 	if len(data) == 0 {
 		return errors.New("empty input")
 	}
 
 	majorType := (data[0] & 0xe0) >> 5
 	if majorType == 6 { // tag
-		return dm.Unmarshal(data, &o.Value)
+		return decModeFor(d).Unmarshal(data, &o.Value)
 	}
 
 	// untagged value must be a uint
 
 	var val UintMkey
-	if err := dm.Unmarshal(data, &val); err != nil {
+	if err := decModeFor(d).Unmarshal(data, &val); err != nil {
 		return err
 	}
 
@@ -311,7 +333,8 @@ func NewMkeyCCAPlatformConfigID(val any) (*Mkey, error) {
 // See also https://go.dev/ref/spec#The_zero_value
 type IMkeyFactory = func(val any) (*Mkey, error)
 
-var mkeyValueRegister = map[string]IMkeyFactory{
+// builtinMkeyTypes contains the measurement key types every Registry starts with
+var builtinMkeyTypes = map[string]IMkeyFactory{
 	OIDType:                 NewMkeyOID,
 	UUIDType:                NewMkeyUUID,
 	UintType:                NewMkeyUint,
@@ -322,24 +345,18 @@ var mkeyValueRegister = map[string]IMkeyFactory{
 // RegisterMkeyType registers a new IMKeyValue implementation
 // (created by the provided IMKeyFactory) under the specified CBOR tag.
 func RegisterMkeyType(tag uint64, factory IMkeyFactory) error {
+	return DefaultRegistry.RegisterMkeyType(tag, factory)
+}
 
+// RegisterMkeyType is like the package-level RegisterMkeyType, but
+// registers with the target Registry.
+func (o *Registry) RegisterMkeyType(tag uint64, factory IMkeyFactory) error {
 	nilVal, err := factory(nil)
 	if err != nil {
 		return err
 	}
 
-	typ := nilVal.Value.Type()
-	if _, exists := mkeyValueRegister[typ]; exists {
-		return fmt.Errorf("measurement key type with name %q already exists", typ)
-	}
-
-	if err := registerCOMIDTag(tag, nilVal.Value); err != nil {
-		return err
-	}
-
-	mkeyValueRegister[typ] = factory
-
-	return nil
+	return registerTypeChoice(o, o.mkeys, "measurement key", nilVal.Value.Type(), tag, nilVal.Value, factory)
 }
 
 // Mval stores a measurement-values-map with JSON and CBOR serializations.
@@ -387,11 +404,21 @@ func (o *Mval) GetExtensions() extensions.IMapValue {
 
 // UnmarshalCBOR deserializes from CBOR
 func (o *Mval) UnmarshalCBOR(data []byte) error {
-	return encoding.PopulateStructFromCBOR(dm, data, o)
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *Mval) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromCBOR(decModeFor(d), data, o)
 }
 
 // MarshalCBOR serializes to CBOR
 func (o Mval) MarshalCBOR() ([]byte, error) {
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o Mval) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
 	// If extensions have been registered, the collection will exist, but
 	// might be empty. If that is the case, set it to nil to avoid
 	// marshaling an empty list (and let the marshaller omit the claim
@@ -402,16 +429,26 @@ func (o Mval) MarshalCBOR() ([]byte, error) {
 		o.Flags = nil
 	}
 
-	return encoding.SerializeStructToCBOR(em, o)
+	return e.SerializeStructToCBOR(encModeFor(e), o)
 }
 
 // UnmarshalJSON deserializes from JSON
 func (o *Mval) UnmarshalJSON(data []byte) error {
-	return encoding.PopulateStructFromJSON(data, o)
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Mval) DecodeJSON(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromJSON(data, o)
 }
 
 // MarshalJSON serializes to JSON
 func (o Mval) MarshalJSON() ([]byte, error) {
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o Mval) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
 	// If extensions have been registered, the collection will exist, but
 	// might be empty. If that is the case, set it to nil to avoid
 	// marshaling an empty list (and let the marshaller omit the claim
@@ -422,7 +459,7 @@ func (o Mval) MarshalJSON() ([]byte, error) {
 		o.Flags = nil
 	}
 
-	return encoding.SerializeStructToJSON(o)
+	return e.SerializeStructToJSON(o)
 }
 
 func (o Mval) Valid() error {
@@ -439,24 +476,30 @@ func (o Mval) Valid() error {
 		o.UUID == nil &&
 		o.IntegrityRegisters == nil &&
 		o.OvmfMetadata == nil {
-		return fmt.Errorf("no measurement value set")
+		return validation.New(validation.CodeEmpty, "no measurement value set")
 	}
 
 	if o.Ver != nil {
 		if err := o.Ver.Valid(); err != nil {
-			return err
+			return validation.Errorf("version", "%w", err)
 		}
 	}
 
 	if o.Digests != nil {
 		if err := o.Digests.Valid(); err != nil {
-			return err
+			return validation.Errorf("digests", "%w", err)
 		}
 	}
 
 	if o.Flags != nil {
 		if err := o.Flags.Valid(); err != nil {
-			return err
+			return validation.Errorf("flags", "%w", err)
+		}
+	}
+
+	if o.IntegrityRegisters != nil {
+		if err := o.IntegrityRegisters.Valid(); err != nil {
+			return validation.Errorf("integrity-registers", "integrity registers: %w", err)
 		}
 	}
 
@@ -495,7 +538,7 @@ func (o *Version) SetScheme(v int64) *Version {
 
 func (o Version) Valid() error {
 	if o.Version == "" {
-		return fmt.Errorf("empty version")
+		return validation.NewAt("value", validation.CodeEmpty, "empty version")
 	}
 	return nil
 }
@@ -508,7 +551,7 @@ type Measurement struct {
 }
 
 func NewMeasurement(val any, typ string) (*Measurement, error) {
-	keyFactory, ok := mkeyValueRegister[typ]
+	keyFactory, ok := lookupTypeChoice(DefaultRegistry, DefaultRegistry.mkeys, typ)
 	if !ok {
 		return nil, fmt.Errorf("unknown Mkey type: %s", typ)
 	}
@@ -770,9 +813,13 @@ func (o *Measurement) SetUUID(u UUID) *Measurement {
 func (o Measurement) Valid() error {
 	if o.Key != nil && o.Key.IsSet() {
 		if err := o.Key.Valid(); err != nil {
-			return err
+			return validation.Errorf("key", "%w", err)
 		}
 	}
 
-	return o.Val.Valid()
+	if err := o.Val.Valid(); err != nil {
+		return validation.Errorf("value", "%w", err)
+	}
+
+	return nil
 }
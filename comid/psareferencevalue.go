@@ -6,6 +6,8 @@ package comid
 import (
 	"encoding/json"
 	"fmt"
+
+	"github.com/jraman567/corim/validation"
 )
 
 var PSARefValIDType = "psa.refval-id"
@@ -21,13 +23,13 @@ type PSARefValID struct {
 // Valid checks the validity (according to the spec) of the target PSARefValID
 func (o PSARefValID) Valid() error {
 	if o.SignerID == nil {
-		return fmt.Errorf("missing mandatory signer ID")
+		return validation.NewAt("signer-id", validation.CodeMissing, "missing mandatory signer ID")
 	}
 
 	switch len(o.SignerID) {
 	case 32, 48, 64:
 	default:
-		return fmt.Errorf("want 32, 48 or 64 bytes, got %d", len(o.SignerID))
+		return validation.Errorf("signer-id", "want 32, 48 or 64 bytes, got %d", len(o.SignerID))
 	}
 
 	return nil
@@ -0,0 +1,355 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package comid
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	cbor "github.com/fxamacker/cbor/v2"
+	"github.com/jraman567/corim/encoding"
+)
+
+// Registry holds the type choice implementations, roles, rels and CBOR tags
+// known to the CoMID encoders and decoders. Registries are independent of each
+// other, so that, for example, a multi-tenant verifier may use a separate
+// Registry for each tenant. A Registry is safe for concurrent use.
+//
+// The package-level Register* and New* functions use DefaultRegistry. Another
+// Registry is selected for an encoding or decoding operation by passing
+// WithRegistry (or WithEncodeRegistry) to Comid.FromCBOR, Comid.FromJSON or
+// Comid.ToCBOR. The values decoded within that operation, including those of
+// nested types, are then created using that Registry.
+//
+// The selected Registry is passed on to the nested types by an
+// encoding.Decoder or encoding.Encoder (see encoding.ICBORDecodable). Nested
+// types (e.g. ClassID) marshaled or unmarshaled directly with the CBOR or JSON
+// library, rather than as part of one of the operations above, use
+// DefaultRegistry.
+type Registry struct {
+	mu sync.RWMutex
+
+	classIDs    map[string]IClassIDFactory
+	instances   map[string]IInstanceFactory
+	groups      map[string]IGroupFactory
+	mkeys       map[string]IMkeyFactory
+	cryptoKeys  map[string]ICryptoKeyFactory
+	svns        map[string]ISVNFactory
+	entityNames map[string]IEntityNameFactory
+
+	roleToString map[Role]string
+	stringToRole map[string]Role
+	relToString  map[Rel]string
+	stringToRel  map[string]Rel
+
+	tags map[uint64]interface{}
+	ems  *encoding.EncModes
+	dms  *encoding.DecModes
+}
+
+// DefaultRegistry is the Registry used unless another one is selected with
+// WithRegistry or WithEncodeRegistry
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns a new Registry containing only the type choice
+// implementations, roles, rels and CBOR tags defined by this package
+func NewRegistry() *Registry {
+	ret := &Registry{
+		classIDs:     copyMap(builtinClassIDTypes),
+		instances:    copyMap(builtinInstanceTypes),
+		groups:       copyMap(builtinGroupTypes),
+		mkeys:        copyMap(builtinMkeyTypes),
+		cryptoKeys:   copyMap(builtinCryptoKeyTypes),
+		svns:         copyMap(builtinSVNTypes),
+		entityNames:  copyMap(builtinEntityNameTypes),
+		roleToString: copyMap(builtinRoles),
+		stringToRole: invertMap(builtinRoles),
+		relToString:  copyMap(builtinRels),
+		stringToRel:  invertMap(builtinRels),
+		tags:         copyMap(comidTagsMap),
+	}
+
+	if err := ret.initModes(); err != nil {
+		// the built-in tags are known to be good
+		panic(err)
+	}
+
+	return ret
+}
+
+// Clone returns a new Registry containing everything registered with the
+// target Registry so far. Further registrations with either do not affect the
+// other.
+func (o *Registry) Clone() *Registry {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return &Registry{
+		classIDs:     copyMap(o.classIDs),
+		instances:    copyMap(o.instances),
+		groups:       copyMap(o.groups),
+		mkeys:        copyMap(o.mkeys),
+		cryptoKeys:   copyMap(o.cryptoKeys),
+		svns:         copyMap(o.svns),
+		entityNames:  copyMap(o.entityNames),
+		roleToString: copyMap(o.roleToString),
+		stringToRole: copyMap(o.stringToRole),
+		relToString:  copyMap(o.relToString),
+		stringToRel:  copyMap(o.stringToRel),
+		tags:         copyMap(o.tags),
+		// CBOR modes are immutable, so they can be shared until the
+		// next tag registration
+		ems: o.ems,
+		dms: o.dms,
+	}
+}
+
+// registerTag associates the type of t with the specified CBOR tag. The caller
+// must hold the write lock.
+func (o *Registry) registerTag(tag uint64, t interface{}) error {
+	if _, exists := o.tags[tag]; exists {
+		return fmt.Errorf("tag %d is already registered", tag)
+	}
+
+	o.tags[tag] = t
+
+	if err := o.initModes(); err != nil {
+		delete(o.tags, tag)
+		return err
+	}
+
+	return nil
+}
+
+func (o *Registry) initModes() error {
+	tags, err := tagSet(o.tags)
+	if err != nil {
+		return err
+	}
+
+	encModes, err := initCBOREncModes(tags)
+	if err != nil {
+		return err
+	}
+
+	decModes, err := initCBORDecModes(tags)
+	if err != nil {
+		return err
+	}
+
+	o.ems, o.dms = encModes, decModes
+
+	return nil
+}
+
+// encMode returns the EncMode selected by opts (see encoding.EncModes)
+func (o *Registry) encMode(opts encoding.EncodeOptions) cbor.EncMode {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.ems.EncMode(opts)
+}
+
+// decMode returns the DecMode selected by opts (see encoding.DecModes)
+func (o *Registry) decMode(opts encoding.DecodeOptions) cbor.DecMode {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.dms.DecMode(opts)
+}
+
+// IRegistryProvider is implemented by the registries of the packages built on
+// top of this one (e.g. corim.Registry) to provide the Registry to use for
+// CoMIDs
+type IRegistryProvider interface {
+	ComidRegistry() *Registry
+}
+
+// WithRegistry selects the Registry to decode with
+func WithRegistry(r *Registry) encoding.DecodeOption {
+	return func(o *encoding.DecodeOptions) {
+		o.Registry = r
+	}
+}
+
+// WithEncodeRegistry selects the Registry to encode with
+func WithEncodeRegistry(r *Registry) encoding.EncodeOption {
+	return func(o *encoding.EncodeOptions) {
+		o.Registry = r
+	}
+}
+
+// RegistryFrom returns the Registry selected by the Registry field of
+// encoding.DecodeOptions or encoding.EncodeOptions: either a *Registry or an
+// IRegistryProvider. DefaultRegistry is returned for anything else.
+func RegistryFrom(v any) *Registry {
+	switch t := v.(type) {
+	case *Registry:
+		if t != nil {
+			return t
+		}
+	case IRegistryProvider:
+		if r := t.ComidRegistry(); r != nil {
+			return r
+		}
+	}
+
+	return DefaultRegistry
+}
+
+// decModeFor returns the DecMode of the Registry selected by d, with the
+// options of d applied
+func decModeFor(d *encoding.Decoder) cbor.DecMode {
+	return RegistryFrom(d.Registry).decMode(d.DecodeOptions)
+}
+
+// encModeFor returns the EncMode of the Registry selected by e, with the
+// options of e applied
+func encModeFor(e *encoding.Encoder) cbor.EncMode {
+	return RegistryFrom(e.Registry).encMode(e.EncodeOptions)
+}
+
+// defaultEncMode is a cbor.EncMode using the CBOR tags of DefaultRegistry
+type defaultEncMode struct{}
+
+func (defaultEncMode) Marshal(v interface{}) ([]byte, error) {
+	return DefaultRegistry.encMode(encoding.EncodeOptions{}).Marshal(v)
+}
+
+func (defaultEncMode) NewEncoder(w io.Writer) *cbor.Encoder {
+	return DefaultRegistry.encMode(encoding.EncodeOptions{}).NewEncoder(w)
+}
+
+func (defaultEncMode) EncOptions() cbor.EncOptions {
+	return DefaultRegistry.encMode(encoding.EncodeOptions{}).EncOptions()
+}
+
+// defaultDecMode is a cbor.DecMode using the CBOR tags of DefaultRegistry
+type defaultDecMode struct{}
+
+func (defaultDecMode) Unmarshal(data []byte, v interface{}) error {
+	return DefaultRegistry.decMode(encoding.DecodeOptions{}).Unmarshal(data, v)
+}
+
+func (defaultDecMode) UnmarshalFirst(data []byte, v interface{}) ([]byte, error) {
+	return DefaultRegistry.decMode(encoding.DecodeOptions{}).UnmarshalFirst(data, v)
+}
+
+func (defaultDecMode) Valid(data []byte) error {
+	return DefaultRegistry.decMode(encoding.DecodeOptions{}).Valid(data)
+}
+
+func (defaultDecMode) Wellformed(data []byte) error {
+	return DefaultRegistry.decMode(encoding.DecodeOptions{}).Wellformed(data)
+}
+
+func (defaultDecMode) NewDecoder(r io.Reader) *cbor.Decoder {
+	return DefaultRegistry.decMode(encoding.DecodeOptions{}).NewDecoder(r)
+}
+
+func (defaultDecMode) DecOptions() cbor.DecOptions {
+	return DefaultRegistry.decMode(encoding.DecodeOptions{}).DecOptions()
+}
+
+// registerTypeChoice adds factory to the type choice register m under the
+// specified type name, and associates the type of nilValue with the specified
+// CBOR tag. what names the type choice in error messages.
+func registerTypeChoice[F any](
+	o *Registry, m map[string]F, what string, typ string, tag uint64, nilValue any, factory F,
+) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, exists := m[typ]; exists {
+		return fmt.Errorf("%s type with name %q already exists", what, typ)
+	}
+
+	if err := o.registerTag(tag, nilValue); err != nil {
+		return err
+	}
+
+	m[typ] = factory
+
+	return nil
+}
+
+// lookupTypeChoice returns the factory registered in m under the specified
+// type name
+func lookupTypeChoice[F any](o *Registry, m map[string]F, typ string) (F, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	factory, ok := m[typ]
+	return factory, ok
+}
+
+// registerName adds the association between val and name to the maps of a
+// Registry, e.g. roleToString and stringToRole. what names the association in
+// error messages.
+func registerName[V ~int64](
+	o *Registry, toString map[V]string, fromString map[string]V, what string, val V, name string,
+) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := toString[val]; ok {
+		return fmt.Errorf("%s with value %d already exists", what, int64(val))
+	}
+
+	if _, ok := fromString[name]; ok {
+		return fmt.Errorf("%s with name %q already exists", what, name)
+	}
+
+	toString[val] = name
+	fromString[name] = val
+
+	return nil
+}
+
+func lookupName[K comparable, V any](o *Registry, m map[K]V, key K) (V, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	val, ok := m[key]
+	return val, ok
+}
+
+func tagSet(tagsMap map[uint64]interface{}) (cbor.TagSet, error) {
+	opts := cbor.TagOptions{
+		EncTag: cbor.EncTagRequired,
+		DecTag: cbor.DecTagRequired,
+	}
+
+	tags := cbor.NewTagSet()
+
+	for tag, typ := range tagsMap {
+		if err := tags.Add(opts, reflect.TypeOf(typ), tag); err != nil {
+			return nil, err
+		}
+	}
+
+	return tags, nil
+}
+
+func copyMap[K comparable, V any](m map[K]V) map[K]V {
+	ret := make(map[K]V, len(m))
+
+	for k, v := range m {
+		ret[k] = v
+	}
+
+	return ret
+}
+
+func invertMap[K comparable, V comparable](m map[K]V) map[V]K {
+	ret := make(map[V]K, len(m))
+
+	for k, v := range m {
+		ret[v] = k
+	}
+
+	return ret
+}
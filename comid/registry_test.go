@@ -0,0 +1,265 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package comid
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/swid"
+)
+
+// registryTestClassID is like testClassID, but is not registered with
+// DefaultRegistry by any test
+type registryTestClassID [4]byte
+
+func newRegistryTestClassID(_ any) (*ClassID, error) {
+	return &ClassID{&registryTestClassID{0x74, 0x65, 0x73, 0x74}}, nil
+}
+
+func (o registryTestClassID) Bytes() []byte {
+	return o[:]
+}
+
+func (o registryTestClassID) Type() string {
+	return "registry-test-class-id"
+}
+
+func (o registryTestClassID) String() string {
+	return string(o[:])
+}
+
+func (o registryTestClassID) Valid() error {
+	return nil
+}
+
+func (o registryTestClassID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.String())
+}
+
+func (o *registryTestClassID) UnmarshalJSON(data []byte) error {
+	var out string
+	if err := json.Unmarshal(data, &out); err != nil {
+		return err
+	}
+
+	if len(out) != 4 {
+		return fmt.Errorf("bad registryTestClassID: decoded %d bytes, want 4", len(out))
+	}
+
+	copy((*o)[:], out)
+
+	return nil
+}
+
+// registryOtherClassID is registered by TestRegistry_concurrentTags with the
+// same CBOR tag as registryTestClassID, but in a different Registry
+type registryOtherClassID [8]byte
+
+func newRegistryOtherClassID(_ any) (*ClassID, error) {
+	return &ClassID{&registryOtherClassID{0x6f, 0x74, 0x68, 0x65, 0x72, 0x2d, 0x69, 0x64}}, nil
+}
+
+func (o registryOtherClassID) Bytes() []byte {
+	return o[:]
+}
+
+func (o registryOtherClassID) Type() string {
+	return "registry-other-class-id"
+}
+
+func (o registryOtherClassID) String() string {
+	return string(o[:])
+}
+
+func (o registryOtherClassID) Valid() error {
+	return nil
+}
+
+func testRegistryComid(t *testing.T, r *Registry) *Comid {
+	classID, err := r.NewClassID(nil, "registry-test-class-id")
+	require.NoError(t, err)
+
+	c := NewComid().
+		SetTagIdentity("test", 0).
+		AddReferenceValue(ValueTriple{
+			Environment: Environment{Class: &Class{ClassID: classID}},
+			Measurement: Measurement{Val: Mval{Ver: NewVersion().SetVersion("1.0").SetScheme(swid.VersionSchemeSemVer)}},
+		})
+	require.NotNil(t, c)
+
+	return c
+}
+
+func TestRegistry_isolation(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.RegisterClassIDType(99998, newRegistryTestClassID))
+
+	_, err := NewClassID(nil, "registry-test-class-id")
+	assert.EqualError(t, err, "unknown class id type: registry-test-class-id")
+
+	c := testRegistryComid(t, r)
+
+	data, err := c.ToCBOR(WithEncodeRegistry(r))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), string([]byte{0xda, 0x00, 0x01, 0x86, 0x9e})) // tag 99998
+
+	var out Comid
+	require.NoError(t, out.FromCBOR(data, WithRegistry(r)))
+	assert.Equal(t, "registry-test-class-id", out.Triples.ReferenceValues.Values[0].Environment.Class.ClassID.Type())
+
+	var outDefault Comid
+	assert.Error(t, outDefault.FromCBOR(data))
+
+	data, err = c.ToJSON()
+	require.NoError(t, err)
+
+	var outJSON Comid
+	require.NoError(t, outJSON.FromJSON(data, WithRegistry(r)))
+	assert.Equal(t, "registry-test-class-id", outJSON.Triples.ReferenceValues.Values[0].Environment.Class.ClassID.Type())
+
+	var outJSONDefault Comid
+	assert.ErrorContains(t, outJSONDefault.FromJSON(data), "unknown class id type: registry-test-class-id")
+}
+
+func TestRegistry_Clone(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.RegisterRole(100, "tester"))
+
+	clone := r.Clone()
+	require.NoError(t, clone.RegisterRole(101, "auditor"))
+	require.NoError(t, clone.RegisterClassIDType(99998, newRegistryTestClassID))
+
+	err := clone.RegisterRole(100, "tester")
+	assert.EqualError(t, err, "role with value 100 already exists")
+
+	require.NoError(t, r.RegisterRole(101, "reviewer"))
+
+	_, err = r.NewClassID(nil, "registry-test-class-id")
+	assert.EqualError(t, err, "unknown class id type: registry-test-class-id")
+
+	_, err = clone.NewClassID(nil, "registry-test-class-id")
+	assert.NoError(t, err)
+
+	err = clone.RegisterRel(int64(RelReplaces), "obsoletes")
+	assert.EqualError(t, err, "rel with value 1 already exists")
+}
+
+func TestRegistry_roles(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.RegisterRole(100, "tester"))
+
+	c := NewComid().
+		SetTagIdentity("test", 0).
+		AddEntity("ACME", nil, RoleCreator).
+		AddReferenceValue(ValueTriple{
+			Environment: Environment{Instance: MustNewUUIDInstance(TestUUID)},
+			Measurement: Measurement{Val: Mval{Ver: NewVersion().SetVersion("1.0").SetScheme(swid.VersionSchemeSemVer)}},
+		})
+	require.NotNil(t, c)
+
+	data, err := c.ToJSON()
+	require.NoError(t, err)
+	data = bytes.Replace(data, []byte(`"creator"`), []byte(`"tester"`), 1)
+
+	var out Comid
+	require.NoError(t, out.FromJSON(data, WithRegistry(r)))
+	assert.Equal(t, Roles{Role(100)}, out.Entities.Values[0].Roles)
+
+	cbor, err := out.ToCBOR(WithEncodeRegistry(r))
+	require.NoError(t, err)
+
+	var back Comid
+	require.NoError(t, back.FromCBOR(cbor, WithRegistry(r)))
+
+	var outDefault Comid
+	assert.ErrorContains(t, outDefault.FromJSON(data), `unknown role "tester"`)
+}
+
+func TestRegistry_concurrent(t *testing.T) {
+	registries := []*Registry{NewRegistry(), NewRegistry()}
+	for _, r := range registries {
+		require.NoError(t, r.RegisterClassIDType(99998, newRegistryTestClassID))
+	}
+
+	data, err := testRegistryComid(t, registries[0]).ToCBOR(WithEncodeRegistry(registries[0]))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 16; i++ {
+		r := registries[i%len(registries)]
+
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+
+			assert.NoError(t, r.RegisterRole(int64(100+i), fmt.Sprintf("role-%d", i)))
+			assert.NoError(t, r.RegisterRel(int64(100+i), fmt.Sprintf("rel-%d", i)))
+		}(i)
+
+		go func() {
+			defer wg.Done()
+
+			var out Comid
+			assert.NoError(t, out.FromCBOR(data, WithRegistry(r)))
+
+			var outDefault Comid
+			assert.Error(t, outDefault.FromCBOR(data))
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestRegistry_concurrentTags(t *testing.T) {
+	testReg := NewRegistry()
+	require.NoError(t, testReg.RegisterClassIDType(99998, newRegistryTestClassID))
+
+	otherReg := NewRegistry()
+	require.NoError(t, otherReg.RegisterClassIDType(99998, newRegistryOtherClassID))
+
+	testData, err := testRegistryComid(t, testReg).ToCBOR(WithEncodeRegistry(testReg))
+	require.NoError(t, err)
+
+	otherClassID, err := otherReg.NewClassID(nil, "registry-other-class-id")
+	require.NoError(t, err)
+
+	other := testRegistryComid(t, testReg)
+	other.Triples.ReferenceValues.Values[0].Environment.Class.ClassID = otherClassID
+
+	otherData, err := other.ToCBOR(WithEncodeRegistry(otherReg))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+
+	decode := func(data []byte, r *Registry, expected string) {
+		defer wg.Done()
+
+		for i := 0; i < 32; i++ {
+			var out Comid
+			if !assert.NoError(t, out.FromCBOR(data, WithRegistry(r))) {
+				return
+			}
+
+			classID := out.Triples.ReferenceValues.Values[0].Environment.Class.ClassID
+			assert.Equal(t, expected, classID.Type())
+		}
+	}
+
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+
+		go decode(testData, testReg, "registry-test-class-id")
+		go decode(otherData, otherReg, "registry-other-class-id")
+	}
+
+	wg.Wait()
+}
@@ -5,8 +5,10 @@ package comid
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
+
+	"github.com/jraman567/corim/encoding"
+	"github.com/jraman567/corim/validation"
 )
 
 type Rel int64
@@ -18,35 +20,22 @@ const (
 	RelUnset = ^Rel(0)
 )
 
-var (
-	relToString = map[Rel]string{
-		RelReplaces:    "replaces",
-		RelSupplements: "supplements",
-	}
-
-	stringToRel = map[string]Rel{
-		"replaces":    RelReplaces,
-		"supplements": RelSupplements,
-	}
-)
+// builtinRels contains the rels every Registry starts with
+var builtinRels = map[Rel]string{
+	RelReplaces:    "replaces",
+	RelSupplements: "supplements",
+}
 
 // RegisterRel creates a new Rel association between the provided value and
 // name. An error is returned if either clashes with any of the existing roles.
 func RegisterRel(val int64, name string) error {
-	rel := Rel(val)
-
-	if _, ok := relToString[rel]; ok {
-		return fmt.Errorf("rel with value %d already exists", val)
-	}
-
-	if _, ok := stringToRel[name]; ok {
-		return fmt.Errorf("rel with name %q already exists", name)
-	}
-
-	relToString[rel] = name
-	stringToRel[name] = rel
+	return DefaultRegistry.RegisterRel(val, name)
+}
 
-	return nil
+// RegisterRel is like the package-level RegisterRel, but registers with the
+// target Registry.
+func (o *Registry) RegisterRel(val int64, name string) error {
+	return registerName(o, o.relToString, o.stringToRel, "rel", Rel(val), name)
 }
 
 func NewRel() *Rel {
@@ -67,14 +56,21 @@ func (o Rel) Get() Rel {
 
 func (o Rel) Valid() error {
 	if o == RelUnset {
-		return errors.New("rel is unset")
+		return validation.New(validation.CodeMissing, "rel is unset")
 	}
 
 	return nil
 }
 
+// String returns the string representation of the Rel, as registered with
+// DefaultRegistry
 func (o Rel) String() string {
-	ret, ok := relToString[o]
+	return o.name(DefaultRegistry)
+}
+
+// name returns the string representation of the Rel, as registered with reg
+func (o Rel) name(reg *Registry) string {
+	ret, ok := lookupName(reg, reg.relToString, o)
 	if ok {
 		return ret
 	}
@@ -105,6 +101,11 @@ func (o *Rel) FromCBOR(data []byte) error {
 }
 
 func (o *Rel) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Rel) DecodeJSON(d *encoding.Decoder, data []byte) error {
 	var s string
 
 	if err := json.Unmarshal(data, &s); err != nil {
@@ -115,18 +116,23 @@ func (o *Rel) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("empty rel")
 	}
 
-	switch s {
-	case "supplements":
-		*o = RelSupplements
-	case "replaces":
-		*o = RelReplaces
-	default:
+	reg := RegistryFrom(d.Registry)
+
+	rel, ok := lookupName(reg, reg.stringToRel, s)
+	if !ok {
 		return fmt.Errorf("unknown rel '%s'", s)
 	}
 
+	*o = rel
+
 	return nil
 }
 
 func (o Rel) MarshalJSON() ([]byte, error) {
-	return json.Marshal(o.String())
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o Rel) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
+	return json.Marshal(o.name(RegistryFrom(e.Registry)))
 }
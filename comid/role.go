@@ -6,6 +6,9 @@ package comid
 import (
 	"encoding/json"
 	"fmt"
+
+	"github.com/jraman567/corim/encoding"
+	"github.com/jraman567/corim/validation"
 )
 
 type Role int64
@@ -26,23 +29,17 @@ const (
 	RoleMaintainer
 )
 
-var (
-	roleToString = map[Role]string{
-		RoleTagCreator: "tagCreator",
-		RoleCreator:    "creator",
-		RoleMaintainer: "maintainer",
-	}
-
-	stringToRole = map[string]Role{
-		"tagCreator": RoleTagCreator,
-		"creator":    RoleCreator,
-		"maintainer": RoleMaintainer,
-	}
-)
+// builtinRoles contains the roles every Registry starts with
+var builtinRoles = map[Role]string{
+	RoleTagCreator: "tagCreator",
+	RoleCreator:    "creator",
+	RoleMaintainer: "maintainer",
+}
 
-// String returns the string representation of the Role.
+// String returns the string representation of the Role, as registered with
+// DefaultRegistry
 func (o Role) String() string {
-	text, ok := roleToString[o]
+	text, ok := lookupName(DefaultRegistry, DefaultRegistry.roleToString, o)
 	if ok {
 		return text
 	}
@@ -53,20 +50,13 @@ func (o Role) String() string {
 // RegisterRole creates a new Role association between the provided value and
 // name. An error is returned if either clashes with any of the existing roles.
 func RegisterRole(val int64, name string) error {
-	role := Role(val)
-
-	if _, ok := roleToString[role]; ok {
-		return fmt.Errorf("role with value %d already exists", val)
-	}
-
-	if _, ok := stringToRole[name]; ok {
-		return fmt.Errorf("role with name %q already exists", name)
-	}
-
-	roleToString[role] = name
-	stringToRole[name] = role
+	return DefaultRegistry.RegisterRole(val, name)
+}
 
-	return nil
+// RegisterRole is like the package-level RegisterRole, but registers with the
+// target Registry.
+func (o *Registry) RegisterRole(val int64, name string) error {
+	return registerName(o, o.roleToString, o.stringToRole, "role", Role(val), name)
 }
 
 type Roles []Role
@@ -85,7 +75,7 @@ func (o *Roles) Add(roles ...Role) *Roles {
 
 func (o Roles) Valid() error {
 	if len(o) == 0 {
-		return fmt.Errorf("empty roles")
+		return validation.New(validation.CodeEmpty, "empty roles")
 	}
 
 	return nil
@@ -114,6 +104,11 @@ func (o *Roles) FromCBOR(data []byte) error {
 }
 
 func (o *Roles) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Roles) DecodeJSON(d *encoding.Decoder, data []byte) error {
 	var a []string
 
 	if err := json.Unmarshal(data, &a); err != nil {
@@ -124,8 +119,10 @@ func (o *Roles) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("no roles found")
 	}
 
+	reg := RegistryFrom(d.Registry)
+
 	for _, s := range a {
-		r, ok := stringToRole[s]
+		r, ok := lookupName(reg, reg.stringToRole, s)
 		if !ok {
 			return fmt.Errorf("unknown role %q", s)
 		}
@@ -136,10 +133,17 @@ func (o *Roles) UnmarshalJSON(data []byte) error {
 }
 
 func (o Roles) MarshalJSON() ([]byte, error) {
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o Roles) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
 	roles := []string{}
 
+	reg := RegistryFrom(e.Registry)
+
 	for _, r := range o {
-		s, ok := roleToString[r]
+		s, ok := lookupName(reg, reg.roleToString, r)
 		if !ok {
 			return nil, fmt.Errorf("unknown role %d", r)
 		}
@@ -22,7 +22,13 @@ type SVN struct {
 // the strings defined by the spec ("exact-value", "min-value"), or has been
 // registered with RegisterSVNType().
 func NewSVN(val any, typ string) (*SVN, error) {
-	factory, ok := svnValueRegister[typ]
+	return DefaultRegistry.NewSVN(val, typ)
+}
+
+// NewSVN is like the package-level NewSVN, but uses the type choices
+// registered with the target Registry.
+func (o *Registry) NewSVN(val any, typ string) (*SVN, error) {
+	factory, ok := lookupTypeChoice(o, o.svns, typ)
 	if !ok {
 		return nil, fmt.Errorf("unknown SVN type: %s", typ)
 	}
@@ -43,12 +49,22 @@ func MustNewSVN(val any, typ string) *SVN {
 
 // MarshalCBOR returns the CBOR encoding of the SVN.
 func (o SVN) MarshalCBOR() ([]byte, error) {
-	return em.Marshal(o.Value)
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o SVN) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return encModeFor(e).Marshal(o.Value)
 }
 
 // UnmarshalCBOR populates the SVN form the provided CBOR bytes.
 func (o *SVN) UnmarshalCBOR(data []byte) error {
-	return dm.Unmarshal(data, &o.Value)
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *SVN) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return decModeFor(d).Unmarshal(data, &o.Value)
 }
 
 // UnmarshalJSON deserializes the supplied JSON object into the target SVN
@@ -65,13 +81,18 @@ func (o *SVN) UnmarshalCBOR(data []byte) error {
 // class id value. The exact encoding is <SVN_TYPE> dependent. For both base
 // types, it is an integer (JSON number).
 func (o *SVN) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *SVN) DecodeJSON(d *encoding.Decoder, data []byte) error {
 	var tnv encoding.TypeAndValue
 
 	if err := json.Unmarshal(data, &tnv); err != nil {
 		return fmt.Errorf("SVN decoding failure: %w", err)
 	}
 
-	decoded, err := NewSVN(nil, tnv.Type)
+	decoded, err := RegistryFrom(d.Registry).NewSVN(nil, tnv.Type)
 	if err != nil {
 		return err
 	}
@@ -238,7 +259,8 @@ func (o TaggedMinSVN) Valid() error {
 // See also https://go.dev/ref/spec#The_zero_value
 type ISVNFactory func(any) (*SVN, error)
 
-var svnValueRegister = map[string]ISVNFactory{
+// builtinSVNTypes contains the SVN types every Registry starts with
+var builtinSVNTypes = map[string]ISVNFactory{
 	ExactValueType: NewTaggedSVN,
 	MinValueType:   NewTaggedMinSVN,
 }
@@ -246,22 +268,16 @@ var svnValueRegister = map[string]ISVNFactory{
 // RegisterSVNType registers a new ISVNValue implementation
 // (created by the provided ISVNFactory) under the specified CBOR tag.
 func RegisterSVNType(tag uint64, factory ISVNFactory) error {
+	return DefaultRegistry.RegisterSVNType(tag, factory)
+}
 
+// RegisterSVNType is like the package-level RegisterSVNType, but
+// registers with the target Registry.
+func (o *Registry) RegisterSVNType(tag uint64, factory ISVNFactory) error {
 	nilVal, err := factory(nil)
 	if err != nil {
 		return err
 	}
 
-	typ := nilVal.Value.Type()
-	if _, exists := svnValueRegister[typ]; exists {
-		return fmt.Errorf("SVN type with name %q already exists", typ)
-	}
-
-	if err := registerCOMIDTag(tag, nilVal.Value); err != nil {
-		return err
-	}
-
-	svnValueRegister[typ] = factory
-
-	return nil
+	return registerTypeChoice(o, o.svns, "SVN", nilVal.Value.Type(), tag, nilVal.Value, factory)
 }
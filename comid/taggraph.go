@@ -0,0 +1,346 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package comid
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jraman567/corim/validation"
+	"github.com/veraison/swid"
+)
+
+// ErrTagCycle is returned when the linked tags of a TagGraph form a cycle
+var ErrTagCycle = errors.New("linked tags cycle")
+
+// TagLink is a linked-tag relation between two CoMIDs in a TagGraph, read as
+// "From Rel To", e.g., "B replaces A"
+type TagLink struct {
+	From swid.TagID
+	To   swid.TagID
+	Rel  Rel
+}
+
+// String returns a printable representation of the TagLink
+func (o TagLink) String() string {
+	return fmt.Sprintf("%s %s %s", o.From, o.Rel, o.To)
+}
+
+// Supersession explains why a tag is no longer active
+type Supersession struct {
+	// TagID identifies the superseded tag
+	TagID swid.TagID
+	// By identifies the tag that caused the supersession: the replacing
+	// tag or, for a supplement, the superseded tag it supplements
+	By swid.TagID
+	// Rel is the relation between TagID and By: RelReplaces if By
+	// replaces TagID, RelSupplements if TagID supplements By
+	Rel Rel
+}
+
+// String returns a printable representation of the Supersession
+func (o Supersession) String() string {
+	if o.Rel == RelSupplements {
+		return fmt.Sprintf("%s supplements %s, which is superseded", o.TagID, o.By)
+	}
+
+	return fmt.Sprintf("%s is replaced by %s", o.TagID, o.By)
+}
+
+// TagGraph builds the linked-tag relations among a set of CoMIDs, and computes
+// which of them are active once "replaces" relations are applied. A tag is
+// superseded if it is replaced by another tag in the graph, or if it only
+// supplements tags that are superseded. Tags are identified by the type and
+// the value of their tag-id, so that a text tag-id never matches a UUID one,
+// even if they print the same.
+type TagGraph struct {
+	order []swid.TagID
+	tags  map[swid.TagID]*Comid
+	links []TagLink
+}
+
+// NewTagGraph instantiates an empty TagGraph
+func NewTagGraph() *TagGraph {
+	return &TagGraph{tags: make(map[swid.TagID]*Comid)}
+}
+
+// NewTagGraphFromComids instantiates a TagGraph containing the supplied
+// CoMIDs
+func NewTagGraphFromComids(comids ...*Comid) (*TagGraph, error) {
+	g := NewTagGraph()
+
+	for i, c := range comids {
+		if err := g.AddComid(c); err != nil {
+			return nil, fmt.Errorf("comid at index %d: %w", i, err)
+		}
+	}
+
+	return g, nil
+}
+
+// AddComid adds the supplied CoMID, and its linked tags, to the graph. Each
+// tag-id can only be added once.
+func (o *TagGraph) AddComid(c *Comid) error {
+	if c == nil {
+		return errors.New("nil comid")
+	}
+
+	if err := c.TagIdentity.Valid(); err != nil {
+		return fmt.Errorf("tag-identity validation failed: %w", err)
+	}
+
+	id := c.TagIdentity.TagID
+
+	if _, exists := o.tags[id]; exists {
+		return fmt.Errorf("duplicate tag-id %q", id)
+	}
+
+	if c.LinkedTags != nil {
+		if err := c.LinkedTags.Valid(); err != nil {
+			return fmt.Errorf("linked-tags validation failed: %w", err)
+		}
+
+		for _, lt := range *c.LinkedTags {
+			o.links = append(o.links, TagLink{
+				From: id,
+				To:   lt.LinkedTagID,
+				Rel:  lt.Rel,
+			})
+		}
+	}
+
+	o.tags[id] = c
+	o.order = append(o.order, id)
+
+	return nil
+}
+
+// Get returns the CoMID with the supplied tag-id
+func (o TagGraph) Get(tagID swid.TagID) (*Comid, bool) {
+	c, ok := o.tags[tagID]
+	return c, ok
+}
+
+// Links returns all the linked-tag relations in the graph, including dangling
+// ones
+func (o TagGraph) Links() []TagLink {
+	return append([]TagLink(nil), o.links...)
+}
+
+// Dangling returns the linked-tag relations whose target is not in the graph
+func (o TagGraph) Dangling() []TagLink {
+	var ret []TagLink
+
+	for _, l := range o.links {
+		if _, ok := o.tags[l.To]; !ok {
+			ret = append(ret, l)
+		}
+	}
+
+	return ret
+}
+
+// Cycles returns the cycles formed by the linked-tag relations in the graph,
+// each one as the sequence of the tag-ids involved
+func (o TagGraph) Cycles() [][]swid.TagID {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+
+	state := make(map[swid.TagID]int)
+	var stack []swid.TagID
+	var ret [][]swid.TagID
+
+	var visit func(id swid.TagID)
+	visit = func(id swid.TagID) {
+		state[id] = inProgress
+		stack = append(stack, id)
+
+		for _, l := range o.links {
+			if l.From != id {
+				continue
+			}
+
+			if _, ok := o.tags[l.To]; !ok {
+				continue
+			}
+
+			switch state[l.To] {
+			case unvisited:
+				visit(l.To)
+			case inProgress:
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == l.To {
+						ret = append(ret, append([]swid.TagID(nil), stack[i:]...))
+						break
+					}
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[id] = done
+	}
+
+	for _, id := range o.order {
+		if state[id] == unvisited {
+			visit(id)
+		}
+	}
+
+	return ret
+}
+
+// Valid checks that the graph has no dangling references and no cycles
+func (o TagGraph) Valid() error {
+	if dangling := o.Dangling(); len(dangling) > 0 {
+		return validation.New(validation.CodeMissing, "dangling linked-tag: %s", dangling[0])
+	}
+
+	if cycles := o.Cycles(); len(cycles) > 0 {
+		return validation.Wrap(cycleError(cycles[0]))
+	}
+
+	return nil
+}
+
+// Superseded returns the tags that are no longer active, keyed by tag-id,
+// along with the reason for their supersession. Dangling references are
+// ignored.
+func (o TagGraph) Superseded() map[swid.TagID]Supersession {
+	ret := make(map[swid.TagID]Supersession)
+
+	for _, l := range o.links {
+		if l.Rel != RelReplaces {
+			continue
+		}
+
+		if _, ok := o.tags[l.To]; !ok {
+			continue
+		}
+
+		if _, ok := ret[l.To]; !ok {
+			ret[l.To] = Supersession{TagID: l.To, By: l.From, Rel: RelReplaces}
+		}
+	}
+
+	// a supplement that only supplements superseded tags is superseded as
+	// well; iterate until a fixed point is reached, as supplements can be
+	// chained
+	for changed := true; changed; {
+		changed = false
+
+		for _, id := range o.order {
+			if _, ok := ret[id]; ok {
+				continue
+			}
+
+			var targets []swid.TagID
+
+			for _, l := range o.links {
+				if l.From != id || l.Rel != RelSupplements {
+					continue
+				}
+
+				if _, ok := o.tags[l.To]; ok {
+					targets = append(targets, l.To)
+				}
+			}
+
+			if len(targets) == 0 {
+				continue
+			}
+
+			allSuperseded := true
+			for _, t := range targets {
+				if _, ok := ret[t]; !ok {
+					allSuperseded = false
+					break
+				}
+			}
+
+			if allSuperseded {
+				ret[id] = Supersession{TagID: id, By: targets[0], Rel: RelSupplements}
+				changed = true
+			}
+		}
+	}
+
+	return ret
+}
+
+// IsActive reports whether the tag with the supplied tag-id is in the graph and
+// has not been superseded
+func (o TagGraph) IsActive(tagID swid.TagID) bool {
+	if _, ok := o.tags[tagID]; !ok {
+		return false
+	}
+
+	_, superseded := o.Superseded()[tagID]
+
+	return !superseded
+}
+
+// Active returns the CoMIDs that are still active once "replaces" relations
+// are applied, in the order in which they were added to the graph. An error
+// is returned if the linked tags form a cycle, since in that case the active
+// set is ill-defined.
+func (o TagGraph) Active() ([]*Comid, error) {
+	if cycles := o.Cycles(); len(cycles) > 0 {
+		return nil, cycleError(cycles[0])
+	}
+
+	superseded := o.Superseded()
+
+	var ret []*Comid
+
+	for _, id := range o.order {
+		if _, ok := superseded[id]; !ok {
+			ret = append(ret, o.tags[id])
+		}
+	}
+
+	return ret, nil
+}
+
+// Explain returns a human-readable explanation of the status of the tag with
+// the supplied tag-id, following the chain of supersessions up to an active
+// tag
+func (o TagGraph) Explain(tagID swid.TagID) string {
+	if _, ok := o.tags[tagID]; !ok {
+		return fmt.Sprintf("%s is not in the graph", tagID)
+	}
+
+	superseded := o.Superseded()
+
+	var steps []string
+	seen := make(map[swid.TagID]bool)
+
+	for id := tagID; !seen[id]; {
+		seen[id] = true
+
+		s, ok := superseded[id]
+		if !ok {
+			steps = append(steps, fmt.Sprintf("%s is active", id))
+			break
+		}
+
+		steps = append(steps, s.String())
+		id = s.By
+	}
+
+	return strings.Join(steps, "; ")
+}
+
+func cycleError(cycle []swid.TagID) error {
+	ids := make([]string, 0, len(cycle))
+	for _, id := range cycle {
+		ids = append(ids, id.String())
+	}
+
+	return fmt.Errorf("%w: %s -> %s", ErrTagCycle, strings.Join(ids, " -> "), cycle[0])
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package comid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/swid"
+)
+
+func textTagID(id string) swid.TagID {
+	ret, err := swid.NewTagIDFromString(id)
+	if err != nil {
+		panic(err)
+	}
+
+	return *ret
+}
+
+func newLinkedComidWithTagID(id swid.TagID, links ...TagLink) *Comid {
+	c := NewComid()
+	c.TagIdentity.TagID = id
+
+	for _, l := range links {
+		if c.LinkedTags == nil {
+			c.LinkedTags = new(LinkedTags)
+		}
+
+		c.LinkedTags.AddLinkedTag(LinkedTag{LinkedTagID: l.To, Rel: l.Rel})
+	}
+
+	return c
+}
+
+func newLinkedComid(id string, links ...TagLink) *Comid {
+	return newLinkedComidWithTagID(textTagID(id), links...)
+}
+
+func replaces(id string) TagLink {
+	return TagLink{To: textTagID(id), Rel: RelReplaces}
+}
+
+func supplements(id string) TagLink {
+	return TagLink{To: textTagID(id), Rel: RelSupplements}
+}
+
+func activeIDs(t *testing.T, g *TagGraph) []string {
+	active, err := g.Active()
+	require.NoError(t, err)
+
+	ids := []string{}
+	for _, c := range active {
+		ids = append(ids, c.TagIdentity.TagID.String())
+	}
+
+	return ids
+}
+
+func TestTagGraph_Active_replaces(t *testing.T) {
+	g, err := NewTagGraphFromComids(
+		newLinkedComid("fw-v1"),
+		newLinkedComid("fw-v1-extra", supplements("fw-v1")),
+		newLinkedComid("fw-v2", replaces("fw-v1")),
+		newLinkedComid("platform"),
+	)
+	require.NoError(t, err)
+
+	assert.NoError(t, g.Valid())
+	assert.Equal(t, []string{"fw-v2", "platform"}, activeIDs(t, g))
+
+	assert.False(t, g.IsActive(textTagID("fw-v1")))
+	assert.False(t, g.IsActive(textTagID("fw-v1-extra")))
+	assert.True(t, g.IsActive(textTagID("fw-v2")))
+	assert.False(t, g.IsActive(textTagID("unknown")))
+
+	superseded := g.Superseded()
+	assert.Equal(t,
+		Supersession{TagID: textTagID("fw-v1"), By: textTagID("fw-v2"), Rel: RelReplaces},
+		superseded[textTagID("fw-v1")])
+	assert.Equal(t,
+		Supersession{TagID: textTagID("fw-v1-extra"), By: textTagID("fw-v1"), Rel: RelSupplements},
+		superseded[textTagID("fw-v1-extra")])
+
+	assert.Equal(t,
+		"fw-v1-extra supplements fw-v1, which is superseded; fw-v1 is replaced by fw-v2; fw-v2 is active",
+		g.Explain(textTagID("fw-v1-extra")))
+	assert.Equal(t, "platform is active", g.Explain(textTagID("platform")))
+	assert.Equal(t, "unknown is not in the graph", g.Explain(textTagID("unknown")))
+}
+
+func TestTagGraph_Active_replacement_chain(t *testing.T) {
+	g, err := NewTagGraphFromComids(
+		newLinkedComid("v1"),
+		newLinkedComid("v2", replaces("v1")),
+		newLinkedComid("v3", replaces("v2")),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"v3"}, activeIDs(t, g))
+	assert.Equal(t, "v1 is replaced by v2; v2 is replaced by v3; v3 is active", g.Explain(textTagID("v1")))
+}
+
+func TestTagGraph_supplement_of_active_and_superseded(t *testing.T) {
+	// a supplement remains active as long as one of its targets is active
+	g, err := NewTagGraphFromComids(
+		newLinkedComid("a"),
+		newLinkedComid("b"),
+		newLinkedComid("a2", replaces("a")),
+		newLinkedComid("s", supplements("a"), supplements("b")),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"b", "a2", "s"}, activeIDs(t, g))
+}
+
+func TestTagGraph_Dangling(t *testing.T) {
+	g, err := NewTagGraphFromComids(
+		newLinkedComid("a", replaces("missing")),
+		newLinkedComid("b", supplements("a")),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		[]TagLink{{From: textTagID("a"), To: textTagID("missing"), Rel: RelReplaces}},
+		g.Dangling())
+	assert.EqualError(t, g.Valid(), "dangling linked-tag: a replaces missing")
+
+	// dangling references do not affect the active set
+	assert.Equal(t, []string{"a", "b"}, activeIDs(t, g))
+	assert.Len(t, g.Links(), 2)
+}
+
+func TestTagGraph_Cycles(t *testing.T) {
+	g, err := NewTagGraphFromComids(
+		newLinkedComid("a", replaces("c")),
+		newLinkedComid("b", replaces("a")),
+		newLinkedComid("c", replaces("b")),
+		newLinkedComid("d", replaces("d")),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]swid.TagID{
+		{textTagID("a"), textTagID("c"), textTagID("b")},
+		{textTagID("d")},
+	}, g.Cycles())
+	assert.ErrorIs(t, g.Valid(), ErrTagCycle)
+
+	_, err = g.Active()
+	assert.EqualError(t, err, "linked tags cycle: a -> c -> b -> a")
+}
+
+func TestTagGraph_AddComid_fail(t *testing.T) {
+	g := NewTagGraph()
+
+	assert.EqualError(t, g.AddComid(nil), "nil comid")
+	assert.EqualError(t, g.AddComid(NewComid()), "tag-identity validation failed: empty tag-id")
+
+	require.NoError(t, g.AddComid(newLinkedComid("a")))
+	assert.EqualError(t, g.AddComid(newLinkedComid("a")), `duplicate tag-id "a"`)
+
+	_, err := NewTagGraphFromComids(newLinkedComid("x"), newLinkedComid("x"))
+	assert.EqualError(t, err, `comid at index 1: duplicate tag-id "x"`)
+
+	c, ok := g.Get(textTagID("a"))
+	assert.True(t, ok)
+	assert.Equal(t, "a", c.TagIdentity.TagID.String())
+}
+
+func TestTagGraph_text_and_UUID_tag_ids(t *testing.T) {
+	uuidID := *swid.NewTagID(TestUUIDString)
+	textID := textTagID(TestUUIDString)
+	require.Equal(t, uuidID.String(), textID.String())
+
+	// the tags print the same, but are different tags
+	g, err := NewTagGraphFromComids(
+		newLinkedComidWithTagID(uuidID),
+		newLinkedComidWithTagID(textID),
+		newLinkedComid("v2", TagLink{To: uuidID, Rel: RelReplaces}),
+	)
+	require.NoError(t, err)
+	assert.NoError(t, g.Valid())
+
+	assert.False(t, g.IsActive(uuidID))
+	assert.True(t, g.IsActive(textID))
+	assert.Equal(t, []string{TestUUIDString, "v2"}, activeIDs(t, g))
+
+	c, ok := g.Get(textID)
+	require.True(t, ok)
+	assert.Equal(t, textID, c.TagIdentity.TagID)
+}
@@ -4,8 +4,9 @@
 package comid
 
 import (
-	"fmt"
+	"strings"
 
+	"github.com/jraman567/corim/validation"
 	"github.com/veraison/swid"
 )
 
@@ -16,8 +17,63 @@ type TagIdentity struct {
 
 func (o TagIdentity) Valid() error {
 	if o.TagID == (swid.TagID{}) {
-		return fmt.Errorf("empty tag-id")
+		return validation.NewAt("id", validation.CodeEmpty, "empty tag-id")
 	}
 
 	return nil
 }
+
+// Compare orders TagIdentity values by tag-id first, then by tag-version. It
+// returns -1 if o sorts before other, +1 if it sorts after, and 0 if both
+// identify the same version of the same tag. Tag-ids are ordered by type
+// (text before UUID), then by value, so that a text tag-id never matches a
+// UUID one, even if they print the same.
+func (o TagIdentity) Compare(other TagIdentity) int {
+	if c := compareTagIDs(o.TagID, other.TagID); c != 0 {
+		return c
+	}
+
+	switch {
+	case o.TagVersion < other.TagVersion:
+		return -1
+	case o.TagVersion > other.TagVersion:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// SameTag reports whether o and other have the same tag-id, regardless of
+// their tag-version
+func (o TagIdentity) SameTag(other TagIdentity) bool {
+	return compareTagIDs(o.TagID, other.TagID) == 0
+}
+
+// Supersedes reports whether o is a later version of the same tag as other
+func (o TagIdentity) Supersedes(other TagIdentity) bool {
+	return o.SameTag(other) && o.TagVersion > other.TagVersion
+}
+
+func compareTagIDs(a, b swid.TagID) int {
+	switch ta, tb := tagIDTypeOrder(a), tagIDTypeOrder(b); {
+	case ta < tb:
+		return -1
+	case ta > tb:
+		return 1
+	}
+
+	// UUIDs print as fixed-length lowercase hex, so this also orders them
+	// by value
+	return strings.Compare(a.String(), b.String())
+}
+
+// tagIDTypeOrder returns 0 for text tag-ids and 1 for UUID ones. swid.TagID
+// does not expose its type, but only UUIDs have a URI that differs from
+// their string representation.
+func tagIDTypeOrder(id swid.TagID) int {
+	if id.URI() != id.String() {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package comid
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/veraison/swid"
+)
+
+func tagIdentity(id string, version uint) TagIdentity {
+	return TagIdentity{TagID: *swid.NewTagID(id), TagVersion: version}
+}
+
+func TestTagIdentity_Compare(t *testing.T) {
+	tvs := []struct {
+		a, b     TagIdentity
+		expected int
+	}{
+		{tagIdentity("a", 1), tagIdentity("a", 1), 0},
+		{tagIdentity("a", 1), tagIdentity("a", 2), -1},
+		{tagIdentity("a", 3), tagIdentity("a", 2), 1},
+		{tagIdentity("a", 9), tagIdentity("b", 0), -1},
+		{tagIdentity("b", 0), tagIdentity("a", 9), 1},
+	}
+
+	for _, tv := range tvs {
+		assert.Equal(t, tv.expected, tv.a.Compare(tv.b), "%v vs %v", tv.a, tv.b)
+	}
+
+	ids := []TagIdentity{tagIdentity("b", 1), tagIdentity("a", 2), tagIdentity("a", 0)}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Compare(ids[j]) < 0 })
+
+	assert.Equal(t, []TagIdentity{tagIdentity("a", 0), tagIdentity("a", 2), tagIdentity("b", 1)}, ids)
+}
+
+func TestTagIdentity_Supersedes(t *testing.T) {
+	assert.True(t, tagIdentity("a", 2).Supersedes(tagIdentity("a", 1)))
+	assert.False(t, tagIdentity("a", 1).Supersedes(tagIdentity("a", 1)))
+	assert.False(t, tagIdentity("a", 1).Supersedes(tagIdentity("a", 2)))
+	assert.False(t, tagIdentity("b", 2).Supersedes(tagIdentity("a", 1)))
+
+	uuidID := TagIdentity{TagID: *swid.NewTagID(TestUUID[:])}
+	assert.True(t, uuidID.SameTag(tagIdentity(TestUUIDString, 4)))
+}
+
+func TestTagIdentity_Compare_types(t *testing.T) {
+	textID, err := swid.NewTagIDFromString(TestUUIDString)
+	assert.NoError(t, err)
+
+	text := TagIdentity{TagID: *textID}
+	uuidID := TagIdentity{TagID: *swid.NewTagID(TestUUID[:])}
+
+	// same string representation, different types
+	assert.Equal(t, text.TagID.String(), uuidID.TagID.String())
+	assert.False(t, text.SameTag(uuidID))
+	assert.Equal(t, -1, text.Compare(uuidID))
+	assert.Equal(t, 1, uuidID.Compare(text))
+	assert.Equal(t, -1, tagIdentity("zzz", 0).Compare(uuidID))
+}
@@ -0,0 +1,173 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package comid
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jraman567/corim/encoding"
+	"github.com/veraison/swid"
+)
+
+var (
+	// ErrTagVersionRollback is returned when a tag is older than the known
+	// version of the same tag
+	ErrTagVersionRollback = errors.New("tag-version rollback")
+	// ErrTagVersionConflict is returned when a tag has the same tag-id and
+	// tag-version as a known tag, but different content
+	ErrTagVersionConflict = errors.New("conflicting content for the same tag-version")
+)
+
+// TagUpgrade is the outcome of successfully applying the tag upgrade rule
+type TagUpgrade int
+
+const (
+	// TagUpgradeNew means that no version of the tag was known
+	TagUpgradeNew TagUpgrade = iota
+	// TagUpgradeReplace means that the tag is a later version, which
+	// replaces the known one
+	TagUpgradeReplace
+	// TagUpgradeUnchanged means that the tag is identical to the known
+	// version
+	TagUpgradeUnchanged
+)
+
+// String returns a printable representation of the TagUpgrade
+func (o TagUpgrade) String() string {
+	switch o {
+	case TagUpgradeNew:
+		return "new"
+	case TagUpgradeReplace:
+		return "replace"
+	case TagUpgradeUnchanged:
+		return "unchanged"
+	default:
+		return fmt.Sprintf("TagUpgrade(%d)", int(o))
+	}
+}
+
+// CheckTagUpgrade applies the tag upgrade rule to an incoming tag, given the
+// latest known version of the same tag (nil if none), and the encoded content
+// of both:
+//
+//   - a tag with no known version is accepted as new;
+//   - a later tag-version replaces the known one;
+//   - the same tag-version is accepted as unchanged if the content is
+//     identical, and flagged with ErrTagVersionConflict otherwise;
+//   - an earlier tag-version is rejected with ErrTagVersionRollback.
+//
+// The rule only looks at tag identities and encoded content, and so it can be
+// applied to any kind of tag (CoMID, CoSWID, CoTS).
+func CheckTagUpgrade(
+	known *TagIdentity,
+	knownData []byte,
+	incoming TagIdentity,
+	incomingData []byte,
+) (TagUpgrade, error) {
+	if known == nil {
+		return TagUpgradeNew, nil
+	}
+
+	if !incoming.SameTag(*known) {
+		return 0, fmt.Errorf("tag-id mismatch: %q vs %q", incoming.TagID, known.TagID)
+	}
+
+	switch {
+	case incoming.TagVersion > known.TagVersion:
+		return TagUpgradeReplace, nil
+	case incoming.TagVersion < known.TagVersion:
+		return 0, fmt.Errorf("%w: %q version %d is older than known version %d",
+			ErrTagVersionRollback, incoming.TagID, incoming.TagVersion, known.TagVersion)
+	case !encoding.EqualCBOR(incomingData, knownData):
+		return 0, fmt.Errorf("%w: %q version %d",
+			ErrTagVersionConflict, incoming.TagID, incoming.TagVersion)
+	default:
+		return TagUpgradeUnchanged, nil
+	}
+}
+
+type versionedTag struct {
+	id   TagIdentity
+	data []byte
+}
+
+// TagVersions tracks the latest version of each tag in a set, enforcing the
+// tag upgrade rule (see CheckTagUpgrade) as tags are added. Tags are
+// identified by the type and the value of their tag-id, so that a text tag-id
+// and a UUID one that print the same are tracked separately.
+type TagVersions struct {
+	order  []swid.TagID
+	latest map[swid.TagID]versionedTag
+}
+
+// NewTagVersions instantiates an empty TagVersions
+func NewTagVersions() *TagVersions {
+	return &TagVersions{latest: make(map[swid.TagID]versionedTag)}
+}
+
+// Add applies the tag upgrade rule to the tag with the supplied identity and
+// encoded content. If the rule is satisfied, the tag becomes the latest known
+// version of its tag-id.
+func (o *TagVersions) Add(id TagIdentity, data []byte) (TagUpgrade, error) {
+	if err := id.Valid(); err != nil {
+		return 0, fmt.Errorf("tag-identity validation failed: %w", err)
+	}
+
+	key := id.TagID
+
+	var known *TagIdentity
+	var knownData []byte
+
+	if t, ok := o.latest[key]; ok {
+		known = &t.id
+		knownData = t.data
+	}
+
+	u, err := CheckTagUpgrade(known, knownData, id, data)
+	if err != nil {
+		return 0, err
+	}
+
+	if u == TagUpgradeNew {
+		o.order = append(o.order, key)
+	}
+
+	o.latest[key] = versionedTag{id: id, data: append([]byte(nil), data...)}
+
+	return u, nil
+}
+
+// AddComid encodes the supplied CoMID and adds it to the set (see Add)
+func (o *TagVersions) AddComid(c *Comid) (TagUpgrade, error) {
+	if c == nil {
+		return 0, errors.New("nil comid")
+	}
+
+	data, err := c.ToCBOR()
+	if err != nil {
+		return 0, fmt.Errorf("encoding comid: %w", err)
+	}
+
+	return o.Add(c.TagIdentity, data)
+}
+
+// Latest returns the latest known version of the tag with the supplied tag-id,
+// along with its encoded content
+func (o TagVersions) Latest(tagID swid.TagID) (TagIdentity, []byte, bool) {
+	t, ok := o.latest[tagID]
+	return t.id, t.data, ok
+}
+
+// Identities returns the latest known identity of each tag, in the order in
+// which the tag-ids were first added
+func (o TagVersions) Identities() []TagIdentity {
+	ret := make([]TagIdentity, 0, len(o.order))
+
+	for _, k := range o.order {
+		ret = append(ret, o.latest[k].id)
+	}
+
+	return ret
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package comid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/swid"
+)
+
+func TestCheckTagUpgrade(t *testing.T) {
+	known := tagIdentity("fw", 2)
+
+	u, err := CheckTagUpgrade(nil, nil, tagIdentity("fw", 0), []byte{0x01})
+	require.NoError(t, err)
+	assert.Equal(t, TagUpgradeNew, u)
+
+	u, err = CheckTagUpgrade(&known, []byte{0x01}, tagIdentity("fw", 3), []byte{0x02})
+	require.NoError(t, err)
+	assert.Equal(t, TagUpgradeReplace, u)
+
+	u, err = CheckTagUpgrade(&known, []byte{0x01}, tagIdentity("fw", 2), []byte{0x01})
+	require.NoError(t, err)
+	assert.Equal(t, TagUpgradeUnchanged, u)
+
+	_, err = CheckTagUpgrade(&known, []byte{0x01}, tagIdentity("fw", 2), []byte{0x02})
+	assert.ErrorIs(t, err, ErrTagVersionConflict)
+	assert.EqualError(t, err, `conflicting content for the same tag-version: "fw" version 2`)
+
+	_, err = CheckTagUpgrade(&known, []byte{0x01}, tagIdentity("fw", 1), []byte{0x01})
+	assert.ErrorIs(t, err, ErrTagVersionRollback)
+	assert.EqualError(t, err, `tag-version rollback: "fw" version 1 is older than known version 2`)
+
+	_, err = CheckTagUpgrade(&known, nil, tagIdentity("other", 3), nil)
+	assert.EqualError(t, err, `tag-id mismatch: "other" vs "fw"`)
+
+	// same content, different encoding
+	u, err = CheckTagUpgrade(&known, []byte{0xa2, 0x01, 0xf4, 0x02, 0xf5},
+		tagIdentity("fw", 2), []byte{0xa2, 0x02, 0xf5, 0x01, 0xf4})
+	require.NoError(t, err)
+	assert.Equal(t, TagUpgradeUnchanged, u)
+}
+
+func newRawValueComid(t *testing.T, id string, rawValue string) *Comid {
+	c := NewComid().SetTagIdentity(id, 0)
+	c.Triples = Triples{
+		ReferenceValues: NewValueTriples().Add(&ValueTriple{
+			Environment: Environment{
+				Instance: MustNewUUIDInstance(TestUUID),
+			},
+			Measurement: Measurement{
+				Val: Mval{
+					RawValue: NewRawValue().SetBytes(MustHexDecode(t, rawValue)),
+				},
+			},
+		}),
+	}
+
+	return c
+}
+
+func TestTagVersions_AddComid(t *testing.T) {
+	tv := NewTagVersions()
+
+	c := newRawValueComid(t, "fw", "deadbeef")
+
+	u, err := tv.AddComid(c)
+	require.NoError(t, err)
+	assert.Equal(t, TagUpgradeNew, u)
+
+	u, err = tv.AddComid(c)
+	require.NoError(t, err)
+	assert.Equal(t, TagUpgradeUnchanged, u)
+
+	// same tag-version, different content
+	changed := newRawValueComid(t, "fw", "cafebabe")
+
+	_, err = tv.AddComid(changed)
+	assert.ErrorIs(t, err, ErrTagVersionConflict)
+
+	_, err = changed.BumpTagVersion()
+	require.NoError(t, err)
+
+	u, err = tv.AddComid(changed)
+	require.NoError(t, err)
+	assert.Equal(t, TagUpgradeReplace, u)
+
+	// re-provisioning the original CoMID is a rollback
+	_, err = tv.AddComid(c)
+	assert.ErrorIs(t, err, ErrTagVersionRollback)
+
+	id, data, ok := tv.Latest(textTagID("fw"))
+	require.True(t, ok)
+	assert.Equal(t, uint(1), id.TagVersion)
+
+	expected, err := changed.ToCBOR()
+	require.NoError(t, err)
+	assert.Equal(t, expected, data)
+
+	_, err = tv.AddComid(newRawValueComid(t, "platform", "00"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []TagIdentity{tagIdentity("fw", 1), tagIdentity("platform", 0)}, tv.Identities())
+
+	_, err = tv.Add(TagIdentity{}, nil)
+	assert.EqualError(t, err, "tag-identity validation failed: empty tag-id")
+
+	_, err = tv.AddComid(nil)
+	assert.EqualError(t, err, "nil comid")
+}
+
+func TestTagVersions_Add_text_and_UUID_tag_ids(t *testing.T) {
+	tv := NewTagVersions()
+
+	uuidID := TagIdentity{TagID: *swid.NewTagID(TestUUIDString), TagVersion: 1}
+	textID := TagIdentity{TagID: textTagID(TestUUIDString)}
+
+	// the tag-ids print the same, but identify different tags
+	u, err := tv.Add(uuidID, []byte{0x01})
+	require.NoError(t, err)
+	assert.Equal(t, TagUpgradeNew, u)
+
+	u, err = tv.Add(textID, []byte{0x02})
+	require.NoError(t, err)
+	assert.Equal(t, TagUpgradeNew, u)
+
+	assert.Equal(t, []TagIdentity{uuidID, textID}, tv.Identities())
+
+	id, data, ok := tv.Latest(textID.TagID)
+	require.True(t, ok)
+	assert.Equal(t, textID, id)
+	assert.Equal(t, []byte{0x02}, data)
+
+	id, _, ok = tv.Latest(uuidID.TagID)
+	require.True(t, ok)
+	assert.Equal(t, uuidID, id)
+}
+
+func TestTagUpgrade_String(t *testing.T) {
+	assert.Equal(t, "replace", TagUpgradeReplace.String())
+	assert.Equal(t, "TagUpgrade(7)", TagUpgrade(7).String())
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/jraman567/corim/encoding"
 	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 )
 
 type Triples struct {
@@ -19,10 +20,14 @@ type Triples struct {
 	Extensions
 }
 
-// RegisterExtensions registers a struct as a collections of extensions
+// RegisterExtensions registers a struct as a collections of extensions. The
+// extensions of the environment (ExtEnvironment and ExtClass) are registered
+// for the triples of all kinds.
 func (o *Triples) RegisterExtensions(exts extensions.Map) error {
 	refValExts := extensions.NewMap()
 	endValExts := extensions.NewMap()
+	devIDExts := extensions.NewMap()
+	attestKeyExts := extensions.NewMap()
 
 	for p, v := range exts {
 		switch p {
@@ -36,6 +41,14 @@ func (o *Triples) RegisterExtensions(exts extensions.Map) error {
 			endValExts[ExtMval] = v
 		case ExtEndorsedValueFlags:
 			endValExts[ExtFlags] = v
+		case ExtDevIdentityKey:
+			devIDExts[ExtKeyTriple] = v
+		case ExtAttestVerifKey:
+			attestKeyExts[ExtKeyTriple] = v
+		case ExtEnvironment, ExtClass:
+			for _, m := range []extensions.Map{refValExts, endValExts, devIDExts, attestKeyExts} {
+				m[p] = v
+			}
 		default:
 			return fmt.Errorf("%w: %q", extensions.ErrUnexpectedPoint, p)
 		}
@@ -56,7 +69,27 @@ func (o *Triples) RegisterExtensions(exts extensions.Map) error {
 			o.EndorsedValues = NewValueTriples()
 		}
 
-		if err := o.EndorsedValues.RegisterExtensions(refValExts); err != nil {
+		if err := o.EndorsedValues.RegisterExtensions(endValExts); err != nil {
+			return err
+		}
+	}
+
+	if len(devIDExts) != 0 {
+		if o.DevIdentityKeys == nil {
+			o.DevIdentityKeys = NewKeyTriples()
+		}
+
+		if err := o.DevIdentityKeys.RegisterExtensions(devIDExts); err != nil {
+			return err
+		}
+	}
+
+	if len(attestKeyExts) != 0 {
+		if o.AttestVerifKeys == nil {
+			o.AttestVerifKeys = NewKeyTriples()
+		}
+
+		if err := o.AttestVerifKeys.RegisterExtensions(attestKeyExts); err != nil {
 			return err
 		}
 	}
@@ -71,11 +104,21 @@ func (o *Triples) GetExtensions() extensions.IMapValue {
 
 // UnmarshalCBOR deserializes from CBOR
 func (o *Triples) UnmarshalCBOR(data []byte) error {
-	return encoding.PopulateStructFromCBOR(dm, data, o)
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *Triples) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromCBOR(decModeFor(d), data, o)
 }
 
 // MarshalCBOR serializes to CBOR
 func (o Triples) MarshalCBOR() ([]byte, error) {
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o Triples) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
 	// If extensions have been registered, the collection will exist, but
 	// might be empty. If that is the case, set it to nil to avoid
 	// marshaling an empty list (and let the marshaller omit the claim
@@ -90,16 +133,34 @@ func (o Triples) MarshalCBOR() ([]byte, error) {
 		o.EndorsedValues = nil
 	}
 
-	return encoding.SerializeStructToCBOR(em, o)
+	if o.DevIdentityKeys != nil && o.DevIdentityKeys.IsEmpty() {
+		o.DevIdentityKeys = nil
+	}
+
+	if o.AttestVerifKeys != nil && o.AttestVerifKeys.IsEmpty() {
+		o.AttestVerifKeys = nil
+	}
+
+	return e.SerializeStructToCBOR(encModeFor(e), o)
 }
 
 // UnmarshalJSON deserializes from JSON
 func (o *Triples) UnmarshalJSON(data []byte) error {
-	return encoding.PopulateStructFromJSON(data, o)
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Triples) DecodeJSON(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromJSON(data, o)
 }
 
 // MarshalJSON serializes to JSON
 func (o Triples) MarshalJSON() ([]byte, error) {
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o Triples) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
 	// If extensions have been registered, the collection will exist, but
 	// might be empty. If that is the case, set it to nil to avoid
 	// marshaling an empty list (and let the marshaller omit the claim
@@ -114,7 +175,15 @@ func (o Triples) MarshalJSON() ([]byte, error) {
 		o.EndorsedValues = nil
 	}
 
-	return encoding.SerializeStructToJSON(o)
+	if o.DevIdentityKeys != nil && o.DevIdentityKeys.IsEmpty() {
+		o.DevIdentityKeys = nil
+	}
+
+	if o.AttestVerifKeys != nil && o.AttestVerifKeys.IsEmpty() {
+		o.AttestVerifKeys = nil
+	}
+
+	return e.SerializeStructToJSON(o)
 }
 
 // Valid checks that the Triples is valid as per the specification
@@ -122,35 +191,37 @@ func (o Triples) Valid() error {
 	// non-empty<>
 	if (o.ReferenceValues == nil || o.ReferenceValues.IsEmpty()) &&
 		(o.EndorsedValues == nil || o.EndorsedValues.IsEmpty()) &&
-		(o.AttestVerifKeys == nil || len(*o.AttestVerifKeys) == 0) &&
-		(o.DevIdentityKeys == nil || len(*o.DevIdentityKeys) == 0) {
-		return fmt.Errorf("triples struct must not be empty")
+		(o.AttestVerifKeys == nil || o.AttestVerifKeys.IsEmpty()) &&
+		(o.DevIdentityKeys == nil || o.DevIdentityKeys.IsEmpty()) {
+		return validation.New(validation.CodeEmpty, "triples struct must not be empty")
 	}
 
 	if o.ReferenceValues != nil {
 		if err := o.ReferenceValues.Valid(); err != nil {
-			return fmt.Errorf("reference values: %w", err)
+			return validation.Errorf("reference-values", "reference values: %w", err)
 		}
 	}
 
 	if o.EndorsedValues != nil {
 		if err := o.EndorsedValues.Valid(); err != nil {
-			return fmt.Errorf("endorsed values: %w", err)
+			return validation.Errorf("endorsed-values", "endorsed values: %w", err)
 		}
 	}
 
 	if o.AttestVerifKeys != nil {
-		for i, ak := range *o.AttestVerifKeys {
+		for i, ak := range o.AttestVerifKeys.Values {
 			if err := ak.Valid(); err != nil {
-				return fmt.Errorf("attestation verification key at index %d: %w", i, err)
+				return validation.Errorf(validation.JoinPath("attester-verification-keys", validation.Index(i)),
+					"attestation verification key at index %d: %w", i, err)
 			}
 		}
 	}
 
 	if o.DevIdentityKeys != nil {
-		for i, dk := range *o.DevIdentityKeys {
+		for i, dk := range o.DevIdentityKeys.Values {
 			if err := dk.Valid(); err != nil {
-				return fmt.Errorf("device identity key at index %d: %w", i, err)
+				return validation.Errorf(validation.JoinPath("dev-identity-keys", validation.Index(i)),
+					"device identity key at index %d: %w", i, err)
 			}
 		}
 	}
@@ -184,7 +255,11 @@ func (o *Triples) AddEndorsedValue(val ValueTriple) *Triples {
 
 func (o *Triples) AddAttestVerifKey(val KeyTriple) *Triples {
 	if o != nil {
-		*o.AttestVerifKeys = append(*o.AttestVerifKeys, val)
+		if o.AttestVerifKeys == nil {
+			o.AttestVerifKeys = new(KeyTriples)
+		}
+
+		o.AttestVerifKeys.Add(&val)
 	}
 
 	return o
@@ -192,7 +267,11 @@ func (o *Triples) AddAttestVerifKey(val KeyTriple) *Triples {
 
 func (o *Triples) AddDevIdentityKey(val KeyTriple) *Triples {
 	if o != nil {
-		*o.DevIdentityKeys = append(*o.DevIdentityKeys, val)
+		if o.DevIdentityKeys == nil {
+			o.DevIdentityKeys = new(KeyTriples)
+		}
+
+		o.DevIdentityKeys.Add(&val)
 	}
 
 	return o
@@ -20,7 +20,11 @@ func TestTriples_extensions(t *testing.T) {
 		Add(ExtReferenceValue, &struct{}{}).
 		Add(ExtReferenceValueFlags, &struct{}{}).
 		Add(ExtEndorsedValue, &struct{}{}).
-		Add(ExtEndorsedValueFlags, &struct{}{})
+		Add(ExtEndorsedValueFlags, &struct{}{}).
+		Add(ExtDevIdentityKey, &struct{}{}).
+		Add(ExtAttestVerifKey, &struct{}{}).
+		Add(ExtEnvironment, &struct{}{}).
+		Add(ExtClass, &struct{}{})
 
 	err := triples.RegisterExtensions(extMap)
 	assert.NoError(t, err)
@@ -67,12 +71,12 @@ func TestTriples_Valid(t *testing.T) {
 	assert.EqualError(t, err, "endorsed values: error at index 0: environment validation failed: environment must not be empty")
 
 	triples.EndorsedValues = nil
-	triples.AttestVerifKeys = &KeyTriples{{}}
+	triples.AttestVerifKeys = NewKeyTriples().Add(&KeyTriple{})
 	err = triples.Valid()
 	assert.EqualError(t, err, "attestation verification key at index 0: environment validation failed: environment must not be empty")
 
 	triples.AttestVerifKeys = nil
-	triples.DevIdentityKeys = &KeyTriples{{}}
+	triples.DevIdentityKeys = NewKeyTriples().Add(&KeyTriple{})
 	err = triples.Valid()
 	assert.EqualError(t, err, "device identity key at index 0: environment validation failed: environment must not be empty")
 }
@@ -7,6 +7,7 @@ import (
 	"encoding/base64"
 	"fmt"
 
+	"github.com/jraman567/corim/validation"
 	"github.com/veraison/eat"
 )
 
@@ -22,7 +23,7 @@ func (o UEID) Empty() bool {
 // Valid checks that the target UEID is in one of the defined formats: IMEI, EUI or RAND
 func (o UEID) Valid() error {
 	if err := eat.UEID(o).Validate(); err != nil {
-		return fmt.Errorf("UEID validation failed: %w", err)
+		return validation.Errorf("", "UEID validation failed: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,172 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package comid
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/jraman567/corim/encoding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComid_unknown_fields_CBOR_round_trip(t *testing.T) {
+	var c Comid
+	require.NoError(t, c.FromJSON([]byte(PSARefValJSONTemplate)))
+
+	data, err := c.ToCBOR()
+	require.NoError(t, err)
+
+	// add an unknown entry to the top-level map, as a newer profile would
+	var m map[int]cbor.RawMessage
+	require.NoError(t, dm.Unmarshal(data, &m))
+	m[99] = cbor.RawMessage{0x63, 'n', 'e', 'w'}
+
+	data, err = em.Marshal(m)
+	require.NoError(t, err)
+
+	var decoded Comid
+	err = decoded.FromCBOR(data, encoding.WithStrict())
+	assert.EqualError(t, err, "/99: unknown map key")
+
+	require.NoError(t, decoded.FromCBOR(data))
+	require.True(t, decoded.HaveUnknownFields())
+	assert.Equal(t, 99, decoded.GetUnknownFields().CBOR[0].Key)
+
+	out, err := decoded.ToCBOR()
+	require.NoError(t, err)
+
+	var back map[int]cbor.RawMessage
+	require.NoError(t, dm.Unmarshal(out, &back))
+	assert.Equal(t, cbor.RawMessage{0x63, 'n', 'e', 'w'}, back[99])
+
+	// discarding the unknown entries restores the original encoding
+	decoded.SetUnknownFields(nil)
+	assert.False(t, decoded.HaveUnknownFields())
+
+	out, err = decoded.ToCBOR()
+	require.NoError(t, err)
+
+	var stripped map[int]cbor.RawMessage
+	require.NoError(t, dm.Unmarshal(out, &stripped))
+	assert.NotContains(t, stripped, 99)
+}
+
+func TestComid_unknown_fields_JSON_round_trip(t *testing.T) {
+	var tmpl map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(PSARefValJSONTemplate), &tmpl))
+
+	tmpl["x-new"] = "top"
+	triples := tmpl["triples"].(map[string]interface{})
+	triples["x-triples"] = 1
+	refVal := triples["reference-values"].([]interface{})[0].(map[string]interface{})
+	mval := refVal["measurement"].(map[string]interface{})["value"].(map[string]interface{})
+	mval["x-future"] = []interface{}{"a", "b"}
+
+	data, err := json.Marshal(tmpl)
+	require.NoError(t, err)
+
+	var c Comid
+	require.NoError(t, c.FromJSON(data))
+
+	assert.Equal(t, "x-new", c.GetUnknownFields().JSON[0].Key)
+	assert.Equal(t, "x-triples", c.Triples.GetUnknownFields().JSON[0].Key)
+
+	v := c.Triples.ReferenceValues.Values[0].Measurement.Val
+	require.True(t, v.HaveUnknownFields())
+	assert.Equal(t, json.RawMessage(`["a","b"]`), v.GetUnknownFields().JSON[0].Value)
+	assert.Empty(t, v.GetUnknownFields().CBOR)
+
+	out, err := c.ToJSON()
+	require.NoError(t, err)
+
+	var back map[string]interface{}
+	require.NoError(t, json.Unmarshal(out, &back))
+	assert.Equal(t, "top", back["x-new"])
+
+	backTriples := back["triples"].(map[string]interface{})
+	assert.Equal(t, float64(1), backTriples["x-triples"])
+
+	backRefVal := backTriples["reference-values"].([]interface{})[0].(map[string]interface{})
+	backMval := backRefVal["measurement"].(map[string]interface{})["value"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"a", "b"}, backMval["x-future"])
+}
+
+func TestComid_FromJSON_strict(t *testing.T) {
+	var tmpl map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(PSARefValJSONTemplate), &tmpl))
+
+	var c Comid
+	assert.NoError(t, c.FromJSON([]byte(PSARefValJSONTemplate), encoding.WithStrict()))
+
+	triples := tmpl["triples"].(map[string]interface{})
+	refVal := triples["reference-values"].([]interface{})[1].(map[string]interface{})
+	mval := refVal["measurement"].(map[string]interface{})["value"].(map[string]interface{})
+	mval["x-future"] = true
+
+	data, err := json.Marshal(tmpl)
+	require.NoError(t, err)
+
+	assert.NoError(t, c.FromJSON(data))
+
+	err = c.FromJSON(data, encoding.WithStrict())
+	assert.EqualError(t, err, "/triples/reference-values/1/measurement/value/x-future: unknown map key")
+	assert.ErrorIs(t, err, encoding.ErrUnknownKey)
+
+	encoded, err := c.ToCBOR()
+	require.NoError(t, err)
+
+	err = c.FromCBOR(encoded, encoding.WithStrict())
+	assert.NoError(t, err, "JSON unknown entries are not emitted to CBOR")
+}
+
+func TestComid_FromCBOR_strict_duplicate_key(t *testing.T) {
+	var c Comid
+	require.NoError(t, c.FromJSON([]byte(PSARefValJSONTemplate)))
+
+	data, err := c.ToCBOR()
+	require.NoError(t, err)
+
+	// repeat the tag-id in the tag-identity-map, which is decoded by the
+	// CBOR library rather than split up by the encoding package
+	var m map[int]cbor.RawMessage
+	require.NoError(t, dm.Unmarshal(data, &m))
+
+	var tagIdentity map[int]cbor.RawMessage
+	require.NoError(t, dm.Unmarshal(m[1], &tagIdentity))
+
+	dup := []byte{0xa2, 0x00}
+	dup = append(dup, tagIdentity[0]...)
+	dup = append(dup, 0x00)
+	m[1] = append(dup, tagIdentity[0]...)
+
+	data, err = em.Marshal(m)
+	require.NoError(t, err)
+
+	var decoded Comid
+	require.NoError(t, decoded.FromCBOR(data))
+
+	err = decoded.FromCBOR(data, encoding.WithStrict())
+	assert.EqualError(t, err, "/1/0: duplicate map key")
+	assert.ErrorIs(t, err, encoding.ErrDuplicateKey)
+}
+
+func TestComid_FromCBOR_strict_non_preferred(t *testing.T) {
+	data, err := os.ReadFile("testcases/comid-1.cbor")
+	require.NoError(t, err)
+
+	// re-encode the first key of the comid-map (tag-identity) in two bytes
+	require.Equal(t, []byte{0xa3, 0x01}, data[:2])
+	data = append([]byte{0xa3, 0x18, 0x01}, data[2:]...)
+
+	var decoded Comid
+	require.NoError(t, decoded.FromCBOR(data))
+
+	err = decoded.FromCBOR(data, encoding.WithStrict())
+	assert.EqualError(t, err, "/: non-preferred integer encoding: 1 encoded in 1 bytes")
+	assert.ErrorIs(t, err, encoding.ErrNonPreferredEncoding)
+}
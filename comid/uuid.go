@@ -8,6 +8,7 @@ import (
 	"fmt"
 
 	"github.com/google/uuid"
+	"github.com/jraman567/corim/validation"
 )
 
 const UUIDType = "uuid"
@@ -34,7 +35,7 @@ func (o UUID) Empty() bool {
 // Valid checks that the target UUID is formatted as per RFC4122
 func (o UUID) Valid() error {
 	if variant := uuid.UUID(o).Variant(); variant != uuid.RFC4122 {
-		return fmt.Errorf("expecting RFC4122 UUID, got %s instead", variant)
+		return validation.New(validation.CodeInvalid, "expecting RFC4122 UUID, got %s instead", variant)
 	}
 	return nil
 }
@@ -0,0 +1,183 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package comid
+
+import "github.com/jraman567/corim/validation"
+
+// ValidateAll checks the target CoMID like Valid, including the constraints of
+// any registered extensions, but rather than stopping at the first problem it
+// carries on and reports all the problems found. Map entries that were not
+// recognized when the CoMID was decoded are reported as warnings. The CoMID is
+// valid if the returned report contains no errors.
+func (o Comid) ValidateAll() *validation.Report {
+	r := validation.NewReport()
+
+	r.Add("tag-identity", o.TagIdentity.Valid())
+
+	if o.Entities != nil {
+		for i, e := range o.Entities.Values {
+			segment := validation.JoinPath("entities", validation.Index(i))
+
+			r.Add(segment, e.Valid())
+			e.ReportUnknownFields(r, segment)
+		}
+	}
+
+	if o.LinkedTags != nil {
+		for i, l := range *o.LinkedTags {
+			r.Add(validation.JoinPath("linked-tags", validation.Index(i)), l.Valid())
+		}
+	}
+
+	r.Merge("triples", o.Triples.validateAll())
+	r.Add("", o.Extensions.validComid(&o))
+	o.ReportUnknownFields(r, "")
+
+	return r
+}
+
+func (o Triples) validateAll() *validation.Report {
+	r := validation.NewReport()
+
+	if (o.ReferenceValues == nil || o.ReferenceValues.IsEmpty()) &&
+		(o.EndorsedValues == nil || o.EndorsedValues.IsEmpty()) &&
+		(o.AttestVerifKeys == nil || o.AttestVerifKeys.IsEmpty()) &&
+		(o.DevIdentityKeys == nil || o.DevIdentityKeys.IsEmpty()) {
+		r.Add("", validation.New(validation.CodeEmpty, "triples struct must not be empty"))
+	}
+
+	if o.ReferenceValues != nil {
+		for i, v := range o.ReferenceValues.Values {
+			r.Merge(validation.JoinPath("reference-values", validation.Index(i)), v.validateAll())
+		}
+	}
+
+	if o.EndorsedValues != nil {
+		for i, v := range o.EndorsedValues.Values {
+			r.Merge(validation.JoinPath("endorsed-values", validation.Index(i)), v.validateAll())
+		}
+	}
+
+	if o.DevIdentityKeys != nil {
+		for i, k := range o.DevIdentityKeys.Values {
+			r.Merge(validation.JoinPath("dev-identity-keys", validation.Index(i)), k.validateAll())
+		}
+	}
+
+	if o.AttestVerifKeys != nil {
+		for i, k := range o.AttestVerifKeys.Values {
+			r.Merge(validation.JoinPath("attester-verification-keys", validation.Index(i)), k.validateAll())
+		}
+	}
+
+	r.Add("", o.Extensions.validTriples(&o))
+	o.ReportUnknownFields(r, "")
+
+	return r
+}
+
+func (o ValueTriple) validateAll() *validation.Report {
+	r := validation.NewReport()
+
+	r.Merge("environment", o.Environment.validateAll())
+	r.Merge("measurement", o.Measurement.validateAll())
+
+	return r
+}
+
+func (o KeyTriple) validateAll() *validation.Report {
+	r := validation.NewReport()
+
+	r.Merge("environment", o.Environment.validateAll())
+
+	if len(o.VerifKeys) == 0 {
+		r.Add("verification-keys", validation.New(validation.CodeEmpty, "no keys to validate"))
+	}
+
+	for i, k := range o.VerifKeys {
+		r.Add(validation.JoinPath("verification-keys", validation.Index(i)), k.Valid())
+	}
+
+	if o.Conditions != nil {
+		r.Add("conditions", o.Conditions.Valid())
+	}
+
+	r.Add("", o.Extensions.validKeyTriple(&o))
+	o.ReportUnknownFields(r, "")
+
+	return r
+}
+
+func (o Environment) validateAll() *validation.Report {
+	r := validation.NewReport()
+
+	if !o.hasClass() && o.Instance == nil && o.Group == nil {
+		r.Add("", validation.New(validation.CodeEmpty, "environment must not be empty"))
+	}
+
+	if o.hasClass() {
+		r.Add("class", o.Class.Valid())
+		o.Class.ReportUnknownFields(r, "class")
+	}
+
+	if o.Instance != nil {
+		r.Add("instance", o.Instance.Valid())
+	}
+
+	if o.Group != nil {
+		r.Add("group", o.Group.Valid())
+	}
+
+	r.Add("", o.Extensions.validEnvironment(&o))
+	o.ReportUnknownFields(r, "")
+
+	return r
+}
+
+func (o Measurement) validateAll() *validation.Report {
+	r := validation.NewReport()
+
+	if o.Key != nil && o.Key.IsSet() {
+		r.Add("key", o.Key.Valid())
+	}
+
+	r.Merge("value", o.Val.validateAll())
+
+	return r
+}
+
+func (o Mval) validateAll() *validation.Report {
+	r := validation.NewReport()
+
+	if o.Ver == nil && o.SVN == nil && o.Digests == nil && o.Flags == nil &&
+		o.RawValue == nil && o.RawValueMask == nil && o.MACAddr == nil &&
+		o.IPAddr == nil && o.SerialNumber == nil && o.UEID == nil &&
+		o.UUID == nil && o.IntegrityRegisters == nil && o.OvmfMetadata == nil {
+		r.Add("", validation.New(validation.CodeEmpty, "no measurement value set"))
+	}
+
+	if o.Ver != nil {
+		r.Add("version", o.Ver.Valid())
+	}
+
+	if o.Digests != nil {
+		for i, d := range *o.Digests {
+			r.Add(validation.JoinPath("digests", validation.Index(i)), ValidHashEntry(d))
+		}
+	}
+
+	if o.Flags != nil {
+		r.Add("flags", o.Flags.Valid())
+		o.Flags.ReportUnknownFields(r, "flags")
+	}
+
+	if o.IntegrityRegisters != nil {
+		r.Add("integrity-registers", o.IntegrityRegisters.Valid())
+	}
+
+	r.Add("", o.Extensions.validMval(&o))
+	o.ReportUnknownFields(r, "")
+
+	return r
+}
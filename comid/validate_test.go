@@ -0,0 +1,161 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package comid
+
+import (
+	"testing"
+
+	"github.com/jraman567/corim/encoding"
+	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/swid"
+)
+
+type mvalConstrainer struct{}
+
+func (o *mvalConstrainer) ConstrainMval(m *Mval) error {
+	if m.Ver == nil {
+		return validation.NewAt("version", validation.CodeMissing, "version is required")
+	}
+
+	return nil
+}
+
+func Test_Comid_ValidateAll(t *testing.T) {
+	vendor := "ACME"
+	env := Environment{Class: &Class{Vendor: &vendor}}
+
+	c := NewComid().
+		SetTagIdentity("test", 0).
+		AddReferenceValue(ValueTriple{
+			Environment: Environment{},
+			Measurement: Measurement{Val: Mval{Ver: &Version{Version: "1.0"}}},
+		}).
+		AddReferenceValue(ValueTriple{
+			Environment: env,
+			Measurement: Measurement{Val: Mval{
+				Digests: &Digests{
+					{HashAlgID: swid.Sha256, HashValue: []byte{0x01}},
+					{HashAlgID: 99, HashValue: []byte{0x01}},
+				},
+			}},
+		})
+	require.NotNil(t, c)
+
+	r := c.ValidateAll()
+	require.True(t, r.HasErrors())
+	assert.Error(t, c.Valid())
+
+	var paths []string
+	for _, e := range r.Errors {
+		paths = append(paths, e.Path)
+	}
+
+	assert.Equal(t, []string{
+		"triples.reference-values[0].environment",
+		"triples.reference-values[1].measurement.value.digests[0].hash-value",
+		"triples.reference-values[1].measurement.value.digests[1].hash-alg-id",
+	}, paths)
+	assert.Equal(t, validation.CodeEmpty, r.Errors[0].Code)
+	assert.Equal(t, validation.CodeUnknown, r.Errors[2].Code)
+	assert.Empty(t, r.Warnings)
+
+	err := r.Err()
+	assert.ErrorContains(t, err, "environment must not be empty")
+	assert.ErrorContains(t, err, "unknown hash algorithm 99")
+}
+
+func Test_Comid_ValidateAll_constraints_and_warnings(t *testing.T) {
+	vendor := "ACME"
+
+	c := NewComid()
+	require.NoError(t, c.RegisterExtensions(extensions.NewMap().
+		Add(ExtReferenceValue, &mvalConstrainer{})))
+
+	c.SetTagIdentity("test", 0).
+		AddReferenceValue(ValueTriple{
+			Environment: Environment{Class: &Class{Vendor: &vendor}},
+			Measurement: Measurement{Val: Mval{
+				Digests: &Digests{{HashAlgID: swid.Sha256, HashValue: make([]byte, 32)}},
+			}},
+		})
+	c.SetUnknownFields(&encoding.UnknownFields{
+		JSON: []encoding.UnknownJSONEntry{{Key: "x-note", Value: []byte(`"hi"`)}},
+	})
+
+	r := c.ValidateAll()
+	require.Len(t, r.Errors, 1)
+	assert.Equal(t, "triples.reference-values[0].measurement.value.version", r.Errors[0].Path)
+	assert.Equal(t, validation.CodeMissing, r.Errors[0].Code)
+
+	require.Len(t, r.Warnings, 1)
+	assert.Equal(t, "x-note", r.Warnings[0].Path)
+	assert.Equal(t, validation.CodeUnknown, r.Warnings[0].Code)
+
+	c.Triples.ReferenceValues.Values[0].Measurement.Val.Ver = &Version{Version: "1.0"}
+	r = c.ValidateAll()
+	assert.NoError(t, r.Err())
+	assert.NoError(t, c.Valid())
+}
+
+func Test_KeyTriple_validateAll_conditions(t *testing.T) {
+	invalidKey := CryptoKey{TaggedPKIXBase64Key("")}
+
+	testCases := []struct {
+		title      string
+		conditions *KeyTripleConditions
+		expected   string
+		path       string
+	}{
+		{
+			title:      "empty",
+			conditions: &KeyTripleConditions{},
+			expected:   "no conditions set",
+			path:       "conditions",
+		},
+		{
+			title:      "invalid mkey",
+			conditions: &KeyTripleConditions{Mkey: &Mkey{}},
+			expected:   "Mkey value not set",
+			path:       "conditions.mkey",
+		},
+		{
+			title:      "invalid authorized-by",
+			conditions: &KeyTripleConditions{AuthorizedBy: &CryptoKeys{&invalidKey}},
+			expected:   "key value not set",
+			path:       "conditions.authorized-by[0]",
+		},
+		{
+			title: "valid",
+			conditions: &KeyTripleConditions{
+				AuthorizedBy: &CryptoKeys{MustNewPKIXBase64Key(TestECPubKey)},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			kt := KeyTriple{
+				Environment: Environment{Instance: MustNewUEIDInstance(TestUEID)},
+				VerifKeys:   CryptoKeys{MustNewPKIXBase64Key(TestECPubKey)},
+				Conditions:  tc.conditions,
+			}
+
+			r := kt.validateAll()
+
+			if tc.expected == "" {
+				assert.NoError(t, kt.Valid())
+				assert.NoError(t, r.Err())
+				return
+			}
+
+			assert.ErrorContains(t, kt.Valid(), tc.expected)
+			require.Len(t, r.Errors, 1)
+			assert.Equal(t, tc.path, r.Errors[0].Path)
+			assert.ErrorContains(t, r.Err(), tc.expected)
+		})
+	}
+}
@@ -4,9 +4,9 @@
 package comid
 
 import (
-	"fmt"
-
+	"github.com/jraman567/corim/encoding"
 	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 )
 
 // ValueTriple relates a measurement to a target environment, essentially
@@ -20,8 +20,27 @@ type ValueTriple struct {
 	Measurement Measurement `json:"measurement"`
 }
 
+// RegisterExtensions registers a struct as a collections of extensions. The
+// extensions of the environment (ExtEnvironment and ExtClass) are registered
+// with the Environment, and the others with the Measurement.
 func (o *ValueTriple) RegisterExtensions(exts extensions.Map) error {
-	return o.Measurement.RegisterExtensions(exts)
+	envExts := extensions.NewMap()
+	measExts := extensions.NewMap()
+
+	for p, v := range exts {
+		switch p {
+		case ExtEnvironment, ExtClass:
+			envExts[p] = v
+		default:
+			measExts[p] = v
+		}
+	}
+
+	if err := o.Environment.RegisterExtensions(envExts); err != nil {
+		return err
+	}
+
+	return o.Measurement.RegisterExtensions(measExts)
 }
 
 func (o *ValueTriple) GetExtensions() extensions.IMapValue {
@@ -30,11 +49,11 @@ func (o *ValueTriple) GetExtensions() extensions.IMapValue {
 
 func (o ValueTriple) Valid() error {
 	if err := o.Environment.Valid(); err != nil {
-		return fmt.Errorf("environment validation failed: %w", err)
+		return validation.Errorf("environment", "environment validation failed: %w", err)
 	}
 
 	if err := o.Measurement.Valid(); err != nil {
-		return fmt.Errorf("measurement validation failed: %w", err)
+		return validation.Errorf("measurement", "measurement validation failed: %w", err)
 	}
 
 	return nil
@@ -70,17 +89,37 @@ func (o *ValueTriples) Add(val *ValueTriple) *ValueTriples {
 }
 
 func (o ValueTriples) MarshalCBOR() ([]byte, error) {
-	return (extensions.Collection[ValueTriple, *ValueTriple])(o).MarshalCBOR()
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o ValueTriples) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return (extensions.Collection[ValueTriple, *ValueTriple])(o).EncodeCBOR(e)
 }
 
 func (o *ValueTriples) UnmarshalCBOR(data []byte) error {
-	return (*extensions.Collection[ValueTriple, *ValueTriple])(o).UnmarshalCBOR(data)
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *ValueTriples) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return (*extensions.Collection[ValueTriple, *ValueTriple])(o).DecodeCBOR(d, data)
 }
 
 func (o ValueTriples) MarshalJSON() ([]byte, error) {
-	return (extensions.Collection[ValueTriple, *ValueTriple])(o).MarshalJSON()
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o ValueTriples) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
+	return (extensions.Collection[ValueTriple, *ValueTriple])(o).EncodeJSON(e)
 }
 
 func (o *ValueTriples) UnmarshalJSON(data []byte) error {
-	return (*extensions.Collection[ValueTriple, *ValueTriple])(o).UnmarshalJSON(data)
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *ValueTriples) DecodeJSON(d *encoding.Decoder, data []byte) error {
+	return (*extensions.Collection[ValueTriple, *ValueTriple])(o).DecodeJSON(d, data)
 }
@@ -4,87 +4,42 @@
 package corim
 
 import (
-	"fmt"
-	"reflect"
-
 	cbor "github.com/fxamacker/cbor/v2"
 	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/encoding"
 )
 
 var (
-	em, emError = initCBOREncMode()
-	dm, dmError = initCBORDecMode()
+	// em and dm encode and decode using the CBOR tags of DefaultRegistry.
+	// The types depending on the Registry use the modes of the one selected
+	// by their encoding.Encoder or encoding.Decoder instead (see encModeFor
+	// and decModeFor).
+	em cbor.EncMode = defaultEncMode{}
+	dm cbor.DecMode = defaultDecMode{}
 )
 
 var (
 	CoswidTag = []byte{0xd9, 0x01, 0xf9} // 505()
 	ComidTag  = []byte{0xd9, 0x01, 0xfa} // 506()
 
+	// corimTagsMap contains the CBOR tags every Registry starts with
 	corimTagsMap = map[uint64]interface{}{
 		32: comid.TaggedURI(""),
 	}
 )
 
-func corimTags() cbor.TagSet {
-	opts := cbor.TagOptions{
-		EncTag: cbor.EncTagRequired,
-		DecTag: cbor.DecTagRequired,
-	}
-
-	tags := cbor.NewTagSet()
-
-	for tag, typ := range corimTagsMap {
-		if err := tags.Add(opts, reflect.TypeOf(typ), tag); err != nil {
-			panic(err)
-		}
-	}
-
-	return tags
-}
-
-func initCBOREncMode() (en cbor.EncMode, err error) {
+func initCBOREncModes(tags cbor.TagSet) (*encoding.EncModes, error) {
 	encOpt := cbor.EncOptions{
 		IndefLength: cbor.IndefLengthForbidden,
 		TimeTag:     cbor.EncTagRequired,
 	}
-	return encOpt.EncModeWithTags(corimTags())
+	return encoding.NewEncModes(encOpt, tags)
 }
 
-func initCBORDecMode() (dm cbor.DecMode, err error) {
+func initCBORDecModes(tags cbor.TagSet) (*encoding.DecModes, error) {
 	decOpt := cbor.DecOptions{
 		IndefLength: cbor.IndefLengthForbidden,
 		TimeTag:     cbor.DecTagRequired,
 	}
-	return decOpt.DecModeWithTags(corimTags())
-}
-
-func registerCORIMTag(tag uint64, t interface{}) error {
-	if _, exists := corimTagsMap[tag]; exists {
-		return fmt.Errorf("tag %d is already registered", tag)
-	}
-
-	corimTagsMap[tag] = t
-
-	var err error
-
-	em, err = initCBOREncMode()
-	if err != nil {
-		return err
-	}
-
-	dm, err = initCBORDecMode()
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func init() {
-	if emError != nil {
-		panic(emError)
-	}
-	if dmError != nil {
-		panic(dmError)
-	}
+	return encoding.NewDecModes(decOpt, tags)
 }
@@ -0,0 +1,183 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+
+	"github.com/cloudflare/circl/sign/ed448"
+	cose "github.com/veraison/go-cose"
+)
+
+// IRemoteSigner is implemented by signing back-ends that keep the private key
+// out of process, e.g., an HSM or a remote KMS. The back-end is handed the
+// digest of the to-be-signed COSE Sig_structure and returns the signature in
+// the same format as crypto.Signer would, i.e., ASN.1 DER for ECDSA and the
+// raw signature for RSA-PSS.
+//
+// EdDSA does not sign digests: in that case, SignDigest is handed the whole
+// to-be-signed Sig_structure, and opts.HashFunc() is zero.
+type IRemoteSigner interface {
+	// Public returns the public key corresponding to the remote private key
+	Public() crypto.PublicKey
+	// SignDigest signs the supplied digest, computed using the hash function
+	// indicated by opts
+	SignDigest(digest []byte, opts crypto.SignerOpts) ([]byte, error)
+}
+
+// NewSignerFromCryptoSigner returns a cose.Signer backed by the supplied
+// crypto.Signer. The signing algorithm is inferred from the public key: ES256,
+// ES384 or ES512 depending on the elliptic curve, EdDSA for Ed25519 and Ed448
+// keys and PS256 for RSA keys.
+func NewSignerFromCryptoSigner(key crypto.Signer) (cose.Signer, error) {
+	if key == nil {
+		return nil, errors.New("nil crypto signer")
+	}
+
+	alg, err := algFromPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSignerWithAlgorithm(alg, key)
+}
+
+// NewSignerFromRemote returns a cose.Signer that delegates the signature
+// operation to the supplied IRemoteSigner. The signing algorithm is inferred
+// from the remote signer's public key, as in NewSignerFromCryptoSigner.
+func NewSignerFromRemote(remote IRemoteSigner) (cose.Signer, error) {
+	if remote == nil {
+		return nil, errors.New("nil remote signer")
+	}
+
+	alg, err := algFromPublicKey(remote.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSignerWithAlgorithm(alg, &remoteCryptoSigner{remote: remote, hash: algToHash(alg)})
+}
+
+// remoteCryptoSigner adapts an IRemoteSigner to the crypto.Signer interface
+// expected by the COSE library
+type remoteCryptoSigner struct {
+	remote IRemoteSigner
+	hash   crypto.Hash
+}
+
+func (o *remoteCryptoSigner) Public() crypto.PublicKey {
+	return o.remote.Public()
+}
+
+func (o *remoteCryptoSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	// the COSE library does not supply the hash function when signing with
+	// ECDSA, so make sure the remote end always knows which one was used
+	if opts == nil {
+		opts = o.hash
+	}
+
+	return o.remote.SignDigest(digest, opts)
+}
+
+// FileRemoteSigner is a local, file-based stand-in for an IRemoteSigner. The
+// private key is stored as a JWK in a file, which is read each time a
+// signature is requested. It is meant for testing code that uses remote
+// signers, not for production use.
+type FileRemoteSigner struct {
+	path   string
+	public crypto.PublicKey
+}
+
+// NewFileRemoteSigner instantiates a FileRemoteSigner using the JWK private
+// key found at the supplied path
+func NewFileRemoteSigner(path string) (*FileRemoteSigner, error) {
+	key, err := loadJWKFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileRemoteSigner{path: path, public: key.Public()}, nil
+}
+
+// Public returns the public key corresponding to the private key in the file
+func (o FileRemoteSigner) Public() crypto.PublicKey {
+	return o.public
+}
+
+// SignDigest signs the supplied digest using the private key in the file
+func (o FileRemoteSigner) SignDigest(digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	key, err := loadJWKFile(o.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !reflect.DeepEqual(key.Public(), o.public) {
+		return nil, fmt.Errorf("key in %s has changed", o.path)
+	}
+
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		// the COSE library only uses RSA with PSS padding
+		if _, isPSS := opts.(*rsa.PSSOptions); !isPSS {
+			opts = &rsa.PSSOptions{
+				SaltLength: rsa.PSSSaltLengthEqualsHash,
+				Hash:       opts.HashFunc(),
+			}
+		}
+	}
+
+	return key.Sign(rand.Reader, digest, opts)
+}
+
+func loadJWKFile(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %w", err)
+	}
+
+	_, key, err := getAlgAndKeyFromJWK(data)
+	if err != nil {
+		return nil, fmt.Errorf("loading key from %s: %w", path, err)
+	}
+
+	return key, nil
+}
+
+func algFromPublicKey(pk crypto.PublicKey) (cose.Algorithm, error) {
+	switch v := pk.(type) {
+	case *ecdsa.PublicKey:
+		alg := ellipticCurveToAlg(v.Curve)
+		if alg == noAlg {
+			return noAlg, fmt.Errorf("unknown elliptic curve %s", v.Curve.Params().Name)
+		}
+		return alg, nil
+	case ed25519.PublicKey, ed448.PublicKey:
+		return cose.AlgorithmEd25519, nil
+	case *rsa.PublicKey:
+		return cose.AlgorithmPS256, nil
+	default:
+		return noAlg, fmt.Errorf("unknown public key type %v", reflect.TypeOf(pk))
+	}
+}
+
+func algToHash(alg cose.Algorithm) crypto.Hash {
+	switch alg {
+	case cose.AlgorithmES256, cose.AlgorithmPS256, AlgorithmRS256:
+		return crypto.SHA256
+	case cose.AlgorithmES384, cose.AlgorithmPS384, AlgorithmRS384:
+		return crypto.SHA384
+	case cose.AlgorithmES512, cose.AlgorithmPS512, AlgorithmRS512:
+		return crypto.SHA512
+	default:
+		return crypto.Hash(0)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cose "github.com/veraison/go-cose"
+)
+
+func TestNewSignerFromCryptoSigner_alg_inference(t *testing.T) {
+	testCases := []struct {
+		key      []byte
+		expected cose.Algorithm
+	}{
+		{testES256Key, cose.AlgorithmES256},
+		{testES384Key, cose.AlgorithmES384},
+		{testES512Key, cose.AlgorithmES512},
+		{testEdDSAKey, cose.AlgorithmEd25519},
+		{testPS384Key, cose.AlgorithmPS256},
+	}
+
+	for _, tc := range testCases {
+		_, key, err := getAlgAndKeyFromJWK(tc.key)
+		require.NoError(t, err)
+
+		signer, err := NewSignerFromCryptoSigner(key)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, signer.Algorithm())
+	}
+}
+
+func TestNewSignerFromCryptoSigner_fail(t *testing.T) {
+	_, err := NewSignerFromCryptoSigner(nil)
+	assert.EqualError(t, err, "nil crypto signer")
+
+	key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+
+	_, err = NewSignerFromCryptoSigner(key)
+	assert.EqualError(t, err, "unknown elliptic curve P-224")
+
+	_, err = NewSignerFromRemote(nil)
+	assert.EqualError(t, err, "nil remote signer")
+}
+
+func TestSignedCorim_SignVerify_remote_signer(t *testing.T) {
+	for _, key := range [][]byte{
+		testES256Key,
+		testES384Key,
+		testES512Key,
+		testEdDSAKey,
+		testPS256Key,
+	} {
+		keyFile := filepath.Join(t.TempDir(), "key.jwk")
+		require.NoError(t, os.WriteFile(keyFile, key, 0600))
+
+		remote, err := NewFileRemoteSigner(keyFile)
+		require.NoError(t, err)
+
+		signer, err := NewSignerFromRemote(remote)
+		require.NoError(t, err)
+
+		var SignedCorimIn SignedCorim
+
+		SignedCorimIn.UnsignedCorim = *unsignedCorimFromCBOR(t, testGoodUnsignedCorimCBOR)
+		SignedCorimIn.Meta = *metaGood(t)
+
+		cbor, err := SignedCorimIn.Sign(signer)
+		require.NoError(t, err)
+
+		var SignedCorimOut SignedCorim
+
+		err = SignedCorimOut.FromCOSE(cbor)
+		require.NoError(t, err)
+
+		err = SignedCorimOut.Verify(remote.Public())
+		assert.NoError(t, err)
+	}
+}
+
+type recordingRemoteSigner struct {
+	FileRemoteSigner
+	opts crypto.SignerOpts
+}
+
+func (o *recordingRemoteSigner) SignDigest(digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	o.opts = opts
+	return o.FileRemoteSigner.SignDigest(digest, opts)
+}
+
+func TestNewSignerFromRemote_hash_supplied(t *testing.T) {
+	remote, err := NewFileRemoteSigner("testcases/src/ec-p256.jwk")
+	require.NoError(t, err)
+
+	recorder := &recordingRemoteSigner{FileRemoteSigner: *remote}
+
+	signer, err := NewSignerFromRemote(recorder)
+	require.NoError(t, err)
+
+	_, err = signer.Sign(rand.Reader, []byte("to be signed"))
+	require.NoError(t, err)
+
+	require.NotNil(t, recorder.opts)
+	assert.Equal(t, crypto.SHA256, recorder.opts.HashFunc())
+}
+
+func TestFileRemoteSigner_key_changed(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key.jwk")
+	require.NoError(t, os.WriteFile(keyFile, testES256Key, 0600))
+
+	remote, err := NewFileRemoteSigner(keyFile)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(keyFile, testES384Key, 0600))
+
+	_, err = remote.SignDigest(make([]byte, 32), crypto.SHA256)
+	assert.EqualError(t, err, "key in "+keyFile+" has changed")
+
+	_, err = NewFileRemoteSigner(filepath.Join(t.TempDir(), "missing.jwk"))
+	assert.ErrorContains(t, err, "reading key file")
+}
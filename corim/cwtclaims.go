@@ -0,0 +1,227 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jraman567/corim/validation"
+)
+
+// HeaderLabelCWTClaims is the COSE header parameter carrying a CWT Claims Set
+// (RFC 9597). Newer CoRIM drafts use it in the protected header of a
+// signed-corim to convey signer and validity information, in lieu of the
+// corim-meta header parameter.
+var HeaderLabelCWTClaims = int64(15)
+
+// MetaHeaderFormat selects how the Meta of a SignedCorim is conveyed in the
+// protected header of the COSE Sign1 envelope.
+type MetaHeaderFormat int
+
+const (
+	// MetaHeaderCorimMeta emits the CBOR-encoded corim-meta-map under the
+	// corim-meta (8) header label. This is the default.
+	MetaHeaderCorimMeta MetaHeaderFormat = iota
+	// MetaHeaderCWTClaims emits a CWT Claims Set under the CWT-Claims (15)
+	// header label.
+	MetaHeaderCWTClaims
+	// MetaHeaderBoth emits both the corim-meta and the CWT-Claims header
+	// parameters.
+	MetaHeaderBoth
+)
+
+// String returns a printable representation of the MetaHeaderFormat
+func (o MetaHeaderFormat) String() string {
+	switch o {
+	case MetaHeaderCorimMeta:
+		return "corim-meta"
+	case MetaHeaderCWTClaims:
+		return "cwt-claims"
+	case MetaHeaderBoth:
+		return "corim-meta+cwt-claims"
+	default:
+		return fmt.Sprintf("MetaHeaderFormat(%d)", o)
+	}
+}
+
+// Valid checks that the MetaHeaderFormat is one of the known values
+func (o MetaHeaderFormat) Valid() error {
+	switch o {
+	case MetaHeaderCorimMeta, MetaHeaderCWTClaims, MetaHeaderBoth:
+		return nil
+	default:
+		return validation.New(validation.CodeUnknown, "unknown meta header format %d", o)
+	}
+}
+
+// CWTClaims stores the subset of the CWT Claims Set (RFC 8392) that maps onto
+// the corim-meta-map: the issuer (iss) carries the signer name, while the
+// expiration time (exp) and not before (nbf) claims carry the validity period.
+// Times are NumericDate values, i.e., seconds since the Unix epoch. Other
+// claims have no counterpart in the corim-meta-map and are ignored.
+type CWTClaims struct {
+	Issuer    *string `cbor:"1,keyasint,omitempty" json:"iss,omitempty"`
+	NotAfter  *int64  `cbor:"4,keyasint,omitempty" json:"exp,omitempty"`
+	NotBefore *int64  `cbor:"5,keyasint,omitempty" json:"nbf,omitempty"`
+}
+
+// Valid checks that the CWTClaims can be mapped onto a corim-meta-map
+func (o CWTClaims) Valid() error {
+	if o.Issuer == nil || *o.Issuer == "" {
+		return validation.NewAt("iss", validation.CodeMissing, "missing issuer (iss) claim")
+	}
+
+	if o.NotBefore != nil && o.NotAfter == nil {
+		return validation.NewAt("exp", validation.CodeMissing, "not before (nbf) claim without expiration time (exp) claim")
+	}
+
+	if o.NotBefore != nil && *o.NotAfter < *o.NotBefore {
+		return validation.Errorf("exp",
+			"invalid nbf / exp: negative delta (%d)", *o.NotAfter-*o.NotBefore,
+		)
+	}
+
+	return nil
+}
+
+// ToCBOR serializes the target CWTClaims to CBOR
+func (o CWTClaims) ToCBOR() ([]byte, error) {
+	return em.Marshal(&o)
+}
+
+// FromCBOR deserializes the supplied CBOR data into the target CWTClaims
+func (o *CWTClaims) FromCBOR(data []byte) error {
+	return dm.Unmarshal(data, o)
+}
+
+// UnmarshalCBOR deserializes from CBOR. A NumericDate may be a floating point
+// value (RFC 8392, section 2), in which case the fractional part is discarded.
+func (o *CWTClaims) UnmarshalCBOR(data []byte) error {
+	var raw struct {
+		Issuer    *string `cbor:"1,keyasint,omitempty"`
+		NotAfter  any     `cbor:"4,keyasint,omitempty"`
+		NotBefore any     `cbor:"5,keyasint,omitempty"`
+	}
+
+	if err := dm.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	notAfter, err := numericDate(raw.NotAfter)
+	if err != nil {
+		return fmt.Errorf("exp: %w", err)
+	}
+
+	notBefore, err := numericDate(raw.NotBefore)
+	if err != nil {
+		return fmt.Errorf("nbf: %w", err)
+	}
+
+	o.Issuer = raw.Issuer
+	o.NotAfter = notAfter
+	o.NotBefore = notBefore
+
+	return nil
+}
+
+// numericDate converts a decoded NumericDate into whole seconds since the
+// Unix epoch
+func numericDate(v any) (*int64, error) {
+	var ret int64
+
+	switch t := v.(type) {
+	case nil:
+		return nil, nil
+	case uint64:
+		if t > math.MaxInt64 {
+			return nil, fmt.Errorf("NumericDate out of range: %d", t)
+		}
+		ret = int64(t)
+	case int64:
+		ret = t
+	case float64:
+		if math.IsNaN(t) || t < math.MinInt64 || t >= math.MaxInt64 {
+			return nil, fmt.Errorf("NumericDate out of range: %v", t)
+		}
+		ret = int64(math.Floor(t))
+	default:
+		return nil, fmt.Errorf("unexpected NumericDate type: %T", v)
+	}
+
+	return &ret, nil
+}
+
+// ToCWTClaims maps the target Meta onto a CWT Claims Set. The signer URI and
+// any extensions (of the Meta, signer or validity) cannot be represented in
+// the CWT claims, in which case an error is returned.
+func (o Meta) ToCWTClaims() (*CWTClaims, error) {
+	if err := o.Valid(); err != nil {
+		return nil, err
+	}
+
+	if o.Signer.URI != nil {
+		return nil, errors.New("signer URI cannot be represented in CWT claims")
+	}
+
+	if !o.Signer.Extensions.IsEmpty() {
+		return nil, errors.New("signer extensions cannot be represented in CWT claims")
+	}
+
+	if !o.Extensions.IsEmpty() {
+		return nil, errors.New("meta extensions cannot be represented in CWT claims")
+	}
+
+	if o.Validity != nil && !o.Validity.Extensions.IsEmpty() {
+		return nil, errors.New("validity extensions cannot be represented in CWT claims")
+	}
+
+	name := o.Signer.Name
+	claims := CWTClaims{Issuer: &name}
+
+	if o.Validity.isSet() {
+		notAfter := o.Validity.NotAfter.Unix()
+		claims.NotAfter = &notAfter
+
+		if o.Validity.NotBefore != nil {
+			notBefore := o.Validity.NotBefore.Unix()
+			claims.NotBefore = &notBefore
+		}
+	}
+
+	return &claims, nil
+}
+
+// FromCWTClaims populates the target Meta from the supplied CWT Claims Set.
+// The extensions registered with the target Meta and its signer and validity,
+// if any, are preserved.
+func (o *Meta) FromCWTClaims(claims CWTClaims) error {
+	if err := claims.Valid(); err != nil {
+		return err
+	}
+
+	var validityExts Extensions
+	if o.Validity != nil {
+		validityExts = o.Validity.Extensions
+	}
+
+	o.Signer.Name = *claims.Issuer
+	o.Signer.URI = nil
+	o.Validity = nil
+
+	if claims.NotAfter != nil {
+		v := Validity{NotAfter: time.Unix(*claims.NotAfter, 0).UTC(), Extensions: validityExts}
+
+		if claims.NotBefore != nil {
+			notBefore := time.Unix(*claims.NotBefore, 0).UTC()
+			v.NotBefore = &notBefore
+		}
+
+		o.Validity = &v
+	}
+
+	return nil
+}
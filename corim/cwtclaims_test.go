@@ -0,0 +1,124 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeta_ToCWTClaims_ok(t *testing.T) {
+	notBefore := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	m := NewMeta().SetSigner("ACME Ltd.", nil).SetValidity(notAfter, &notBefore)
+	require.NotNil(t, m)
+
+	claims, err := m.ToCWTClaims()
+	require.NoError(t, err)
+
+	assert.Equal(t, "ACME Ltd.", *claims.Issuer)
+	assert.Equal(t, notAfter.Unix(), *claims.NotAfter)
+	assert.Equal(t, notBefore.Unix(), *claims.NotBefore)
+
+	var actual Meta
+	err = actual.FromCWTClaims(*claims)
+	require.NoError(t, err)
+
+	assert.Equal(t, m.Signer.Name, actual.Signer.Name)
+	assert.True(t, notAfter.Equal(actual.Validity.NotAfter))
+	assert.True(t, notBefore.Equal(*actual.Validity.NotBefore))
+}
+
+func TestMeta_ToCWTClaims_fail(t *testing.T) {
+	_, err := Meta{}.ToCWTClaims()
+	assert.EqualError(t, err, "invalid signer: empty name")
+
+	uri := "https://acme.example"
+	m := NewMeta().SetSigner("ACME Ltd.", &uri)
+	require.NotNil(t, m)
+
+	_, err = m.ToCWTClaims()
+	assert.EqualError(t, err, "signer URI cannot be represented in CWT claims")
+}
+
+func TestCWTClaims_Valid(t *testing.T) {
+	iss := "ACME Ltd."
+	exp := int64(1000)
+	nbf := int64(2000)
+
+	testCases := []struct {
+		name     string
+		claims   CWTClaims
+		expected string
+	}{
+		{"no issuer", CWTClaims{}, "missing issuer (iss) claim"},
+		{"nbf without exp", CWTClaims{Issuer: &iss, NotBefore: &nbf},
+			"not before (nbf) claim without expiration time (exp) claim"},
+		{"exp before nbf", CWTClaims{Issuer: &iss, NotAfter: &exp, NotBefore: &nbf},
+			"invalid nbf / exp: negative delta (-1000)"},
+		{"ok", CWTClaims{Issuer: &iss, NotAfter: &exp}, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.claims.Valid()
+			if tc.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expected)
+			}
+		})
+	}
+}
+
+func TestCWTClaims_CBOR_roundtrip(t *testing.T) {
+	iss := "ACME Ltd."
+	exp := int64(1605181526)
+
+	claims := CWTClaims{Issuer: &iss, NotAfter: &exp}
+
+	data, err := claims.ToCBOR()
+	require.NoError(t, err)
+
+	// {1: "ACME Ltd.", 4: 1605181526}
+	expected := []byte{
+		0xa2, 0x01, 0x69, 0x41, 0x43, 0x4d, 0x45, 0x20, 0x4c, 0x74, 0x64,
+		0x2e, 0x04, 0x1a, 0x5f, 0xad, 0x20, 0x56,
+	}
+	assert.Equal(t, expected, data)
+
+	var actual CWTClaims
+	err = actual.FromCBOR(data)
+	require.NoError(t, err)
+	assert.Equal(t, claims, actual)
+}
+
+func TestCWTClaims_FromCBOR_NumericDate(t *testing.T) {
+	// {1: "ACME", 2: "sub", 4: 1605181526.5, 5: 1605181000}
+	data := []byte{
+		0xa4, 0x01, 0x64, 0x41, 0x43, 0x4d, 0x45, 0x02, 0x63, 0x73, 0x75, 0x62,
+		0x04, 0xfb, 0x41, 0xd7, 0xeb, 0x48, 0x15, 0xa0, 0x00, 0x00,
+		0x05, 0x1a, 0x5f, 0xad, 0x1e, 0x48,
+	}
+
+	var claims CWTClaims
+	require.NoError(t, claims.FromCBOR(data))
+	assert.Equal(t, "ACME", *claims.Issuer)
+	assert.Equal(t, int64(1605181526), *claims.NotAfter)
+	assert.Equal(t, int64(1605181000), *claims.NotBefore)
+
+	// {1: "ACME", 4: "soon"}
+	data = []byte{0xa2, 0x01, 0x64, 0x41, 0x43, 0x4d, 0x45, 0x04, 0x64, 0x73, 0x6f, 0x6f, 0x6e}
+	err := claims.FromCBOR(data)
+	assert.EqualError(t, err, "exp: unexpected NumericDate type: string")
+
+	// {1: "ACME", 5: NaN}
+	data = []byte{0xa2, 0x01, 0x64, 0x41, 0x43, 0x4d, 0x45, 0x05, 0xf9, 0x7e, 0x00}
+	err = claims.FromCBOR(data)
+	assert.EqualError(t, err, "nbf: NumericDate out of range: NaN")
+}
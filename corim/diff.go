@@ -0,0 +1,402 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/encoding"
+)
+
+// TagChange is a tag that was added, removed or changed. Tags are matched by
+// type and tag-id; tags without a tag-id are matched by content.
+type TagChange struct {
+	Kind    comid.ChangeKind `json:"kind"`
+	TagKind string           `json:"tag-kind"`
+	TagID   string           `json:"tag-id,omitempty"`
+	// Comid is the semantic difference between two versions of a CoMID
+	Comid *comid.ComidDiff `json:"comid,omitempty"`
+	// Changes lists the differences between two versions of a CoSWID or
+	// CoTS, which are not compared semantically
+	Changes []comid.FieldChange `json:"changes,omitempty"`
+}
+
+// LocatorChange is a dependent RIM that was added or removed
+type LocatorChange struct {
+	Kind comid.ChangeKind `json:"kind"`
+	Href string           `json:"href"`
+}
+
+// CorimDiff is the semantic difference between two unsigned CoRIMs
+type CorimDiff struct {
+	// Fields lists changes to the top-level fields (corim-id, profile,
+	// rim-validity)
+	Fields        []comid.FieldChange  `json:"fields,omitempty"`
+	Entities      []comid.EntityChange `json:"entities,omitempty"`
+	DependentRims []LocatorChange      `json:"dependent-rims,omitempty"`
+	Tags          []TagChange          `json:"tags,omitempty"`
+}
+
+// IsEmpty returns true if the compared CoRIMs are semantically equivalent
+func (o CorimDiff) IsEmpty() bool {
+	return len(o.Fields) == 0 && len(o.Entities) == 0 &&
+		len(o.DependentRims) == 0 && len(o.Tags) == 0
+}
+
+// ToJSON serializes the target CorimDiff to JSON
+func (o CorimDiff) ToJSON() ([]byte, error) {
+	return json.Marshal(&o)
+}
+
+// String returns the textual diff format (see comid.ComidDiff.String), with
+// the differences between CoMIDs nested below the corresponding tag
+func (o CorimDiff) String() string {
+	var b strings.Builder
+
+	for _, f := range o.Fields {
+		fmt.Fprintf(&b, "~ %s\n", f)
+	}
+
+	for _, e := range o.Entities {
+		fmt.Fprintf(&b, "%s entity %q\n", e.Kind.Symbol(), e.Name)
+		for _, c := range e.Changes {
+			fmt.Fprintf(&b, "    %s\n", c)
+		}
+	}
+
+	for _, l := range o.DependentRims {
+		fmt.Fprintf(&b, "%s dependent-rim %s\n", l.Kind.Symbol(), l.Href)
+	}
+
+	for _, t := range o.Tags {
+		fmt.Fprintf(&b, "%s %s", t.Kind.Symbol(), t.TagKind)
+		if t.TagID != "" {
+			fmt.Fprintf(&b, " %q", t.TagID)
+		}
+		b.WriteString("\n")
+
+		for _, c := range t.Changes {
+			fmt.Fprintf(&b, "    %s\n", c)
+		}
+
+		if t.Comid != nil {
+			t.Comid.WriteIndented(&b, "    ")
+		}
+	}
+
+	return b.String()
+}
+
+// Diff compares two unsigned CoRIMs semantically: tags are decoded (using the
+// extensions of the respective profiles) and matched independently of their
+// position, CoMIDs are compared with comid.Diff, and entities and dependent
+// RIMs are compared independently of their order
+func Diff(a, b *UnsignedCorim) (*CorimDiff, error) {
+	if a == nil || b == nil {
+		return nil, errors.New("nil CoRIM")
+	}
+
+	var ret CorimDiff
+
+	if ia, ib := a.GetID(), b.GetID(); ia != ib {
+		ret.Fields = append(ret.Fields, comid.FieldChange{Field: "corim-id", Old: ia, New: ib})
+	}
+
+	if !sameProfile(a.Profile, b.Profile) {
+		ret.Fields = append(ret.Fields, comid.FieldChange{
+			Field: "profile",
+			Old:   optionalProfileString(a),
+			New:   optionalProfileString(b),
+		})
+	}
+
+	ret.Fields = append(ret.Fields, comid.DiffFields(validityFields(a.RimValidity),
+		validityFields(b.RimValidity))...)
+
+	var err error
+
+	if ret.Entities, err = diffEntities(a.Entities, b.Entities); err != nil {
+		return nil, err
+	}
+
+	ret.DependentRims = diffDependentRims(a.DependentRims, b.DependentRims)
+
+	if ret.Tags, err = diffTags(a, b); err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+func optionalProfileString(uc *UnsignedCorim) string {
+	if uc.Profile == nil {
+		return ""
+	}
+
+	return profileString(uc.Profile)
+}
+
+func validityFields(v *Validity) map[string]string {
+	ret := make(map[string]string)
+
+	if !v.isSet() {
+		return ret
+	}
+
+	if v.NotBefore != nil {
+		ret["not-before"] = v.NotBefore.UTC().Format(time.RFC3339)
+	}
+	ret["not-after"] = v.NotAfter.UTC().Format(time.RFC3339)
+
+	return ret
+}
+
+func diffEntities(a, b *Entities) ([]comid.EntityChange, error) {
+	ea, order, err := entitiesByName(a, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	eb, order, err := entitiesByName(b, order)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []comid.EntityChange
+
+	for _, name := range order {
+		fa, inA := ea[name]
+		fb, inB := eb[name]
+
+		switch {
+		case !inA:
+			ret = append(ret, comid.EntityChange{Kind: comid.ChangeAdded, Name: name})
+		case !inB:
+			ret = append(ret, comid.EntityChange{Kind: comid.ChangeRemoved, Name: name})
+		default:
+			if changes := comid.DiffFields(fa, fb); len(changes) > 0 {
+				ret = append(ret, comid.EntityChange{
+					Kind:    comid.ChangeModified,
+					Name:    name,
+					Changes: changes,
+				})
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// entitiesByName returns the printable fields of each entity, keyed by name,
+// and appends the names not already in order to it
+func entitiesByName(ents *Entities, order []string) (map[string]map[string]string, []string, error) {
+	ret := make(map[string]map[string]string)
+
+	if ents == nil {
+		return ret, order, nil
+	}
+
+	for i, e := range ents.Values {
+		name := ""
+		if e.Name != nil {
+			name = e.Name.String()
+		}
+
+		fields := make(map[string]string)
+
+		if e.RegID != nil {
+			fields["regid"] = string(*e.RegID)
+		}
+
+		roles := make([]string, 0, len(e.Roles))
+		for _, r := range e.Roles {
+			roles = append(roles, r.String())
+		}
+		sort.Strings(roles)
+		fields["roles"] = strings.Join(roles, ",")
+
+		if e.Extensions.IMapValue != nil {
+			ext, err := json.Marshal(e.Extensions.IMapValue)
+			if err != nil {
+				return nil, nil, fmt.Errorf("encoding extensions of entity %d: %w", i, err)
+			}
+			fields["extensions"] = string(ext)
+		}
+
+		if _, ok := ret[name]; !ok && !containsString(order, name) {
+			order = append(order, name)
+		}
+
+		ret[name] = fields
+	}
+
+	return ret, order, nil
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func diffDependentRims(a, b *Locators) []LocatorChange {
+	key := func(l Locator) string {
+		if l.Thumbprint != nil {
+			return fmt.Sprintf("%s (%s)", l.Href, l.Thumbprint)
+		}
+		return string(l.Href)
+	}
+
+	var la, lb []string
+
+	if a != nil {
+		for _, l := range a.Values {
+			la = append(la, key(l))
+		}
+	}
+
+	if b != nil {
+		for _, l := range b.Values {
+			lb = append(lb, key(l))
+		}
+	}
+
+	var ret []LocatorChange
+
+	for _, l := range la {
+		if !containsString(lb, l) {
+			ret = append(ret, LocatorChange{Kind: comid.ChangeRemoved, Href: l})
+		}
+	}
+
+	for _, l := range lb {
+		if !containsString(la, l) {
+			ret = append(ret, LocatorChange{Kind: comid.ChangeAdded, Href: l})
+		}
+	}
+
+	return ret
+}
+
+// tagKey returns the key used to match tags across CoRIMs
+func tagKey(t *TypedTag) (string, string) {
+	if id, ok := t.TagID(); ok {
+		return fmt.Sprintf("%d/%s", t.Kind, id), id
+	}
+
+	return fmt.Sprintf("%d/%x", t.Kind, []byte(t.Raw)), ""
+}
+
+func diffTags(a, b *UnsignedCorim) ([]TagChange, error) {
+	ta, err := a.DecodeTags()
+	if err != nil {
+		return nil, fmt.Errorf("first CoRIM: %w", err)
+	}
+
+	tb, err := b.DecodeTags()
+	if err != nil {
+		return nil, fmt.Errorf("second CoRIM: %w", err)
+	}
+
+	byKey := make(map[string]*TypedTag, len(tb))
+	for _, t := range tb {
+		k, _ := tagKey(t)
+		if _, ok := byKey[k]; !ok {
+			byKey[k] = t
+		}
+	}
+
+	var ret []TagChange
+	seen := make(map[string]bool, len(ta))
+
+	for _, t := range ta {
+		k, id := tagKey(t)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		other, ok := byKey[k]
+		if !ok {
+			ret = append(ret, TagChange{Kind: comid.ChangeRemoved, TagKind: t.Kind.String(), TagID: id})
+			continue
+		}
+
+		c, err := diffTag(t, other)
+		if err != nil {
+			return nil, fmt.Errorf("%s %q: %w", t.Kind, id, err)
+		}
+
+		if c != nil {
+			c.TagID = id
+			ret = append(ret, *c)
+		}
+	}
+
+	for _, t := range tb {
+		k, id := tagKey(t)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		ret = append(ret, TagChange{Kind: comid.ChangeAdded, TagKind: t.Kind.String(), TagID: id})
+	}
+
+	return ret, nil
+}
+
+// diffTag compares two tags with the same type and tag-id, returning nil if
+// they are equivalent
+func diffTag(a, b *TypedTag) (*TagChange, error) {
+	ret := TagChange{Kind: comid.ChangeModified, TagKind: a.Kind.String()}
+
+	if a.Comid != nil && b.Comid != nil {
+		d, err := comid.Diff(a.Comid, b.Comid)
+		if err != nil {
+			return nil, err
+		}
+
+		if d.IsEmpty() {
+			return nil, nil
+		}
+
+		ret.Comid = d
+
+		return &ret, nil
+	}
+
+	if encoding.EqualCBOR(a.Raw, b.Raw) {
+		return nil, nil
+	}
+
+	ia, _ := a.TagIdentity()
+	ib, _ := b.TagIdentity()
+
+	if ia.TagVersion != ib.TagVersion {
+		ret.Changes = append(ret.Changes, comid.FieldChange{
+			Field: "tag-version",
+			Old:   fmt.Sprint(ia.TagVersion),
+			New:   fmt.Sprint(ib.TagVersion),
+		})
+	}
+
+	ret.Changes = append(ret.Changes, comid.FieldChange{
+		Field: "content",
+		Old:   fmt.Sprintf("%d bytes", len(a.Raw)),
+		New:   fmt.Sprintf("%d bytes", len(b.Raw)),
+	})
+
+	return &ret, nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff_identical(t *testing.T) {
+	a := corimWith(t, "a", psaComid(t, "fw", 1, ""), psaComid(t, "bl", 0, ""))
+	b := corimWith(t, "a", psaComid(t, "bl", 0, ""), psaComid(t, "fw", 1, ""))
+
+	d, err := Diff(a, b)
+	require.NoError(t, err)
+	assert.True(t, d.IsEmpty())
+	assert.Equal(t, "", d.String())
+}
+
+func TestDiff_tags(t *testing.T) {
+	a := corimWith(t, "a", psaComid(t, "fw", 1, ""), psaComid(t, "bl", 0, ""))
+	b := corimWith(t, "a", psaComid(t, "fw", 2, ""), psaComid(t, "os", 0, ""))
+
+	d, err := Diff(a, b)
+	require.NoError(t, err)
+	require.Len(t, d.Tags, 3)
+
+	assert.Equal(t, comid.ChangeModified, d.Tags[0].Kind)
+	assert.Equal(t, "comid", d.Tags[0].TagKind)
+	assert.Equal(t, "fw", d.Tags[0].TagID)
+	require.NotNil(t, d.Tags[0].Comid)
+	assert.Equal(t, []comid.FieldChange{
+		{Field: "tag-version", Old: "1", New: "2"},
+	}, d.Tags[0].Comid.Fields)
+
+	assert.Equal(t, TagChange{Kind: comid.ChangeRemoved, TagKind: "comid", TagID: "bl"}, d.Tags[1])
+	assert.Equal(t, TagChange{Kind: comid.ChangeAdded, TagKind: "comid", TagID: "os"}, d.Tags[2])
+
+	expected := `~ comid "fw"
+    ~ tag-version: 1 -> 2
+- comid "bl"
++ comid "os"
+`
+	assert.Equal(t, expected, d.String())
+
+	data, err := d.ToJSON()
+	require.NoError(t, err)
+
+	var back CorimDiff
+	require.NoError(t, json.Unmarshal(data, &back))
+	assert.Equal(t, *d, back)
+}
+
+func TestDiff_cots(t *testing.T) {
+	a := newMixedTagsCorim(t)
+	b := newMixedTagsCorim(t)
+
+	tags, err := b.DecodeTags()
+	require.NoError(t, err)
+
+	ts := tags[1].Cots
+	ts.TagIdentity.TagVersion = 6
+	data, err := ts.ToCBOR()
+	require.NoError(t, err)
+	b.Tags[1] = append(append(Tag{}, tags[1].Raw[:3]...), data...)
+
+	d, err := Diff(a, b)
+	require.NoError(t, err)
+	require.Len(t, d.Tags, 1)
+
+	assert.Equal(t, "cots", d.Tags[0].TagKind)
+	assert.Nil(t, d.Tags[0].Comid)
+	require.Len(t, d.Tags[0].Changes, 2)
+	assert.Equal(t, comid.FieldChange{Field: "tag-version", Old: "5", New: "6"}, d.Tags[0].Changes[0])
+	assert.Equal(t, "content", d.Tags[0].Changes[1].Field)
+}
+
+func TestDiff_metadata(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	a := corimWith(t, "a", psaComid(t, "fw", 1, "")).
+		AddEntity("ACME Ltd.", nil, RoleManifestCreator).
+		AddDependentRim("https://acme.example/rims/a.cbor", nil).
+		SetRimValidity(t1, nil)
+
+	b := corimWith(t, "b", psaComid(t, "fw", 1, "")).
+		SetProfile("http://example.com/p1").
+		AddEntity("Other Corp.", nil, RoleManifestCreator).
+		AddDependentRim("https://acme.example/rims/b.cbor", nil).
+		SetRimValidity(t2, &t1)
+
+	d, err := Diff(a, b)
+	require.NoError(t, err)
+	assert.Empty(t, d.Tags)
+
+	expected := `~ corim-id: a -> b
+~ profile: <none> -> http://example.com/p1
+~ not-after: 2024-01-01T00:00:00Z -> 2025-01-01T00:00:00Z
+~ not-before: <none> -> 2024-01-01T00:00:00Z
+- entity "ACME Ltd."
++ entity "Other Corp."
+- dependent-rim https://acme.example/rims/a.cbor
++ dependent-rim https://acme.example/rims/b.cbor
+`
+	assert.Equal(t, expected, d.String())
+}
+
+func TestDiff_fail(t *testing.T) {
+	_, err := Diff(nil, NewUnsignedCorim())
+	assert.EqualError(t, err, "nil CoRIM")
+
+	bad := NewUnsignedCorim().SetID("bad")
+	bad.Tags = []Tag{append(append(Tag{}, ComidTag...), 0xff)}
+
+	_, err = Diff(corimWith(t, "a", psaComid(t, "fw", 1, "")), bad)
+	assert.ErrorContains(t, err, "second CoRIM: tag at index 0: decoding comid")
+}
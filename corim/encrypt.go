@@ -0,0 +1,738 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	cbor "github.com/fxamacker/cbor/v2"
+	cose "github.com/veraison/go-cose"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Content encryption (AES-GCM) and key distribution (AES key wrap, ECDH-ES
+// with AES key wrap) algorithms, as registered in RFC 9053. These are not
+// supported by the COSE library, and are implemented here.
+const (
+	AlgorithmA128GCM = cose.Algorithm(1)
+	AlgorithmA192GCM = cose.Algorithm(2)
+	AlgorithmA256GCM = cose.Algorithm(3)
+
+	AlgorithmA128KW = cose.Algorithm(-3)
+	AlgorithmA192KW = cose.Algorithm(-4)
+	AlgorithmA256KW = cose.Algorithm(-5)
+
+	AlgorithmECDHESA128KW = cose.Algorithm(-29)
+	AlgorithmECDHESA192KW = cose.Algorithm(-30)
+	AlgorithmECDHESA256KW = cose.Algorithm(-31)
+)
+
+// SignedContentType is the content type used when a signed-corim is the
+// plaintext of an encrypted message
+var SignedContentType = "application/rim+cose"
+
+// ErrEncryptedCorim is returned when encrypted data is passed to a function
+// expecting a plaintext CoRIM
+var ErrEncryptedCorim = errors.New("CoRIM is encrypted")
+
+const (
+	coseEncrypt0Tag = 16
+	coseEncryptTag  = 96
+
+	headerLabelIV           = int64(5)
+	headerLabelEphemeralKey = int64(-1)
+)
+
+// Recipient describes a recipient of a COSE_Encrypt message, i.e., the
+// algorithm and key used to distribute the content-encryption key to it.
+type Recipient struct {
+	// Algorithm is either one of the AES key wrap algorithms (A128KW,
+	// A192KW, A256KW) or one of the ECDH-ES + AES key wrap algorithms
+	// (ECDH-ES+A128KW, ECDH-ES+A192KW, ECDH-ES+A256KW)
+	Algorithm cose.Algorithm
+	// KeyID optionally identifies the recipient key
+	KeyID []byte
+	// Key is the key-encryption key ([]byte) for AES key wrap, or the
+	// recipient's public key (*ecdsa.PublicKey or *ecdh.PublicKey) for
+	// ECDH-ES
+	Key any
+}
+
+type coseEncrypt0 struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected map[int64]cbor.RawMessage
+	Ciphertext  []byte
+}
+
+type coseEncrypt struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected map[int64]cbor.RawMessage
+	Ciphertext  []byte
+	Recipients  []coseRecipient
+}
+
+type coseRecipient struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected map[int64]cbor.RawMessage
+	Ciphertext  []byte
+}
+
+type encProtectedHeader struct {
+	Alg         *int64 `cbor:"1,keyasint,omitempty"`
+	ContentType string `cbor:"3,keyasint,omitempty"`
+}
+
+type ec2Key struct {
+	Kty int64  `cbor:"1,keyasint"`
+	Crv int64  `cbor:"-1,keyasint"`
+	X   []byte `cbor:"-2,keyasint"`
+	Y   []byte `cbor:"-3,keyasint"`
+}
+
+// IsEncrypted reports whether the supplied data is a (tagged) COSE_Encrypt0 or
+// COSE_Encrypt message
+func IsEncrypted(data []byte) bool {
+	var tag cbor.RawTag
+
+	if err := dm.Unmarshal(data, &tag); err != nil {
+		return false
+	}
+
+	return tag.Number == coseEncrypt0Tag || tag.Number == coseEncryptTag
+}
+
+// EncryptToEncrypt0 encrypts the supplied plaintext with the supplied
+// content-encryption key using AES-GCM, and returns a tagged COSE_Encrypt0
+// message. The content type, if not empty, is carried in the protected header.
+func EncryptToEncrypt0(
+	plaintext []byte,
+	contentType string,
+	alg cose.Algorithm,
+	cek []byte,
+) ([]byte, error) {
+	protected, err := encodeEncProtected(alg, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	iv, ciphertext, err := sealGCM(alg, cek, plaintext, "Encrypt0", protected)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := coseEncrypt0{
+		Protected:   protected,
+		Unprotected: map[int64]cbor.RawMessage{headerLabelIV: iv},
+		Ciphertext:  ciphertext,
+	}
+
+	return em.Marshal(cbor.Tag{Number: coseEncrypt0Tag, Content: msg})
+}
+
+// EncryptToEncrypt encrypts the supplied plaintext using AES-GCM with a
+// randomly generated content-encryption key, which is distributed to each of
+// the supplied recipients, and returns a tagged COSE_Encrypt message. The
+// content type, if not empty, is carried in the protected header.
+func EncryptToEncrypt(
+	plaintext []byte,
+	contentType string,
+	alg cose.Algorithm,
+	recipients ...Recipient,
+) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients")
+	}
+
+	keyLen, err := gcmKeyLen(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	cek := make([]byte, keyLen)
+	if _, err = io.ReadFull(rand.Reader, cek); err != nil {
+		return nil, fmt.Errorf("generating content-encryption key: %w", err)
+	}
+
+	protected, err := encodeEncProtected(alg, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	iv, ciphertext, err := sealGCM(alg, cek, plaintext, "Encrypt", protected)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := coseEncrypt{
+		Protected:   protected,
+		Unprotected: map[int64]cbor.RawMessage{headerLabelIV: iv},
+		Ciphertext:  ciphertext,
+	}
+
+	for i, r := range recipients {
+		rcpt, err := r.wrapKey(cek)
+		if err != nil {
+			return nil, fmt.Errorf("recipient at index %d: %w", i, err)
+		}
+
+		msg.Recipients = append(msg.Recipients, *rcpt)
+	}
+
+	return em.Marshal(cbor.Tag{Number: coseEncryptTag, Content: msg})
+}
+
+// Decrypt decrypts the supplied COSE_Encrypt0 or COSE_Encrypt message and
+// returns the plaintext together with the content type found in the protected
+// header (if any). For COSE_Encrypt0, key is the content-encryption key
+// ([]byte). For COSE_Encrypt, key is either a key-encryption key ([]byte) or
+// an EC private key (*ecdsa.PrivateKey or *ecdh.PrivateKey), which is tried
+// against each recipient with a compatible algorithm.
+func Decrypt(data []byte, key any) ([]byte, string, error) {
+	var tag cbor.RawTag
+
+	if err := dm.Unmarshal(data, &tag); err != nil {
+		return nil, "", fmt.Errorf("decoding encrypted message: %w", err)
+	}
+
+	switch tag.Number {
+	case coseEncrypt0Tag:
+		var msg coseEncrypt0
+
+		if err := dm.Unmarshal(tag.Content, &msg); err != nil {
+			return nil, "", fmt.Errorf("decoding COSE_Encrypt0: %w", err)
+		}
+
+		cek, ok := key.([]byte)
+		if !ok {
+			return nil, "", fmt.Errorf("COSE_Encrypt0 requires a []byte key, got %T", key)
+		}
+
+		return openGCM(cek, msg.Protected, msg.Unprotected, msg.Ciphertext, "Encrypt0")
+	case coseEncryptTag:
+		var msg coseEncrypt
+
+		if err := dm.Unmarshal(tag.Content, &msg); err != nil {
+			return nil, "", fmt.Errorf("decoding COSE_Encrypt: %w", err)
+		}
+
+		for _, r := range msg.Recipients {
+			cek, err := r.unwrapKey(key)
+			if err != nil {
+				continue
+			}
+
+			return openGCM(cek, msg.Protected, msg.Unprotected, msg.Ciphertext, "Encrypt")
+		}
+
+		return nil, "", errors.New("no recipient could be decrypted with the supplied key")
+	default:
+		return nil, "", fmt.Errorf("unexpected CBOR tag %d for encrypted message", tag.Number)
+	}
+}
+
+func encodeEncProtected(alg cose.Algorithm, contentType string) ([]byte, error) {
+	a := int64(alg)
+
+	return em.Marshal(encProtectedHeader{Alg: &a, ContentType: contentType})
+}
+
+func encStructure(context string, protected []byte) ([]byte, error) {
+	return em.Marshal([]interface{}{context, protected, NoExternalData})
+}
+
+func gcmKeyLen(alg cose.Algorithm) (int, error) {
+	switch alg {
+	case AlgorithmA128GCM:
+		return 16, nil
+	case AlgorithmA192GCM:
+		return 24, nil
+	case AlgorithmA256GCM:
+		return 32, nil
+	default:
+		return 0, fmt.Errorf("unsupported content encryption algorithm %d", alg)
+	}
+}
+
+func newGCM(alg cose.Algorithm, key []byte) (cipher.AEAD, error) {
+	keyLen, err := gcmKeyLen(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != keyLen {
+		return nil, fmt.Errorf("expecting a %d bytes key, got %d", keyLen, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func sealGCM(
+	alg cose.Algorithm,
+	key, plaintext []byte,
+	context string,
+	protected []byte,
+) (cbor.RawMessage, []byte, error) {
+	aead, err := newGCM(alg, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aad, err := encStructure(context, protected)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	iv := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, nil, fmt.Errorf("generating IV: %w", err)
+	}
+
+	ivCBOR, err := em.Marshal(iv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ivCBOR, aead.Seal(nil, iv, plaintext, aad), nil
+}
+
+func openGCM(
+	key, protected []byte,
+	unprotected map[int64]cbor.RawMessage,
+	ciphertext []byte,
+	context string,
+) ([]byte, string, error) {
+	var hdr encProtectedHeader
+
+	if err := dm.Unmarshal(protected, &hdr); err != nil {
+		return nil, "", fmt.Errorf("decoding protected header: %w", err)
+	}
+
+	if hdr.Alg == nil {
+		return nil, "", errors.New("missing algorithm in protected header")
+	}
+
+	aead, err := newGCM(cose.Algorithm(*hdr.Alg), key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var iv []byte
+
+	if err := dm.Unmarshal(unprotected[headerLabelIV], &iv); err != nil {
+		return nil, "", fmt.Errorf("decoding IV: %w", err)
+	}
+
+	if len(iv) != aead.NonceSize() {
+		return nil, "", fmt.Errorf("expecting a %d bytes IV, got %d", aead.NonceSize(), len(iv))
+	}
+
+	aad, err := encStructure(context, protected)
+	if err != nil {
+		return nil, "", err
+	}
+
+	plaintext, err := aead.Open(nil, iv, ciphertext, aad)
+	if err != nil {
+		return nil, "", fmt.Errorf("decryption failed: %w", err)
+	}
+
+	return plaintext, hdr.ContentType, nil
+}
+
+func kwKeyLen(alg cose.Algorithm) (int, error) {
+	switch alg {
+	case AlgorithmA128KW, AlgorithmECDHESA128KW:
+		return 16, nil
+	case AlgorithmA192KW, AlgorithmECDHESA192KW:
+		return 24, nil
+	case AlgorithmA256KW, AlgorithmECDHESA256KW:
+		return 32, nil
+	default:
+		return 0, fmt.Errorf("unsupported key distribution algorithm %d", alg)
+	}
+}
+
+func isECDHES(alg cose.Algorithm) bool {
+	return alg == AlgorithmECDHESA128KW ||
+		alg == AlgorithmECDHESA192KW ||
+		alg == AlgorithmECDHESA256KW
+}
+
+func (o Recipient) wrapKey(cek []byte) (*coseRecipient, error) {
+	keyLen, err := kwKeyLen(o.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	rcpt := coseRecipient{
+		Protected:   []byte{},
+		Unprotected: map[int64]cbor.RawMessage{},
+	}
+
+	if o.KeyID != nil {
+		kid, err := em.Marshal(o.KeyID)
+		if err != nil {
+			return nil, err
+		}
+		rcpt.Unprotected[cose.HeaderLabelKeyID] = kid
+	}
+
+	var kek []byte
+
+	if isECDHES(o.Algorithm) {
+		pub, err := toECDHPublicKey(o.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		ephemeral, err := pub.Curve().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generating ephemeral key: %w", err)
+		}
+
+		epk, err := encodeEC2Key(ephemeral.PublicKey())
+		if err != nil {
+			return nil, err
+		}
+		rcpt.Unprotected[headerLabelEphemeralKey] = epk
+
+		// the algorithm of an ECDH-ES recipient must be protected
+		rcpt.Protected, err = encodeEncProtected(o.Algorithm, "")
+		if err != nil {
+			return nil, err
+		}
+
+		z, err := ephemeral.ECDH(pub)
+		if err != nil {
+			return nil, fmt.Errorf("computing shared secret: %w", err)
+		}
+
+		kek, err = deriveKEK(o.Algorithm, z, rcpt.Protected, keyLen)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var ok bool
+
+		kek, ok = o.Key.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("AES key wrap requires a []byte key, got %T", o.Key)
+		}
+
+		if len(kek) != keyLen {
+			return nil, fmt.Errorf("expecting a %d bytes key-encryption key, got %d", keyLen, len(kek))
+		}
+
+		// the protected header of an AES key wrap recipient must be
+		// empty, so the algorithm goes in the unprotected header
+		alg, err := em.Marshal(int64(o.Algorithm))
+		if err != nil {
+			return nil, err
+		}
+		rcpt.Unprotected[cose.HeaderLabelAlgorithm] = alg
+	}
+
+	rcpt.Ciphertext, err = aesKeyWrap(kek, cek)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rcpt, nil
+}
+
+func (o coseRecipient) unwrapKey(key any) ([]byte, error) {
+	var hdr encProtectedHeader
+
+	if len(o.Protected) > 0 {
+		if err := dm.Unmarshal(o.Protected, &hdr); err != nil {
+			return nil, fmt.Errorf("decoding recipient protected header: %w", err)
+		}
+	}
+
+	if hdr.Alg == nil {
+		if v, ok := o.Unprotected[cose.HeaderLabelAlgorithm]; ok {
+			var a int64
+			if err := dm.Unmarshal(v, &a); err != nil {
+				return nil, fmt.Errorf("decoding recipient algorithm: %w", err)
+			}
+			hdr.Alg = &a
+		}
+	}
+
+	if hdr.Alg == nil {
+		return nil, errors.New("missing recipient algorithm")
+	}
+
+	alg := cose.Algorithm(*hdr.Alg)
+
+	keyLen, err := kwKeyLen(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	var kek []byte
+
+	if isECDHES(alg) {
+		priv, err := toECDHPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		var epk ec2Key
+
+		if err := dm.Unmarshal(o.Unprotected[headerLabelEphemeralKey], &epk); err != nil {
+			return nil, fmt.Errorf("decoding ephemeral key: %w", err)
+		}
+
+		pub, err := decodeEC2Key(epk)
+		if err != nil {
+			return nil, err
+		}
+
+		z, err := priv.ECDH(pub)
+		if err != nil {
+			return nil, fmt.Errorf("computing shared secret: %w", err)
+		}
+
+		kek, err = deriveKEK(alg, z, o.Protected, keyLen)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var ok bool
+
+		kek, ok = key.([]byte)
+		if !ok || len(kek) != keyLen {
+			return nil, errors.New("key does not match recipient algorithm")
+		}
+	}
+
+	return aesKeyUnwrap(kek, o.Ciphertext)
+}
+
+// deriveKEK derives the key-encryption key from the ECDH shared secret using
+// HKDF-SHA-256 and the COSE_KDF_Context (RFC 9053, Section 5)
+func deriveKEK(alg cose.Algorithm, z, protected []byte, keyLen int) ([]byte, error) {
+	// the AlgorithmID is that of the key wrap algorithm
+	kwAlg := map[cose.Algorithm]int64{
+		AlgorithmECDHESA128KW: int64(AlgorithmA128KW),
+		AlgorithmECDHESA192KW: int64(AlgorithmA192KW),
+		AlgorithmECDHESA256KW: int64(AlgorithmA256KW),
+	}[alg]
+
+	if protected == nil {
+		protected = []byte{}
+	}
+
+	info, err := em.Marshal([]interface{}{
+		kwAlg,
+		[]interface{}{nil, nil, nil},
+		[]interface{}{nil, nil, nil},
+		[]interface{}{keyLen * 8, protected},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding COSE_KDF_Context: %w", err)
+	}
+
+	kek := make([]byte, keyLen)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, z, nil, info), kek); err != nil {
+		return nil, fmt.Errorf("deriving key-encryption key: %w", err)
+	}
+
+	return kek, nil
+}
+
+func toECDHPublicKey(k any) (*ecdh.PublicKey, error) {
+	switch v := k.(type) {
+	case *ecdh.PublicKey:
+		return v, nil
+	case *ecdsa.PublicKey:
+		return v.ECDH()
+	default:
+		return nil, fmt.Errorf("ECDH-ES requires an EC public key, got %T", k)
+	}
+}
+
+func toECDHPrivateKey(k any) (*ecdh.PrivateKey, error) {
+	switch v := k.(type) {
+	case *ecdh.PrivateKey:
+		return v, nil
+	case *ecdsa.PrivateKey:
+		return v.ECDH()
+	default:
+		return nil, fmt.Errorf("ECDH-ES requires an EC private key, got %T", k)
+	}
+}
+
+// COSE elliptic curve identifiers (RFC 9053, Section 7.1)
+var ecdhCurves = map[int64]ecdh.Curve{
+	1: ecdh.P256(),
+	2: ecdh.P384(),
+	3: ecdh.P521(),
+}
+
+func encodeEC2Key(pub *ecdh.PublicKey) (cbor.RawMessage, error) {
+	var crv int64
+
+	for id, c := range ecdhCurves {
+		if c == pub.Curve() {
+			crv = id
+		}
+	}
+
+	if crv == 0 {
+		return nil, errors.New("unsupported ECDH curve")
+	}
+
+	// uncompressed point: 0x04 || X || Y
+	raw := pub.Bytes()
+	size := (len(raw) - 1) / 2
+
+	return em.Marshal(ec2Key{Kty: 2, Crv: crv, X: raw[1 : 1+size], Y: raw[1+size:]})
+}
+
+func decodeEC2Key(k ec2Key) (*ecdh.PublicKey, error) {
+	if k.Kty != 2 {
+		return nil, fmt.Errorf("unsupported ephemeral key type %d", k.Kty)
+	}
+
+	curve, ok := ecdhCurves[k.Crv]
+	if !ok {
+		return nil, fmt.Errorf("unsupported ephemeral key curve %d", k.Crv)
+	}
+
+	raw := append([]byte{0x04}, k.X...)
+	raw = append(raw, k.Y...)
+
+	return curve.NewPublicKey(raw)
+}
+
+// EncryptToEncrypt0 serializes the target UnsignedCorim and encrypts it into a
+// COSE_Encrypt0 message using the supplied content-encryption key
+func (o UnsignedCorim) EncryptToEncrypt0(alg cose.Algorithm, cek []byte) ([]byte, error) {
+	plaintext, err := o.encryptablePayload()
+	if err != nil {
+		return nil, err
+	}
+
+	return EncryptToEncrypt0(plaintext, ContentType, alg, cek)
+}
+
+// EncryptToEncrypt serializes the target UnsignedCorim and encrypts it into a
+// COSE_Encrypt message for the supplied recipients
+func (o UnsignedCorim) EncryptToEncrypt(alg cose.Algorithm, recipients ...Recipient) ([]byte, error) {
+	plaintext, err := o.encryptablePayload()
+	if err != nil {
+		return nil, err
+	}
+
+	return EncryptToEncrypt(plaintext, ContentType, alg, recipients...)
+}
+
+func (o UnsignedCorim) encryptablePayload() ([]byte, error) {
+	if err := o.Valid(); err != nil {
+		return nil, fmt.Errorf("failed validation of unsigned CoRIM: %w", err)
+	}
+
+	data, err := o.ToCBOR()
+	if err != nil {
+		return nil, fmt.Errorf("failed CBOR encoding of unsigned CoRIM: %w", err)
+	}
+
+	return data, nil
+}
+
+// FromEncrypted decrypts the supplied COSE_Encrypt0 or COSE_Encrypt message
+// using the supplied key (see Decrypt), and decodes the resulting
+// unsigned-corim into the target UnsignedCorim
+func (o *UnsignedCorim) FromEncrypted(data []byte, key any) error {
+	plaintext, err := decryptCorim(data, key, ContentType)
+	if err != nil {
+		return err
+	}
+
+	return o.FromCBOR(plaintext)
+}
+
+// EncryptToEncrypt0 encrypts the target SignedCorim into a COSE_Encrypt0
+// message using the supplied content-encryption key. The target must have been
+// either signed (using Sign) or decoded (using FromCOSE).
+func (o SignedCorim) EncryptToEncrypt0(alg cose.Algorithm, cek []byte) ([]byte, error) {
+	plaintext, err := o.encryptablePayload()
+	if err != nil {
+		return nil, err
+	}
+
+	return EncryptToEncrypt0(plaintext, SignedContentType, alg, cek)
+}
+
+// EncryptToEncrypt encrypts the target SignedCorim into a COSE_Encrypt message
+// for the supplied recipients. The target must have been either signed (using
+// Sign) or decoded (using FromCOSE).
+func (o SignedCorim) EncryptToEncrypt(alg cose.Algorithm, recipients ...Recipient) ([]byte, error) {
+	plaintext, err := o.encryptablePayload()
+	if err != nil {
+		return nil, err
+	}
+
+	return EncryptToEncrypt(plaintext, SignedContentType, alg, recipients...)
+}
+
+func (o SignedCorim) encryptablePayload() ([]byte, error) {
+	if o.message == nil || len(o.message.Signature) == 0 {
+		return nil, errors.New("no signed Sign1 message found")
+	}
+
+	data, err := o.message.MarshalCBOR()
+	if err != nil {
+		return nil, fmt.Errorf("signed-corim marshaling failed: %w", err)
+	}
+
+	return data, nil
+}
+
+// FromEncrypted decrypts the supplied COSE_Encrypt0 or COSE_Encrypt message
+// using the supplied key (see Decrypt), and decodes the resulting
+// signed-corim into the target SignedCorim, as FromCOSE does. The signature is
+// not verified.
+func (o *SignedCorim) FromEncrypted(data []byte, key any) error {
+	plaintext, err := decryptCorim(data, key, SignedContentType)
+	if err != nil {
+		return err
+	}
+
+	return o.FromCOSE(plaintext)
+}
+
+func decryptCorim(data []byte, key any, expectedContentType string) ([]byte, error) {
+	plaintext, contentType, err := Decrypt(data, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if contentType != "" && contentType != expectedContentType {
+		return nil, fmt.Errorf(
+			"expecting content type %q, got %q instead",
+			expectedContentType, contentType,
+		)
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cose "github.com/veraison/go-cose"
+)
+
+func randomKey(t *testing.T, size int) []byte {
+	key := make([]byte, size)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+func TestAESKeyWrap_RFC3394_vector(t *testing.T) {
+	kek, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F")
+	key, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF")
+	expected, _ := hex.DecodeString("1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5")
+
+	wrapped, err := aesKeyWrap(kek, key)
+	require.NoError(t, err)
+	assert.Equal(t, expected, wrapped)
+
+	unwrapped, err := aesKeyUnwrap(kek, wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, key, unwrapped)
+
+	wrapped[0] ^= 0xff
+	_, err = aesKeyUnwrap(kek, wrapped)
+	assert.EqualError(t, err, "AES key unwrap integrity check failed")
+}
+
+func TestUnsignedCorim_EncryptToEncrypt0_ok(t *testing.T) {
+	uc := unsignedCorimFromCBOR(t, testGoodUnsignedCorimCBOR)
+	cek := randomKey(t, 32)
+
+	data, err := uc.EncryptToEncrypt0(AlgorithmA256GCM, cek)
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(data))
+
+	var actual UnsignedCorim
+
+	err = actual.FromEncrypted(data, cek)
+	require.NoError(t, err)
+	assert.Equal(t, uc.GetID(), actual.GetID())
+	assert.Equal(t, uc.Tags, actual.Tags)
+
+	_, err = UnmarshalUnsignedCorimFromCBOR(data)
+	assert.ErrorIs(t, err, ErrEncryptedCorim)
+
+	unmarshaled, err := UnmarshalUnsignedCorimFromEncrypted(data, cek)
+	require.NoError(t, err)
+	assert.Equal(t, uc.Tags, unmarshaled.Tags)
+
+	err = actual.FromEncrypted(data, randomKey(t, 32))
+	assert.ErrorContains(t, err, "decryption failed")
+
+	err = actual.FromEncrypted(data, randomKey(t, 16))
+	assert.EqualError(t, err, "expecting a 32 bytes key, got 16")
+}
+
+func TestUnsignedCorim_EncryptToEncrypt_recipients(t *testing.T) {
+	uc := unsignedCorimFromCBOR(t, testGoodUnsignedCorimCBOR)
+
+	kek := randomKey(t, 16)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	xKey, err := ecdh.P384().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	data, err := uc.EncryptToEncrypt(AlgorithmA128GCM,
+		Recipient{Algorithm: AlgorithmA128KW, KeyID: []byte("kek-1"), Key: kek},
+		Recipient{Algorithm: AlgorithmECDHESA128KW, Key: &ecKey.PublicKey},
+		Recipient{Algorithm: AlgorithmECDHESA256KW, Key: xKey.PublicKey()},
+	)
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(data))
+
+	for _, key := range []any{kek, ecKey, xKey} {
+		var actual UnsignedCorim
+
+		err = actual.FromEncrypted(data, key)
+		require.NoError(t, err)
+		assert.Equal(t, uc.Tags, actual.Tags)
+	}
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	var actual UnsignedCorim
+
+	err = actual.FromEncrypted(data, otherKey)
+	assert.EqualError(t, err, "no recipient could be decrypted with the supplied key")
+}
+
+func TestSignedCorim_Encrypt_ok(t *testing.T) {
+	signer, err := NewSignerFromJWK(testES256Key)
+	require.NoError(t, err)
+
+	pk, err := NewPublicKeyFromJWK(testES256Key)
+	require.NoError(t, err)
+
+	var sc SignedCorim
+
+	sc.UnsignedCorim = *unsignedCorimFromCBOR(t, testGoodUnsignedCorimCBOR)
+	sc.Meta = *metaGood(t)
+
+	_, err = sc.EncryptToEncrypt0(AlgorithmA256GCM, randomKey(t, 32))
+	assert.EqualError(t, err, "no signed Sign1 message found")
+
+	_, err = sc.Sign(signer)
+	require.NoError(t, err)
+
+	kek := randomKey(t, 32)
+
+	data, err := sc.EncryptToEncrypt(AlgorithmA256GCM,
+		Recipient{Algorithm: AlgorithmA256KW, Key: kek},
+	)
+	require.NoError(t, err)
+
+	_, err = UnmarshalSignedCorimFromCBOR(data)
+	assert.ErrorIs(t, err, ErrEncryptedCorim)
+
+	actual, err := UnmarshalSignedCorimFromEncrypted(data, kek)
+	require.NoError(t, err)
+
+	err = actual.Verify(pk)
+	assert.NoError(t, err)
+	assert.Equal(t, sc.Meta.Signer.Name, actual.Meta.Signer.Name)
+
+	// an encrypted signed-corim is not an unsigned-corim
+	_, err = UnmarshalUnsignedCorimFromEncrypted(data, kek)
+	assert.EqualError(t, err,
+		`expecting content type "application/rim+cbor", got "application/rim+cose" instead`)
+}
+
+func TestEncrypt_fail(t *testing.T) {
+	_, err := EncryptToEncrypt0([]byte("x"), "", cose.AlgorithmES256, randomKey(t, 16))
+	assert.EqualError(t, err, "unsupported content encryption algorithm -7")
+
+	_, err = EncryptToEncrypt([]byte("x"), "", AlgorithmA128GCM)
+	assert.EqualError(t, err, "no recipients")
+
+	_, err = EncryptToEncrypt([]byte("x"), "", AlgorithmA128GCM,
+		Recipient{Algorithm: AlgorithmA128KW, Key: randomKey(t, 32)})
+	assert.EqualError(t, err,
+		"recipient at index 0: expecting a 16 bytes key-encryption key, got 32")
+
+	_, err = EncryptToEncrypt([]byte("x"), "", AlgorithmA128GCM,
+		Recipient{Algorithm: AlgorithmECDHESA128KW, Key: randomKey(t, 16)})
+	assert.EqualError(t, err,
+		"recipient at index 0: ECDH-ES requires an EC public key, got []uint8")
+
+	_, _, err = Decrypt([]byte{0xd8, 0x12, 0x80}, nil)
+	assert.EqualError(t, err, "unexpected CBOR tag 18 for encrypted message")
+
+	assert.False(t, IsEncrypted(testGoodUnsignedCorimCBOR))
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/jraman567/corim/comid"
 	"github.com/jraman567/corim/encoding"
 	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 )
 
 // Entity stores an entity-map capable of CBOR and JSON serializations.
@@ -85,22 +86,28 @@ func (o *Entity) SetRoles(roles ...Role) *Entity {
 	return o
 }
 
-// Valid checks for validity of the fields within each Entity
+// Valid checks for validity of the fields within each Entity. The roles must
+// be registered with DefaultRegistry.
 func (o Entity) Valid() error {
+	return o.valid(DefaultRegistry)
+}
+
+// valid is like Valid, but the roles must be registered with reg
+func (o Entity) valid(reg *Registry) error {
 	if o.Name == nil {
-		return fmt.Errorf("invalid entity: empty entity-name")
+		return validation.Errorf("name", "invalid entity: %w", validation.New(validation.CodeMissing, "empty entity-name"))
 	}
 
 	if err := o.Name.Valid(); err != nil {
-		return fmt.Errorf("invalid entity: %w", err)
+		return validation.Errorf("name", "invalid entity: %w", err)
 	}
 
 	if o.RegID != nil && o.RegID.Empty() {
-		return fmt.Errorf("invalid entity: empty reg-id")
+		return validation.Errorf("regid", "invalid entity: %w", validation.New(validation.CodeEmpty, "empty reg-id"))
 	}
 
-	if err := o.Roles.Valid(); err != nil {
-		return fmt.Errorf("invalid entity: %w", err)
+	if err := o.Roles.valid(reg); err != nil {
+		return validation.Errorf("roles", "invalid entity: %w", err)
 	}
 
 	return o.Extensions.validEntity(&o)
@@ -108,22 +115,42 @@ func (o Entity) Valid() error {
 
 // UnmarshalCBOR deserializes from CBOR
 func (o *Entity) UnmarshalCBOR(data []byte) error {
-	return encoding.PopulateStructFromCBOR(dm, data, o)
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *Entity) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromCBOR(decModeFor(d), data, o)
 }
 
 // MarshalCBOR serializes to CBOR
 func (o Entity) MarshalCBOR() ([]byte, error) {
-	return encoding.SerializeStructToCBOR(em, o)
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o Entity) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return e.SerializeStructToCBOR(encModeFor(e), o)
 }
 
 // UnmarshalJSON deserializes from JSON
 func (o *Entity) UnmarshalJSON(data []byte) error {
-	return encoding.PopulateStructFromJSON(data, o)
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Entity) DecodeJSON(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromJSON(data, o)
 }
 
 // MarshalJSON serializes to JSON
 func (o Entity) MarshalJSON() ([]byte, error) {
-	return encoding.SerializeStructToJSON(o)
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o Entity) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
+	return e.SerializeStructToJSON(o)
 }
 
 // Entities is a container for Entity instances and their extensions.
@@ -156,19 +183,39 @@ func (o *Entities) Add(val *Entity) *Entities {
 }
 
 func (o Entities) MarshalCBOR() ([]byte, error) {
-	return (extensions.Collection[Entity, *Entity])(o).MarshalCBOR()
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o Entities) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return (extensions.Collection[Entity, *Entity])(o).EncodeCBOR(e)
 }
 
 func (o *Entities) UnmarshalCBOR(data []byte) error {
-	return (*extensions.Collection[Entity, *Entity])(o).UnmarshalCBOR(data)
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *Entities) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return (*extensions.Collection[Entity, *Entity])(o).DecodeCBOR(d, data)
 }
 
 func (o Entities) MarshalJSON() ([]byte, error) {
-	return (extensions.Collection[Entity, *Entity])(o).MarshalJSON()
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o Entities) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
+	return (extensions.Collection[Entity, *Entity])(o).EncodeJSON(e)
 }
 
 func (o *Entities) UnmarshalJSON(data []byte) error {
-	return (*extensions.Collection[Entity, *Entity])(o).UnmarshalJSON(data)
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Entities) DecodeJSON(d *encoding.Decoder, data []byte) error {
+	return (*extensions.Collection[Entity, *Entity])(o).DecodeJSON(d, data)
 }
 
 // EntityName encapsulates the name of the associated Entity. The CoRIM
@@ -181,7 +228,13 @@ type EntityName struct {
 // NewEntityName creates a new EntityName of the specified type using the
 // provided value.
 func NewEntityName(val any, typ string) (*EntityName, error) {
-	factory, ok := entityNameValueRegister[typ]
+	return DefaultRegistry.NewEntityName(val, typ)
+}
+
+// NewEntityName is like the package-level NewEntityName, but uses the entity
+// name types registered with the target Registry.
+func (o *Registry) NewEntityName(val any, typ string) (*EntityName, error) {
+	factory, ok := o.entityNameFactory(typ)
 	if !ok {
 		return nil, fmt.Errorf("unexpected entity name type: %s", typ)
 	}
@@ -209,23 +262,33 @@ func (o EntityName) String() string {
 // describing the problem otherwise.
 func (o EntityName) Valid() error {
 	if o.Value == nil {
-		return errors.New("empty entity name")
+		return validation.New(validation.CodeMissing, "empty entity name")
 	}
 
-	return o.Value.Valid()
+	return validation.Wrap(o.Value.Valid())
 }
 
 // MarshalCBOR serializes the EntityName into CBOR-encoded bytes.
 func (o EntityName) MarshalCBOR() ([]byte, error) {
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o EntityName) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
 	if err := o.Valid(); err != nil {
 		return nil, err
 	}
 
-	return em.Marshal(o.Value)
+	return encModeFor(e).Marshal(o.Value)
 }
 
 // UnmarshalCBOR deserializes the EntityName from CBOR-encoded bytes.
 func (o *EntityName) UnmarshalCBOR(data []byte) error {
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *EntityName) DecodeCBOR(d *encoding.Decoder, data []byte) error {
 	if len(data) == 0 {
 		return errors.New("empty")
 	}
@@ -234,7 +297,7 @@ func (o *EntityName) UnmarshalCBOR(data []byte) error {
 	if majorType == 3 { // text string
 		var text string
 
-		if err := dm.Unmarshal(data, &text); err != nil {
+		if err := decModeFor(d).Unmarshal(data, &text); err != nil {
 			return err
 		}
 
@@ -244,7 +307,7 @@ func (o *EntityName) UnmarshalCBOR(data []byte) error {
 		return nil
 	}
 
-	return dm.Unmarshal(data, &o.Value)
+	return decModeFor(d).Unmarshal(data, &o.Value)
 }
 
 // MarshalJSON serializes the EntityName into a JSON object.
@@ -272,6 +335,11 @@ func (o EntityName) MarshalJSON() ([]byte, error) {
 
 // UnmarshalJSON deserializes EntityName from the provided JSON object.
 func (o *EntityName) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *EntityName) DecodeJSON(d *encoding.Decoder, data []byte) error {
 	var text string
 	if err := json.Unmarshal(data, &text); err == nil {
 		*o = *MustNewStringEntityName(text)
@@ -284,7 +352,7 @@ func (o *EntityName) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("entity name decoding failure: %w", err)
 	}
 
-	decoded, err := NewEntityName(nil, tnv.Type)
+	decoded, err := registryFrom(d.Registry).NewEntityName(nil, tnv.Type)
 	if err != nil {
 		return err
 	}
@@ -357,7 +425,7 @@ func (o StringEntityName) Type() string {
 
 func (o StringEntityName) Valid() error {
 	if o == "" {
-		return errors.New("empty entity-name")
+		return validation.New(validation.CodeEmpty, "empty entity-name")
 	}
 
 	return nil
@@ -373,7 +441,9 @@ func (o StringEntityName) Valid() error {
 // See also https://go.dev/ref/spec#The_zero_value
 type IEntityNameFactory func(any) (*EntityName, error)
 
-var entityNameValueRegister = map[string]IEntityNameFactory{
+// builtinEntityNameTypes contains the entity name types every Registry starts
+// with
+var builtinEntityNameTypes = map[string]IEntityNameFactory{
 	extensions.StringType: NewStringEntityName,
 }
 
@@ -381,22 +451,31 @@ var entityNameValueRegister = map[string]IEntityNameFactory{
 // (created by the provided IEntityNameFactory) under the specified type name
 // and CBOR tag.
 func RegisterEntityNameType(tag uint64, factory IEntityNameFactory) error {
+	return DefaultRegistry.RegisterEntityNameType(tag, factory)
+}
 
+// RegisterEntityNameType is like the package-level RegisterEntityNameType, but
+// registers with the target Registry.
+func (o *Registry) RegisterEntityNameType(tag uint64, factory IEntityNameFactory) error {
 	nilVal, err := factory(nil)
 	if err != nil {
 		return err
 	}
 
 	typ := nilVal.Value.Type()
-	if _, exists := entityNameValueRegister[typ]; exists {
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, exists := o.entityNames[typ]; exists {
 		return fmt.Errorf("entity name type with name %q already exists", typ)
 	}
 
-	if err := registerCORIMTag(tag, nilVal.Value); err != nil {
+	if err := o.registerTag(tag, nilVal.Value); err != nil {
 		return err
 	}
 
-	entityNameValueRegister[typ] = factory
+	o.entityNames[typ] = factory
 
 	return nil
 }
@@ -4,12 +4,16 @@ package corim
 
 import (
 	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 )
 
 const (
 	ExtUnsignedCorim extensions.Point = "UnsignedCorim"
 	ExtEntity        extensions.Point = "CorimEntity"
 	ExtSigner        extensions.Point = "Signer"
+	ExtLocator       extensions.Point = "Locator"
+	ExtMeta          extensions.Point = "Meta"
+	ExtValidity      extensions.Point = "Validity"
 )
 
 type IEntityConstrainer interface {
@@ -24,6 +28,18 @@ type ISignerConstrainer interface {
 	ConstrainSigner(*Signer) error
 }
 
+type ILocatorConstrainer interface {
+	ConstrainLocator(*Locator) error
+}
+
+type IMetaConstrainer interface {
+	ConstrainMeta(*Meta) error
+}
+
+type IValidityConstrainer interface {
+	ConstrainValidity(*Validity) error
+}
+
 type Extensions struct {
 	extensions.Extensions
 }
@@ -36,7 +52,7 @@ func (o *Extensions) validEntity(entity *Entity) error {
 	ev, ok := o.IMapValue.(IEntityConstrainer)
 	if ok {
 		if err := ev.ConstrainEntity(entity); err != nil {
-			return err
+			return validation.New(validation.CodeConstraint, "%w", err)
 		}
 	}
 
@@ -51,7 +67,7 @@ func (o *Extensions) validCorim(c *UnsignedCorim) error {
 	ev, ok := o.IMapValue.(ICorimConstrainer)
 	if ok {
 		if err := ev.ConstrainCorim(c); err != nil {
-			return err
+			return validation.New(validation.CodeConstraint, "%w", err)
 		}
 	}
 
@@ -66,7 +82,52 @@ func (o *Extensions) validSigner(signer *Signer) error {
 	ev, ok := o.IMapValue.(ISignerConstrainer)
 	if ok {
 		if err := ev.ConstrainSigner(signer); err != nil {
-			return err
+			return validation.New(validation.CodeConstraint, "%w", err)
+		}
+	}
+
+	return nil
+}
+
+func (o *Extensions) validLocator(locator *Locator) error {
+	if !o.HaveExtensions() {
+		return nil
+	}
+
+	ev, ok := o.IMapValue.(ILocatorConstrainer)
+	if ok {
+		if err := ev.ConstrainLocator(locator); err != nil {
+			return validation.New(validation.CodeConstraint, "%w", err)
+		}
+	}
+
+	return nil
+}
+
+func (o *Extensions) validMeta(meta *Meta) error {
+	if !o.HaveExtensions() {
+		return nil
+	}
+
+	ev, ok := o.IMapValue.(IMetaConstrainer)
+	if ok {
+		if err := ev.ConstrainMeta(meta); err != nil {
+			return validation.New(validation.CodeConstraint, "%w", err)
+		}
+	}
+
+	return nil
+}
+
+func (o *Extensions) validValidity(validity *Validity) error {
+	if !o.HaveExtensions() {
+		return nil
+	}
+
+	ev, ok := o.IMapValue.(IValidityConstrainer)
+	if ok {
+		if err := ev.ConstrainValidity(validity); err != nil {
+			return validation.New(validation.CodeConstraint, "%w", err)
 		}
 	}
 
@@ -33,6 +33,18 @@ func (o TestExtensions) ConstrainSigner(_ *Signer) error {
 	return errors.New("invalid")
 }
 
+func (o TestExtensions) ConstrainLocator(_ *Locator) error {
+	return errors.New("invalid")
+}
+
+func (o TestExtensions) ConstrainMeta(_ *Meta) error {
+	return errors.New("invalid")
+}
+
+func (o TestExtensions) ConstrainValidity(_ *Validity) error {
+	return errors.New("invalid")
+}
+
 func TestEntityExtensions_Valid(t *testing.T) {
 	ent := NewEntity()
 	ent.SetName("The Simpsons")
@@ -54,6 +66,9 @@ func TestEntityExtensions_Valid(t *testing.T) {
 
 	assert.EqualError(t, ent.Extensions.validCorim(nil), "invalid")
 	assert.EqualError(t, ent.Extensions.validSigner(nil), "invalid")
+	assert.EqualError(t, ent.Extensions.validLocator(nil), "invalid")
+	assert.EqualError(t, ent.Extensions.validMeta(nil), "invalid")
+	assert.EqualError(t, ent.Extensions.validValidity(nil), "invalid")
 }
 
 func TestEntityExtensions_CBOR(t *testing.T) {
@@ -0,0 +1,344 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/cloudflare/circl/sign/ed448"
+	cose "github.com/veraison/go-cose"
+)
+
+// RSASSA-PKCS1-v1_5 algorithms (RFC 8812). These are not supported by the
+// COSE library, and are implemented here so that callers can explicitly choose
+// PKCS#1 v1.5 rather than PSS padding for RSA keys.
+const (
+	AlgorithmRS256 = cose.Algorithm(-257)
+	AlgorithmRS384 = cose.Algorithm(-258)
+	AlgorithmRS512 = cose.Algorithm(-259)
+)
+
+// oidEd448 identifies Ed448 keys in PKCS#8 and SPKI structures (RFC 8410)
+var oidEd448 = asn1.ObjectIdentifier{1, 3, 101, 113}
+
+// NewSignerWithAlgorithm returns a cose.Signer that signs with the supplied
+// key using the explicitly specified algorithm. On top of the algorithms
+// supported by the COSE library, RS256, RS384 and RS512 can be used with RSA
+// keys, and EdDSA with Ed448 keys.
+func NewSignerWithAlgorithm(alg cose.Algorithm, key crypto.Signer) (cose.Signer, error) {
+	if key == nil {
+		return nil, errors.New("nil crypto signer")
+	}
+
+	switch alg {
+	case cose.AlgorithmEd25519:
+		if _, ok := key.Public().(ed448.PublicKey); ok {
+			return &ed448Signer{key: key}, nil
+		}
+
+		return cose.NewSigner(alg, key)
+	case AlgorithmRS256, AlgorithmRS384, AlgorithmRS512:
+		pk, ok := key.Public().(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", algName(alg), cose.ErrInvalidPubKey)
+		}
+
+		if err := checkRSAKeySize(pk); err != nil {
+			return nil, err
+		}
+
+		return &rsaPKCS1Signer{alg: alg, key: key}, nil
+	default:
+		return cose.NewSigner(alg, key)
+	}
+}
+
+// NewSignerFromPEM returns a cose.Signer for the private key in the supplied
+// PEM data. PKCS#8 ("PRIVATE KEY"), SEC 1 ("EC PRIVATE KEY") and PKCS#1 ("RSA
+// PRIVATE KEY") encodings are accepted. Ed448 keys must use PKCS#8. The signing algorithm is inferred from
+// the key, as in NewSignerFromCryptoSigner.
+func NewSignerFromPEM(data []byte) (cose.Signer, error) {
+	key, err := parsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSignerFromCryptoSigner(key)
+}
+
+// NewSignerFromPEMWithAlgorithm is like NewSignerFromPEM, except that the
+// signing algorithm is explicitly specified, e.g., to choose between RSA-PSS
+// (PS256, PS384, PS512) and RSA PKCS#1 v1.5 (RS256, RS384, RS512).
+func NewSignerFromPEMWithAlgorithm(data []byte, alg cose.Algorithm) (cose.Signer, error) {
+	key, err := parsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSignerWithAlgorithm(alg, key)
+}
+
+// NewPublicKeyFromPEM returns the public key in the supplied PEM data. SPKI
+// ("PUBLIC KEY") and PKCS#1 ("RSA PUBLIC KEY") encodings are accepted.
+func NewPublicKeyFromPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		pk, err := parsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing SPKI public key: %w", err)
+		}
+
+		if _, err := algFromPublicKey(pk); err != nil {
+			return nil, err
+		}
+
+		return pk, nil
+	case "RSA PUBLIC KEY":
+		pk, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing PKCS#1 public key: %w", err)
+		}
+
+		return pk, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+}
+
+func parsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var (
+		key any
+		err error
+	)
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = parsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing PKCS#8 private key: %w", err)
+		}
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing SEC 1 private key: %w", err)
+		}
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing PKCS#1 private key: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+
+	switch v := key.(type) {
+	case *ecdsa.PrivateKey, *rsa.PrivateKey, ed25519.PrivateKey, ed448.PrivateKey:
+		return v.(crypto.Signer), nil
+	default:
+		return nil, fmt.Errorf("unknown private key type %v", reflect.TypeOf(key))
+	}
+}
+
+// parsePKCS8PrivateKey is like x509.ParsePKCS8PrivateKey, except that it also
+// supports Ed448 keys
+func parsePKCS8PrivateKey(der []byte) (any, error) {
+	var p8 struct {
+		Version    int
+		Algo       pkix.AlgorithmIdentifier
+		PrivateKey []byte
+	}
+
+	if _, err := asn1.Unmarshal(der, &p8); err != nil || !p8.Algo.Algorithm.Equal(oidEd448) {
+		return x509.ParsePKCS8PrivateKey(der)
+	}
+
+	// the private key is itself an OCTET STRING containing the seed
+	var seed []byte
+
+	if _, err := asn1.Unmarshal(p8.PrivateKey, &seed); err != nil {
+		return nil, fmt.Errorf("invalid Ed448 private key: %w", err)
+	}
+
+	if len(seed) != ed448.SeedSize {
+		return nil, fmt.Errorf("invalid Ed448 private key size %d", len(seed))
+	}
+
+	return ed448.NewKeyFromSeed(seed), nil
+}
+
+// parsePKIXPublicKey is like x509.ParsePKIXPublicKey, except that it also
+// supports Ed448 keys
+func parsePKIXPublicKey(der []byte) (any, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+
+	if _, err := asn1.Unmarshal(der, &spki); err != nil || !spki.Algorithm.Algorithm.Equal(oidEd448) {
+		return x509.ParsePKIXPublicKey(der)
+	}
+
+	if len(spki.PublicKey.Bytes) != ed448.PublicKeySize {
+		return nil, fmt.Errorf("invalid Ed448 public key size %d", len(spki.PublicKey.Bytes))
+	}
+
+	return ed448.PublicKey(spki.PublicKey.Bytes), nil
+}
+
+// newVerifier is like cose.NewVerifier, except that it also supports the
+// RSASSA-PKCS1-v1_5 algorithms and EdDSA with Ed448 keys
+func newVerifier(alg cose.Algorithm, pk crypto.PublicKey) (cose.Verifier, error) {
+	switch alg {
+	case cose.AlgorithmEd25519:
+		if vk, ok := pk.(ed448.PublicKey); ok {
+			return &ed448Verifier{key: vk}, nil
+		}
+
+		return cose.NewVerifier(alg, pk)
+	case AlgorithmRS256, AlgorithmRS384, AlgorithmRS512:
+		vk, ok := pk.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", algName(alg), cose.ErrInvalidPubKey)
+		}
+
+		if err := checkRSAKeySize(vk); err != nil {
+			return nil, err
+		}
+
+		return &rsaPKCS1Verifier{alg: alg, key: vk}, nil
+	default:
+		return cose.NewVerifier(alg, pk)
+	}
+}
+
+// validSigningAlg checks that the supplied algorithm is one we know how to
+// sign and verify with
+func validSigningAlg(alg cose.Algorithm) error {
+	switch alg {
+	case cose.AlgorithmES256, cose.AlgorithmES384, cose.AlgorithmES512,
+		cose.AlgorithmPS256, cose.AlgorithmPS384, cose.AlgorithmPS512,
+		cose.AlgorithmEd25519,
+		AlgorithmRS256, AlgorithmRS384, AlgorithmRS512:
+		return nil
+	default:
+		return errors.New("signer has no algorithm")
+	}
+}
+
+func algName(alg cose.Algorithm) string {
+	switch alg {
+	case AlgorithmRS256:
+		return "RS256"
+	case AlgorithmRS384:
+		return "RS384"
+	case AlgorithmRS512:
+		return "RS512"
+	default:
+		return alg.String()
+	}
+}
+
+func checkRSAKeySize(pk *rsa.PublicKey) error {
+	// RFC 8230 6.1 requires RSA keys having a minimum size of 2048 bits.
+	if pk.N.BitLen() < 2048 {
+		return errors.New("RSA key must be at least 2048 bits long")
+	}
+
+	return nil
+}
+
+// rsaPKCS1Signer is a cose.Signer using RSASSA-PKCS1-v1_5
+type rsaPKCS1Signer struct {
+	alg cose.Algorithm
+	key crypto.Signer
+}
+
+func (o *rsaPKCS1Signer) Algorithm() cose.Algorithm {
+	return o.alg
+}
+
+func (o *rsaPKCS1Signer) Sign(rand io.Reader, content []byte) ([]byte, error) {
+	hash := algToHash(o.alg)
+
+	h := hash.New()
+	h.Write(content) // nolint:errcheck
+
+	return o.key.Sign(rand, h.Sum(nil), hash)
+}
+
+// rsaPKCS1Verifier is a cose.Verifier using RSASSA-PKCS1-v1_5
+type rsaPKCS1Verifier struct {
+	alg cose.Algorithm
+	key *rsa.PublicKey
+}
+
+func (o *rsaPKCS1Verifier) Algorithm() cose.Algorithm {
+	return o.alg
+}
+
+func (o *rsaPKCS1Verifier) Verify(content []byte, signature []byte) error {
+	hash := algToHash(o.alg)
+
+	h := hash.New()
+	h.Write(content) // nolint:errcheck
+
+	if err := rsa.VerifyPKCS1v15(o.key, hash, h.Sum(nil), signature); err != nil {
+		return cose.ErrVerification
+	}
+
+	return nil
+}
+
+// ed448Signer is a cose.Signer using EdDSA with an Ed448 key. The COSE library
+// only supports EdDSA with Ed25519 keys.
+type ed448Signer struct {
+	key crypto.Signer
+}
+
+func (o *ed448Signer) Algorithm() cose.Algorithm {
+	return cose.AlgorithmEd25519
+}
+
+func (o *ed448Signer) Sign(rand io.Reader, content []byte) ([]byte, error) {
+	// PureEdDSA signs the whole content, rather than a digest
+	return o.key.Sign(rand, content, crypto.Hash(0))
+}
+
+// ed448Verifier is a cose.Verifier using EdDSA with an Ed448 key
+type ed448Verifier struct {
+	key ed448.PublicKey
+}
+
+func (o *ed448Verifier) Algorithm() cose.Algorithm {
+	return cose.AlgorithmEd25519
+}
+
+func (o *ed448Verifier) Verify(content []byte, signature []byte) error {
+	if !ed448.Verify(o.key, content, signature, "") {
+		return cose.ErrVerification
+	}
+
+	return nil
+}
@@ -0,0 +1,293 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"testing"
+
+	"github.com/cloudflare/circl/sign/ed448"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cose "github.com/veraison/go-cose"
+)
+
+var (
+	testEd448Key = []byte(`{
+		"kty": "OKP",
+		"crv": "Ed448",
+		"x": "X9dEm1m0Yf0s54fsYWrUah2hNCSFpw4fig6nXYDpZ3jt8SR2m0bHBhvWeD3x5Q9s0foavq_oJWGA",
+		"d": "bIKlYsuAjRDWMr6JyFE-v2ySnzTd-oyfY8mWDvbjSKNSjIo_zC8ETjmj_FuUSS-PAy51SaIAmPlb"
+	  }`)
+
+	testEd25519PublicKey = []byte(`{
+		"kty": "OKP",
+		"crv": "Ed25519",
+		"x": "JL3cmVCzN3m3afnctG2agbjb6nrZWFl48A8Feknkpx0"
+	  }`)
+)
+
+func keyPEMsFromJWK(t *testing.T, j []byte) (private []byte, public []byte) {
+	_, key, err := getAlgAndKeyFromJWK(j)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	private = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	der, err = x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+
+	public = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	return private, public
+}
+
+func signAndVerify(t *testing.T, signer cose.Signer, pk crypto.PublicKey) {
+	var SignedCorimIn SignedCorim
+
+	SignedCorimIn.UnsignedCorim = *unsignedCorimFromCBOR(t, testGoodUnsignedCorimCBOR)
+	SignedCorimIn.Meta = *metaGood(t)
+
+	cbor, err := SignedCorimIn.Sign(signer)
+	require.NoError(t, err)
+
+	var SignedCorimOut SignedCorim
+
+	err = SignedCorimOut.FromCOSE(cbor)
+	require.NoError(t, err)
+
+	err = SignedCorimOut.Verify(pk)
+	assert.NoError(t, err)
+}
+
+func TestNewSignerFromPEM_ok(t *testing.T) {
+	testCases := []struct {
+		key      []byte
+		expected cose.Algorithm
+	}{
+		{testES256Key, cose.AlgorithmES256},
+		{testES512Key, cose.AlgorithmES512},
+		{testEdDSAKey, cose.AlgorithmEd25519},
+		{testPS256Key, cose.AlgorithmPS256},
+	}
+
+	for _, tc := range testCases {
+		private, public := keyPEMsFromJWK(t, tc.key)
+
+		signer, err := NewSignerFromPEM(private)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, signer.Algorithm())
+
+		pk, err := NewPublicKeyFromPEM(public)
+		require.NoError(t, err)
+
+		signAndVerify(t, signer, pk)
+	}
+}
+
+func TestNewSignerFromPEM_legacy_encodings(t *testing.T) {
+	_, ecKey, err := getAlgAndKeyFromJWK(testES384Key)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalECPrivateKey(ecKey.(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+
+	signer, err := NewSignerFromPEM(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	assert.Equal(t, cose.AlgorithmES384, signer.Algorithm())
+
+	_, rsaKey, err := getAlgAndKeyFromJWK(testPS256Key)
+	require.NoError(t, err)
+
+	der = x509.MarshalPKCS1PrivateKey(rsaKey.(*rsa.PrivateKey))
+
+	signer, err = NewSignerFromPEM(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	assert.Equal(t, cose.AlgorithmPS256, signer.Algorithm())
+
+	der = x509.MarshalPKCS1PublicKey(rsaKey.Public().(*rsa.PublicKey))
+
+	pk, err := NewPublicKeyFromPEM(pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+
+	signAndVerify(t, signer, pk)
+}
+
+func TestNewSignerFromPEMWithAlgorithm_rsa_schemes(t *testing.T) {
+	private, public := keyPEMsFromJWK(t, testPS256Key)
+
+	pk, err := NewPublicKeyFromPEM(public)
+	require.NoError(t, err)
+
+	for _, alg := range []cose.Algorithm{
+		cose.AlgorithmPS256,
+		cose.AlgorithmPS384,
+		cose.AlgorithmPS512,
+		AlgorithmRS256,
+		AlgorithmRS384,
+		AlgorithmRS512,
+	} {
+		signer, err := NewSignerFromPEMWithAlgorithm(private, alg)
+		require.NoError(t, err)
+		assert.Equal(t, alg, signer.Algorithm())
+
+		signAndVerify(t, signer, pk)
+	}
+
+	ecPrivate, _ := keyPEMsFromJWK(t, testES256Key)
+
+	_, err = NewSignerFromPEMWithAlgorithm(ecPrivate, AlgorithmRS256)
+	assert.EqualError(t, err, "RS256: invalid public key")
+}
+
+func TestNewSignerFromJWK_rsa_pkcs1(t *testing.T) {
+	key := bytes.Replace(testPS256Key, []byte(`"PS256"`), []byte(`"RS256"`), 1)
+
+	signer, err := NewSignerFromJWK(key)
+	require.NoError(t, err)
+	assert.Equal(t, AlgorithmRS256, signer.Algorithm())
+
+	pk, err := NewPublicKeyFromJWK(key)
+	require.NoError(t, err)
+
+	signAndVerify(t, signer, pk)
+}
+
+func TestNewPublicKeyFromJWK_public_only(t *testing.T) {
+	signer, err := NewSignerFromJWK(testEdDSAKey)
+	require.NoError(t, err)
+
+	pk, err := NewPublicKeyFromJWK(testEd25519PublicKey)
+	require.NoError(t, err)
+
+	signAndVerify(t, signer, pk)
+}
+
+func TestNewSignerFromJWK_Ed448(t *testing.T) {
+	signer, err := NewSignerFromJWK(testEd448Key)
+	require.NoError(t, err)
+	assert.Equal(t, cose.AlgorithmEd25519, signer.Algorithm())
+
+	pk, err := NewPublicKeyFromJWK(testEd448Key)
+	require.NoError(t, err)
+	assert.IsType(t, ed448.PublicKey{}, pk)
+
+	signAndVerify(t, signer, pk)
+
+	bad := bytes.Replace(testEd448Key, []byte(`"X9dE`), []byte(`"Y9dE`), 1)
+
+	_, err = NewSignerFromJWK(bad)
+	assert.EqualError(t, err, "invalid x value given d value")
+}
+
+func TestNewSignerFromPEM_Ed448(t *testing.T) {
+	_, key, err := getAlgAndKeyFromJWK(testEd448Key)
+	require.NoError(t, err)
+
+	seed, err := asn1.Marshal(key.(ed448.PrivateKey).Seed())
+	require.NoError(t, err)
+
+	der, err := asn1.Marshal(struct {
+		Version    int
+		Algo       pkix.AlgorithmIdentifier
+		PrivateKey []byte
+	}{0, pkix.AlgorithmIdentifier{Algorithm: oidEd448}, seed})
+	require.NoError(t, err)
+
+	signer, err := NewSignerFromPEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	assert.Equal(t, cose.AlgorithmEd25519, signer.Algorithm())
+
+	pub := key.Public().(ed448.PublicKey)
+
+	der, err = asn1.Marshal(struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}{pkix.AlgorithmIdentifier{Algorithm: oidEd448}, asn1.BitString{Bytes: pub, BitLength: 8 * len(pub)}})
+	require.NoError(t, err)
+
+	pk, err := NewPublicKeyFromPEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+	assert.Equal(t, pub, pk)
+
+	signAndVerify(t, signer, pk)
+
+	// Ed448 signatures do not verify with an Ed25519 key
+	_, ed25519Public := keyPEMsFromJWK(t, testEdDSAKey)
+	ed25519Key, err := NewPublicKeyFromPEM(ed25519Public)
+	require.NoError(t, err)
+
+	var SignedCorimIn SignedCorim
+
+	SignedCorimIn.UnsignedCorim = *unsignedCorimFromCBOR(t, testGoodUnsignedCorimCBOR)
+	SignedCorimIn.Meta = *metaGood(t)
+
+	cbor, err := SignedCorimIn.Sign(signer)
+	require.NoError(t, err)
+
+	var SignedCorimOut SignedCorim
+
+	require.NoError(t, SignedCorimOut.FromCOSE(cbor))
+	assert.Error(t, SignedCorimOut.Verify(ed25519Key))
+}
+
+func TestPEM_fail(t *testing.T) {
+	_, err := NewSignerFromPEM([]byte("not a PEM"))
+	assert.EqualError(t, err, "no PEM block found")
+
+	_, err = NewPublicKeyFromPEM([]byte("not a PEM"))
+	assert.EqualError(t, err, "no PEM block found")
+
+	_, public := keyPEMsFromJWK(t, testES256Key)
+
+	_, err = NewSignerFromPEM(public)
+	assert.EqualError(t, err, `unsupported PEM block type "PUBLIC KEY"`)
+
+	private, _ := keyPEMsFromJWK(t, testES256Key)
+
+	_, err = NewPublicKeyFromPEM(private)
+	assert.EqualError(t, err, `unsupported PEM block type "PRIVATE KEY"`)
+
+	bad := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{0x00}})
+
+	_, err = NewSignerFromPEM(bad)
+	assert.ErrorContains(t, err, "parsing PKCS#8 private key")
+}
+
+func TestSignedCorim_Verify_rsa_pkcs1_tampered(t *testing.T) {
+	private, public := keyPEMsFromJWK(t, testPS256Key)
+
+	signer, err := NewSignerFromPEMWithAlgorithm(private, AlgorithmRS256)
+	require.NoError(t, err)
+
+	pk, err := NewPublicKeyFromPEM(public)
+	require.NoError(t, err)
+
+	var SignedCorimIn SignedCorim
+
+	SignedCorimIn.UnsignedCorim = *unsignedCorimFromCBOR(t, testGoodUnsignedCorimCBOR)
+	SignedCorimIn.Meta = *metaGood(t)
+
+	cbor, err := SignedCorimIn.Sign(signer)
+	require.NoError(t, err)
+
+	cbor[len(cbor)-1] ^= 0xff
+
+	var SignedCorimOut SignedCorim
+
+	err = SignedCorimOut.FromCOSE(cbor)
+	require.NoError(t, err)
+
+	err = SignedCorimOut.Verify(pk)
+	assert.EqualError(t, err, "verification error")
+}
@@ -0,0 +1,164 @@
+// Copyright 2021-2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"fmt"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/encoding"
+	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
+	"github.com/veraison/swid"
+)
+
+// Locator is the internal representation of the corim-locator-map with CBOR and
+// JSON serialization.
+type Locator struct {
+	Href       comid.TaggedURI `cbor:"0,keyasint" json:"href"`
+	Thumbprint *swid.HashEntry `cbor:"1,keyasint,omitempty" json:"thumbprint,omitempty"`
+
+	Extensions
+}
+
+// RegisterExtensions registers a struct as a collections of extensions
+func (o *Locator) RegisterExtensions(exts extensions.Map) error {
+	for p, v := range exts {
+		switch p {
+		case ExtLocator:
+			o.Extensions.Register(v)
+		default:
+			return fmt.Errorf("%w: %q", extensions.ErrUnexpectedPoint, p)
+		}
+	}
+
+	return nil
+}
+
+// GetExtensions returns previously registered extension
+func (o *Locator) GetExtensions() extensions.IMapValue {
+	return o.Extensions.IMapValue
+}
+
+func (o Locator) Valid() error {
+	if o.Href.Empty() {
+		return validation.NewAt("href", validation.CodeEmpty, "empty href")
+	}
+
+	if tp := o.Thumbprint; tp != nil {
+		if err := swid.ValidHashEntry(tp.HashAlgID, tp.HashValue); err != nil {
+			return validation.Errorf("thumbprint", "invalid locator thumbprint: %w", err)
+		}
+	}
+
+	return o.Extensions.validLocator(&o)
+}
+
+// UnmarshalCBOR deserializes from CBOR
+func (o *Locator) UnmarshalCBOR(data []byte) error {
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *Locator) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromCBOR(decModeFor(d), data, o)
+}
+
+// MarshalCBOR serializes to CBOR
+func (o Locator) MarshalCBOR() ([]byte, error) {
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o Locator) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return e.SerializeStructToCBOR(encModeFor(e), o)
+}
+
+// UnmarshalJSON deserializes from JSON
+func (o *Locator) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Locator) DecodeJSON(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromJSON(data, o)
+}
+
+// MarshalJSON serializes to JSON
+func (o Locator) MarshalJSON() ([]byte, error) {
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o Locator) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
+	return e.SerializeStructToJSON(o)
+}
+
+// Locators is a container for Locator instances and their extensions.
+// It is a thin wrapper around extensions.Collection, so that the extensions
+// registered for the locators are registered with each one before it is
+// decoded.  Note that it used to be a plain []Locator: the locators are now in
+// Values, and are appended with Add.
+type Locators extensions.Collection[Locator, *Locator]
+
+func NewLocators() *Locators {
+	return (*Locators)(extensions.NewCollection[Locator]())
+}
+
+func (o *Locators) RegisterExtensions(exts extensions.Map) error {
+	return (*extensions.Collection[Locator, *Locator])(o).RegisterExtensions(exts)
+}
+
+func (o *Locators) GetExtensions() extensions.IMapValue {
+	return (*extensions.Collection[Locator, *Locator])(o).GetExtensions()
+}
+
+func (o *Locators) Valid() error {
+	return (*extensions.Collection[Locator, *Locator])(o).Valid()
+}
+
+func (o *Locators) IsEmpty() bool {
+	return (*extensions.Collection[Locator, *Locator])(o).IsEmpty()
+}
+
+func (o *Locators) Add(val *Locator) *Locators {
+	ret := (*extensions.Collection[Locator, *Locator])(o).Add(val)
+	return (*Locators)(ret)
+}
+
+func (o Locators) MarshalCBOR() ([]byte, error) {
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o Locators) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return (extensions.Collection[Locator, *Locator])(o).EncodeCBOR(e)
+}
+
+func (o *Locators) UnmarshalCBOR(data []byte) error {
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *Locators) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return (*extensions.Collection[Locator, *Locator])(o).DecodeCBOR(d, data)
+}
+
+func (o Locators) MarshalJSON() ([]byte, error) {
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o Locators) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
+	return (extensions.Collection[Locator, *Locator])(o).EncodeJSON(e)
+}
+
+func (o *Locators) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Locators) DecodeJSON(d *encoding.Decoder, data []byte) error {
+	return (*extensions.Collection[Locator, *Locator])(o).DecodeJSON(d, data)
+}
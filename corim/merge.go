@@ -0,0 +1,398 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/encoding"
+	"github.com/veraison/eat"
+	"github.com/veraison/go-cose"
+)
+
+var (
+	// ErrMergeConflict is returned when the merged CoRIMs contain different
+	// tags with the same tag-id and tag-version
+	ErrMergeConflict = errors.New("conflicting tags")
+	// ErrProfileMismatch is returned when the merged CoRIMs have different
+	// profiles
+	ErrProfileMismatch = errors.New("profile mismatch")
+	// ErrRegistryMismatch is returned when the merged CoRIMs were decoded
+	// with different Registries
+	ErrRegistryMismatch = errors.New("registry mismatch")
+	// ErrNoValidityOverlap is returned when the validity periods of the
+	// merged CoRIMs do not overlap
+	ErrNoValidityOverlap = errors.New("validity periods do not overlap")
+)
+
+// MergeConflict describes two tags with the same tag-id and tag-version but
+// different content. Only the first one is retained in the merged CoRIM.
+type MergeConflict struct {
+	Kind       TagKind
+	TagID      string
+	TagVersion uint
+	// Kept and Dropped are the positions, in the list of merged CoRIMs, of
+	// the CoRIMs containing the retained and the discarded tag
+	Kept    int
+	Dropped int
+}
+
+// String returns a printable representation of the MergeConflict
+func (o MergeConflict) String() string {
+	return fmt.Sprintf("%s %q version %d in CoRIM %d differs from the one in CoRIM %d",
+		o.Kind, o.TagID, o.TagVersion, o.Dropped, o.Kept)
+}
+
+// MergeResult is the outcome of merging several CoRIMs
+type MergeResult struct {
+	Corim *UnsignedCorim
+	// Duplicates is the number of identical tags that were merged
+	Duplicates int
+	// Conflicts lists the tags that were dropped because a different tag
+	// with the same identity was already merged
+	Conflicts []MergeConflict
+}
+
+// Err returns an error wrapping ErrMergeConflict describing the conflicts
+// found while merging, or nil if there are none
+func (o MergeResult) Err() error {
+	if len(o.Conflicts) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, 0, len(o.Conflicts))
+	for _, c := range o.Conflicts {
+		msgs = append(msgs, c.String())
+	}
+
+	return fmt.Errorf("%w: %s", ErrMergeConflict, strings.Join(msgs, "; "))
+}
+
+// Sign signs the merged CoRIM with the supplied signer, conveying the supplied
+// Meta in the header parameter(s) selected by format, and returns the
+// serialized signed-corim. The encoding options are passed on to
+// SignedCorim.Sign, after one selecting the Registry of the merged CoRIM. Merge
+// results with conflicts are not signed, since some of the input tags have
+// been dropped: the error returned by Err is returned instead.
+func (o MergeResult) Sign(
+	meta Meta,
+	format MetaHeaderFormat,
+	signer cose.Signer,
+	opts ...encoding.EncodeOption,
+) ([]byte, error) {
+	if o.Corim == nil {
+		return nil, errors.New("no merged CoRIM")
+	}
+
+	if err := o.Err(); err != nil {
+		return nil, err
+	}
+
+	if o.Corim.registry != nil {
+		opts = append([]encoding.EncodeOption{WithEncodeRegistry(o.Corim.registry)}, opts...)
+	}
+
+	sc := SignedCorim{UnsignedCorim: *o.Corim, Meta: meta, MetaFormat: format}
+
+	return sc.Sign(signer, opts...)
+}
+
+// mergeKey identifies a tag across the merged CoRIMs
+type mergeKey struct {
+	kind TagKind
+	id   comid.TagIdentity
+}
+
+type mergedTag struct {
+	corim int
+	raw   Tag
+}
+
+// Merge combines the supplied CoRIMs into a new one with the supplied corim-id:
+//
+//   - tags are concatenated in order, dropping those with the same type,
+//     tag-id and tag-version as an already merged one. Identical tags are
+//     counted as duplicates, different ones are reported as conflicts (see
+//     MergeResult.Err). Tags without a tag-id are only deduplicated if
+//     identical;
+//   - entities and dependent RIMs are unioned;
+//   - the validity period is the intersection of the input ones, and an error
+//     is returned if they do not overlap;
+//   - the profile is retained, and an error is returned if the input CoRIMs
+//     have different profiles.
+//
+// The merged CoRIM uses the Registry the first input CoRIM was decoded with
+// (DefaultRegistry for CoRIMs that were not decoded with WithRegistry), and an
+// error wrapping ErrRegistryMismatch is returned if the input CoRIMs use
+// different Registries.
+//
+// Extension values of the input CoRIMs are not merged.
+func Merge(id interface{}, corims ...*UnsignedCorim) (*MergeResult, error) {
+	if len(corims) == 0 {
+		return nil, errors.New("no CoRIMs to merge")
+	}
+
+	for i, uc := range corims {
+		if uc == nil {
+			return nil, fmt.Errorf("nil CoRIM at index %d", i)
+		}
+	}
+
+	reg, err := mergeRegistry(corims)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := mergeProfile(corims)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := reg.GetUnsignedCorim(corims[0].Profile)
+	if ret.SetID(id) == nil {
+		return nil, fmt.Errorf("invalid corim-id: %v", id)
+	}
+	ret.Profile = profile
+	ret.registry = corims[0].registry
+
+	res := MergeResult{Corim: ret}
+
+	if err := res.mergeTags(corims); err != nil {
+		return nil, err
+	}
+
+	enc := reg.encMode(encoding.EncodeOptions{})
+
+	if err := mergeDependentRims(ret, corims, enc); err != nil {
+		return nil, err
+	}
+
+	if err := mergeEntities(ret, corims, enc); err != nil {
+		return nil, err
+	}
+
+	if err := mergeValidity(ret, corims); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (o *MergeResult) mergeTags(corims []*UnsignedCorim) error {
+	byIdentity := make(map[mergeKey]mergedTag)
+	var anonymous [][]byte
+
+	for i, uc := range corims {
+		it := uc.IterTags()
+
+		for it.Next() {
+			t := it.Tag()
+
+			tid, ok := t.TagIdentity()
+			if !ok {
+				if containsEncoded(anonymous, t.Raw) {
+					o.Duplicates++
+					continue
+				}
+
+				anonymous = append(anonymous, t.Raw)
+				o.Corim.Tags = append(o.Corim.Tags, t.Raw)
+
+				continue
+			}
+
+			key := mergeKey{kind: t.Kind, id: tid}
+
+			if prev, ok := byIdentity[key]; ok {
+				if encoding.EqualCBOR(prev.raw, t.Raw) {
+					o.Duplicates++
+				} else {
+					o.Conflicts = append(o.Conflicts, MergeConflict{
+						Kind:       t.Kind,
+						TagID:      tid.TagID.String(),
+						TagVersion: tid.TagVersion,
+						Kept:       prev.corim,
+						Dropped:    i,
+					})
+				}
+
+				continue
+			}
+
+			byIdentity[key] = mergedTag{corim: i, raw: t.Raw}
+			o.Corim.Tags = append(o.Corim.Tags, t.Raw)
+		}
+
+		if err := it.Err(); err != nil {
+			return fmt.Errorf("CoRIM at index %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func mergeRegistry(corims []*UnsignedCorim) (*Registry, error) {
+	first := corims[0].getRegistry()
+
+	for i, uc := range corims[1:] {
+		if uc.getRegistry() != first {
+			return nil, fmt.Errorf("%w: CoRIM at index %d uses a different Registry than CoRIM 0",
+				ErrRegistryMismatch, i+1)
+		}
+	}
+
+	return first, nil
+}
+
+func mergeProfile(corims []*UnsignedCorim) (*eat.Profile, error) {
+	first := corims[0].Profile
+
+	for i, uc := range corims[1:] {
+		if !sameProfile(first, uc.Profile) {
+			return nil, fmt.Errorf("%w: CoRIM at index %d has profile %s, expecting %s",
+				ErrProfileMismatch, i+1, profileString(uc.Profile), profileString(first))
+		}
+	}
+
+	return first, nil
+}
+
+func sameProfile(a, b *eat.Profile) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return profileString(a) == profileString(b)
+}
+
+func profileString(p *eat.Profile) string {
+	if p == nil {
+		return "none"
+	}
+
+	s, err := p.Get()
+	if err != nil {
+		return "invalid"
+	}
+
+	return s
+}
+
+func mergeDependentRims(ret *UnsignedCorim, corims []*UnsignedCorim, enc cbor.EncMode) error {
+	var (
+		rims = NewLocators()
+		seen [][]byte
+	)
+
+	for i, uc := range corims {
+		if uc.DependentRims == nil {
+			continue
+		}
+
+		for j, l := range uc.DependentRims.Values {
+			data, err := enc.Marshal(l)
+			if err != nil {
+				return fmt.Errorf("encoding dependent RIM %d of CoRIM at index %d: %w", j, i, err)
+			}
+
+			if containsEncoded(seen, data) {
+				continue
+			}
+
+			seen = append(seen, data)
+			rims.Add(&l)
+		}
+	}
+
+	if !rims.IsEmpty() {
+		ret.DependentRims = rims
+	}
+
+	return nil
+}
+
+func mergeEntities(ret *UnsignedCorim, corims []*UnsignedCorim, enc cbor.EncMode) error {
+	var seen [][]byte
+
+	for i, uc := range corims {
+		if uc.Entities == nil {
+			continue
+		}
+
+		for j := range uc.Entities.Values {
+			e := uc.Entities.Values[j]
+
+			data, err := enc.Marshal(&e)
+			if err != nil {
+				return fmt.Errorf("encoding entity %d of CoRIM at index %d: %w", j, i, err)
+			}
+
+			if containsEncoded(seen, data) {
+				continue
+			}
+
+			seen = append(seen, data)
+
+			if ret.Entities == nil {
+				ret.Entities = NewEntities()
+			}
+			ret.Entities.Add(&e)
+		}
+	}
+
+	return nil
+}
+
+func containsEncoded(seen [][]byte, data []byte) bool {
+	for _, s := range seen {
+		if encoding.EqualCBOR(s, data) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func mergeValidity(ret *UnsignedCorim, corims []*UnsignedCorim) error {
+	var v *Validity
+
+	for _, uc := range corims {
+		if !uc.RimValidity.isSet() {
+			continue
+		}
+
+		if v == nil {
+			cp := *uc.RimValidity
+			v = &cp
+			continue
+		}
+
+		if uc.RimValidity.NotAfter.Before(v.NotAfter) {
+			v.NotAfter = uc.RimValidity.NotAfter
+		}
+
+		if nb := uc.RimValidity.NotBefore; nb != nil {
+			if v.NotBefore == nil || nb.After(*v.NotBefore) {
+				v.NotBefore = nb
+			}
+		}
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	if v.NotBefore != nil && v.NotBefore.After(v.NotAfter) {
+		return fmt.Errorf("%w: latest not-before %s is after earliest not-after %s",
+			ErrNoValidityOverlap, v.NotBefore, v.NotAfter)
+	}
+
+	ret.RimValidity = v
+
+	return nil
+}
@@ -0,0 +1,245 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/encoding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/eat"
+)
+
+func psaComid(t *testing.T, tagID string, version uint, language string) comid.Comid {
+	var c comid.Comid
+	require.NoError(t, c.FromJSON([]byte(comid.PSARefValJSONTemplate)))
+
+	c.SetTagIdentity(tagID, version)
+
+	if language != "" {
+		c.SetLanguage(language)
+	}
+
+	return c
+}
+
+func corimWith(t *testing.T, id string, comids ...comid.Comid) *UnsignedCorim {
+	uc := NewUnsignedCorim().SetID(id)
+
+	for _, c := range comids {
+		require.NotNil(t, uc.AddComid(c))
+	}
+
+	return uc
+}
+
+func TestMerge_tags(t *testing.T) {
+	a := corimWith(t, "a", psaComid(t, "fw", 1, ""), psaComid(t, "bl", 0, ""))
+	b := corimWith(t, "b", psaComid(t, "fw", 1, ""), psaComid(t, "fw", 2, ""))
+	c := corimWith(t, "c", psaComid(t, "bl", 0, "en-US"), psaComid(t, "os", 0, ""))
+
+	res, err := Merge("product", a, b, c)
+	require.NoError(t, err)
+
+	assert.Equal(t, "product", res.Corim.GetID())
+	assert.Equal(t, 1, res.Duplicates)
+	assert.Equal(t, []MergeConflict{
+		{Kind: TagKindComid, TagID: "bl", TagVersion: 0, Kept: 0, Dropped: 2},
+	}, res.Conflicts)
+
+	err = res.Err()
+	assert.ErrorIs(t, err, ErrMergeConflict)
+	assert.EqualError(t, err,
+		`conflicting tags: comid "bl" version 0 in CoRIM 2 differs from the one in CoRIM 0`)
+
+	var ids []string
+	tags, err := res.Corim.DecodeTags()
+	require.NoError(t, err)
+
+	for _, tag := range tags {
+		id, _ := tag.TagIdentity()
+		ids = append(ids, fmt.Sprintf("%s@%d", id.TagID, id.TagVersion))
+	}
+
+	assert.Equal(t, []string{"fw@1", "bl@0", "fw@2", "os@0"}, ids)
+	assert.NoError(t, res.Corim.Valid())
+}
+
+func TestMerge_no_conflicts(t *testing.T) {
+	a := corimWith(t, "a", psaComid(t, "fw", 1, ""))
+	b := corimWith(t, "b", psaComid(t, "os", 1, ""))
+
+	res, err := Merge("product", a, b)
+	require.NoError(t, err)
+	assert.NoError(t, res.Err())
+	assert.Len(t, res.Corim.Tags, 2)
+}
+
+func TestMerge_entities_and_dependent_rims(t *testing.T) {
+	regID := "https://acme.example"
+
+	a := corimWith(t, "a", psaComid(t, "fw", 1, "")).
+		AddEntity("ACME Ltd.", &regID, RoleManifestCreator).
+		AddDependentRim("https://acme.example/rims/a.cbor", nil)
+
+	b := corimWith(t, "b", psaComid(t, "os", 1, "")).
+		AddEntity("ACME Ltd.", &regID, RoleManifestCreator).
+		AddEntity("Other Corp.", nil, RoleManifestCreator).
+		AddDependentRim("https://acme.example/rims/a.cbor", nil).
+		AddDependentRim("https://acme.example/rims/b.cbor", nil)
+
+	res, err := Merge("product", a, b)
+	require.NoError(t, err)
+
+	require.NotNil(t, res.Corim.Entities)
+	require.Len(t, res.Corim.Entities.Values, 2)
+	assert.Equal(t, "ACME Ltd.", res.Corim.Entities.Values[0].Name.String())
+	assert.Equal(t, "Other Corp.", res.Corim.Entities.Values[1].Name.String())
+
+	require.NotNil(t, res.Corim.DependentRims)
+	require.Len(t, res.Corim.DependentRims.Values, 2)
+	assert.Equal(t, comid.TaggedURI("https://acme.example/rims/b.cbor"), res.Corim.DependentRims.Values[1].Href)
+}
+
+func TestMerge_validity(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	t3 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	t4 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	a := corimWith(t, "a", psaComid(t, "fw", 1, "")).SetRimValidity(t3, &t1)
+	b := corimWith(t, "b", psaComid(t, "os", 1, "")).SetRimValidity(t4, &t2)
+	c := corimWith(t, "c", psaComid(t, "bl", 1, ""))
+
+	res, err := Merge("product", a, b, c)
+	require.NoError(t, err)
+
+	require.NotNil(t, res.Corim.RimValidity)
+	assert.Equal(t, t2, *res.Corim.RimValidity.NotBefore)
+	assert.Equal(t, t3, res.Corim.RimValidity.NotAfter)
+
+	// the input CoRIMs are not modified
+	assert.Equal(t, t1, *a.RimValidity.NotBefore)
+
+	d := corimWith(t, "d", psaComid(t, "x", 1, "")).SetRimValidity(t1, nil)
+
+	_, err = Merge("product", b, d)
+	assert.ErrorIs(t, err, ErrNoValidityOverlap)
+}
+
+func TestMerge_profile(t *testing.T) {
+	a := corimWith(t, "a", psaComid(t, "fw", 1, "")).SetProfile("http://example.com/p1")
+	b := corimWith(t, "b", psaComid(t, "os", 1, "")).SetProfile("http://example.com/p1")
+	c := corimWith(t, "c", psaComid(t, "bl", 1, "")).SetProfile("http://example.com/p2")
+	d := corimWith(t, "d", psaComid(t, "bl", 1, ""))
+
+	res, err := Merge("product", a, b)
+	require.NoError(t, err)
+
+	p, err := res.Corim.Profile.Get()
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/p1", p)
+
+	_, err = Merge("product", a, c)
+	assert.ErrorIs(t, err, ErrProfileMismatch)
+	assert.EqualError(t, err, "profile mismatch: CoRIM at index 1 has profile "+
+		"http://example.com/p2, expecting http://example.com/p1")
+
+	_, err = Merge("product", d, a)
+	assert.EqualError(t, err, "profile mismatch: CoRIM at index 1 has profile "+
+		"http://example.com/p1, expecting none")
+}
+
+func TestMerge_registry(t *testing.T) {
+	profID, err := eat.NewProfile("http://example.com/test-profile")
+	require.NoError(t, err)
+
+	r := NewRegistry()
+	require.NoError(t, r.RegisterProfile(profID, testRegistryProfileExtensions()))
+
+	a, err := UnmarshalUnsignedCorimFromCBOR(testUnsignedCorimWithExtensionsCBOR, WithRegistry(r))
+	require.NoError(t, err)
+
+	b, err := UnmarshalUnsignedCorimFromCBOR(testUnsignedCorimWithExtensionsCBOR, WithRegistry(r))
+	require.NoError(t, err)
+
+	res, err := Merge("product", a, b)
+	require.NoError(t, err)
+	assert.Same(t, r, res.Corim.getRegistry())
+	assert.Equal(t, 1, res.Duplicates)
+
+	tags, err := res.Corim.DecodeTags()
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	assert.Equal(t, "123 Fake Street",
+		tags[0].Comid.Entities.Values[0].Extensions.MustGetString("Address"))
+
+	c, err := UnmarshalUnsignedCorimFromCBOR(testUnsignedCorimWithExtensionsCBOR)
+	require.NoError(t, err)
+
+	_, err = Merge("product", a, c)
+	assert.ErrorIs(t, err, ErrRegistryMismatch)
+	assert.EqualError(t, err,
+		"registry mismatch: CoRIM at index 1 uses a different Registry than CoRIM 0")
+}
+
+func TestMerge_fail(t *testing.T) {
+	_, err := Merge("product")
+	assert.EqualError(t, err, "no CoRIMs to merge")
+
+	_, err = Merge("product", corimWith(t, "a", psaComid(t, "fw", 1, "")), nil)
+	assert.EqualError(t, err, "nil CoRIM at index 1")
+
+	_, err = Merge(42, corimWith(t, "a", psaComid(t, "fw", 1, "")))
+	assert.EqualError(t, err, "invalid corim-id: 42")
+
+	bad := NewUnsignedCorim().SetID("bad")
+	bad.Tags = []Tag{append(append(Tag{}, ComidTag...), 0xff)}
+
+	_, err = Merge("product", bad)
+	assert.ErrorContains(t, err, "CoRIM at index 0: tag at index 0: decoding comid")
+}
+
+func TestMergeResult_Sign(t *testing.T) {
+	signer, err := NewSignerFromJWK(testES256Key)
+	require.NoError(t, err)
+
+	pk, err := NewPublicKeyFromJWK(testES256Key)
+	require.NoError(t, err)
+
+	res, err := Merge("product",
+		corimWith(t, "a", psaComid(t, "fw", 1, "")),
+		corimWith(t, "b", psaComid(t, "os", 1, "")),
+	)
+	require.NoError(t, err)
+
+	data, err := res.Sign(*metaGood(t), MetaHeaderCWTClaims, signer,
+		encoding.WithDeterministic())
+	require.NoError(t, err)
+
+	sc, err := UnmarshalSignedCorimFromCBOR(data)
+	require.NoError(t, err)
+	require.NoError(t, sc.Verify(pk))
+
+	assert.Equal(t, "product", sc.UnsignedCorim.GetID())
+	assert.Len(t, sc.UnsignedCorim.Tags, 2)
+	assert.Equal(t, MetaHeaderCWTClaims, sc.MetaFormat)
+	assert.True(t, encoding.IsCanonical(data))
+
+	_, err = MergeResult{}.Sign(*metaGood(t), MetaHeaderCorimMeta, signer)
+	assert.EqualError(t, err, "no merged CoRIM")
+
+	res, err = Merge("product",
+		corimWith(t, "a", psaComid(t, "bl", 0, "")),
+		corimWith(t, "b", psaComid(t, "bl", 0, "en-US")),
+	)
+	require.NoError(t, err)
+
+	_, err = res.Sign(*metaGood(t), MetaHeaderCorimMeta, signer)
+	assert.ErrorIs(t, err, ErrMergeConflict)
+}
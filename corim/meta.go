@@ -8,7 +8,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/jraman567/corim/encoding"
 	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 )
 
 // Meta stores a corim-meta-map with JSON and CBOR serializations.  It carries
@@ -18,17 +20,30 @@ import (
 type Meta struct {
 	Signer   Signer    `cbor:"0,keyasint" json:"signer"`
 	Validity *Validity `cbor:"1,keyasint,omitempty" json:"validity,omitempty"`
+
+	Extensions
 }
 
 func NewMeta() *Meta {
 	return &Meta{}
 }
 
+// RegisterExtensions registers a struct as a collections of extensions. The
+// extensions of the Validity are registered with a new Validity, if the
+// target Meta does not have one.
 func (o *Meta) RegisterExtensions(exts extensions.Map) error {
 	for p, v := range exts {
 		switch p {
+		case ExtMeta:
+			o.Extensions.Register(v)
 		case ExtSigner:
 			o.Signer.Extensions.Register(v)
+		case ExtValidity:
+			if o.Validity == nil {
+				o.Validity = NewValidity()
+			}
+
+			o.Validity.Extensions.Register(v)
 		default:
 			return fmt.Errorf("%w: %q", extensions.ErrUnexpectedPoint, p)
 		}
@@ -37,6 +52,11 @@ func (o *Meta) RegisterExtensions(exts extensions.Map) error {
 	return nil
 }
 
+// GetExtensions returns previously registered extension
+func (o *Meta) GetExtensions() extensions.IMapValue {
+	return o.Extensions.IMapValue
+}
+
 // SetSigner populates the Signer element in the target Meta with the supplied
 // name and optional URI
 func (o *Meta) SetSigner(name string, uri *string) *Meta {
@@ -60,8 +80,13 @@ func (o *Meta) SetSigner(name string, uri *string) *Meta {
 // range
 func (o *Meta) SetValidity(notAfter time.Time, notBefore *time.Time) *Meta {
 	if o != nil {
-		v := NewValidity().Set(notAfter, notBefore)
-		if v == nil {
+		v := NewValidity()
+		if o.Validity != nil {
+			// retain the registered extensions, if any
+			v.Extensions = o.Validity.Extensions
+		}
+
+		if v.Set(notAfter, notBefore) == nil {
 			return nil
 		}
 
@@ -73,18 +98,82 @@ func (o *Meta) SetValidity(notAfter time.Time, notBefore *time.Time) *Meta {
 // Valid checks for validity of the fields within Meta
 func (o Meta) Valid() error {
 	if err := o.Signer.Valid(); err != nil {
-		return fmt.Errorf("invalid signer: %w", err)
+		return validation.Errorf("signer", "invalid signer: %w", err)
 	}
 
-	if o.Validity != nil {
+	if o.Validity.isSet() {
 		if err := o.Validity.Valid(); err != nil {
-			return fmt.Errorf("invalid validity: %w", err)
+			return validation.Errorf("validity", "invalid validity: %w", err)
 		}
 	}
 
+	return o.Extensions.validMeta(&o)
+}
+
+// UnmarshalCBOR deserializes from CBOR
+func (o *Meta) UnmarshalCBOR(data []byte) error {
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *Meta) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	if err := d.PopulateStructFromCBOR(decModeFor(d), data, o); err != nil {
+		return err
+	}
+
+	o.dropEmptyValidity()
+
+	return nil
+}
+
+// MarshalCBOR serializes to CBOR
+func (o Meta) MarshalCBOR() ([]byte, error) {
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o Meta) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	o.dropEmptyValidity()
+
+	return e.SerializeStructToCBOR(encModeFor(e), o)
+}
+
+// UnmarshalJSON deserializes from JSON
+func (o *Meta) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Meta) DecodeJSON(d *encoding.Decoder, data []byte) error {
+	if err := d.PopulateStructFromJSON(data, o); err != nil {
+		return err
+	}
+
+	o.dropEmptyValidity()
+
 	return nil
 }
 
+// MarshalJSON serializes to JSON
+func (o Meta) MarshalJSON() ([]byte, error) {
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o Meta) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
+	o.dropEmptyValidity()
+
+	return e.SerializeStructToJSON(o)
+}
+
+// dropEmptyValidity unsets the Validity if it was only created to hold the
+// Validity extensions (see RegisterExtensions)
+func (o *Meta) dropEmptyValidity() {
+	if !o.Validity.isSet() {
+		o.Validity = nil
+	}
+}
+
 // ToCBOR serializes the target Meta to CBOR
 func (o Meta) ToCBOR() ([]byte, error) {
 	return em.Marshal(&o)
@@ -196,6 +196,17 @@ func Test_Meata_extensions(t *testing.T) {
 	assert.True(t, meta.Signer.Extensions.HaveExtensions())
 	assert.Equal(t, exts, meta.Signer.GetExtensions())
 
+	metaExts := &struct{}{}
+	validityExts := &struct{}{}
+	extMap = extensions.NewMap().
+		Add(ExtMeta, metaExts).
+		Add(ExtValidity, validityExts)
+
+	err = meta.RegisterExtensions(extMap)
+	assert.NoError(t, err)
+	assert.Equal(t, metaExts, meta.GetExtensions())
+	assert.Equal(t, validityExts, meta.Validity.GetExtensions())
+
 	badMap := extensions.NewMap().Add(extensions.Point("test"), exts)
 	err = meta.RegisterExtensions(badMap)
 	assert.EqualError(t, err, `unexpected extension point: "test"`)
@@ -216,3 +227,38 @@ func Test_meta_Valid(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "invalid validity: invalid not-before / not-after")
 }
+
+func Test_Meta_extensions_CBOR(t *testing.T) {
+	type validityExtensions struct {
+		Reason *string `cbor:"-1,keyasint,omitempty" json:"reason,omitempty"`
+	}
+
+	extMap := extensions.NewMap().Add(ExtValidity, &validityExtensions{})
+
+	meta := NewMeta().SetSigner("ACME Ltd.", nil)
+	require.NoError(t, meta.RegisterExtensions(extMap))
+
+	// a validity without anything but registered extensions is not encoded
+	data, err := meta.ToCBOR()
+	require.NoError(t, err)
+
+	var out Meta
+	require.NoError(t, out.RegisterExtensions(extMap))
+	require.NoError(t, out.FromCBOR(data))
+	assert.Nil(t, out.Validity)
+
+	notAfter := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NotNil(t, meta.SetValidity(notAfter, nil))
+	require.NoError(t, meta.Validity.Extensions.Set("reason", "renewal"))
+	assert.NoError(t, meta.Valid())
+
+	data, err = meta.ToCBOR()
+	require.NoError(t, err)
+
+	out = Meta{}
+	require.NoError(t, out.RegisterExtensions(extMap))
+	require.NoError(t, out.FromCBOR(data))
+	require.NotNil(t, out.Validity)
+	assert.Equal(t, notAfter.Unix(), out.Validity.NotAfter.Unix())
+	assert.Equal(t, "renewal", out.Validity.MustGetString("reason"))
+}
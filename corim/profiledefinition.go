@@ -0,0 +1,748 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/cots"
+	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
+	"github.com/veraison/eat"
+	"gopkg.in/yaml.v3"
+)
+
+// ProfileDefinition is a declarative definition of a profile, allowing
+// profiles to be defined by configuration rather than by Go code. For each
+// extension point, it declares the extension fields added by the profile, and
+// the constraints on the fields (both the extension fields and those defined
+// by this library) of the structure extended at that point. It may also
+// restrict the digest algorithms of measurements and the class ID types of
+// environments. For example, in YAML:
+//
+//	id: http://example.com/partner-profile
+//	extensions:
+//	  ComidEntity:
+//	    fields:
+//	      - name: Address
+//	        cbor-key: -1
+//	        json-name: address
+//	        type: string
+//	    constraints:
+//	      - field: address
+//	        required: true
+//	  Comid:
+//	    constraints:
+//	      - field: lang
+//	        allowed-values: [en-GB]
+//	digest-algorithms: [sha-256, sha-384]
+//	class-id-types: [psa.impl-id]
+//
+// Use ParseProfileDefinition to read a ProfileDefinition and
+// RegisterProfileDefinition to register the profile it defines.
+type ProfileDefinition struct {
+	// ID is the EAT profile ID, a URI or an OID
+	ID string `json:"id"`
+	// Extensions maps the extension points (see AllExtensionPoints) to the
+	// definitions of their extension fields and constraints
+	Extensions map[extensions.Point]ExtensionPointDefinition `json:"extensions,omitempty"`
+	// DigestAlgorithms, if not empty, lists the names of the only hash
+	// algorithms (see comid.LookupHashAlgorithmByName) allowed for the
+	// digests of reference and endorsed value measurements
+	DigestAlgorithms []string `json:"digest-algorithms,omitempty"`
+	// ClassIDTypes, if not empty, lists the only class ID types (see
+	// comid.ClassID.Type) allowed for the environments of reference value,
+	// endorsed value and key triples
+	ClassIDTypes []string `json:"class-id-types,omitempty"`
+}
+
+// ExtensionPointDefinition declares the extension fields and constraints of
+// an extension point
+type ExtensionPointDefinition struct {
+	Fields      []ExtensionFieldDefinition `json:"fields,omitempty"`
+	Constraints []FieldConstraint          `json:"constraints,omitempty"`
+}
+
+// ExtensionFieldDefinition declares an extension field. Type is one of
+// "string", "int", "uint", "bool", "float" and "bytes", or "[]" followed by
+// any of those but "bytes" for an array. Name must be an exported Go
+// identifier: it may be used, as may JSONName and the CBOR key, to get and set
+// the field (see extensions.Extensions.Get and extensions.Extensions.Set).
+// JSONName defaults to Name.
+type ExtensionFieldDefinition struct {
+	Name     string `json:"name"`
+	CBORKey  *int   `json:"cbor-key"`
+	JSONName string `json:"json-name,omitempty"`
+	Type     string `json:"type"`
+}
+
+// FieldConstraint constrains a field, identified by its Go name, JSON name or
+// CBOR key, of the structure extended at an extension point. The field may be
+// an extension field or one defined by this library. If Required is set, the
+// field must be present. AllowedValues and Pattern, if set, constrain the
+// string representations of the value (or of each of the elements of an array
+// value) of the field, when present: byte strings are represented in hex, and
+// types implementing fmt.Stringer as returned by String().
+type FieldConstraint struct {
+	Field         string `json:"field"`
+	Required      bool   `json:"required,omitempty"`
+	AllowedValues []any  `json:"allowed-values,omitempty"`
+	Pattern       string `json:"pattern,omitempty"`
+}
+
+// ParseProfileDefinition parses a ProfileDefinition from JSON or YAML data.
+// Unknown members are rejected.
+func ParseProfileDefinition(data []byte) (*ProfileDefinition, error) {
+	var raw any
+
+	// JSON is also valid YAML, so both are parsed as YAML first, and then
+	// converted to JSON to be decoded with the json tags of the definition
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing profile definition: %w", err)
+	}
+
+	buf, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing profile definition: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.DisallowUnknownFields()
+	decoder.UseNumber()
+
+	var ret ProfileDefinition
+
+	if err := decoder.Decode(&ret); err != nil {
+		return nil, fmt.Errorf("decoding profile definition: %w", err)
+	}
+
+	return &ret, nil
+}
+
+// Compile returns the profile ID and the extensions that implement the
+// ProfileDefinition, as expected by RegisterProfile. An error is returned if
+// the ProfileDefinition is invalid, e.g. if it lists class ID types that are
+// not registered with comid.DefaultRegistry.
+func (o ProfileDefinition) Compile() (*eat.Profile, extensions.Map, error) {
+	return o.compile(comid.DefaultRegistry)
+}
+
+// compile is like Compile, but checks the class ID types against the
+// supplied comid Registry
+func (o ProfileDefinition) compile(reg *comid.Registry) (*eat.Profile, extensions.Map, error) {
+	id, err := eat.NewProfile(o.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid profile ID %q: %w", o.ID, err)
+	}
+
+	points := make(map[extensions.Point]*declaredPoint)
+
+	for _, p := range sortedPoints(o.Extensions) {
+		point, err := compilePoint(p, o.Extensions[p])
+		if err != nil {
+			return nil, nil, fmt.Errorf("extension point %q: %w", p, err)
+		}
+
+		points[p] = point
+	}
+
+	if len(o.DigestAlgorithms) != 0 {
+		algs := make(map[uint64]bool, len(o.DigestAlgorithms))
+
+		for _, name := range o.DigestAlgorithms {
+			alg, ok := comid.LookupHashAlgorithmByName(name)
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown digest algorithm %q", name)
+			}
+
+			algs[alg.ID] = true
+		}
+
+		for _, p := range []extensions.Point{comid.ExtReferenceValue, comid.ExtEndorsedValue} {
+			getOrAddPoint(points, p).digestAlgorithms = algs
+		}
+	}
+
+	if len(o.ClassIDTypes) != 0 {
+		types := make(map[string]bool, len(o.ClassIDTypes))
+
+		for _, typ := range o.ClassIDTypes {
+			if !reg.HasClassIDType(typ) {
+				return nil, nil, fmt.Errorf("unknown class ID type %q", typ)
+			}
+
+			types[typ] = true
+		}
+
+		getOrAddPoint(points, comid.ExtTriples).classIDTypes = types
+	}
+
+	exts := extensions.NewMap()
+
+	for p, point := range points {
+		exts[p] = declarablePoints[p].newMapValue(point)
+	}
+
+	return id, exts, nil
+}
+
+// RegisterProfileDefinition compiles the supplied ProfileDefinition (see
+// ProfileDefinition.Compile) and registers the resulting profile (see
+// RegisterProfile).
+func RegisterProfileDefinition(def *ProfileDefinition) error {
+	return DefaultRegistry.RegisterProfileDefinition(def)
+}
+
+// RegisterProfileDefinition is like the package-level
+// RegisterProfileDefinition, but registers with the target Registry.
+func (o *Registry) RegisterProfileDefinition(def *ProfileDefinition) error {
+	if def == nil {
+		return errors.New("nil profile definition")
+	}
+
+	id, exts, err := def.compile(o.comid)
+	if err != nil {
+		return fmt.Errorf("profile %q: %w", def.ID, err)
+	}
+
+	return o.RegisterProfile(id, exts)
+}
+
+// declarablePoint describes an extension point that may be used by a
+// ProfileDefinition
+type declarablePoint struct {
+	// base is the type of the structure extended at the point
+	base reflect.Type
+	// newMapValue returns the IMapValue implementing the point
+	newMapValue func(*declaredPoint) extensions.IMapValue
+}
+
+var declarablePoints = map[extensions.Point]declarablePoint{
+	comid.ExtComid:               {reflect.TypeOf(comid.Comid{}), newDeclaredComidExtensions},
+	comid.ExtEntity:              {reflect.TypeOf(comid.Entity{}), newDeclaredComidExtensions},
+	comid.ExtTriples:             {reflect.TypeOf(comid.Triples{}), newDeclaredComidExtensions},
+	comid.ExtReferenceValue:      {reflect.TypeOf(comid.Mval{}), newDeclaredComidExtensions},
+	comid.ExtReferenceValueFlags: {reflect.TypeOf(comid.FlagsMap{}), newDeclaredComidExtensions},
+	comid.ExtEndorsedValue:       {reflect.TypeOf(comid.Mval{}), newDeclaredComidExtensions},
+	comid.ExtEndorsedValueFlags:  {reflect.TypeOf(comid.FlagsMap{}), newDeclaredComidExtensions},
+	comid.ExtDevIdentityKey:      {reflect.TypeOf(comid.KeyTriple{}), newDeclaredComidExtensions},
+	comid.ExtAttestVerifKey:      {reflect.TypeOf(comid.KeyTriple{}), newDeclaredComidExtensions},
+	comid.ExtEnvironment:         {reflect.TypeOf(comid.Environment{}), newDeclaredComidExtensions},
+	comid.ExtClass:               {reflect.TypeOf(comid.Class{}), newDeclaredComidExtensions},
+	ExtUnsignedCorim:             {reflect.TypeOf(UnsignedCorim{}), newDeclaredCorimExtensions},
+	ExtEntity:                    {reflect.TypeOf(Entity{}), newDeclaredCorimExtensions},
+	ExtSigner:                    {reflect.TypeOf(Signer{}), newDeclaredCorimExtensions},
+	ExtLocator:                   {reflect.TypeOf(Locator{}), newDeclaredCorimExtensions},
+	ExtMeta:                      {reflect.TypeOf(Meta{}), newDeclaredCorimExtensions},
+	ExtValidity:                  {reflect.TypeOf(Validity{}), newDeclaredCorimExtensions},
+	cots.ExtConciseTaStore:       {reflect.TypeOf(cots.ConciseTaStore{}), newDeclaredCorimExtensions},
+}
+
+var fieldTypes = map[string]reflect.Type{
+	"string": reflect.TypeOf(""),
+	"int":    reflect.TypeOf(int64(0)),
+	"uint":   reflect.TypeOf(uint64(0)),
+	"bool":   reflect.TypeOf(false),
+	"float":  reflect.TypeOf(float64(0)),
+}
+
+var goIdentifier = regexp.MustCompile(`^[A-Z][A-Za-z0-9_]*$`)
+
+// declaredPoint holds the compiled definition of an extension point
+type declaredPoint struct {
+	// fields is the struct type holding the extension fields
+	fields      reflect.Type
+	constraints []compiledConstraint
+
+	// digestAlgorithms and classIDTypes are only set for the points that
+	// enforce them
+	digestAlgorithms map[uint64]bool
+	classIDTypes     map[string]bool
+}
+
+type compiledConstraint struct {
+	FieldConstraint
+
+	allowed map[string]bool
+	pattern *regexp.Regexp
+}
+
+func compilePoint(p extensions.Point, def ExtensionPointDefinition) (*declaredPoint, error) {
+	declarable, ok := declarablePoints[p]
+	if !ok {
+		return nil, extensions.ErrUnexpectedPoint
+	}
+
+	fields, err := compileFields(def.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := declaredPoint{fields: reflect.StructOf(fields)}
+
+	for i, c := range def.Constraints {
+		if !hasField(declarable.base, c.Field) && !hasField(ret.fields, c.Field) {
+			return nil, fmt.Errorf("constraint at index %d: unknown field %q", i, c.Field)
+		}
+
+		compiled := compiledConstraint{FieldConstraint: c}
+
+		if len(c.AllowedValues) != 0 {
+			compiled.allowed = make(map[string]bool, len(c.AllowedValues))
+			for _, v := range c.AllowedValues {
+				compiled.allowed[fmt.Sprint(v)] = true
+			}
+		}
+
+		if c.Pattern != "" {
+			if compiled.pattern, err = regexp.Compile(c.Pattern); err != nil {
+				return nil, fmt.Errorf("constraint at index %d: %w", i, err)
+			}
+		}
+
+		ret.constraints = append(ret.constraints, compiled)
+	}
+
+	return &ret, nil
+}
+
+func compileFields(defs []ExtensionFieldDefinition) ([]reflect.StructField, error) {
+	ret := make([]reflect.StructField, 0, len(defs))
+	seen := make(map[string]bool)
+
+	for i, def := range defs {
+		if !goIdentifier.MatchString(def.Name) {
+			return nil, fmt.Errorf("field at index %d: invalid name %q", i, def.Name)
+		}
+
+		if def.CBORKey == nil {
+			return nil, fmt.Errorf("field %q: missing CBOR key", def.Name)
+		}
+
+		typ, err := fieldType(def.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", def.Name, err)
+		}
+
+		jsonName := def.JSONName
+		if jsonName == "" {
+			jsonName = def.Name
+		}
+
+		cborKey := strconv.Itoa(*def.CBORKey)
+
+		keys := map[string]bool{def.Name: true, jsonName: true, cborKey: true}
+		for key := range keys {
+			if seen[key] {
+				return nil, fmt.Errorf("field %q: duplicate name or key %q", def.Name, key)
+			}
+		}
+
+		for key := range keys {
+			seen[key] = true
+		}
+
+		ret = append(ret, reflect.StructField{
+			Name: def.Name,
+			Type: typ,
+			Tag: reflect.StructTag(fmt.Sprintf(`cbor:"%s,keyasint,omitempty" json:"%s,omitempty"`,
+				cborKey, jsonName)),
+		})
+	}
+
+	return ret, nil
+}
+
+// fieldType returns the type of an extension field: a pointer for scalars, so
+// that absent fields can be told from zero values
+func fieldType(name string) (reflect.Type, error) {
+	if name == "bytes" {
+		return reflect.TypeOf([]byte(nil)), nil
+	}
+
+	if elem, ok := strings.CutPrefix(name, "[]"); ok {
+		if typ, ok := fieldTypes[elem]; ok {
+			return reflect.SliceOf(typ), nil
+		}
+	} else if typ, ok := fieldTypes[name]; ok {
+		return reflect.PointerTo(typ), nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %q", name)
+}
+
+func (o *declaredPoint) newFields() extensions.IMapValue {
+	return reflect.New(o.fields).Interface()
+}
+
+// check enforces the field constraints on v, the structure extended at the
+// point
+func (o *declaredPoint) check(v any) error {
+	for _, c := range o.constraints {
+		field, ok := lookupField(reflect.ValueOf(v), c.Field)
+		if !ok || field.IsZero() {
+			if c.Required {
+				return validation.NewAt(c.Field, validation.CodeConstraint,
+					"missing required field %q", c.Field)
+			}
+
+			continue
+		}
+
+		for _, s := range valueStrings(field) {
+			if c.allowed != nil && !c.allowed[s] {
+				return validation.NewAt(c.Field, validation.CodeConstraint,
+					"field %q: value %q is not allowed", c.Field, s)
+			}
+
+			if c.pattern != nil && !c.pattern.MatchString(s) {
+				return validation.NewAt(c.Field, validation.CodeConstraint,
+					"field %q: value %q does not match %q", c.Field, s, c.Pattern)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (o *declaredPoint) checkDigests(m *comid.Mval) error {
+	if o.digestAlgorithms == nil || m.Digests == nil {
+		return nil
+	}
+
+	for i, d := range *m.Digests {
+		if !o.digestAlgorithms[d.HashAlgID] {
+			name := strconv.FormatUint(d.HashAlgID, 10)
+			if alg, ok := comid.LookupHashAlgorithm(d.HashAlgID); ok {
+				name = alg.Name
+			}
+
+			return validation.NewAt(validation.JoinPath("digests", validation.Index(i)),
+				validation.CodeConstraint,
+				"digest at index %d: algorithm %s is not allowed", i, name)
+		}
+	}
+
+	return nil
+}
+
+func (o *declaredPoint) checkClassIDs(t *comid.Triples) error {
+	if o.classIDTypes == nil {
+		return nil
+	}
+
+	for _, vt := range []struct {
+		name    string
+		triples *comid.ValueTriples
+	}{
+		{"reference-values", t.ReferenceValues},
+		{"endorsed-values", t.EndorsedValues},
+	} {
+		if vt.triples == nil {
+			continue
+		}
+
+		for i, triple := range vt.triples.Values {
+			segment := validation.JoinPath(vt.name, validation.Index(i), "environment")
+			if err := o.checkClassID(segment, triple.Environment); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, kt := range []struct {
+		name    string
+		triples *comid.KeyTriples
+	}{
+		{"dev-identity-keys", t.DevIdentityKeys},
+		{"attester-verification-keys", t.AttestVerifKeys},
+	} {
+		if kt.triples == nil {
+			continue
+		}
+
+		for i, triple := range kt.triples.Values {
+			segment := validation.JoinPath(kt.name, validation.Index(i), "environment")
+			if err := o.checkClassID(segment, triple.Environment); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (o *declaredPoint) checkClassID(segment string, env comid.Environment) error {
+	if env.Class == nil || env.Class.ClassID == nil {
+		return nil
+	}
+
+	if typ := env.Class.ClassID.Type(); !o.classIDTypes[typ] {
+		return validation.NewAt(validation.JoinPath(segment, "class", "id"),
+			validation.CodeConstraint, "%s: class ID type %q is not allowed", segment, typ)
+	}
+
+	return nil
+}
+
+// declaredComidExtensions is the IMapValue implementing the CoMID extension
+// points of a ProfileDefinition. The extension fields are held by the
+// embedded IMapValue, so that they are encoded as if they were its own.
+type declaredComidExtensions struct {
+	extensions.IMapValue
+
+	point *declaredPoint
+}
+
+func newDeclaredComidExtensions(point *declaredPoint) extensions.IMapValue {
+	return &declaredComidExtensions{IMapValue: point.newFields(), point: point}
+}
+
+func (o *declaredComidExtensions) NewMapValue() extensions.IMapValue {
+	return newDeclaredComidExtensions(o.point)
+}
+
+func (o *declaredComidExtensions) ConstrainComid(c *comid.Comid) error {
+	return o.point.check(c)
+}
+
+func (o *declaredComidExtensions) ConstrainEntity(e *comid.Entity) error {
+	return o.point.check(e)
+}
+
+func (o *declaredComidExtensions) ValidTriples(t *comid.Triples) error {
+	if err := o.point.check(t); err != nil {
+		return err
+	}
+
+	return o.point.checkClassIDs(t)
+}
+
+func (o *declaredComidExtensions) ConstrainMval(m *comid.Mval) error {
+	if err := o.point.check(m); err != nil {
+		return err
+	}
+
+	return o.point.checkDigests(m)
+}
+
+func (o *declaredComidExtensions) ConstrainFlagsMap(f *comid.FlagsMap) error {
+	return o.point.check(f)
+}
+
+func (o *declaredComidExtensions) ConstrainKeyTriple(k *comid.KeyTriple) error {
+	return o.point.check(k)
+}
+
+func (o *declaredComidExtensions) ConstrainEnvironment(e *comid.Environment) error {
+	return o.point.check(e)
+}
+
+func (o *declaredComidExtensions) ConstrainClass(c *comid.Class) error {
+	return o.point.check(c)
+}
+
+// declaredCorimExtensions is the IMapValue implementing the CoRIM (and CoTS)
+// extension points of a ProfileDefinition (see declaredComidExtensions)
+type declaredCorimExtensions struct {
+	extensions.IMapValue
+
+	point *declaredPoint
+}
+
+func newDeclaredCorimExtensions(point *declaredPoint) extensions.IMapValue {
+	return &declaredCorimExtensions{IMapValue: point.newFields(), point: point}
+}
+
+func (o *declaredCorimExtensions) NewMapValue() extensions.IMapValue {
+	return newDeclaredCorimExtensions(o.point)
+}
+
+func (o *declaredCorimExtensions) ConstrainCorim(c *UnsignedCorim) error {
+	return o.point.check(c)
+}
+
+func (o *declaredCorimExtensions) ConstrainEntity(e *Entity) error {
+	return o.point.check(e)
+}
+
+func (o *declaredCorimExtensions) ConstrainSigner(s *Signer) error {
+	return o.point.check(s)
+}
+
+func (o *declaredCorimExtensions) ConstrainLocator(l *Locator) error {
+	return o.point.check(l)
+}
+
+func (o *declaredCorimExtensions) ConstrainMeta(m *Meta) error {
+	return o.point.check(m)
+}
+
+func (o *declaredCorimExtensions) ConstrainValidity(v *Validity) error {
+	return o.point.check(v)
+}
+
+func (o *declaredCorimExtensions) ConstrainConciseTaStore(c *cots.ConciseTaStore) error {
+	return o.point.check(c)
+}
+
+func getOrAddPoint(points map[extensions.Point]*declaredPoint, p extensions.Point) *declaredPoint {
+	point, ok := points[p]
+	if !ok {
+		point = &declaredPoint{fields: reflect.StructOf(nil)}
+		points[p] = point
+	}
+
+	return point
+}
+
+func sortedPoints(m map[extensions.Point]ExtensionPointDefinition) []extensions.Point {
+	ret := make([]extensions.Point, 0, len(m))
+	for p := range m {
+		ret = append(ret, p)
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+
+	return ret
+}
+
+// fieldNameMatches returns true if name is the Go name, JSON name or CBOR key
+// of the field
+func fieldNameMatches(field reflect.StructField, name string) bool {
+	if field.Name == name {
+		return true
+	}
+
+	for _, key := range []string{"json", "cbor"} {
+		if tag, ok := field.Tag.Lookup(key); ok && strings.Split(tag, ",")[0] == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isEmbedded(field reflect.StructField) bool {
+	return field.Anonymous && field.Name == field.Type.Name()
+}
+
+// hasField returns true if the struct type typ, or any of the structs it
+// embeds, has an exported field matching name (see fieldNameMatches)
+func hasField(typ reflect.Type, name string) bool {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+
+		if isEmbedded(field) && field.Type.Kind() == reflect.Struct {
+			if hasField(field.Type, name) {
+				return true
+			}
+			continue
+		}
+
+		if field.IsExported() && fieldNameMatches(field, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookupField returns the value of the exported field matching name (see
+// fieldNameMatches) of the struct (or pointer to struct) val, descending into
+// embedded structs and into the values of embedded interfaces, which hold the
+// extensions
+func lookupField(val reflect.Value, name string) (reflect.Value, bool) {
+	for val.Kind() == reflect.Pointer || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return reflect.Value{}, false
+		}
+
+		val = val.Elem()
+	}
+
+	if val.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+
+	typ := val.Type()
+
+	for i := 0; i < val.NumField(); i++ {
+		field := typ.Field(i)
+
+		if isEmbedded(field) && (field.Type.Kind() == reflect.Struct ||
+			field.Type.Kind() == reflect.Interface) {
+			if ret, ok := lookupField(val.Field(i), name); ok {
+				return ret, true
+			}
+			continue
+		}
+
+		if field.IsExported() && fieldNameMatches(field, name) {
+			return val.Field(i), true
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+// valueStrings returns the string representations of val, or of its elements
+// if it is an array other than a byte string
+func valueStrings(val reflect.Value) []string {
+	for val.Kind() == reflect.Pointer || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return nil
+		}
+
+		val = val.Elem()
+	}
+
+	if stringer, ok := asStringer(val); ok {
+		return []string{stringer.String()}
+	}
+
+	switch val.Kind() {
+	case reflect.Slice, reflect.Array:
+		if val.Type().Elem().Kind() == reflect.Uint8 {
+			buf := make([]byte, val.Len())
+			for i := range buf {
+				buf[i] = byte(val.Index(i).Uint())
+			}
+
+			return []string{hex.EncodeToString(buf)}
+		}
+
+		var ret []string
+		for i := 0; i < val.Len(); i++ {
+			ret = append(ret, valueStrings(val.Index(i))...)
+		}
+
+		return ret
+	default:
+		return []string{fmt.Sprint(val.Interface())}
+	}
+}
+
+func asStringer(val reflect.Value) (fmt.Stringer, bool) {
+	if stringer, ok := val.Interface().(fmt.Stringer); ok {
+		return stringer, true
+	}
+
+	if val.CanAddr() {
+		stringer, ok := val.Addr().Interface().(fmt.Stringer)
+		return stringer, ok
+	}
+
+	return nil, false
+}
@@ -0,0 +1,260 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package corim
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testExampleProfileDefinition(t *testing.T) *ProfileDefinition {
+	data, err := os.ReadFile("testcases/example-profile.yaml")
+	require.NoError(t, err)
+
+	def, err := ParseProfileDefinition(data)
+	require.NoError(t, err)
+
+	return def
+}
+
+func testDecodeExampleComid(t *testing.T, def *ProfileDefinition) *comid.Comid {
+	r := NewRegistry()
+	require.NoError(t, r.RegisterProfileDefinition(def))
+
+	buf, err := os.ReadFile("testcases/unsigned-example-corim.cbor")
+	require.NoError(t, err)
+
+	c, err := UnmarshalUnsignedCorimFromCBOR(buf, WithRegistry(r))
+	require.NoError(t, err)
+	assert.Equal(t, "foo", c.Extensions.MustGetString("ext1"))
+
+	cmd, err := UnmarshalComidFromCBOR(c.Tags[0], c.Profile, WithRegistry(r))
+	require.NoError(t, err)
+
+	return cmd
+}
+
+func TestParseProfileDefinition(t *testing.T) {
+	def := testExampleProfileDefinition(t)
+	assert.Equal(t, "http://example.com/example-profile", def.ID)
+	assert.Equal(t, []string{"sha-256", "sha-384"}, def.DigestAlgorithms)
+	require.Contains(t, def.Extensions, comid.ExtEntity)
+	assert.Equal(t, "address", def.Extensions[comid.ExtEntity].Fields[0].JSONName)
+
+	fromJSON, err := ParseProfileDefinition([]byte(`{
+		"id": "http://example.com/example-profile",
+		"extensions": {
+			"ComidEntity": {
+				"fields": [
+					{"name": "Address", "cbor-key": -1, "json-name": "address", "type": "string"}
+				]
+			}
+		}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, def.Extensions[comid.ExtEntity].Fields, fromJSON.Extensions[comid.ExtEntity].Fields)
+
+	_, err = ParseProfileDefinition([]byte(`{"id": "1.2.3", "digest-algs": ["sha-256"]}`))
+	assert.EqualError(t, err, `decoding profile definition: json: unknown field "digest-algs"`)
+
+	_, err = ParseProfileDefinition([]byte("id: [1.2.3"))
+	assert.ErrorContains(t, err, "parsing profile definition: ")
+}
+
+func TestProfileDefinition_Compile_bad(t *testing.T) {
+	key := -1
+
+	for _, tv := range []struct {
+		name string
+		def  ProfileDefinition
+		err  string
+	}{
+		{
+			name: "bad ID",
+			def:  ProfileDefinition{},
+			err:  `invalid profile ID "": profile string must be an absolute URL or an ASN.1 OID: no valid OID`,
+		},
+		{
+			name: "unknown point",
+			def: ProfileDefinition{ID: "1.2.3", Extensions: map[extensions.Point]ExtensionPointDefinition{
+				"Foo": {},
+			}},
+			err: `extension point "Foo": unexpected extension point`,
+		},
+		{
+			name: "bad name",
+			def: ProfileDefinition{ID: "1.2.3", Extensions: map[extensions.Point]ExtensionPointDefinition{
+				comid.ExtEntity: {Fields: []ExtensionFieldDefinition{{Name: "address", CBORKey: &key, Type: "string"}}},
+			}},
+			err: `extension point "ComidEntity": field at index 0: invalid name "address"`,
+		},
+		{
+			name: "missing CBOR key",
+			def: ProfileDefinition{ID: "1.2.3", Extensions: map[extensions.Point]ExtensionPointDefinition{
+				comid.ExtEntity: {Fields: []ExtensionFieldDefinition{{Name: "Address", Type: "string"}}},
+			}},
+			err: `extension point "ComidEntity": field "Address": missing CBOR key`,
+		},
+		{
+			name: "bad type",
+			def: ProfileDefinition{ID: "1.2.3", Extensions: map[extensions.Point]ExtensionPointDefinition{
+				comid.ExtEntity: {Fields: []ExtensionFieldDefinition{{Name: "Address", CBORKey: &key, Type: "[]bytes"}}},
+			}},
+			err: `extension point "ComidEntity": field "Address": unsupported type "[]bytes"`,
+		},
+		{
+			name: "duplicate key",
+			def: ProfileDefinition{ID: "1.2.3", Extensions: map[extensions.Point]ExtensionPointDefinition{
+				comid.ExtEntity: {Fields: []ExtensionFieldDefinition{
+					{Name: "Address", CBORKey: &key, Type: "string"},
+					{Name: "Phone", CBORKey: &key, Type: "string"},
+				}},
+			}},
+			err: `extension point "ComidEntity": field "Phone": duplicate name or key "-1"`,
+		},
+		{
+			name: "unknown constraint field",
+			def: ProfileDefinition{ID: "1.2.3", Extensions: map[extensions.Point]ExtensionPointDefinition{
+				comid.ExtComid: {Constraints: []FieldConstraint{{Field: "address", Required: true}}},
+			}},
+			err: `extension point "Comid": constraint at index 0: unknown field "address"`,
+		},
+		{
+			name: "bad pattern",
+			def: ProfileDefinition{ID: "1.2.3", Extensions: map[extensions.Point]ExtensionPointDefinition{
+				comid.ExtComid: {Constraints: []FieldConstraint{{Field: "lang", Pattern: "("}}},
+			}},
+			err: "extension point \"Comid\": constraint at index 0: error parsing regexp: missing closing ): `(`",
+		},
+		{
+			name: "unknown digest algorithm",
+			def:  ProfileDefinition{ID: "1.2.3", DigestAlgorithms: []string{"md5"}},
+			err:  `unknown digest algorithm "md5"`,
+		},
+		{
+			name: "unknown class ID type",
+			def:  ProfileDefinition{ID: "1.2.3", ClassIDTypes: []string{"uuid", "psa.implid"}},
+			err:  `unknown class ID type "psa.implid"`,
+		},
+	} {
+		t.Run(tv.name, func(t *testing.T) {
+			_, _, err := tv.def.Compile()
+			assert.EqualError(t, err, tv.err)
+		})
+	}
+}
+
+func TestRegistry_RegisterProfileDefinition(t *testing.T) {
+	def := testExampleProfileDefinition(t)
+
+	cmd := testDecodeExampleComid(t, def)
+	assert.NoError(t, cmd.Valid())
+
+	assert.Equal(t, "123 Fake Street", cmd.Entities.Values[0].Extensions.MustGetString("Address"))
+
+	mval := cmd.Triples.ReferenceValues.Values[0].Measurement.Val
+	assert.Equal(t, int64(1720782190), mval.Extensions.MustGetInt64("timestamp"))
+
+	require.NoError(t, mval.Extensions.Set("Timestamp", int64(1)))
+	assert.Equal(t, int64(1), mval.Extensions.MustGetInt64("-1"))
+
+	r := NewRegistry()
+	require.NoError(t, r.RegisterProfileDefinition(def))
+
+	err := r.RegisterProfileDefinition(def)
+	assert.EqualError(t, err, `profile with id "http://example.com/example-profile" already registered`)
+
+	err = r.RegisterProfileDefinition(&ProfileDefinition{ID: "1.2.3", DigestAlgorithms: []string{"md5"}})
+	assert.EqualError(t, err, `profile "1.2.3": unknown digest algorithm "md5"`)
+}
+
+func TestRegistry_RegisterProfileDefinition_constraints(t *testing.T) {
+	phoneKey := -2
+
+	for _, tv := range []struct {
+		name   string
+		modify func(*ProfileDefinition)
+		path   string
+		err    string
+	}{
+		{
+			name: "allowed values",
+			modify: func(def *ProfileDefinition) {
+				def.Extensions[comid.ExtComid].Constraints[0].AllowedValues = []any{"en-US"}
+			},
+			path: "lang",
+			err:  `field "lang": value "en-GB" is not allowed`,
+		},
+		{
+			name: "pattern",
+			modify: func(def *ProfileDefinition) {
+				def.Extensions[comid.ExtEntity].Constraints[0].Pattern = "^PO Box "
+			},
+			path: "entities[0].address",
+			err:  `field "address": value "123 Fake Street" does not match "^PO Box "`,
+		},
+		{
+			name: "required",
+			modify: func(def *ProfileDefinition) {
+				point := def.Extensions[comid.ExtEntity]
+				point.Fields[0].CBORKey = &phoneKey
+				point.Fields[0].Name = "Phone"
+				point.Fields[0].JSONName = "phone"
+				point.Constraints[0] = FieldConstraint{Field: "phone", Required: true}
+				def.Extensions[comid.ExtEntity] = point
+			},
+			path: "entities[0].phone",
+			err:  `missing required field "phone"`,
+		},
+		{
+			name: "class",
+			modify: func(def *ProfileDefinition) {
+				def.Extensions[comid.ExtClass] = ExtensionPointDefinition{
+					Fields: []ExtensionFieldDefinition{
+						{Name: "FamilyIndicator", CBORKey: &phoneKey, JSONName: "family-indicator", Type: "string"},
+					},
+					Constraints: []FieldConstraint{{Field: "family-indicator", Required: true}},
+				}
+			},
+			path: "triples.reference-values[0].environment.class.family-indicator",
+			err:  `missing required field "family-indicator"`,
+		},
+		{
+			name: "digest algorithms",
+			modify: func(def *ProfileDefinition) {
+				def.DigestAlgorithms = []string{"sha-384"}
+			},
+			path: "triples.reference-values[0].measurement.value.digests[0]",
+			err:  "digest at index 0: algorithm sha-256 is not allowed",
+		},
+		{
+			name: "class ID types",
+			modify: func(def *ProfileDefinition) {
+				def.ClassIDTypes = []string{"uuid"}
+			},
+			path: "triples.reference-values[0].environment.class.id",
+			err:  `reference-values[0].environment: class ID type "psa.impl-id" is not allowed`,
+		},
+	} {
+		t.Run(tv.name, func(t *testing.T) {
+			def := testExampleProfileDefinition(t)
+			tv.modify(def)
+
+			err := testDecodeExampleComid(t, def).Valid()
+			require.Error(t, err)
+			assert.ErrorContains(t, err, tv.err)
+
+			var verr *validation.Error
+			require.True(t, errors.As(err, &verr))
+			assert.Equal(t, validation.CodeConstraint, verr.Code)
+			assert.Equal(t, tv.path, verr.Path)
+		})
+	}
+}
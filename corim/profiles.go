@@ -8,6 +8,8 @@ import (
 	"reflect"
 
 	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/cots"
+	"github.com/jraman567/corim/encoding"
 	"github.com/jraman567/corim/extensions"
 	"github.com/veraison/eat"
 	"github.com/veraison/go-cose"
@@ -17,8 +19,11 @@ import (
 // SignedCorim.
 var SignedCorimMapExtensionPoints = []extensions.Point{
 	ExtSigner,
+	ExtMeta,
+	ExtValidity,
 	ExtUnsignedCorim,
 	ExtEntity,
+	ExtLocator,
 }
 
 // UnsignedCorimMapExtensionPoints is a list of extension.Point's valid for a
@@ -26,6 +31,8 @@ var SignedCorimMapExtensionPoints = []extensions.Point{
 var UnsignedCorimMapExtensionPoints = []extensions.Point{
 	ExtUnsignedCorim,
 	ExtEntity,
+	ExtLocator,
+	ExtValidity,
 }
 
 // ComidMapExtensionPoints is a list of extension.Point's valid for a comid.Comid.
@@ -37,6 +44,16 @@ var ComidMapExtensionPoints = []extensions.Point{
 	comid.ExtReferenceValueFlags,
 	comid.ExtEndorsedValue,
 	comid.ExtEndorsedValueFlags,
+	comid.ExtDevIdentityKey,
+	comid.ExtAttestVerifKey,
+	comid.ExtEnvironment,
+	comid.ExtClass,
+}
+
+// CotsMapExtensionPoints is a list of extension.Point's valid for a
+// cots.ConciseTaStore.
+var CotsMapExtensionPoints = []extensions.Point{
+	cots.ExtConciseTaStore,
 }
 
 // AllExtensionPoints is a list of all valid extension.Point's
@@ -45,8 +62,22 @@ var AllExtensionPoints = make(map[extensions.Point]bool) // populated inside ini
 // UnmarshalSignedCorimFromCBOR unmarshals a SignedCorim from provided
 // CBOR data. If there are extensions associated with the profile specified by
 // the data, they will be registered with the UnsignedCorim before it is
-// unmarshaled.
-func UnmarshalSignedCorimFromCBOR(buf []byte) (*SignedCorim, error) {
+// unmarshaled. If the data is an encrypted CoRIM, ErrEncryptedCorim is
+// returned: use UnmarshalSignedCorimFromEncrypted instead. The supplied options
+// (e.g. encoding.WithStrict or encoding.WithLimits) are passed on to
+// SignedCorim.FromCOSE. If WithRegistry is used, the profile is looked up in
+// the selected Registry rather than in DefaultRegistry. The type choices of
+// the profile (see RegisterProfileWithTypeChoices), if any, are used for
+// decoding.
+func UnmarshalSignedCorimFromCBOR(buf []byte, opts ...encoding.DecodeOption) (*SignedCorim, error) {
+	if IsEncrypted(buf) {
+		return nil, ErrEncryptedCorim
+	}
+
+	if err := encoding.CheckLimitsCBOR(buf, opts...); err != nil {
+		return nil, fmt.Errorf("COSE-Sign1 signed CoRIM: %w", err)
+	}
+
 	message := cose.NewSign1Message()
 
 	if err := message.UnmarshalCBOR(buf); err != nil {
@@ -61,19 +92,40 @@ func UnmarshalSignedCorimFromCBOR(buf []byte) (*SignedCorim, error) {
 		return nil, err
 	}
 
-	ret := GetSignedCorim(profiled.Profile)
-	if err := ret.FromCOSE(buf); err != nil {
+	reg := registryFrom(encoding.NewDecodeOptions(opts...).Registry)
+	profile, _ := reg.GetProfile(profiled.Profile)
+
+	ret := reg.GetSignedCorim(profiled.Profile)
+	if err := ret.FromCOSE(buf, profile.decodeOptions(opts)...); err != nil {
 		return nil, err
 	}
 
+	// record the selected Registry, in which the profile is registered,
+	// rather than the one with the profile's type choices
+	ret.UnsignedCorim.setRegistry(opts)
+
 	return ret, nil
 }
 
 // UnmarshalUnsignedCorimFromCBOR unmarshals an UnsignedCorim from provided
 // CBOR data. If there are extensions associated with the profile specified by
 // the data, they will be registered with the UnsignedCorim before it is
-// unmarshaled.
-func UnmarshalUnsignedCorimFromCBOR(buf []byte) (*UnsignedCorim, error) {
+// unmarshaled. If the data is an encrypted CoRIM, ErrEncryptedCorim is
+// returned: use UnmarshalUnsignedCorimFromEncrypted instead. The supplied
+// options (e.g. encoding.WithStrict or encoding.WithLimits) are passed on to
+// UnsignedCorim.FromCBOR. If WithRegistry is used, the profile is looked up in
+// the selected Registry rather than in DefaultRegistry. The type choices of
+// the profile (see RegisterProfileWithTypeChoices), if any, are used for
+// decoding.
+func UnmarshalUnsignedCorimFromCBOR(buf []byte, opts ...encoding.DecodeOption) (*UnsignedCorim, error) {
+	if IsEncrypted(buf) {
+		return nil, ErrEncryptedCorim
+	}
+
+	if err := encoding.CheckLimitsCBOR(buf, opts...); err != nil {
+		return nil, err
+	}
+
 	profiled := struct {
 		Profile *eat.Profile `cbor:"3,keyasint,omitempty"`
 	}{}
@@ -82,19 +134,62 @@ func UnmarshalUnsignedCorimFromCBOR(buf []byte) (*UnsignedCorim, error) {
 		return nil, err
 	}
 
-	ret := GetUnsignedCorim(profiled.Profile)
-	if err := ret.FromCBOR(buf); err != nil {
+	reg := registryFrom(encoding.NewDecodeOptions(opts...).Registry)
+	profile, _ := reg.GetProfile(profiled.Profile)
+
+	ret := reg.GetUnsignedCorim(profiled.Profile)
+	if err := ret.FromCBOR(buf, profile.decodeOptions(opts)...); err != nil {
 		return nil, err
 	}
 
+	// record the selected Registry, in which the profile is registered,
+	// rather than the one with the profile's type choices
+	ret.setRegistry(opts)
+
 	return ret, nil
 }
 
+// UnmarshalSignedCorimFromEncrypted decrypts the supplied COSE_Encrypt0 or
+// COSE_Encrypt message using the supplied key (see Decrypt), then unmarshals
+// the resulting signed-corim as UnmarshalSignedCorimFromCBOR does.
+func UnmarshalSignedCorimFromEncrypted(buf []byte, key any, opts ...encoding.DecodeOption) (*SignedCorim, error) {
+	if err := encoding.CheckLimitsCBOR(buf, opts...); err != nil {
+		return nil, fmt.Errorf("encrypted CoRIM: %w", err)
+	}
+
+	plaintext, err := decryptCorim(buf, key, SignedContentType)
+	if err != nil {
+		return nil, err
+	}
+
+	return UnmarshalSignedCorimFromCBOR(plaintext, opts...)
+}
+
+// UnmarshalUnsignedCorimFromEncrypted decrypts the supplied COSE_Encrypt0 or
+// COSE_Encrypt message using the supplied key (see Decrypt), then unmarshals
+// the resulting unsigned-corim as UnmarshalUnsignedCorimFromCBOR does.
+func UnmarshalUnsignedCorimFromEncrypted(buf []byte, key any, opts ...encoding.DecodeOption) (*UnsignedCorim, error) {
+	if err := encoding.CheckLimitsCBOR(buf, opts...); err != nil {
+		return nil, fmt.Errorf("encrypted CoRIM: %w", err)
+	}
+
+	plaintext, err := decryptCorim(buf, key, ContentType)
+	if err != nil {
+		return nil, err
+	}
+
+	return UnmarshalUnsignedCorimFromCBOR(plaintext, opts...)
+}
+
 // UnmarshalUnsignedCorimFromJSON unmarshals an UnsignedCorim from provided
 // JSON data. If there are extensions associated with the profile specified by
 // the data, they will be registered with the UnsignedCorim before it is
-// unmarshaled.
-func UnmarshalUnsignedCorimFromJSON(buf []byte) (*UnsignedCorim, error) {
+// unmarshaled. The supplied options (e.g. encoding.WithStrict) are passed on to
+// UnsignedCorim.FromJSON. If WithRegistry is used, the profile is looked up in
+// the selected Registry rather than in DefaultRegistry. The type choices of
+// the profile (see RegisterProfileWithTypeChoices), if any, are used for
+// decoding.
+func UnmarshalUnsignedCorimFromJSON(buf []byte, opts ...encoding.DecodeOption) (*UnsignedCorim, error) {
 	profiled := struct {
 		Profile *eat.Profile `json:"profile,omitempty"`
 	}{}
@@ -103,28 +198,42 @@ func UnmarshalUnsignedCorimFromJSON(buf []byte) (*UnsignedCorim, error) {
 		return nil, err
 	}
 
-	ret := GetUnsignedCorim(profiled.Profile)
-	if err := ret.FromJSON(buf); err != nil {
+	reg := registryFrom(encoding.NewDecodeOptions(opts...).Registry)
+	profile, _ := reg.GetProfile(profiled.Profile)
+
+	ret := reg.GetUnsignedCorim(profiled.Profile)
+	if err := ret.FromJSON(buf, profile.decodeOptions(opts)...); err != nil {
 		return nil, err
 	}
 
+	// record the selected Registry, in which the profile is registered,
+	// rather than the one with the profile's type choices
+	ret.setRegistry(opts)
+
 	return ret, nil
 }
 
 // UnmarshalComidFromCBOR unmarshals a comid.Comid from provided CBOR data. If
 // there are extensions associated with the profile specified by the data, they
-// will be registered with the comid.Comid before it is unmarshaled.
-func UnmarshalComidFromCBOR(buf []byte, profileID *eat.Profile) (*comid.Comid, error) {
+// will be registered with the comid.Comid before it is unmarshaled. The
+// supplied options (e.g. encoding.WithStrict) are passed on to
+// comid.Comid.FromCBOR. If WithRegistry is used, the profile is looked up in
+// the selected Registry rather than in DefaultRegistry. The type choices of
+// the profile (see RegisterProfileWithTypeChoices), if any, are used for
+// decoding.
+func UnmarshalComidFromCBOR(buf []byte, profileID *eat.Profile, opts ...encoding.DecodeOption) (*comid.Comid, error) {
 	var ret *comid.Comid
 
-	profile, ok := GetProfile(profileID)
+	reg := registryFrom(encoding.NewDecodeOptions(opts...).Registry)
+
+	profile, ok := reg.GetProfile(profileID)
 	if ok {
 		ret = profile.GetComid()
 	} else {
 		ret = comid.NewComid()
 	}
 
-	if err := ret.FromCBOR(buf); err != nil {
+	if err := ret.FromCBOR(buf, profile.decodeOptions(opts)...); err != nil {
 		return nil, err
 	}
 
@@ -135,12 +244,18 @@ func UnmarshalComidFromCBOR(buf []byte, profileID *eat.Profile) (*comid.Comid, e
 // are extensions associated with the provided profileID, they will be
 // registered with the instance.
 func GetSignedCorim(profileID *eat.Profile) *SignedCorim {
+	return DefaultRegistry.GetSignedCorim(profileID)
+}
+
+// GetSignedCorim is like the package-level GetSignedCorim, but uses the
+// profiles registered with the target Registry.
+func (o *Registry) GetSignedCorim(profileID *eat.Profile) *SignedCorim {
 	var ret *SignedCorim
 
 	if profileID == nil {
 		ret = NewSignedCorim()
 	} else {
-		profile, ok := GetProfile(profileID)
+		profile, ok := o.GetProfile(profileID)
 		if !ok {
 			// unknown profile -- treat here like an unprofiled
 			// CoRIM. While the CoRIM spec states that unknown
@@ -164,22 +279,20 @@ func GetSignedCorim(profileID *eat.Profile) *SignedCorim {
 // are extensions associated with the provided profileID, they will be
 // registered with the instance.
 func GetUnsignedCorim(profileID *eat.Profile) *UnsignedCorim {
+	return DefaultRegistry.GetUnsignedCorim(profileID)
+}
+
+// GetUnsignedCorim is like the package-level GetUnsignedCorim, but uses the
+// profiles registered with the target Registry.
+func (o *Registry) GetUnsignedCorim(profileID *eat.Profile) *UnsignedCorim {
 	var ret *UnsignedCorim
 
 	if profileID == nil {
 		ret = NewUnsignedCorim()
 	} else {
-		profile, ok := GetProfile(profileID)
+		profile, ok := o.GetProfile(profileID)
 		if !ok {
-			// unknown profile -- treat here like an unprofiled
-			// CoRIM. While the CoRIM spec states that unknown
-			// profiles should be rejected, we're not actually
-			// validating the profile here, just trying to identify
-			// any extensions we may need to load. Profile
-			// validation is left up to the calling code, as a
-			// profile only needs to be registered here if it
-			// defines extensions. Profiles that do not add any
-			// additional fields may not be registered.
+			// unknown profile -- see GetSignedCorim
 			ret = NewUnsignedCorim()
 		} else {
 			ret = profile.GetUnsignedCorim()
@@ -191,10 +304,38 @@ func GetUnsignedCorim(profileID *eat.Profile) *UnsignedCorim {
 
 // Profile associates an EAT profile ID with a set of extensions. It allows
 // obtaining new CoRIM and CoMID structures that had associated extensions
-// registered.
+// registered. A Profile may also carry its own type choice implementations
+// (see TypeChoices), which are used when decoding the documents of the
+// Profile.
 type Profile struct {
 	ID            *eat.Profile
 	MapExtensions extensions.Map
+	TypeChoices   *TypeChoices
+
+	// registry is the Registry to use for the documents of the Profile: the
+	// one it was registered with or, if it has TypeChoices, a clone of it
+	// with the TypeChoices registered
+	registry *Registry
+}
+
+// Registry returns the Registry used for the documents of the Profile. If the
+// Profile has TypeChoices, this is a clone of the Registry the Profile was
+// registered with, taken at registration time, with the TypeChoices
+// registered. Use it (see WithRegistry and WithEncodeRegistry) to encode, or
+// decode from JSON, documents of the Profile that use its type choices. nil is
+// returned for a Profile that has not been registered.
+func (o *Profile) Registry() *Registry {
+	return o.registry
+}
+
+// decodeOptions returns the supplied options, extended to select the Registry
+// of the Profile, if registered
+func (o *Profile) decodeOptions(opts []encoding.DecodeOption) []encoding.DecodeOption {
+	if o.registry == nil {
+		return opts
+	}
+
+	return append(opts[:len(opts):len(opts)], WithRegistry(o.registry))
 }
 
 // GetComid returns a pointer to a new comid.Comid that had the Profile's
@@ -205,11 +346,19 @@ func (o *Profile) GetComid() *comid.Comid {
 	return ret
 }
 
+// GetCots returns a pointer to a new cots.ConciseTaStore that had the
+// Profile's extensions (if any) registered.
+func (o *Profile) GetCots() *cots.ConciseTaStore {
+	ret := cots.NewConciseTaStore()
+	o.registerExtensions(ret, CotsMapExtensionPoints)
+	return ret
+}
+
 // GetUnsignedCorim returns a pointer to a new UnsignedCorim that had the
 // Profile's extensions (if any) registered.
 func (o *Profile) GetUnsignedCorim() *UnsignedCorim {
 	ret := NewUnsignedCorim()
-	ret.Profile = o.ID
+	ret.Profile = o.newID()
 	o.registerExtensions(ret, UnsignedCorimMapExtensionPoints)
 	return ret
 }
@@ -218,16 +367,29 @@ func (o *Profile) GetUnsignedCorim() *UnsignedCorim {
 // Profile's extensions (if any) registered.
 func (o *Profile) GetSignedCorim() *SignedCorim {
 	ret := NewSignedCorim()
-	ret.UnsignedCorim.Profile = o.ID
+	ret.UnsignedCorim.Profile = o.newID()
 	o.registerExtensions(ret, SignedCorimMapExtensionPoints)
 	return ret
 }
 
+// newID returns a copy of the Profile's ID, so that decoding into the
+// structures obtained from the Profile does not write to the ID itself
+func (o *Profile) newID() *eat.Profile {
+	if o.ID == nil {
+		return nil
+	}
+
+	id := *o.ID
+	return &id
+}
+
 func (o *Profile) registerExtensions(e iextensible, points []extensions.Point) {
+	// each structure gets its own instances of the extensions, so that
+	// structures obtained from the same Profile may be decoded concurrently
 	exts := extensions.NewMap()
 	for _, p := range points {
 		if v, ok := o.MapExtensions[p]; ok {
-			exts[p] = v
+			exts[p] = extensions.Extensions{IMapValue: v}.New()
 		}
 	}
 
@@ -243,12 +405,50 @@ func (o *Profile) registerExtensions(e iextensible, points []extensions.Point) {
 // the profile has already been registered, or if the extensions are invalid,
 // an error is returned.
 func RegisterProfile(id *eat.Profile, exts extensions.Map) error {
+	return DefaultRegistry.RegisterProfile(id, exts)
+}
+
+// RegisterProfile is like the package-level RegisterProfile, but registers
+// with the target Registry.
+func (o *Registry) RegisterProfile(id *eat.Profile, exts extensions.Map) error {
+	return o.RegisterProfileWithTypeChoices(id, exts, nil)
+}
+
+// RegisterProfileWithTypeChoices is like RegisterProfile, but also associates
+// the supplied type choices with the profile. They are not registered
+// globally: they are only used when decoding the documents of the profile
+// with UnmarshalSignedCorimFromCBOR, UnmarshalUnsignedCorimFromCBOR,
+// UnmarshalUnsignedCorimFromJSON, UnmarshalComidFromCBOR and DecodeTag (see
+// also Profile.Registry). An error is returned if any of them clashes with the
+// type choices already registered.
+func RegisterProfileWithTypeChoices(id *eat.Profile, exts extensions.Map, types *TypeChoices) error {
+	return DefaultRegistry.RegisterProfileWithTypeChoices(id, exts, types)
+}
+
+// RegisterProfileWithTypeChoices is like the package-level
+// RegisterProfileWithTypeChoices, but registers with the target Registry.
+func (o *Registry) RegisterProfileWithTypeChoices(
+	id *eat.Profile, exts extensions.Map, types *TypeChoices,
+) error {
 	strID, err := id.Get()
 	if err != nil {
 		return err
 	}
 
-	if _, ok := profilesRegister[strID]; ok {
+	profile := Profile{ID: id, MapExtensions: exts, TypeChoices: types, registry: o}
+
+	if !types.IsEmpty() {
+		profile.registry = o.Clone()
+
+		if err := types.apply(profile.registry); err != nil {
+			return fmt.Errorf("profile %q: %w", strID, err)
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.profiles[strID]; ok {
 		return fmt.Errorf("profile with id %q already registered", strID)
 	}
 
@@ -262,7 +462,7 @@ func RegisterProfile(id *eat.Profile, exts extensions.Map) error {
 		}
 	}
 
-	profilesRegister[strID] = Profile{ID: id, MapExtensions: exts}
+	o.profiles[strID] = profile
 
 	return nil
 }
@@ -271,17 +471,22 @@ func RegisterProfile(id *eat.Profile, exts extensions.Map) error {
 // specified profile ID. Returns true if extensions were previously registered
 // and have been removed, and false otherwise.
 func UnregisterProfile(id *eat.Profile) bool {
-	if id == nil {
-		return false
-	}
+	return DefaultRegistry.UnregisterProfile(id)
+}
 
-	strID, err := id.Get()
-	if err != nil {
+// UnregisterProfile is like the package-level UnregisterProfile, but
+// unregisters from the target Registry.
+func (o *Registry) UnregisterProfile(id *eat.Profile) bool {
+	strID, ok := profileKey(id)
+	if !ok {
 		return false
 	}
 
-	if _, ok := profilesRegister[strID]; ok {
-		delete(profilesRegister, strID)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.profiles[strID]; ok {
+		delete(o.profiles, strID)
 		return true
 	}
 
@@ -292,16 +497,21 @@ func UnregisterProfile(id *eat.Profile) bool {
 // profile if no Profile has been registered for the id. The second return
 // value indicates whether a profile for the ID has been found.
 func GetProfile(id *eat.Profile) (Profile, bool) {
-	if id == nil {
-		return Profile{}, false
-	}
+	return DefaultRegistry.GetProfile(id)
+}
 
-	strID, err := id.Get()
-	if err != nil {
+// GetProfile is like the package-level GetProfile, but looks up the profiles
+// registered with the target Registry.
+func (o *Registry) GetProfile(id *eat.Profile) (Profile, bool) {
+	strID, ok := profileKey(id)
+	if !ok {
 		return Profile{}, false
 	}
 
-	prof, ok := profilesRegister[strID]
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	prof, ok := o.profiles[strID]
 	return prof, ok
 }
 
@@ -309,8 +519,6 @@ type iextensible interface {
 	RegisterExtensions(exts extensions.Map) error
 }
 
-var profilesRegister = make(map[string]Profile)
-
 func init() {
 	for _, p := range SignedCorimMapExtensionPoints {
 		AllExtensionPoints[p] = true
@@ -323,4 +531,8 @@ func init() {
 	for _, p := range ComidMapExtensionPoints {
 		AllExtensionPoints[p] = true
 	}
+
+	for _, p := range CotsMapExtensionPoints {
+		AllExtensionPoints[p] = true
+	}
 }
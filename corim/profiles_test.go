@@ -3,11 +3,14 @@
 package corim
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/cots"
+	"github.com/jraman567/corim/encoding"
 	"github.com/jraman567/corim/extensions"
 	"github.com/veraison/eat"
 )
@@ -70,7 +73,9 @@ func TestProfile_getters(t *testing.T) {
 		MapExtensions: extensions.NewMap().
 			Add(comid.ExtComid, &struct{}{}).
 			Add(ExtUnsignedCorim, &struct{}{}).
-			Add(ExtSigner, &struct{}{}),
+			Add(ExtSigner, &struct{}{}).
+			Add(ExtMeta, &struct{}{}).
+			Add(cots.ExtConciseTaStore, &struct{}{}),
 	}
 
 	c := profile.GetComid()
@@ -82,6 +87,74 @@ func TestProfile_getters(t *testing.T) {
 	s := profile.GetSignedCorim()
 	assert.NotNil(t, s.UnsignedCorim.Extensions.IMapValue)
 	assert.NotNil(t, s.Meta.Signer.Extensions.IMapValue)
+	assert.NotNil(t, s.Meta.Extensions.IMapValue)
+
+	ts := profile.GetCots()
+	assert.NotNil(t, ts.Extensions.IMapValue)
+}
+
+type tpmAttestKeyExtensions struct {
+	KeyName []byte `cbor:"-1,keyasint,omitempty" json:"key-name,omitempty"`
+}
+
+func (o *tpmAttestKeyExtensions) ConstrainKeyTriple(_ *comid.KeyTriple) error {
+	if len(o.KeyName) == 0 {
+		return errors.New("missing TPM key name")
+	}
+
+	return nil
+}
+
+type tpmClassExtensions struct {
+	FamilyIndicator *string `cbor:"-1,keyasint,omitempty" json:"family-indicator,omitempty"`
+}
+
+func TestProfile_key_triple_and_class_extensions(t *testing.T) {
+	profID, err := eat.NewProfile("http://example.com/tpm-profile")
+	require.NoError(t, err)
+
+	r := NewRegistry()
+	require.NoError(t, r.RegisterProfile(profID, extensions.NewMap().
+		Add(comid.ExtAttestVerifKey, &tpmAttestKeyExtensions{}).
+		Add(comid.ExtClass, &tpmClassExtensions{})))
+
+	profile, ok := r.GetProfile(profID)
+	require.True(t, ok)
+
+	vendor := "ACME"
+	c := profile.GetComid()
+	c.SetTagIdentity("tpm", 0).
+		AddAttestVerifKey(comid.KeyTriple{
+			Environment: comid.Environment{Class: &comid.Class{Vendor: &vendor}},
+			VerifKeys:   comid.CryptoKeys{comid.MustNewPKIXBase64Key(comid.TestECPubKey)},
+		})
+
+	env := &c.Triples.AttestVerifKeys.Values[0].Environment
+	require.NoError(t, env.Class.Set("family-indicator", "2.0"))
+
+	err = c.Valid()
+	assert.ErrorContains(t, err, "missing TPM key name")
+
+	require.NoError(t, c.Triples.AttestVerifKeys.Values[0].Set("key-name", []byte{0x00, 0x0b}))
+	require.NoError(t, c.Valid())
+
+	data, err := c.ToCBOR()
+	require.NoError(t, err)
+
+	out, err := UnmarshalComidFromCBOR(data, profID, WithRegistry(r))
+	require.NoError(t, err)
+
+	kt := out.Triples.AttestVerifKeys.Values[0]
+	assert.Equal(t, []byte{0x00, 0x0b}, kt.GetExtensions().(*tpmAttestKeyExtensions).KeyName)
+	assert.Equal(t, "ACME", *kt.Environment.Class.Vendor)
+	assert.Equal(t, "2.0", kt.Environment.Class.MustGetString("family-indicator"))
+	assert.NoError(t, out.Valid())
+
+	// the extensions do not apply outside of the profile
+	out, err = UnmarshalComidFromCBOR(data, nil, WithRegistry(r))
+	require.NoError(t, err)
+	assert.Nil(t, out.Triples.AttestVerifKeys.Values[0].GetExtensions())
+	assert.True(t, out.Triples.AttestVerifKeys.Values[0].HaveUnknownFields())
 }
 
 func TestProfile_marshaling(t *testing.T) {
@@ -185,3 +258,92 @@ func TestProfile_marshaling(t *testing.T) {
 
 	UnregisterProfile(profID)
 }
+
+func TestProfile_strict_decoding(t *testing.T) {
+	type corimExtensions struct {
+		Extension1 *string `cbor:"-1,keyasint,omitempty" json:"ext1,omitempty"`
+	}
+
+	type entityExtensions struct {
+		Address *string `cbor:"-1,keyasint,omitempty" json:"address,omitempty"`
+	}
+
+	type refValExtensions struct {
+		Timestamp *int `cbor:"-1,keyasint,omitempty" json:"timestamp,omitempty"`
+	}
+
+	profID, err := eat.NewProfile("http://example.com/test-profile")
+	require.NoError(t, err)
+
+	// without the profile's extensions, nothing claims the extra entries
+	c, err := UnmarshalUnsignedCorimFromCBOR(testUnsignedCorimWithExtensionsCBOR)
+	require.NoError(t, err)
+
+	_, err = UnmarshalUnsignedCorimFromCBOR(testUnsignedCorimWithExtensionsCBOR, encoding.WithStrict())
+	assert.EqualError(t, err, "/-1: unknown map key")
+	assert.ErrorIs(t, err, encoding.ErrUnknownKey)
+
+	_, err = UnmarshalComidFromCBOR(c.Tags[0], profID, encoding.WithStrict())
+	assert.ErrorIs(t, err, encoding.ErrUnknownKey)
+
+	_, err = UnmarshalUnsignedCorimFromJSON(testUnsignedCorimWithExtensionsJSON, encoding.WithStrict())
+	assert.EqualError(t, err, "/ext1: unknown map key")
+
+	extMap := extensions.NewMap().
+		Add(ExtUnsignedCorim, &corimExtensions{}).
+		Add(comid.ExtEntity, &entityExtensions{}).
+		Add(comid.ExtReferenceValue, &refValExtensions{})
+	require.NoError(t, RegisterProfile(profID, extMap))
+	defer UnregisterProfile(profID)
+
+	c, err = UnmarshalUnsignedCorimFromCBOR(testUnsignedCorimWithExtensionsCBOR, encoding.WithStrict())
+	require.NoError(t, err)
+
+	_, err = UnmarshalComidFromCBOR(c.Tags[0], c.Profile, encoding.WithStrict())
+	assert.NoError(t, err)
+
+	_, err = UnmarshalUnsignedCorimFromJSON(testUnsignedCorimWithExtensionsJSON, encoding.WithStrict())
+	assert.NoError(t, err)
+
+	_, err = UnmarshalSignedCorimFromCBOR(testSignedCorimWithExtensionsCBOR, encoding.WithStrict())
+	assert.NoError(t, err)
+
+	// a non-preferred encoding of the length of the unsigned-corim-map
+	bad := append([]byte{}, testUnsignedCorimWithExtensionsCBOR...)
+	require.Equal(t, byte(0xa4), bad[0])
+	bad = append([]byte{0xb8, 0x04}, bad[1:]...)
+
+	_, err = UnmarshalUnsignedCorimFromCBOR(bad)
+	assert.NoError(t, err)
+
+	_, err = UnmarshalUnsignedCorimFromCBOR(bad, encoding.WithStrict())
+	assert.EqualError(t, err, "/: non-preferred integer encoding: 4 encoded in 1 bytes")
+}
+
+func TestUnmarshal_limits(t *testing.T) {
+	tight := encoding.WithLimits(encoding.Limits{MaxNestingLevels: 4})
+
+	_, err := UnmarshalSignedCorimFromCBOR(testGoodSignedCorimCBOR, tight)
+	assert.NoError(t, err)
+
+	_, err = UnmarshalUnsignedCorimFromCBOR(testGoodUnsignedCorimCBOR, tight)
+	assert.NoError(t, err)
+
+	_, err = UnmarshalUnsignedCorimFromCBOR(testGoodUnsignedCorimCBOR,
+		encoding.WithLimits(encoding.Limits{MaxInputSize: 16}))
+	assert.EqualError(t, err, "max-input-size exceeded: 16")
+
+	// the tags are byte strings, which are longer than 16 bytes
+	_, err = UnmarshalUnsignedCorimFromCBOR(testGoodUnsignedCorimCBOR,
+		encoding.WithLimits(encoding.Limits{MaxStringLength: 16}))
+	assert.EqualError(t, err, "max-string-length exceeded: 16")
+
+	c, err := UnmarshalUnsignedCorimFromCBOR(testGoodUnsignedCorimCBOR)
+	require.NoError(t, err)
+
+	_, err = UnmarshalComidFromCBOR(c.Tags[0], nil, tight)
+	assert.ErrorIs(t, err, encoding.ErrLimitExceeded)
+
+	_, err = UnmarshalComidFromCBOR(c.Tags[0], nil)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,300 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	cbor "github.com/fxamacker/cbor/v2"
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/encoding"
+	"github.com/veraison/eat"
+)
+
+// Registry holds the entity name types, roles, CBOR tags and profiles known to
+// the CoRIM encoders and decoders, along with the comid.Registry used for the
+// CoMID types they embed. Registries are independent of each other, so that,
+// for example, a multi-tenant verifier may use a separate Registry for each
+// tenant. A Registry is safe for concurrent use.
+//
+// The package-level Register* and Get* functions use DefaultRegistry. Another
+// Registry is selected by passing WithRegistry (or WithEncodeRegistry) to the
+// encoding and decoding functions and methods of this package, which pass it
+// on to those of the comid and cots packages. See comid.Registry for how the
+// Registry reaches nested types.
+type Registry struct {
+	mu sync.RWMutex
+
+	comid *comid.Registry
+
+	entityNames  map[string]IEntityNameFactory
+	roleToString map[Role]string
+	stringToRole map[string]Role
+	profiles     map[string]Profile
+
+	tags map[uint64]interface{}
+	ems  *encoding.EncModes
+	dms  *encoding.DecModes
+}
+
+// DefaultRegistry is the Registry used unless another one is selected with
+// WithRegistry or WithEncodeRegistry. It uses comid.DefaultRegistry for CoMID
+// types.
+var DefaultRegistry = newRegistry(comid.DefaultRegistry)
+
+// NewRegistry returns a new Registry containing only the entity name types,
+// roles and CBOR tags defined by this package, and no profiles. It uses a new
+// comid.Registry for CoMID types (see ComidRegistry).
+func NewRegistry() *Registry {
+	return newRegistry(comid.NewRegistry())
+}
+
+func newRegistry(c *comid.Registry) *Registry {
+	ret := &Registry{
+		comid:        c,
+		entityNames:  copyMap(builtinEntityNameTypes),
+		roleToString: copyMap(builtinRoles),
+		stringToRole: make(map[string]Role, len(builtinRoles)),
+		profiles:     make(map[string]Profile),
+		tags:         copyMap(corimTagsMap),
+	}
+
+	for role, name := range builtinRoles {
+		ret.stringToRole[name] = role
+	}
+
+	if err := ret.initModes(); err != nil {
+		// the built-in tags are known to be good
+		panic(err)
+	}
+
+	return ret
+}
+
+// Clone returns a new Registry containing everything registered with the
+// target Registry so far, including a clone of its comid.Registry. Further
+// registrations with either do not affect the other.
+func (o *Registry) Clone() *Registry {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	ret := &Registry{
+		comid:        o.comid.Clone(),
+		entityNames:  copyMap(o.entityNames),
+		roleToString: copyMap(o.roleToString),
+		stringToRole: copyMap(o.stringToRole),
+		profiles:     copyMap(o.profiles),
+		tags:         copyMap(o.tags),
+		ems:          o.ems,
+		dms:          o.dms,
+	}
+
+	// the documents of profiles without type choices are decoded with the
+	// Registry the profiles are registered with
+	for id, profile := range ret.profiles {
+		if profile.registry == o {
+			profile.registry = ret
+			ret.profiles[id] = profile
+		}
+	}
+
+	return ret
+}
+
+// ComidRegistry returns the comid.Registry used for the CoMID types, e.g. to
+// register class ID types. It implements comid.IRegistryProvider.
+func (o *Registry) ComidRegistry() *comid.Registry {
+	return o.comid
+}
+
+// registerTag associates the type of t with the specified CBOR tag. The caller
+// must hold the write lock.
+func (o *Registry) registerTag(tag uint64, t interface{}) error {
+	if _, exists := o.tags[tag]; exists {
+		return fmt.Errorf("tag %d is already registered", tag)
+	}
+
+	o.tags[tag] = t
+
+	if err := o.initModes(); err != nil {
+		delete(o.tags, tag)
+		return err
+	}
+
+	return nil
+}
+
+func (o *Registry) initModes() error {
+	opts := cbor.TagOptions{
+		EncTag: cbor.EncTagRequired,
+		DecTag: cbor.DecTagRequired,
+	}
+
+	tags := cbor.NewTagSet()
+
+	for tag, typ := range o.tags {
+		if err := tags.Add(opts, reflect.TypeOf(typ), tag); err != nil {
+			return err
+		}
+	}
+
+	encModes, err := initCBOREncModes(tags)
+	if err != nil {
+		return err
+	}
+
+	decModes, err := initCBORDecModes(tags)
+	if err != nil {
+		return err
+	}
+
+	o.ems, o.dms = encModes, decModes
+
+	return nil
+}
+
+// encMode returns the EncMode selected by opts (see encoding.EncModes)
+func (o *Registry) encMode(opts encoding.EncodeOptions) cbor.EncMode {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.ems.EncMode(opts)
+}
+
+// decMode returns the DecMode selected by opts (see encoding.DecModes)
+func (o *Registry) decMode(opts encoding.DecodeOptions) cbor.DecMode {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.dms.DecMode(opts)
+}
+
+func (o *Registry) entityNameFactory(typ string) (IEntityNameFactory, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	factory, ok := o.entityNames[typ]
+	return factory, ok
+}
+
+func (o *Registry) roleName(role Role) (string, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	name, ok := o.roleToString[role]
+	return name, ok
+}
+
+func (o *Registry) roleByName(name string) (Role, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	role, ok := o.stringToRole[name]
+	return role, ok
+}
+
+// WithRegistry selects the Registry to decode with
+func WithRegistry(r *Registry) encoding.DecodeOption {
+	return func(o *encoding.DecodeOptions) {
+		o.Registry = r
+	}
+}
+
+// WithEncodeRegistry selects the Registry to encode with
+func WithEncodeRegistry(r *Registry) encoding.EncodeOption {
+	return func(o *encoding.EncodeOptions) {
+		o.Registry = r
+	}
+}
+
+// registryFrom returns the Registry selected by the Registry field of
+// encoding.DecodeOptions or encoding.EncodeOptions, or DefaultRegistry if it
+// is not a *Registry
+func registryFrom(v any) *Registry {
+	if r, ok := v.(*Registry); ok && r != nil {
+		return r
+	}
+
+	return DefaultRegistry
+}
+
+// decModeFor returns the DecMode of the Registry selected by d, with the
+// options of d applied
+func decModeFor(d *encoding.Decoder) cbor.DecMode {
+	return registryFrom(d.Registry).decMode(d.DecodeOptions)
+}
+
+// encModeFor returns the EncMode of the Registry selected by e, with the
+// options of e applied
+func encModeFor(e *encoding.Encoder) cbor.EncMode {
+	return registryFrom(e.Registry).encMode(e.EncodeOptions)
+}
+
+// defaultEncMode is a cbor.EncMode using the CBOR tags of DefaultRegistry
+type defaultEncMode struct{}
+
+func (defaultEncMode) Marshal(v interface{}) ([]byte, error) {
+	return DefaultRegistry.encMode(encoding.EncodeOptions{}).Marshal(v)
+}
+
+func (defaultEncMode) NewEncoder(w io.Writer) *cbor.Encoder {
+	return DefaultRegistry.encMode(encoding.EncodeOptions{}).NewEncoder(w)
+}
+
+func (defaultEncMode) EncOptions() cbor.EncOptions {
+	return DefaultRegistry.encMode(encoding.EncodeOptions{}).EncOptions()
+}
+
+// defaultDecMode is a cbor.DecMode using the CBOR tags of DefaultRegistry
+type defaultDecMode struct{}
+
+func (defaultDecMode) Unmarshal(data []byte, v interface{}) error {
+	return DefaultRegistry.decMode(encoding.DecodeOptions{}).Unmarshal(data, v)
+}
+
+func (defaultDecMode) UnmarshalFirst(data []byte, v interface{}) ([]byte, error) {
+	return DefaultRegistry.decMode(encoding.DecodeOptions{}).UnmarshalFirst(data, v)
+}
+
+func (defaultDecMode) Valid(data []byte) error {
+	return DefaultRegistry.decMode(encoding.DecodeOptions{}).Valid(data)
+}
+
+func (defaultDecMode) Wellformed(data []byte) error {
+	return DefaultRegistry.decMode(encoding.DecodeOptions{}).Wellformed(data)
+}
+
+func (defaultDecMode) NewDecoder(r io.Reader) *cbor.Decoder {
+	return DefaultRegistry.decMode(encoding.DecodeOptions{}).NewDecoder(r)
+}
+
+func (defaultDecMode) DecOptions() cbor.DecOptions {
+	return DefaultRegistry.decMode(encoding.DecodeOptions{}).DecOptions()
+}
+
+func profileKey(id *eat.Profile) (string, bool) {
+	if id == nil {
+		return "", false
+	}
+
+	strID, err := id.Get()
+	if err != nil {
+		return "", false
+	}
+
+	return strID, true
+}
+
+func copyMap[K comparable, V any](m map[K]V) map[K]V {
+	ret := make(map[K]V, len(m))
+
+	for k, v := range m {
+		ret[k] = v
+	}
+
+	return ret
+}
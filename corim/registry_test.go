@@ -0,0 +1,162 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package corim
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/extensions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/eat"
+)
+
+func testRegistryProfileExtensions() extensions.Map {
+	type corimExtensions struct {
+		Extension1 *string `cbor:"-1,keyasint,omitempty" json:"ext1,omitempty"`
+	}
+
+	type entityExtensions struct {
+		Address *string `cbor:"-1,keyasint,omitempty" json:"address,omitempty"`
+	}
+
+	return extensions.NewMap().
+		Add(ExtUnsignedCorim, &corimExtensions{}).
+		Add(comid.ExtEntity, &entityExtensions{})
+}
+
+func TestRegistry_profiles(t *testing.T) {
+	profID, err := eat.NewProfile("http://example.com/test-profile")
+	require.NoError(t, err)
+
+	r := NewRegistry()
+	require.NoError(t, r.RegisterProfile(profID, testRegistryProfileExtensions()))
+
+	_, ok := GetProfile(profID)
+	assert.False(t, ok)
+
+	_, ok = r.GetProfile(profID)
+	assert.True(t, ok)
+
+	c, err := UnmarshalUnsignedCorimFromCBOR(testUnsignedCorimWithExtensionsCBOR, WithRegistry(r))
+	require.NoError(t, err)
+	assert.Equal(t, "foo", c.Extensions.MustGetString("Extension1"))
+
+	cmd, err := UnmarshalComidFromCBOR(c.Tags[0], c.Profile, WithRegistry(r))
+	require.NoError(t, err)
+	assert.Equal(t, "123 Fake Street", cmd.Entities.Values[0].Extensions.MustGetString("Address"))
+
+	s, err := UnmarshalSignedCorimFromCBOR(testSignedCorimWithExtensionsCBOR, WithRegistry(r))
+	require.NoError(t, err)
+	assert.Equal(t, "foo", s.UnsignedCorim.Extensions.MustGetString("Extension1"))
+
+	c, err = UnmarshalUnsignedCorimFromCBOR(testUnsignedCorimWithExtensionsCBOR)
+	require.NoError(t, err)
+	assert.Equal(t, "", c.Extensions.MustGetString("Extension1"))
+
+	cmd, err = UnmarshalComidFromCBOR(c.Tags[0], c.Profile)
+	require.NoError(t, err)
+	assert.Equal(t, "", cmd.Entities.Values[0].Extensions.MustGetString("Address"))
+
+	clone := r.Clone()
+	assert.True(t, clone.UnregisterProfile(profID))
+
+	_, ok = r.GetProfile(profID)
+	assert.True(t, ok)
+}
+
+func TestRegistry_tags(t *testing.T) {
+	profID, err := eat.NewProfile("http://example.com/test-profile")
+	require.NoError(t, err)
+
+	r := NewRegistry()
+	require.NoError(t, r.RegisterProfile(profID, testRegistryProfileExtensions()))
+
+	c, err := UnmarshalUnsignedCorimFromCBOR(testUnsignedCorimWithExtensionsCBOR, WithRegistry(r))
+	require.NoError(t, err)
+
+	tags, err := c.DecodeTags()
+	require.NoError(t, err)
+	assert.Equal(t, "123 Fake Street", tags[0].Comid.Entities.Values[0].Extensions.MustGetString("Address"))
+
+	for _, w := range c.ValidateAll().Warnings {
+		assert.NotEqual(t, "profile", w.Path)
+	}
+
+	c, err = UnmarshalUnsignedCorimFromCBOR(testUnsignedCorimWithExtensionsCBOR)
+	require.NoError(t, err)
+
+	tags, err = c.DecodeTags()
+	require.NoError(t, err)
+	assert.Equal(t, "", tags[0].Comid.Entities.Values[0].Extensions.MustGetString("Address"))
+}
+
+func TestRegistry_roles(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.RegisterRole(10, "auditor"))
+
+	err := r.RegisterRole(11, "auditor")
+	assert.EqualError(t, err, `role with name "auditor" already exists`)
+
+	data := bytes.Replace(testUnsignedCorimJSON, []byte("manifestCreator"), []byte("auditor"), 1)
+
+	var c UnsignedCorim
+	require.NoError(t, c.FromJSON(data, WithRegistry(r)))
+	assert.Equal(t, Roles{Role(10)}, c.Entities.Values[0].Roles)
+
+	assert.ErrorContains(t, c.FromJSON(data), `unknown role "auditor"`)
+	assert.Equal(t, "Role(10)", Role(10).String())
+}
+
+func TestRegistry_ComidRegistry(t *testing.T) {
+	r := NewRegistry()
+	assert.NotSame(t, comid.DefaultRegistry, r.ComidRegistry())
+	assert.Same(t, comid.DefaultRegistry, DefaultRegistry.ComidRegistry())
+	assert.Same(t, r.ComidRegistry(), comid.RegistryFrom(r))
+
+	clone := r.Clone()
+	assert.NotSame(t, r.ComidRegistry(), clone.ComidRegistry())
+}
+
+func TestRegistry_concurrent(t *testing.T) {
+	profID, err := eat.NewProfile("http://example.com/test-profile")
+	require.NoError(t, err)
+
+	registries := []*Registry{NewRegistry(), NewRegistry()}
+	require.NoError(t, registries[0].RegisterProfile(profID, testRegistryProfileExtensions()))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 16; i++ {
+		r := registries[i%len(registries)]
+		withExtensions := i%len(registries) == 0
+
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+
+			assert.NoError(t, r.RegisterRole(int64(100+i), "role-"+string(rune('a'+i))))
+		}(i)
+
+		go func() {
+			defer wg.Done()
+
+			c, err := UnmarshalUnsignedCorimFromCBOR(testUnsignedCorimWithExtensionsCBOR, WithRegistry(r))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			if withExtensions {
+				assert.Equal(t, "foo", c.Extensions.MustGetString("Extension1"))
+			} else {
+				assert.Equal(t, "", c.Extensions.MustGetString("Extension1"))
+			}
+		}()
+	}
+
+	wg.Wait()
+}
@@ -5,8 +5,10 @@ package corim
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
+
+	"github.com/jraman567/corim/encoding"
+	"github.com/jraman567/corim/validation"
 )
 
 type Role int64
@@ -15,18 +17,15 @@ const (
 	RoleManifestCreator Role = iota + 1
 )
 
-var (
-	stringToRole = map[string]Role{
-		"manifestCreator": RoleManifestCreator,
-	}
-	roleToString = map[Role]string{
-		RoleManifestCreator: "manifestCreator",
-	}
-)
+// builtinRoles contains the roles every Registry starts with
+var builtinRoles = map[Role]string{
+	RoleManifestCreator: "manifestCreator",
+}
 
-// String returns the string representation of the Role.
+// String returns the string representation of the Role, as registered with
+// DefaultRegistry
 func (o Role) String() string {
-	text, ok := roleToString[o]
+	text, ok := DefaultRegistry.roleName(o)
 	if ok {
 		return text
 	}
@@ -37,18 +36,27 @@ func (o Role) String() string {
 // RegisterRole creates a new Role association between the provided value and
 // name. An error is returned if either clashes with any of the existing roles.
 func RegisterRole(val int64, name string) error {
+	return DefaultRegistry.RegisterRole(val, name)
+}
+
+// RegisterRole is like the package-level RegisterRole, but registers with the
+// target Registry.
+func (o *Registry) RegisterRole(val int64, name string) error {
 	role := Role(val)
 
-	if _, ok := roleToString[role]; ok {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.roleToString[role]; ok {
 		return fmt.Errorf("role with value %d already exists", val)
 	}
 
-	if _, ok := stringToRole[name]; ok {
+	if _, ok := o.stringToRole[name]; ok {
 		return fmt.Errorf("role with name %q already exists", name)
 	}
 
-	roleToString[role] = name
-	stringToRole[name] = role
+	o.roleToString[role] = name
+	o.stringToRole[name] = role
 
 	return nil
 }
@@ -59,11 +67,12 @@ func NewRoles() *Roles {
 	return &Roles{}
 }
 
-// Add appends the supplied roles to Roles list.
+// Add appends the supplied roles to Roles list. The roles must be registered
+// with DefaultRegistry.
 func (o *Roles) Add(roles ...Role) *Roles {
 	if o != nil {
 		for _, r := range roles {
-			if !isRole(r) {
+			if !DefaultRegistry.isRole(r) {
 				return nil
 			}
 			*o = append(*o, r)
@@ -72,20 +81,26 @@ func (o *Roles) Add(roles ...Role) *Roles {
 	return o
 }
 
-func isRole(r Role) bool {
-	_, ok := roleToString[r]
+func (o *Registry) isRole(r Role) bool {
+	_, ok := o.roleName(r)
 	return ok
 }
 
-// Valid iterates over the range of individual roles to check for validity
+// Valid iterates over the range of individual roles to check that they are
+// registered with DefaultRegistry
 func (o Roles) Valid() error {
+	return o.valid(DefaultRegistry)
+}
+
+// valid is like Valid, but checks the roles against reg
+func (o Roles) valid(reg *Registry) error {
 	if len(o) == 0 {
-		return errors.New("empty roles")
+		return validation.New(validation.CodeEmpty, "empty roles")
 	}
 
 	for i, r := range o {
-		if !isRole(r) {
-			return fmt.Errorf("unknown role %d at index %d", r, i)
+		if !reg.isRole(r) {
+			return validation.NewAt(validation.Index(i), validation.CodeUnknown, "unknown role %d at index %d", r, i)
 		}
 	}
 
@@ -93,28 +108,42 @@ func (o Roles) Valid() error {
 }
 
 func (o *Roles) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Roles) DecodeJSON(d *encoding.Decoder, data []byte) error {
 	var a []string
 
 	if err := json.Unmarshal(data, &a); err != nil {
 		return err
 	}
 
+	reg := registryFrom(d.Registry)
+
 	for _, s := range a {
-		r, ok := stringToRole[s]
+		r, ok := reg.roleByName(s)
 		if !ok {
 			return fmt.Errorf("unknown role %q", s)
 		}
-		o = o.Add(r)
+		*o = append(*o, r)
 	}
 
 	return nil
 }
 
 func (o Roles) MarshalJSON() ([]byte, error) {
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o Roles) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
 	roles := []string{}
 
+	reg := registryFrom(e.Registry)
+
 	for _, r := range o {
-		s, ok := roleToString[r]
+		s, ok := reg.roleName(r)
 		if !ok {
 			return nil, fmt.Errorf("unknown role %d", r)
 		}
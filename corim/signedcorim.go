@@ -8,8 +8,8 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
-	"strings"
 
+	"github.com/jraman567/corim/encoding"
 	"github.com/jraman567/corim/extensions"
 	cose "github.com/veraison/go-cose"
 )
@@ -25,7 +25,11 @@ var (
 type SignedCorim struct {
 	UnsignedCorim UnsignedCorim
 	Meta          Meta
-	message       *cose.Sign1Message
+	// MetaFormat selects the protected header parameter(s) used to convey
+	// Meta when signing. When decoding, it is set to reflect the header
+	// parameter(s) found in the signed-corim.
+	MetaFormat MetaHeaderFormat
+	message    *cose.Sign1Message
 }
 
 // NewSignedCorim instantiates an empty SignedCorim
@@ -38,11 +42,20 @@ func (o *SignedCorim) RegisterExtensions(exts extensions.Map) error {
 
 	for p, v := range exts {
 		switch p {
-		case ExtSigner:
-			signerExts := extensions.NewMap().Add(ExtSigner, v)
-			if err := o.Meta.RegisterExtensions(signerExts); err != nil {
+		case ExtSigner, ExtMeta:
+			metaExts := extensions.NewMap().Add(p, v)
+			if err := o.Meta.RegisterExtensions(metaExts); err != nil {
 				return err
 			}
+		case ExtValidity:
+			// the validity of the Meta gets its own instance of the
+			// extensions, as the one of the UnsignedCorim uses v
+			metaExts := extensions.NewMap().Add(p, extensions.Extensions{IMapValue: v}.New())
+			if err := o.Meta.RegisterExtensions(metaExts); err != nil {
+				return err
+			}
+
+			unsignedExts.Add(p, v)
 		default:
 			unsignedExts.Add(p, v)
 		}
@@ -71,21 +84,57 @@ func (o *SignedCorim) processHdrs() error {
 	// TODO(tho) Check with the CoRIM design team.
 	// See https://github.com/jraman567/corim/issues/14
 
-	v, ok = hdr.Protected[HeaderLabelCorimMeta]
-	if !ok {
-		return errors.New("missing mandatory corim.meta")
-	}
+	metaVal, haveMeta := hdr.Protected[HeaderLabelCorimMeta]
+	cwtVal, haveCWT := hdr.Protected[HeaderLabelCWTClaims]
 
-	metaCBOR, ok := v.([]byte)
-	if !ok {
-		return fmt.Errorf("expecting CBOR-encoded CoRIM Meta, got %T instead", v)
+	if !haveMeta && !haveCWT {
+		return errors.New("missing mandatory corim.meta or CWT claims")
 	}
 
 	var meta Meta
 
-	err := meta.FromCBOR(metaCBOR)
-	if err != nil {
-		return fmt.Errorf("unable to decode CoRIM Meta: %w", err)
+	// make sure any extensions registered with the target are retained
+	meta.Extensions = o.Meta.Extensions
+	meta.Signer.Extensions = o.Meta.Signer.Extensions
+
+	if o.Meta.Validity != nil && o.Meta.Validity.HaveExtensions() {
+		meta.Validity = &Validity{Extensions: o.Meta.Validity.Extensions}
+	}
+
+	if haveMeta {
+		metaCBOR, ok := metaVal.([]byte)
+		if !ok {
+			return fmt.Errorf("expecting CBOR-encoded CoRIM Meta, got %T instead", metaVal)
+		}
+
+		if err := meta.FromCBOR(metaCBOR); err != nil {
+			return fmt.Errorf("unable to decode CoRIM Meta: %w", err)
+		}
+	}
+
+	if haveCWT {
+		claims, err := decodeCWTClaimsHeader(cwtVal)
+		if err != nil {
+			return err
+		}
+
+		if haveMeta {
+			// corim-meta takes precedence, but the two must agree
+			if err := checkCWTClaimsMatchMeta(*claims, meta); err != nil {
+				return fmt.Errorf("CWT claims inconsistent with CoRIM Meta: %w", err)
+			}
+		} else if err := meta.FromCWTClaims(*claims); err != nil {
+			return fmt.Errorf("unable to map CWT claims to CoRIM Meta: %w", err)
+		}
+	}
+
+	switch {
+	case haveMeta && haveCWT:
+		o.MetaFormat = MetaHeaderBoth
+	case haveCWT:
+		o.MetaFormat = MetaHeaderCWTClaims
+	default:
+		o.MetaFormat = MetaHeaderCorimMeta
 	}
 
 	o.Meta = meta
@@ -93,11 +142,70 @@ func (o *SignedCorim) processHdrs() error {
 	return nil
 }
 
+func decodeCWTClaimsHeader(v interface{}) (*CWTClaims, error) {
+	// the COSE library decodes header parameter values into generic Go
+	// types, so re-encode the value and decode it into CWTClaims
+	data, err := em.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("unable to re-encode CWT claims: %w", err)
+	}
+
+	var claims CWTClaims
+
+	if err := claims.FromCBOR(data); err != nil {
+		return nil, fmt.Errorf("unable to decode CWT claims: %w", err)
+	}
+
+	return &claims, nil
+}
+
+func checkCWTClaimsMatchMeta(claims CWTClaims, meta Meta) error {
+	var fromClaims Meta
+
+	if err := fromClaims.FromCWTClaims(claims); err != nil {
+		return err
+	}
+
+	if fromClaims.Signer.Name != meta.Signer.Name {
+		return fmt.Errorf("issuer %q does not match signer name %q",
+			fromClaims.Signer.Name, meta.Signer.Name)
+	}
+
+	if (fromClaims.Validity == nil) != (meta.Validity == nil) {
+		return errors.New("validity present in only one of them")
+	}
+
+	if meta.Validity != nil {
+		if fromClaims.Validity.NotAfter.Unix() != meta.Validity.NotAfter.Unix() {
+			return errors.New("exp does not match not-after")
+		}
+
+		a, b := fromClaims.Validity.NotBefore, meta.Validity.NotBefore
+		if (a == nil) != (b == nil) || (a != nil && a.Unix() != b.Unix()) {
+			return errors.New("nbf does not match not-before")
+		}
+	}
+
+	return nil
+}
+
 // FromCOSE decodes and effects syntactic validation on the supplied
 // signed-corim message, including the embedded unsigned-corim and corim-meta.
 // On success, the unsigned-corim-map is made available via the UnsignedCorim
-// field while the corim-meta-map is decoded into the Meta field.
-func (o *SignedCorim) FromCOSE(buf []byte) error {
+// field while the corim-meta-map is decoded into the Meta field. If the
+// signed-corim carries a CWT Claims Set instead of (or in addition to) the
+// corim-meta, the claims are mapped onto the Meta field and MetaFormat is set
+// accordingly. Both the COSE_Sign1 envelope and the unsigned-corim are checked
+// against the decoding limits (encoding.DefaultLimits unless
+// encoding.WithLimits is used). With encoding.WithStrict, the unsigned-corim
+// must conform exactly to the schema: the COSE_Sign1 envelope is always
+// decoded without tolerating duplicate map keys or indefinite-length items.
+// Use WithRegistry to decode with a Registry other than DefaultRegistry.
+func (o *SignedCorim) FromCOSE(buf []byte, opts ...encoding.DecodeOption) error {
+	if err := encoding.CheckLimitsCBOR(buf, opts...); err != nil {
+		return fmt.Errorf("COSE-Sign1 signed CoRIM: %w", err)
+	}
+
 	o.message = cose.NewSign1Message()
 
 	if err := o.message.UnmarshalCBOR(buf); err != nil {
@@ -108,11 +216,13 @@ func (o *SignedCorim) FromCOSE(buf []byte) error {
 		return fmt.Errorf("processing COSE headers: %w", err)
 	}
 
-	if err := o.UnsignedCorim.FromCBOR(o.message.Payload); err != nil {
+	if err := o.UnsignedCorim.FromCBOR(o.message.Payload, opts...); err != nil {
 		return fmt.Errorf("failed CBOR decoding of unsigned CoRIM: %w", err)
 	}
 
-	if err := o.UnsignedCorim.Valid(); err != nil {
+	reg := registryFrom(encoding.NewDecodeOptions(opts...).Registry)
+
+	if err := o.UnsignedCorim.valid(reg); err != nil {
 		return fmt.Errorf("failed validation of unsigned CoRIM: %w", err)
 	}
 
@@ -121,38 +231,41 @@ func (o *SignedCorim) FromCOSE(buf []byte) error {
 
 // Sign returns the serialized signed-corim, signed by the supplied cose Signer.
 // The target SignedCorim must have its UnsignedCorim field correctly
-// populated.
-func (o *SignedCorim) Sign(signer cose.Signer) ([]byte, error) {
+// populated. The Meta is conveyed in the protected header in the form(s)
+// selected by MetaFormat. With encoding.WithDeterministic, both the
+// unsigned-corim payload and the corim-meta are in core deterministic encoding.
+// Use WithEncodeRegistry to encode with a Registry other than DefaultRegistry.
+func (o *SignedCorim) Sign(signer cose.Signer, opts ...encoding.EncodeOption) ([]byte, error) {
 	if signer == nil {
 		return nil, errors.New("nil signer")
 	}
 
-	if err := o.UnsignedCorim.Valid(); err != nil {
+	reg := registryFrom(encoding.NewEncodeOptions(opts...).Registry)
+
+	if err := o.UnsignedCorim.valid(reg); err != nil {
 		return nil, fmt.Errorf("failed validation of unsigned CoRIM: %w", err)
 	}
 
 	o.message = cose.NewSign1Message()
 
 	var err error
-	o.message.Payload, err = o.UnsignedCorim.ToCBOR()
+	o.message.Payload, err = o.UnsignedCorim.ToCBOR(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed CBOR encoding of unsigned CoRIM: %w", err)
 	}
 
-	metaCBOR, err := o.Meta.ToCBOR()
-	if err != nil {
-		return nil, fmt.Errorf("failed CBOR encoding of CoRIM Meta: %w", err)
-	}
-
 	alg := signer.Algorithm()
 
-	if strings.Contains(alg.String(), "unknown algorithm value") {
-		return nil, errors.New("signer has no algorithm")
+	if err := validSigningAlg(alg); err != nil {
+		return nil, err
 	}
 
 	o.message.Headers.Protected.SetAlgorithm(alg)
 	o.message.Headers.Protected[cose.HeaderLabelContentType] = ContentType
-	o.message.Headers.Protected[HeaderLabelCorimMeta] = metaCBOR
+
+	if err := o.setMetaHeaders(opts...); err != nil {
+		return nil, err
+	}
 
 	err = o.message.Sign(rand.Reader, NoExternalData, signer)
 	if err != nil {
@@ -167,6 +280,49 @@ func (o *SignedCorim) Sign(signer cose.Signer) ([]byte, error) {
 	return wrap, nil
 }
 
+func (o *SignedCorim) setMetaHeaders(opts ...encoding.EncodeOption) error {
+	if err := o.MetaFormat.Valid(); err != nil {
+		return err
+	}
+
+	if o.MetaFormat == MetaHeaderCorimMeta || o.MetaFormat == MetaHeaderBoth {
+		metaCBOR, err := o.Meta.EncodeCBOR(encoding.NewEncoder(opts...))
+		if err != nil {
+			return fmt.Errorf("failed CBOR encoding of CoRIM Meta: %w", err)
+		}
+
+		o.message.Headers.Protected[HeaderLabelCorimMeta] = metaCBOR
+	}
+
+	if o.MetaFormat == MetaHeaderCWTClaims || o.MetaFormat == MetaHeaderBoth {
+		meta := o.Meta
+
+		if o.MetaFormat == MetaHeaderBoth {
+			// the signer URI and the extensions are conveyed by the
+			// corim-meta, the CWT claims only need to carry what can be
+			// mapped onto them
+			meta.Signer.URI = nil
+			meta.Signer.Extensions = Extensions{}
+			meta.Extensions = Extensions{}
+
+			if meta.Validity != nil {
+				validity := *meta.Validity
+				validity.Extensions = Extensions{}
+				meta.Validity = &validity
+			}
+		}
+
+		claims, err := meta.ToCWTClaims()
+		if err != nil {
+			return fmt.Errorf("failed mapping CoRIM Meta to CWT claims: %w", err)
+		}
+
+		o.message.Headers.Protected[HeaderLabelCWTClaims] = claims
+	}
+
+	return nil
+}
+
 // Verify verifies the signature of the target SignedCorim object using the
 // supplied public key
 func (o *SignedCorim) Verify(pk crypto.PublicKey) error {
@@ -181,7 +337,7 @@ func (o *SignedCorim) Verify(pk crypto.PublicKey) error {
 		return fmt.Errorf("unable to get verification algorithm: %w", err)
 	}
 
-	verifier, err := cose.NewVerifier(alg, pk)
+	verifier, err := newVerifier(alg, pk)
 	if err != nil {
 		return fmt.Errorf("unable to instantiate verifier: %w", err)
 	}
@@ -8,8 +8,10 @@ import (
 	"testing"
 	"time"
 
+	cbor "github.com/fxamacker/cbor/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/jraman567/corim/encoding"
 	"github.com/jraman567/corim/extensions"
 )
 
@@ -367,6 +369,29 @@ func TestSignedCorim_SignVerify_ok(t *testing.T) {
 	}
 }
 
+func TestSignedCorim_Sign_deterministic(t *testing.T) {
+	signer, err := NewSignerFromJWK(testES256Key)
+	require.NoError(t, err)
+
+	var sc SignedCorim
+
+	sc.UnsignedCorim = *unsignedCorimFromCBOR(t, testGoodUnsignedCorimCBOR)
+	sc.Meta = *metaGood(t)
+
+	signed, err := sc.Sign(signer, encoding.WithDeterministic())
+	require.NoError(t, err)
+
+	var out SignedCorim
+	require.NoError(t, out.FromCOSE(signed))
+
+	assert.True(t, encoding.IsCanonical(out.message.Payload))
+	assert.True(t, encoding.IsCanonical(out.message.Headers.RawProtected))
+
+	meta, ok := out.message.Headers.Protected[HeaderLabelCorimMeta].([]byte)
+	require.True(t, ok)
+	assert.True(t, encoding.IsCanonical(meta))
+}
+
 func TestSignedCorim_SignVerify_fail_tampered(t *testing.T) {
 	signer, err := NewSignerFromJWK(testES256Key)
 	require.NoError(t, err)
@@ -438,3 +463,262 @@ func TestSignedCorim_extensions(t *testing.T) {
 	err = s.RegisterExtensions(badMap)
 	assert.EqualError(t, err, `unexpected extension point: "test"`)
 }
+
+func TestSignedCorim_SignVerify_meta_formats(t *testing.T) {
+	signer, err := NewSignerFromJWK(testES256Key)
+	require.NoError(t, err)
+
+	pk, err := NewPublicKeyFromJWK(testES256Key)
+	require.NoError(t, err)
+
+	for _, format := range []MetaHeaderFormat{
+		MetaHeaderCorimMeta,
+		MetaHeaderCWTClaims,
+		MetaHeaderBoth,
+	} {
+		t.Run(format.String(), func(t *testing.T) {
+			var SignedCorimIn SignedCorim
+
+			SignedCorimIn.UnsignedCorim = *unsignedCorimFromCBOR(t, testGoodUnsignedCorimCBOR)
+			SignedCorimIn.Meta = *metaGood(t)
+			SignedCorimIn.MetaFormat = format
+
+			cbor, err := SignedCorimIn.Sign(signer)
+			require.NoError(t, err)
+
+			var SignedCorimOut SignedCorim
+
+			err = SignedCorimOut.FromCOSE(cbor)
+			require.NoError(t, err)
+
+			assert.Equal(t, format, SignedCorimOut.MetaFormat)
+			assert.Equal(t, SignedCorimIn.Meta.Signer.Name, SignedCorimOut.Meta.Signer.Name)
+			require.NotNil(t, SignedCorimOut.Meta.Validity)
+			assert.True(t, SignedCorimIn.Meta.Validity.NotAfter.Equal(
+				SignedCorimOut.Meta.Validity.NotAfter))
+
+			_, hasMeta := SignedCorimOut.message.Headers.Protected[HeaderLabelCorimMeta]
+			_, hasCWT := SignedCorimOut.message.Headers.Protected[HeaderLabelCWTClaims]
+			assert.Equal(t, format != MetaHeaderCWTClaims, hasMeta)
+			assert.Equal(t, format != MetaHeaderCorimMeta, hasCWT)
+
+			err = SignedCorimOut.Verify(pk)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSignedCorim_Sign_fail_cwt_claims_signer_uri(t *testing.T) {
+	signer, err := NewSignerFromJWK(testES256Key)
+	require.NoError(t, err)
+
+	uri := "https://acme.example"
+
+	var SignedCorimIn SignedCorim
+
+	SignedCorimIn.UnsignedCorim = *unsignedCorimFromCBOR(t, testGoodUnsignedCorimCBOR)
+	SignedCorimIn.Meta = *NewMeta().SetSigner("ACME Ltd.", &uri)
+	SignedCorimIn.MetaFormat = MetaHeaderCWTClaims
+
+	_, err = SignedCorimIn.Sign(signer)
+	assert.EqualError(t, err,
+		"failed mapping CoRIM Meta to CWT claims: signer URI cannot be represented in CWT claims")
+
+	// when both forms are emitted, the URI is carried by the corim-meta
+	SignedCorimIn.MetaFormat = MetaHeaderBoth
+
+	cbor, err := SignedCorimIn.Sign(signer)
+	require.NoError(t, err)
+
+	var SignedCorimOut SignedCorim
+
+	err = SignedCorimOut.FromCOSE(cbor)
+	require.NoError(t, err)
+	require.NotNil(t, SignedCorimOut.Meta.Signer.URI)
+	assert.Equal(t, uri, string(*SignedCorimOut.Meta.Signer.URI))
+}
+
+type testMetaExtensions struct {
+	Purpose *string `cbor:"-1,keyasint,omitempty" json:"purpose,omitempty"`
+}
+
+type testValidityExtensions struct {
+	Reason *string `cbor:"-1,keyasint,omitempty" json:"reason,omitempty"`
+}
+
+func TestSignedCorim_SignVerify_both_meta_formats_extensions(t *testing.T) {
+	signer, err := NewSignerFromJWK(testES256Key)
+	require.NoError(t, err)
+
+	extMap := extensions.NewMap().
+		Add(ExtMeta, &testMetaExtensions{}).
+		Add(ExtValidity, &testValidityExtensions{})
+
+	var SignedCorimIn SignedCorim
+
+	require.NoError(t, SignedCorimIn.RegisterExtensions(extMap))
+	SignedCorimIn.UnsignedCorim = *unsignedCorimFromCBOR(t, testGoodUnsignedCorimCBOR)
+	SignedCorimIn.Meta.SetSigner("ACME Ltd.", nil)
+	require.NotNil(t, SignedCorimIn.Meta.SetValidity(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), nil))
+	require.NoError(t, SignedCorimIn.Meta.Set("purpose", "release"))
+	require.NoError(t, SignedCorimIn.Meta.Validity.Extensions.Set("reason", "renewal"))
+	SignedCorimIn.MetaFormat = MetaHeaderBoth
+
+	cbor, err := SignedCorimIn.Sign(signer)
+	require.NoError(t, err)
+
+	// the extensions are left to the corim-meta
+	assert.Equal(t, "release", SignedCorimIn.Meta.MustGetString("purpose"))
+	assert.Equal(t, "renewal", SignedCorimIn.Meta.Validity.MustGetString("reason"))
+
+	var SignedCorimOut SignedCorim
+
+	require.NoError(t, SignedCorimOut.RegisterExtensions(extensions.NewMap().
+		Add(ExtMeta, &testMetaExtensions{}).
+		Add(ExtValidity, &testValidityExtensions{})))
+	require.NoError(t, SignedCorimOut.FromCOSE(cbor))
+
+	assert.Equal(t, MetaHeaderBoth, SignedCorimOut.MetaFormat)
+	assert.Equal(t, "release", SignedCorimOut.Meta.MustGetString("purpose"))
+	require.NotNil(t, SignedCorimOut.Meta.Validity)
+	assert.Equal(t, "renewal", SignedCorimOut.Meta.Validity.MustGetString("reason"))
+
+	pk, err := NewPublicKeyFromJWK(testES256Key)
+	require.NoError(t, err)
+	assert.NoError(t, SignedCorimOut.Verify(pk))
+}
+
+func TestSignedCorim_FromCOSE_cwt_claims(t *testing.T) {
+	/*
+		18(
+		  [
+		    / protected / << {
+		      / alg / 1: -7, / ECDSA 256 /
+		      / content-type / 3: "application/rim+cbor",
+		      / CWT-Claims / 15: {
+		        / iss / 1: "ACME Ltd.",
+		        / exp / 4: 1605181526
+		      }
+		    } >>,
+		    / unprotected / {},
+		    / payload / << unsigned-good-corim >>,
+		    / signature / h'deadbeef'
+		  ]
+		)
+	*/
+	protected := map[interface{}]interface{}{
+		int64(1): int64(-7),
+		int64(3): ContentType,
+		int64(15): map[interface{}]interface{}{
+			int64(1): "ACME Ltd.",
+			int64(4): int64(1605181526),
+		},
+	}
+
+	tv := mustSign1Bytes(t, protected, testGoodUnsignedCorimCBOR)
+
+	var actual SignedCorim
+	err := actual.FromCOSE(tv)
+	require.NoError(t, err)
+
+	assert.Equal(t, MetaHeaderCWTClaims, actual.MetaFormat)
+	assert.Equal(t, "ACME Ltd.", actual.Meta.Signer.Name)
+	require.NotNil(t, actual.Meta.Validity)
+	assert.Equal(t, int64(1605181526), actual.Meta.Validity.NotAfter.Unix())
+	assert.Nil(t, actual.Meta.Validity.NotBefore)
+}
+
+func TestSignedCorim_FromCOSE_fail_no_meta(t *testing.T) {
+	protected := map[interface{}]interface{}{
+		int64(1): int64(-7),
+		int64(3): ContentType,
+	}
+
+	tv := mustSign1Bytes(t, protected, testGoodUnsignedCorimCBOR)
+
+	var actual SignedCorim
+	err := actual.FromCOSE(tv)
+	assert.EqualError(t, err, "processing COSE headers: missing mandatory corim.meta or CWT claims")
+}
+
+func TestSignedCorim_FromCOSE_fail_inconsistent_meta(t *testing.T) {
+	metaCBOR, err := metaGood(t).ToCBOR()
+	require.NoError(t, err)
+
+	protected := map[interface{}]interface{}{
+		int64(1): int64(-7),
+		int64(3): ContentType,
+		int64(8): metaCBOR,
+		int64(15): map[interface{}]interface{}{
+			int64(1): "EMCA Ltd.",
+		},
+	}
+
+	tv := mustSign1Bytes(t, protected, testGoodUnsignedCorimCBOR)
+
+	var actual SignedCorim
+	err = actual.FromCOSE(tv)
+	assert.EqualError(t, err,
+		`processing COSE headers: CWT claims inconsistent with CoRIM Meta: issuer "EMCA Ltd." does not match signer name "ACME Ltd."`)
+}
+
+// mustSign1Bytes assembles a COSE_Sign1_Tagged with the supplied protected
+// header and payload, and a dummy signature
+func mustSign1Bytes(t *testing.T, protected map[interface{}]interface{}, payload []byte) []byte {
+	protectedCBOR, err := em.Marshal(protected)
+	require.NoError(t, err)
+
+	sign1 := cbor.Tag{
+		Number: 18,
+		Content: []interface{}{
+			protectedCBOR,
+			map[interface{}]interface{}{},
+			payload,
+			[]byte{0xde, 0xad, 0xbe, 0xef},
+		},
+	}
+
+	data, err := em.Marshal(sign1)
+	require.NoError(t, err)
+
+	return data
+}
+
+func TestSignedCorim_FromCOSE_limits(t *testing.T) {
+	var sc SignedCorim
+
+	require.NoError(t, sc.FromCOSE(testGoodSignedCorimCBOR))
+
+	err := sc.FromCOSE(testGoodSignedCorimCBOR, encoding.WithLimits(encoding.Limits{MaxInputSize: 64}))
+	assert.ErrorIs(t, err, encoding.ErrLimitExceeded)
+	assert.EqualError(t, err, "COSE-Sign1 signed CoRIM: max-input-size exceeded: 64")
+
+	// the limits also apply to the unsigned-corim in the payload
+	metaCBOR, err := metaGood(t).ToCBOR()
+	require.NoError(t, err)
+
+	payload, err := em.Marshal(cbor.Tag{
+		Number: 501,
+		Content: map[interface{}]interface{}{
+			int64(0):  "test corim id",
+			int64(99): [][][][]int{{{{1}}}},
+		},
+	})
+	require.NoError(t, err)
+
+	protected := map[interface{}]interface{}{
+		int64(1): int64(-7),
+		int64(3): ContentType,
+		int64(8): metaCBOR,
+	}
+
+	tv := mustSign1Bytes(t, protected, payload)
+
+	err = sc.FromCOSE(tv, encoding.WithLimits(encoding.Limits{MaxNestingLevels: 4}))
+	assert.ErrorIs(t, err, encoding.ErrLimitExceeded)
+
+	var limitErr *encoding.LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, encoding.LimitNestingLevels, limitErr.Limit)
+	assert.Equal(t, 4, limitErr.Max)
+}
@@ -3,6 +3,7 @@
 package corim
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
@@ -12,10 +13,13 @@ import (
 	"fmt"
 	"reflect"
 
-	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/cloudflare/circl/sign/ed448"
 	"github.com/jraman567/corim/comid"
 	"github.com/jraman567/corim/encoding"
 	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
 	cose "github.com/veraison/go-cose"
 )
 
@@ -80,12 +84,12 @@ func (o *Signer) SetURI(uri string) *Signer {
 // Valid checks the validity of individual fields within Signer
 func (o Signer) Valid() error {
 	if o.Name == "" {
-		return errors.New("empty name")
+		return validation.NewAt("name", validation.CodeEmpty, "empty name")
 	}
 
 	if o.URI != nil {
 		if err := comid.IsAbsoluteURI(string(*o.URI)); err != nil {
-			return fmt.Errorf("invalid URI: %w", err)
+			return validation.Errorf("uri", "invalid URI: %w", err)
 		}
 	}
 
@@ -94,22 +98,42 @@ func (o Signer) Valid() error {
 
 // UnmarshalCBOR deserializes from CBOR
 func (o *Signer) UnmarshalCBOR(data []byte) error {
-	return encoding.PopulateStructFromCBOR(dm, data, o)
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *Signer) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromCBOR(decModeFor(d), data, o)
 }
 
 // MarshalCBOR serializes to CBOR
 func (o Signer) MarshalCBOR() ([]byte, error) {
-	return encoding.SerializeStructToCBOR(em, o)
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o Signer) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return e.SerializeStructToCBOR(encModeFor(e), o)
 }
 
 // UnmarshalJSON deserializes from JSON
 func (o *Signer) UnmarshalJSON(data []byte) error {
-	return encoding.PopulateStructFromJSON(data, o)
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Signer) DecodeJSON(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromJSON(data, o)
 }
 
 // MarshalJSON serializes to JSON
 func (o Signer) MarshalJSON() ([]byte, error) {
-	return encoding.SerializeStructToJSON(o)
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o Signer) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
+	return e.SerializeStructToJSON(o)
 }
 
 const noAlg = cose.Algorithm(-65537)
@@ -127,9 +151,18 @@ func getAlgAndKeyFromJWK(j []byte) (cose.Algorithm, crypto.Signer, error) {
 		return noAlg, nil, err
 	}
 
+	if err = checkOKPCurve(k); err != nil {
+		return noAlg, nil, err
+	}
+
 	var key crypto.Signer
 
-	err = k.Raw(&key)
+	if okp, ok := k.(jwk.OKPPrivateKey); ok && okp.Crv() == jwa.Ed448 {
+		key, err = ed448KeyFromJWK(okp)
+	} else {
+		err = k.Raw(&key)
+	}
+
 	if err != nil {
 		return noAlg, nil, err
 	}
@@ -140,7 +173,7 @@ func getAlgAndKeyFromJWK(j []byte) (cose.Algorithm, crypto.Signer, error) {
 		if alg == noAlg {
 			return noAlg, nil, fmt.Errorf("unknown elliptic curve %v", crv)
 		}
-	case ed25519.PrivateKey:
+	case ed25519.PrivateKey, ed448.PrivateKey:
 		alg = cose.AlgorithmEd25519
 	case *rsa.PrivateKey:
 		alg = rsaJWKToAlg(k)
@@ -175,25 +208,98 @@ func rsaJWKToAlg(k jwk.Key) cose.Algorithm {
 		return cose.AlgorithmPS384
 	case "PS512":
 		return cose.AlgorithmPS512
+	case "RS256":
+		return AlgorithmRS256
+	case "RS384":
+		return AlgorithmRS384
+	case "RS512":
+		return AlgorithmRS512
 	default:
 		return noAlg
 	}
 }
 
+// checkOKPCurve makes sure that octet key pairs use one of the Edwards curves
+// that can be used for signing, i.e., Ed25519 or Ed448
+func checkOKPCurve(k jwk.Key) error {
+	var crv jwa.EllipticCurveAlgorithm
+
+	switch v := k.(type) {
+	case jwk.OKPPrivateKey:
+		crv = v.Crv()
+	case jwk.OKPPublicKey:
+		crv = v.Crv()
+	default:
+		return nil
+	}
+
+	if crv != jwa.Ed25519 && crv != jwa.Ed448 {
+		return fmt.Errorf("unsupported OKP curve %q (only Ed25519 and Ed448 are supported)", crv)
+	}
+
+	return nil
+}
+
+// ed448KeyFromJWK returns the Ed448 private key in the supplied OKP JWK. This
+// is not supported by the JWK library.
+func ed448KeyFromJWK(k jwk.OKPPrivateKey) (ed448.PrivateKey, error) {
+	if len(k.D()) != ed448.SeedSize {
+		return nil, fmt.Errorf("invalid Ed448 private key size %d", len(k.D()))
+	}
+
+	key := ed448.NewKeyFromSeed(k.D())
+
+	if !bytes.Equal(k.X(), key.Public().(ed448.PublicKey)) {
+		return nil, errors.New("invalid x value given d value")
+	}
+
+	return key, nil
+}
+
+// NewSignerFromJWK returns a cose.Signer for the private key in the supplied
+// JWK. EC (P-256, P-384, P-521), OKP (Ed25519, Ed448) and RSA keys are
+// supported. For
+// RSA keys, the "alg" member selects between PSS (PS256, PS384, PS512) and
+// PKCS#1 v1.5 (RS256, RS384, RS512) padding.
 func NewSignerFromJWK(j []byte) (cose.Signer, error) {
 	alg, key, err := getAlgAndKeyFromJWK(j)
 	if err != nil {
 		return nil, err
 	}
 
-	return cose.NewSigner(alg, key)
+	return NewSignerWithAlgorithm(alg, key)
 }
 
+// NewPublicKeyFromJWK returns the public key in the supplied JWK, which may
+// contain either a private or a public key.
 func NewPublicKeyFromJWK(j []byte) (crypto.PublicKey, error) {
-	_, key, err := getAlgAndKeyFromJWK(j)
+	k, err := jwk.ParseKey(j)
 	if err != nil {
 		return nil, err
 	}
 
-	return key.Public(), nil
+	if err = checkOKPCurve(k); err != nil {
+		return nil, err
+	}
+
+	pub, err := k.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if okp, ok := pub.(jwk.OKPPublicKey); ok && okp.Crv() == jwa.Ed448 {
+		if len(okp.X()) != ed448.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed448 public key size %d", len(okp.X()))
+		}
+
+		return ed448.PublicKey(okp.X()), nil
+	}
+
+	var key crypto.PublicKey
+
+	if err = pub.Raw(&key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
 }
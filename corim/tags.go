@@ -0,0 +1,310 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/cots"
+	"github.com/jraman567/corim/encoding"
+	"github.com/veraison/eat"
+	"github.com/veraison/swid"
+)
+
+// ErrTagNotFound is returned when no tag in a CoRIM has the requested tag-id
+var ErrTagNotFound = errors.New("tag not found")
+
+// TagKind identifies the type of a Tag, based on its CBOR tag
+type TagKind int
+
+const (
+	TagKindUnknown TagKind = iota
+	TagKindComid
+	TagKindCoswid
+	TagKindCots
+)
+
+// String returns a printable representation of the TagKind
+func (o TagKind) String() string {
+	switch o {
+	case TagKindComid:
+		return "comid"
+	case TagKindCoswid:
+		return "coswid"
+	case TagKindCots:
+		return "cots"
+	default:
+		return "unknown"
+	}
+}
+
+// Kind returns the type of the tag, based on its CBOR tag prefix
+func (o Tag) Kind() TagKind {
+	switch {
+	case bytes.HasPrefix(o, ComidTag):
+		return TagKindComid
+	case bytes.HasPrefix(o, CoswidTag):
+		return TagKindCoswid
+	case bytes.HasPrefix(o, cots.CotsTag):
+		return TagKindCots
+	default:
+		return TagKindUnknown
+	}
+}
+
+// TypedTag is a decoded Tag. Exactly one of Comid, Coswid and Cots is set,
+// according to Kind, unless Kind is TagKindUnknown.
+type TypedTag struct {
+	Kind   TagKind
+	Raw    Tag
+	Comid  *comid.Comid
+	Coswid *swid.SoftwareIdentity
+	Cots   *cots.ConciseTaStore
+}
+
+// TagID returns the tag-id of the decoded tag, and false if the tag does not
+// have one (i.e., an unknown tag, or a CoTS without tag-identity)
+func (o TypedTag) TagID() (string, bool) {
+	switch {
+	case o.Comid != nil:
+		return o.Comid.TagIdentity.TagID.String(), true
+	case o.Coswid != nil:
+		return o.Coswid.TagID.String(), true
+	case o.Cots != nil && o.Cots.TagIdentity != nil:
+		return o.Cots.TagIdentity.TagID.String(), true
+	default:
+		return "", false
+	}
+}
+
+// TagIdentity returns the tag-id and tag-version of the decoded tag, and false
+// if the tag does not have a tag-id
+func (o TypedTag) TagIdentity() (comid.TagIdentity, bool) {
+	switch {
+	case o.Comid != nil:
+		return o.Comid.TagIdentity, true
+	case o.Coswid != nil && o.Coswid.TagVersion >= 0:
+		return comid.TagIdentity{
+			TagID:      o.Coswid.TagID,
+			TagVersion: uint(o.Coswid.TagVersion),
+		}, true
+	case o.Cots != nil && o.Cots.TagIdentity != nil:
+		return *o.Cots.TagIdentity, true
+	default:
+		return comid.TagIdentity{}, false
+	}
+}
+
+// DecodeTag decodes the supplied tag according to its CBOR tag. If there are
+// extensions associated with the supplied profile, they are registered with
+// a CoMID or CoTS before it is decoded. Tags of unknown type are returned undecoded,
+// with Kind set to TagKindUnknown. The supplied options (e.g. WithRegistry) are
+// passed on to the CoMID and CoTS decoders, which also use the type choices of
+// the profile, if any.
+func DecodeTag(tag Tag, profileID *eat.Profile, opts ...encoding.DecodeOption) (*TypedTag, error) {
+	ret := TypedTag{Kind: tag.Kind(), Raw: tag}
+
+	switch ret.Kind {
+	case TagKindComid:
+		c, err := UnmarshalComidFromCBOR(tag[len(ComidTag):], profileID, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("decoding comid: %w", err)
+		}
+		ret.Comid = c
+	case TagKindCoswid:
+		var s swid.SoftwareIdentity
+		if err := s.FromCBOR(tag[len(CoswidTag):]); err != nil {
+			return nil, fmt.Errorf("decoding coswid: %w", err)
+		}
+		ret.Coswid = &s
+	case TagKindCots:
+		profile, _ := registryFrom(encoding.NewDecodeOptions(opts...).Registry).GetProfile(profileID)
+
+		c := profile.GetCots()
+		if err := c.FromCBOR(tag[len(cots.CotsTag):], profile.decodeOptions(opts)...); err != nil {
+			return nil, fmt.Errorf("decoding cots: %w", err)
+		}
+		ret.Cots = c
+	}
+
+	return &ret, nil
+}
+
+// TagIterator iterates over the decoded tags of an UnsignedCorim:
+//
+//	it := uc.IterTags()
+//	for it.Next() {
+//		t := it.Tag()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type TagIterator struct {
+	corim *UnsignedCorim
+	index int
+	cur   *TypedTag
+	err   error
+}
+
+// IterTags returns an iterator over the decoded tags of the target
+// UnsignedCorim. CoMIDs and CoTS are decoded with the extensions of the
+// CoRIM's profile, if any, and with the Registry the CoRIM was decoded with.
+func (o *UnsignedCorim) IterTags() *TagIterator {
+	return &TagIterator{corim: o, index: -1}
+}
+
+// Next advances the iterator to the next tag, returning false when there are
+// no more tags or a tag fails to decode
+func (o *TagIterator) Next() bool {
+	if o.err != nil || o.index+1 >= len(o.corim.Tags) {
+		o.cur = nil
+		return false
+	}
+
+	o.index++
+
+	t, err := DecodeTag(o.corim.Tags[o.index], o.corim.Profile, o.corim.decodeOptions()...)
+	if err != nil {
+		o.cur = nil
+		o.err = fmt.Errorf("tag at index %d: %w", o.index, err)
+		return false
+	}
+
+	o.cur = t
+
+	return true
+}
+
+// Tag returns the current tag
+func (o TagIterator) Tag() *TypedTag {
+	return o.cur
+}
+
+// Index returns the position of the current tag in the tags array
+func (o TagIterator) Index() int {
+	return o.index
+}
+
+// Err returns the decoding error that stopped the iteration, if any
+func (o TagIterator) Err() error {
+	return o.err
+}
+
+// DecodeTags returns all the decoded tags of the target UnsignedCorim (see
+// IterTags)
+func (o *UnsignedCorim) DecodeTags() ([]*TypedTag, error) {
+	ret := make([]*TypedTag, 0, len(o.Tags))
+
+	it := o.IterTags()
+	for it.Next() {
+		ret = append(ret, it.Tag())
+	}
+
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// RemoveTag removes all the tags with the supplied tag-id from the target
+// UnsignedCorim, returning the number of removed tags. Tag-ids are matched by
+// type and value (see comid.TagIdentity.SameTag).
+func (o *UnsignedCorim) RemoveTag(tagID swid.TagID) (int, error) {
+	idx, err := o.tagIndexes(tagID)
+	if err != nil {
+		return 0, err
+	}
+
+	o.Tags = removeIndexes(o.Tags, idx)
+
+	return len(idx), nil
+}
+
+// ReplaceTag replaces the tag with the supplied tag-id with tag, which takes
+// the position of the first match. Any further tag with the same tag-id is
+// removed. ErrTagNotFound is returned if there is no such tag. Tag-ids are
+// matched by type and value (see comid.TagIdentity.SameTag).
+func (o *UnsignedCorim) ReplaceTag(tagID swid.TagID, tag Tag) error {
+	if err := tag.Valid(); err != nil {
+		return err
+	}
+
+	idx, err := o.tagIndexes(tagID)
+	if err != nil {
+		return err
+	}
+
+	if len(idx) == 0 {
+		return fmt.Errorf("%w: %q", ErrTagNotFound, tagID.String())
+	}
+
+	o.Tags[idx[0]] = tag
+	o.Tags = removeIndexes(o.Tags, idx[1:])
+
+	return nil
+}
+
+// ReplaceComid replaces the tag with the same tag-id as the supplied CoMID
+// with its CBOR encoding (see ReplaceTag). The CoMID is encoded with the
+// Registry the target UnsignedCorim was decoded with.
+func (o *UnsignedCorim) ReplaceComid(c comid.Comid) error {
+	if err := c.Valid(); err != nil {
+		return fmt.Errorf("comid validation failed: %w", err)
+	}
+
+	var opts []encoding.EncodeOption
+	if o.registry != nil {
+		opts = append(opts, WithEncodeRegistry(o.registry))
+	}
+
+	data, err := c.ToCBOR(opts...)
+	if err != nil {
+		return err
+	}
+
+	return o.ReplaceTag(c.TagIdentity.TagID, append(append(Tag{}, ComidTag...), data...))
+}
+
+func (o *UnsignedCorim) tagIndexes(tagID swid.TagID) ([]int, error) {
+	var ret []int
+
+	want := comid.TagIdentity{TagID: tagID}
+
+	it := o.IterTags()
+	for it.Next() {
+		if id, ok := it.Tag().TagIdentity(); ok && id.SameTag(want) {
+			ret = append(ret, it.Index())
+		}
+	}
+
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// removeIndexes removes the elements at the supplied (ascending) positions
+func removeIndexes(tags []Tag, idx []int) []Tag {
+	if len(idx) == 0 {
+		return tags
+	}
+
+	ret := make([]Tag, 0, len(tags)-len(idx))
+
+	for i, t := range tags {
+		if len(idx) > 0 && idx[0] == i {
+			idx = idx[1:]
+			continue
+		}
+		ret = append(ret, t)
+	}
+
+	return ret
+}
@@ -0,0 +1,206 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"testing"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/cots"
+	"github.com/jraman567/corim/extensions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/eat"
+	"github.com/veraison/swid"
+)
+
+func newMixedTagsCorim(t *testing.T) *UnsignedCorim {
+	uc := NewUnsignedCorim().SetID("mixed")
+	require.NotNil(t, uc)
+
+	var c comid.Comid
+	require.NoError(t, c.FromJSON([]byte(comid.PSARefValJSONTemplate)))
+	require.NotNil(t, uc.AddComid(c))
+
+	var ts cots.ConciseTaStore
+	require.NoError(t, ts.FromJSON([]byte(cots.ConciseTaStoreTemplateSingleOrg)))
+	require.NotNil(t, uc.AddCots(ts))
+
+	sw, err := swid.NewTag("sw-tag", "Acme Firmware", "1.0")
+	require.NoError(t, err)
+	e := swid.Entity{EntityName: "Acme"}
+	require.NoError(t, e.SetRoles(swid.RoleTagCreator))
+	require.NoError(t, sw.AddEntity(e))
+	require.NotNil(t, uc.AddCoswid(*sw))
+
+	// a tag of unknown type
+	uc.Tags = append(uc.Tags, Tag{0xd9, 0x01, 0xfc, 0xa0})
+
+	return uc
+}
+
+func TestUnsignedCorim_IterTags(t *testing.T) {
+	uc := newMixedTagsCorim(t)
+
+	var kinds []TagKind
+	var ids []string
+
+	it := uc.IterTags()
+	for it.Next() {
+		tag := it.Tag()
+		kinds = append(kinds, tag.Kind)
+
+		if id, ok := tag.TagID(); ok {
+			ids = append(ids, id)
+		}
+
+		assert.Equal(t, uc.Tags[it.Index()], tag.Raw)
+	}
+	require.NoError(t, it.Err())
+
+	assert.Equal(t, []TagKind{TagKindComid, TagKindCots, TagKindCoswid, TagKindUnknown}, kinds)
+	assert.Equal(t, []string{"43bbe37f-2e61-4b33-aed3-53cff1428b16", "ab0f44b1-bfdc-4604-ab4a-30f80407ebcc", "sw-tag"}, ids)
+
+	tags, err := uc.DecodeTags()
+	require.NoError(t, err)
+	require.Len(t, tags, 4)
+
+	require.NotNil(t, tags[0].Comid)
+	assert.Nil(t, tags[0].Cots)
+	require.NotNil(t, tags[1].Cots)
+	require.NotNil(t, tags[2].Coswid)
+	assert.Equal(t, "Acme Firmware", tags[2].Coswid.SoftwareName)
+	assert.Nil(t, tags[3].Comid)
+	assert.Nil(t, tags[3].Coswid)
+	assert.Nil(t, tags[3].Cots)
+
+	id, ok := tags[2].TagIdentity()
+	assert.True(t, ok)
+	assert.Equal(t, "sw-tag", id.TagID.String())
+
+	_, ok = tags[3].TagIdentity()
+	assert.False(t, ok)
+}
+
+func TestUnsignedCorim_IterTags_decode_error(t *testing.T) {
+	uc := newMixedTagsCorim(t)
+	uc.Tags = append([]Tag{append(append(Tag{}, ComidTag...), 0xff)}, uc.Tags...)
+
+	it := uc.IterTags()
+	assert.False(t, it.Next())
+	assert.Nil(t, it.Tag())
+	assert.ErrorContains(t, it.Err(), "tag at index 0: decoding comid")
+	assert.False(t, it.Next())
+
+	_, err := uc.DecodeTags()
+	assert.ErrorContains(t, err, "tag at index 0")
+
+	_, err = uc.RemoveTag(*swid.NewTagID("sw-tag"))
+	assert.ErrorContains(t, err, "tag at index 0")
+}
+
+func TestUnsignedCorim_IterTags_profile(t *testing.T) {
+	type entityExtensions struct {
+		Address *string `cbor:"-1,keyasint,omitempty" json:"address,omitempty"`
+	}
+
+	profID, err := eat.NewProfile("http://example.com/test-profile")
+	require.NoError(t, err)
+
+	require.NoError(t, RegisterProfile(profID,
+		extensions.NewMap().Add(comid.ExtEntity, &entityExtensions{})))
+	defer UnregisterProfile(profID)
+
+	uc, err := UnmarshalUnsignedCorimFromCBOR(testUnsignedCorimWithExtensionsCBOR)
+	require.NoError(t, err)
+
+	tags, err := uc.DecodeTags()
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+
+	address := tags[0].Comid.Entities.Values[0].Extensions.MustGetString("Address")
+	assert.Equal(t, "123 Fake Street", address)
+}
+
+func TestUnsignedCorim_RemoveTag(t *testing.T) {
+	uc := newMixedTagsCorim(t)
+
+	n, err := uc.RemoveTag(*swid.NewTagID("ab0f44b1-bfdc-4604-ab4a-30f80407ebcc"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	require.Len(t, uc.Tags, 3)
+	assert.Equal(t, TagKindComid, uc.Tags[0].Kind())
+	assert.Equal(t, TagKindCoswid, uc.Tags[1].Kind())
+
+	n, err = uc.RemoveTag(*swid.NewTagID("missing"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Len(t, uc.Tags, 3)
+}
+
+func TestUnsignedCorim_ReplaceComid(t *testing.T) {
+	uc := newMixedTagsCorim(t)
+
+	// duplicate the CoMID at the end of the tags array
+	uc.Tags = append(uc.Tags, uc.Tags[0])
+
+	tags, err := uc.DecodeTags()
+	require.NoError(t, err)
+
+	c := tags[0].Comid
+	c.TagIdentity.TagVersion = 7
+
+	require.NoError(t, uc.ReplaceComid(*c))
+
+	require.Len(t, uc.Tags, 4)
+
+	tags, err = uc.DecodeTags()
+	require.NoError(t, err)
+	require.NotNil(t, tags[0].Comid)
+	assert.Equal(t, uint(7), tags[0].Comid.TagIdentity.TagVersion)
+	assert.Equal(t, TagKindUnknown, tags[3].Kind)
+
+	err = uc.ReplaceTag(*swid.NewTagID("missing"), uc.Tags[0])
+	assert.ErrorIs(t, err, ErrTagNotFound)
+	assert.EqualError(t, err, `tag not found: "missing"`)
+
+	assert.EqualError(t, uc.ReplaceTag(*swid.NewTagID("sw-tag"), nil), "empty tag")
+
+	assert.EqualError(t, uc.ReplaceComid(comid.Comid{}),
+		"comid validation failed: tag-identity validation failed: empty tag-id")
+}
+
+func TestUnsignedCorim_ReplaceComid_text_and_UUID_tag_ids(t *testing.T) {
+	uc := newMixedTagsCorim(t)
+	want := len(uc.Tags)
+
+	tags, err := uc.DecodeTags()
+	require.NoError(t, err)
+
+	// a CoMID whose text tag-id prints like the UUID tag-id of the CoMID in
+	// the CoRIM is a different tag, hence it is not replaced
+	orig := tags[0].Comid
+
+	textID, err := swid.NewTagIDFromString(orig.TagIdentity.TagID.String())
+	require.NoError(t, err)
+	require.NotEqual(t, orig.TagIdentity.TagID, *textID)
+
+	c := *orig
+	c.TagIdentity = comid.TagIdentity{TagID: *textID, TagVersion: 7}
+
+	err = uc.ReplaceComid(c)
+	assert.ErrorIs(t, err, ErrTagNotFound)
+
+	n, err := uc.RemoveTag(*textID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Len(t, uc.Tags, want)
+}
+
+func TestTagKind_String(t *testing.T) {
+	assert.Equal(t, "comid", TagKindComid.String())
+	assert.Equal(t, "coswid", TagKindCoswid.String())
+	assert.Equal(t, "cots", TagKindCots.String())
+	assert.Equal(t, "unknown", TagKind(42).String())
+}
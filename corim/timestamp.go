@@ -0,0 +1,308 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+	"github.com/digitorus/timestamp"
+	cose "github.com/veraison/go-cose"
+)
+
+// HeaderLabelTimestampToken is the COSE unprotected header parameter carrying
+// an RFC 3161 TimeStampToken computed over the signature of the COSE Sign1
+// envelope (the "3161-ctt" mode of draft-ietf-cose-tsa-tst-header-parameter).
+var HeaderLabelTimestampToken = int64(270)
+
+// ITSAClient is implemented by clients of an RFC 3161 Time-Stamp Authority.
+// The client is handed a DER-encoded TimeStampReq and returns the
+// DER-encoded TimeStampResp obtained from the TSA.
+type ITSAClient interface {
+	Timestamp(req []byte) ([]byte, error)
+}
+
+// HTTPTSAClient is an ITSAClient that talks to a TSA using the HTTP transport
+// described in RFC 3161, Section 3.4
+type HTTPTSAClient struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewHTTPTSAClient instantiates an HTTPTSAClient for the TSA at the supplied
+// URL, using the default HTTP client
+func NewHTTPTSAClient(url string) *HTTPTSAClient {
+	return &HTTPTSAClient{URL: url, Client: http.DefaultClient}
+}
+
+// Timestamp posts the supplied TimeStampReq to the TSA and returns its
+// response
+func (o HTTPTSAClient) Timestamp(req []byte) ([]byte, error) {
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Post(o.URL, "application/timestamp-query", bytes.NewReader(req))
+	if err != nil {
+		return nil, fmt.Errorf("sending timestamp request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TSA returned HTTP status %s", res.Status)
+	}
+
+	if ct := res.Header.Get("Content-Type"); ct != "application/timestamp-reply" {
+		return nil, fmt.Errorf("unexpected TSA response content type %q", ct)
+	}
+
+	return io.ReadAll(res.Body)
+}
+
+// LocalTSAPolicy is the TSA policy used by LocalTSA unless otherwise
+// specified. It is taken from the 2.999 arc, which is reserved for examples.
+var LocalTSAPolicy = asn1.ObjectIdentifier{2, 999, 3161}
+
+// LocalTSA is an in-process stand-in for a Time-Stamp Authority that signs
+// timestamp tokens with the supplied certificate and key. It is meant for
+// testing code that uses TSA clients, not for production use.
+type LocalTSA struct {
+	Certificate *x509.Certificate
+	Key         crypto.Signer
+	Policy      asn1.ObjectIdentifier
+	// Clock, if set, supplies the time at which tokens are issued. It
+	// defaults to time.Now.
+	Clock func() time.Time
+}
+
+// NewLocalTSA instantiates a LocalTSA using the supplied TSA certificate and
+// the corresponding private key
+func NewLocalTSA(cert *x509.Certificate, key crypto.Signer) (*LocalTSA, error) {
+	if cert == nil {
+		return nil, errors.New("nil TSA certificate")
+	}
+
+	if key == nil {
+		return nil, errors.New("nil TSA key")
+	}
+
+	return &LocalTSA{Certificate: cert, Key: key, Policy: LocalTSAPolicy}, nil
+}
+
+// Timestamp issues a TimeStampResp for the supplied TimeStampReq
+func (o LocalTSA) Timestamp(req []byte) ([]byte, error) {
+	r, err := timestamp.ParseRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("parsing timestamp request: %w", err)
+	}
+
+	now := time.Now
+	if o.Clock != nil {
+		now = o.Clock
+	}
+
+	policy := o.Policy
+	if policy == nil {
+		policy = LocalTSAPolicy
+	}
+
+	ts := timestamp.Timestamp{
+		HashAlgorithm:     r.HashAlgorithm,
+		HashedMessage:     r.HashedMessage,
+		Time:              now().UTC(),
+		Policy:            policy,
+		Nonce:             r.Nonce,
+		AddTSACertificate: r.Certificates,
+	}
+
+	return ts.CreateResponseWithOpts(o.Certificate, o.Key, crypto.SHA256)
+}
+
+// AddTimestamp requests an RFC 3161 timestamp token over the signature of the
+// target SignedCorim from the supplied TSA, and stores it in the unprotected
+// header. The target must have been either signed (using Sign) or decoded
+// (using FromCOSE). On success, the serialized signed-corim including the
+// timestamp token is returned.
+func (o *SignedCorim) AddTimestamp(tsa ITSAClient) ([]byte, error) {
+	if tsa == nil {
+		return nil, errors.New("nil TSA client")
+	}
+
+	if o.message == nil || len(o.message.Signature) == 0 {
+		return nil, errors.New("no signed Sign1 message found")
+	}
+
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("generating timestamp nonce: %w", err)
+	}
+
+	req, err := timestamp.CreateRequest(
+		bytes.NewReader(o.message.Signature),
+		&timestamp.RequestOptions{
+			Hash:         crypto.SHA256,
+			Certificates: true,
+			Nonce:        nonce,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating timestamp request: %w", err)
+	}
+
+	res, err := tsa.Timestamp(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting timestamp: %w", err)
+	}
+
+	ts, err := timestamp.ParseResponse(res)
+	if err != nil {
+		return nil, fmt.Errorf("parsing timestamp response: %w", err)
+	}
+
+	if ts.Nonce == nil || ts.Nonce.Cmp(nonce) != 0 {
+		return nil, errors.New("timestamp response nonce does not match request")
+	}
+
+	if err := checkMessageImprint(ts, o.message.Signature); err != nil {
+		return nil, err
+	}
+
+	if o.message.Headers.Unprotected == nil {
+		o.message.Headers.Unprotected = cose.UnprotectedHeader{}
+	}
+
+	o.message.Headers.Unprotected[HeaderLabelTimestampToken] = ts.RawToken
+
+	wrap, err := o.message.MarshalCBOR()
+	if err != nil {
+		return nil, fmt.Errorf("signed-corim marshaling failed: %w", err)
+	}
+
+	return wrap, nil
+}
+
+// TimestampToken returns the DER-encoded RFC 3161 timestamp token found in the
+// unprotected header of the target SignedCorim, if any
+func (o SignedCorim) TimestampToken() ([]byte, bool) {
+	if o.message == nil {
+		return nil, false
+	}
+
+	v, ok := o.message.Headers.Unprotected[HeaderLabelTimestampToken]
+	if !ok {
+		return nil, false
+	}
+
+	token, ok := v.([]byte)
+
+	return token, ok
+}
+
+// VerifyTimestamp checks the RFC 3161 timestamp token in the unprotected
+// header of the target SignedCorim. The token must cover the COSE signature
+// and be issued by a TSA whose certificate chains back to one of the supplied
+// trust anchors, and was valid for time-stamping at the time the token was
+// issued. On success, the time asserted by the TSA is returned.
+func (o SignedCorim) VerifyTimestamp(tsaRoots *x509.CertPool) (time.Time, error) {
+	if tsaRoots == nil {
+		return time.Time{}, errors.New("no TSA trust anchors supplied")
+	}
+
+	token, ok := o.TimestampToken()
+	if !ok {
+		return time.Time{}, errors.New("no timestamp token found")
+	}
+
+	ts, err := timestamp.Parse(token)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing timestamp token: %w", err)
+	}
+
+	if err := checkMessageImprint(ts, o.message.Signature); err != nil {
+		return time.Time{}, err
+	}
+
+	p7, err := pkcs7.Parse(token)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing timestamp token: %w", err)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range p7.Certificates {
+		intermediates.AddCert(cert)
+	}
+
+	err = p7.VerifyWithOpts(x509.VerifyOptions{
+		Roots:         tsaRoots,
+		Intermediates: intermediates,
+		CurrentTime:   ts.Time,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("verifying timestamp token: %w", err)
+	}
+
+	return ts.Time, nil
+}
+
+// VerifyWithTimestamp verifies the signature of the target SignedCorim using
+// the public key in the supplied signer certificate, then checks that the
+// timestamp token (see VerifyTimestamp) proves that the signature was created
+// while the signer certificate was valid. This allows verifying a CoRIM long
+// after its signer certificate has expired. Validating the signer certificate
+// chain is left to the caller. On success, the time asserted by the TSA is
+// returned.
+func (o SignedCorim) VerifyWithTimestamp(
+	signerCert *x509.Certificate,
+	tsaRoots *x509.CertPool,
+) (time.Time, error) {
+	if signerCert == nil {
+		return time.Time{}, errors.New("nil signer certificate")
+	}
+
+	if err := o.Verify(signerCert.PublicKey); err != nil {
+		return time.Time{}, err
+	}
+
+	t, err := o.VerifyTimestamp(tsaRoots)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if t.Before(signerCert.NotBefore) || t.After(signerCert.NotAfter) {
+		return time.Time{}, fmt.Errorf(
+			"timestamp %s is outside the signer certificate validity period (%s - %s)",
+			t.Format(time.RFC3339), signerCert.NotBefore.Format(time.RFC3339),
+			signerCert.NotAfter.Format(time.RFC3339),
+		)
+	}
+
+	return t, nil
+}
+
+func checkMessageImprint(ts *timestamp.Timestamp, signature []byte) error {
+	if !ts.HashAlgorithm.Available() {
+		return fmt.Errorf("unsupported timestamp hash algorithm %s", ts.HashAlgorithm)
+	}
+
+	h := ts.HashAlgorithm.New()
+	h.Write(signature) // nolint:errcheck
+
+	if !bytes.Equal(h.Sum(nil), ts.HashedMessage) {
+		return errors.New("timestamp token does not cover the COSE signature")
+	}
+
+	return nil
+}
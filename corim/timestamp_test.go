@@ -0,0 +1,287 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cose "github.com/veraison/go-cose"
+)
+
+var (
+	testSignerNotBefore = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	testSignerNotAfter  = time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	testTimestampTime   = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+)
+
+type testPKI struct {
+	root    *x509.Certificate
+	tsaCert *x509.Certificate
+	tsaKey  crypto.Signer
+}
+
+func newTestCert(
+	t *testing.T,
+	tmpl *x509.Certificate,
+	parent *x509.Certificate,
+	parentKey crypto.Signer,
+) (*x509.Certificate, crypto.Signer) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert, key
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	root, rootKey := newTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test TSA Root"},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+
+	tsaCert, tsaKey := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test TSA"},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}, root, rootKey)
+
+	return testPKI{root: root, tsaCert: tsaCert, tsaKey: tsaKey}
+}
+
+func (o testPKI) roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(o.root)
+	return pool
+}
+
+func (o testPKI) tsa(t *testing.T, at time.Time) *LocalTSA {
+	tsa, err := NewLocalTSA(o.tsaCert, o.tsaKey)
+	require.NoError(t, err)
+
+	tsa.Clock = func() time.Time { return at }
+
+	return tsa
+}
+
+func newTestSignerCert(t *testing.T) (*x509.Certificate, cose.Signer) {
+	cert, key := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "ACME Ltd signing key"},
+		NotBefore:    testSignerNotBefore,
+		NotAfter:     testSignerNotAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, nil, nil)
+
+	signer, err := NewSignerFromCryptoSigner(key)
+	require.NoError(t, err)
+
+	return cert, signer
+}
+
+func signTestCorim(t *testing.T, signer cose.Signer) *SignedCorim {
+	var sc SignedCorim
+
+	sc.UnsignedCorim = *unsignedCorimFromCBOR(t, testGoodUnsignedCorimCBOR)
+	sc.Meta = *metaGood(t)
+
+	_, err := sc.Sign(signer)
+	require.NoError(t, err)
+
+	return &sc
+}
+
+func TestSignedCorim_AddTimestamp_VerifyWithTimestamp_ok(t *testing.T) {
+	pki := newTestPKI(t)
+	cert, signer := newTestSignerCert(t)
+
+	sc := signTestCorim(t, signer)
+
+	cbor, err := sc.AddTimestamp(pki.tsa(t, testTimestampTime))
+	require.NoError(t, err)
+
+	var actual SignedCorim
+
+	err = actual.FromCOSE(cbor)
+	require.NoError(t, err)
+
+	token, ok := actual.TimestampToken()
+	assert.True(t, ok)
+	assert.NotEmpty(t, token)
+
+	// the signer certificate has long expired, but the timestamp proves that
+	// the signature was created while it was still valid
+	ts, err := actual.VerifyWithTimestamp(cert, pki.roots())
+	require.NoError(t, err)
+	assert.True(t, testTimestampTime.Equal(ts))
+}
+
+func TestSignedCorim_VerifyWithTimestamp_outside_validity(t *testing.T) {
+	pki := newTestPKI(t)
+	cert, signer := newTestSignerCert(t)
+
+	sc := signTestCorim(t, signer)
+
+	cbor, err := sc.AddTimestamp(pki.tsa(t, testSignerNotAfter.Add(time.Hour)))
+	require.NoError(t, err)
+
+	var actual SignedCorim
+
+	err = actual.FromCOSE(cbor)
+	require.NoError(t, err)
+
+	_, err = actual.VerifyWithTimestamp(cert, pki.roots())
+	assert.EqualError(t, err,
+		"timestamp 2024-06-30T01:00:00Z is outside the signer certificate validity period (2024-01-01T00:00:00Z - 2024-06-30T00:00:00Z)")
+}
+
+func TestSignedCorim_VerifyTimestamp_untrusted_tsa(t *testing.T) {
+	pki := newTestPKI(t)
+	other := newTestPKI(t)
+	_, signer := newTestSignerCert(t)
+
+	sc := signTestCorim(t, signer)
+
+	_, err := sc.AddTimestamp(pki.tsa(t, testTimestampTime))
+	require.NoError(t, err)
+
+	_, err = sc.VerifyTimestamp(other.roots())
+	assert.ErrorContains(t, err, "verifying timestamp token")
+
+	_, err = sc.VerifyTimestamp(nil)
+	assert.EqualError(t, err, "no TSA trust anchors supplied")
+}
+
+func TestSignedCorim_VerifyTimestamp_signature_mismatch(t *testing.T) {
+	pki := newTestPKI(t)
+	_, signer := newTestSignerCert(t)
+
+	sc := signTestCorim(t, signer)
+
+	_, err := sc.AddTimestamp(pki.tsa(t, testTimestampTime))
+	require.NoError(t, err)
+
+	token, _ := sc.TimestampToken()
+
+	// re-sign: the signature changes, so the old token no longer covers it
+	_, err = sc.Sign(signer)
+	require.NoError(t, err)
+
+	sc.message.Headers.Unprotected[HeaderLabelTimestampToken] = token
+
+	_, err = sc.VerifyTimestamp(pki.roots())
+	assert.EqualError(t, err, "timestamp token does not cover the COSE signature")
+}
+
+func TestSignedCorim_VerifyTimestamp_no_token(t *testing.T) {
+	pki := newTestPKI(t)
+	_, signer := newTestSignerCert(t)
+
+	sc := signTestCorim(t, signer)
+
+	_, err := sc.VerifyTimestamp(pki.roots())
+	assert.EqualError(t, err, "no timestamp token found")
+}
+
+func TestSignedCorim_AddTimestamp_fail(t *testing.T) {
+	pki := newTestPKI(t)
+
+	var sc SignedCorim
+
+	_, err := sc.AddTimestamp(pki.tsa(t, testTimestampTime))
+	assert.EqualError(t, err, "no signed Sign1 message found")
+
+	_, signer := newTestSignerCert(t)
+	sc = *signTestCorim(t, signer)
+
+	_, err = sc.AddTimestamp(nil)
+	assert.EqualError(t, err, "nil TSA client")
+
+	_, err = sc.AddTimestamp(failingTSA{})
+	assert.EqualError(t, err, "requesting timestamp: TSA unavailable")
+}
+
+type failingTSA struct{}
+
+func (failingTSA) Timestamp([]byte) ([]byte, error) {
+	return nil, errors.New("TSA unavailable")
+}
+
+func TestHTTPTSAClient(t *testing.T) {
+	pki := newTestPKI(t)
+	tsa := pki.tsa(t, testTimestampTime)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/timestamp-query", r.Header.Get("Content-Type"))
+
+		req, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		res, err := tsa.Timestamp(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/timestamp-reply")
+		_, _ = w.Write(res)
+	}))
+	defer srv.Close()
+
+	cert, signer := newTestSignerCert(t)
+	sc := signTestCorim(t, signer)
+
+	_, err := sc.AddTimestamp(NewHTTPTSAClient(srv.URL))
+	require.NoError(t, err)
+
+	_, err = sc.VerifyWithTimestamp(cert, pki.roots())
+	assert.NoError(t, err)
+
+	_, err = NewHTTPTSAClient(srv.URL).Timestamp([]byte("garbage"))
+	assert.ErrorContains(t, err, "TSA returned HTTP status 400")
+}
+
+func TestLocalTSA_policy(t *testing.T) {
+	pki := newTestPKI(t)
+
+	_, err := NewLocalTSA(nil, pki.tsaKey)
+	assert.EqualError(t, err, "nil TSA certificate")
+
+	_, err = NewLocalTSA(pki.tsaCert, nil)
+	assert.EqualError(t, err, "nil TSA key")
+
+	tsa := pki.tsa(t, testTimestampTime)
+	assert.Equal(t, asn1.ObjectIdentifier{2, 999, 3161}, tsa.Policy)
+}
@@ -0,0 +1,116 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"fmt"
+
+	"github.com/jraman567/corim/comid"
+)
+
+// TypeChoices is a set of type choice implementations, along with the CBOR
+// tags they are registered under, associated with a Profile (see
+// RegisterProfileWithTypeChoices). Unlike those registered with the
+// package-level Register*Type functions, they only apply to the documents of
+// that Profile, so different profiles may use the same CBOR tag for different
+// types.
+type TypeChoices struct {
+	registrations []func(*Registry) error
+}
+
+// NewTypeChoices instantiates an empty TypeChoices
+func NewTypeChoices() *TypeChoices {
+	return &TypeChoices{}
+}
+
+// IsEmpty returns true if no type choices have been added
+func (o *TypeChoices) IsEmpty() bool {
+	return o == nil || len(o.registrations) == 0
+}
+
+// AddClassIDType adds a comid.IClassIDValue implementation (see
+// comid.RegisterClassIDType)
+func (o *TypeChoices) AddClassIDType(tag uint64, factory comid.IClassIDFactory) *TypeChoices {
+	return o.add(func(r *Registry) error {
+		return r.ComidRegistry().RegisterClassIDType(tag, factory)
+	})
+}
+
+// AddInstanceType adds a comid.IInstanceValue implementation (see
+// comid.RegisterInstanceType)
+func (o *TypeChoices) AddInstanceType(tag uint64, factory comid.IInstanceFactory) *TypeChoices {
+	return o.add(func(r *Registry) error {
+		return r.ComidRegistry().RegisterInstanceType(tag, factory)
+	})
+}
+
+// AddGroupType adds a comid.IGroupValue implementation (see
+// comid.RegisterGroupType)
+func (o *TypeChoices) AddGroupType(tag uint64, factory comid.IGroupFactory) *TypeChoices {
+	return o.add(func(r *Registry) error {
+		return r.ComidRegistry().RegisterGroupType(tag, factory)
+	})
+}
+
+// AddMkeyType adds a comid.IMKeyValue implementation (see
+// comid.RegisterMkeyType)
+func (o *TypeChoices) AddMkeyType(tag uint64, factory comid.IMkeyFactory) *TypeChoices {
+	return o.add(func(r *Registry) error {
+		return r.ComidRegistry().RegisterMkeyType(tag, factory)
+	})
+}
+
+// AddCryptoKeyType adds a comid.ICryptoKeyValue implementation (see
+// comid.RegisterCryptoKeyType)
+func (o *TypeChoices) AddCryptoKeyType(tag uint64, factory comid.ICryptoKeyFactory) *TypeChoices {
+	return o.add(func(r *Registry) error {
+		return r.ComidRegistry().RegisterCryptoKeyType(tag, factory)
+	})
+}
+
+// AddSVNType adds a comid.ISVNValue implementation (see
+// comid.RegisterSVNType)
+func (o *TypeChoices) AddSVNType(tag uint64, factory comid.ISVNFactory) *TypeChoices {
+	return o.add(func(r *Registry) error {
+		return r.ComidRegistry().RegisterSVNType(tag, factory)
+	})
+}
+
+// AddComidEntityNameType adds a comid.IEntityNameValue implementation, used
+// by CoMID entities (see comid.RegisterEntityNameType)
+func (o *TypeChoices) AddComidEntityNameType(tag uint64, factory comid.IEntityNameFactory) *TypeChoices {
+	return o.add(func(r *Registry) error {
+		return r.ComidRegistry().RegisterEntityNameType(tag, factory)
+	})
+}
+
+// AddEntityNameType adds an IEntityNameValue implementation, used by CoRIM
+// entities (see RegisterEntityNameType)
+func (o *TypeChoices) AddEntityNameType(tag uint64, factory IEntityNameFactory) *TypeChoices {
+	return o.add(func(r *Registry) error {
+		return r.RegisterEntityNameType(tag, factory)
+	})
+}
+
+func (o *TypeChoices) add(registration func(*Registry) error) *TypeChoices {
+	if o != nil {
+		o.registrations = append(o.registrations, registration)
+	}
+	return o
+}
+
+// apply registers the type choices with the supplied Registry
+func (o *TypeChoices) apply(r *Registry) error {
+	if o == nil {
+		return nil
+	}
+
+	for i, registration := range o.registrations {
+		if err := registration(r); err != nil {
+			return fmt.Errorf("type choice at index %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
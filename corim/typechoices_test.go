@@ -0,0 +1,165 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package corim
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/extensions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/eat"
+)
+
+// the partner profiles below both use this private tag for their class IDs
+const testPartnerClassIDTag = 65000
+
+type partnerAClassID string
+
+func newPartnerAClassID(val any) (*comid.ClassID, error) {
+	var ret partnerAClassID
+
+	switch t := val.(type) {
+	case nil:
+	case string:
+		ret = partnerAClassID(t)
+	default:
+		return nil, errors.New("unexpected type")
+	}
+
+	return &comid.ClassID{Value: &ret}, nil
+}
+
+func (o partnerAClassID) Bytes() []byte  { return []byte(o) }
+func (o partnerAClassID) Type() string   { return "partner-a.class-id" }
+func (o partnerAClassID) String() string { return string(o) }
+func (o partnerAClassID) Valid() error   { return nil }
+
+type partnerBClassID uint64
+
+func newPartnerBClassID(val any) (*comid.ClassID, error) {
+	var ret partnerBClassID
+
+	switch t := val.(type) {
+	case nil:
+	case uint64:
+		ret = partnerBClassID(t)
+	default:
+		return nil, errors.New("unexpected type")
+	}
+
+	return &comid.ClassID{Value: &ret}, nil
+}
+
+func (o partnerBClassID) Bytes() []byte  { return []byte(o.String()) }
+func (o partnerBClassID) Type() string   { return "partner-b.class-id" }
+func (o partnerBClassID) String() string { return strconv.FormatUint(uint64(o), 10) }
+func (o partnerBClassID) Valid() error   { return nil }
+
+func testPartnerComid(t *testing.T, r *Registry, val any, typ string) []byte {
+	classID, err := r.ComidRegistry().NewClassID(val, typ)
+	require.NoError(t, err)
+
+	c := comid.NewComid().
+		SetTagIdentity("partner", 0).
+		AddReferenceValue(comid.ValueTriple{
+			Environment: comid.Environment{Class: &comid.Class{ClassID: classID}},
+			Measurement: *comid.MustNewUUIDMeasurement(comid.TestUUID).SetRawValueBytes([]byte{0x01}, nil),
+		})
+	require.NotNil(t, c)
+
+	data, err := c.ToCBOR(WithEncodeRegistry(r))
+	require.NoError(t, err)
+
+	return data
+}
+
+func TestProfile_type_choices(t *testing.T) {
+	profA, err := eat.NewProfile("http://example.com/partner-a")
+	require.NoError(t, err)
+
+	profB, err := eat.NewProfile("http://example.com/partner-b")
+	require.NoError(t, err)
+
+	r := NewRegistry()
+
+	require.NoError(t, r.RegisterProfileWithTypeChoices(profA, extensions.NewMap(),
+		NewTypeChoices().AddClassIDType(testPartnerClassIDTag, newPartnerAClassID)))
+
+	require.NoError(t, r.RegisterProfileWithTypeChoices(profB, extensions.NewMap(),
+		NewTypeChoices().AddClassIDType(testPartnerClassIDTag, newPartnerBClassID)))
+
+	profileA, ok := r.GetProfile(profA)
+	require.True(t, ok)
+
+	profileB, ok := r.GetProfile(profB)
+	require.True(t, ok)
+
+	dataA := testPartnerComid(t, profileA.Registry(), "widget", "partner-a.class-id")
+	dataB := testPartnerComid(t, profileB.Registry(), uint64(7), "partner-b.class-id")
+
+	c, err := UnmarshalComidFromCBOR(dataA, profA, WithRegistry(r))
+	require.NoError(t, err)
+	classID := c.Triples.ReferenceValues.Values[0].Environment.Class.ClassID
+	assert.Equal(t, "partner-a.class-id", classID.Type())
+	assert.Equal(t, "widget", classID.String())
+
+	c, err = UnmarshalComidFromCBOR(dataB, profB, WithRegistry(r))
+	require.NoError(t, err)
+	classID = c.Triples.ReferenceValues.Values[0].Environment.Class.ClassID
+	assert.Equal(t, "partner-b.class-id", classID.Type())
+	assert.Equal(t, "7", classID.String())
+
+	// the same tag has a different meaning in the other profile...
+	_, err = UnmarshalComidFromCBOR(dataA, profB, WithRegistry(r))
+	assert.Error(t, err)
+
+	// ...and none at all outside of the profiles
+	_, err = UnmarshalComidFromCBOR(dataA, nil, WithRegistry(r))
+	assert.Error(t, err)
+
+	_, err = r.ComidRegistry().NewClassID("widget", "partner-a.class-id")
+	assert.EqualError(t, err, "unknown class id type: partner-a.class-id")
+
+	// the profiles are not visible in the default registry
+	_, err = UnmarshalComidFromCBOR(dataA, profA)
+	assert.Error(t, err)
+}
+
+func TestProfile_type_choices_registration(t *testing.T) {
+	profID, err := eat.NewProfile("http://example.com/partner-a")
+	require.NoError(t, err)
+
+	r := NewRegistry()
+
+	err = r.RegisterProfileWithTypeChoices(profID, extensions.NewMap(),
+		NewTypeChoices().AddClassIDType(600, newPartnerAClassID))
+	assert.EqualError(t, err, `profile "http://example.com/partner-a": type choice at index 0: tag 600 is already registered`)
+
+	_, ok := r.GetProfile(profID)
+	assert.False(t, ok)
+
+	require.NoError(t, r.RegisterProfile(profID, extensions.NewMap()))
+
+	profile, ok := r.GetProfile(profID)
+	require.True(t, ok)
+	assert.Same(t, r, profile.Registry())
+	assert.True(t, profile.TypeChoices.IsEmpty())
+
+	clone := r.Clone()
+	profile, ok = clone.GetProfile(profID)
+	require.True(t, ok)
+	assert.Same(t, clone, profile.Registry())
+
+	assert.Nil(t, (&Profile{}).Registry())
+
+	require.NoError(t, RegisterProfileWithTypeChoices(profID, extensions.NewMap(),
+		NewTypeChoices().AddClassIDType(testPartnerClassIDTag, newPartnerAClassID)))
+	defer UnregisterProfile(profID)
+
+	_, err = comid.NewClassID("widget", "partner-a.class-id")
+	assert.EqualError(t, err, "unknown class id type: partner-a.class-id")
+}
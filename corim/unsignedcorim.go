@@ -4,7 +4,6 @@
 package corim
 
 import (
-	"errors"
 	"fmt"
 	"time"
 
@@ -13,6 +12,7 @@ import (
 	"github.com/jraman567/corim/extensions"
 
 	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/validation"
 	"github.com/veraison/eat"
 	"github.com/veraison/swid"
 )
@@ -30,12 +30,17 @@ type UnsignedCorim struct {
 	// marshaling. Hence omitempty is present for the json tag, but not
 	// cbor.
 	Tags          []Tag        `cbor:"1,keyasint" json:"tags,omitempty"`
-	DependentRims *[]Locator   `cbor:"2,keyasint,omitempty" json:"dependent-rims,omitempty"`
+	DependentRims *Locators    `cbor:"2,keyasint,omitempty" json:"dependent-rims,omitempty"`
 	Profile       *eat.Profile `cbor:"3,keyasint,omitempty" json:"profile,omitempty"`
 	RimValidity   *Validity    `cbor:"4,keyasint,omitempty" json:"validity,omitempty"`
 	Entities      *Entities    `cbor:"5,keyasint,omitempty" json:"entities,omitempty"`
 
 	Extensions
+
+	// registry is the Registry selected when the UnsignedCorim was
+	// decoded (nil for DefaultRegistry). It is used to decode and validate
+	// the tags, and to validate the entities.
+	registry *Registry
 }
 
 // NewUnsignedCorim instantiates an empty UnsignedCorim
@@ -43,7 +48,9 @@ func NewUnsignedCorim() *UnsignedCorim {
 	return &UnsignedCorim{}
 }
 
-// RegisterExtensions registers a struct as a collections of extensions
+// RegisterExtensions registers a struct as a collections of extensions. The
+// extensions of the RIM validity are registered with a new Validity, if the
+// target UnsignedCorim does not have one.
 func (o *UnsignedCorim) RegisterExtensions(exts extensions.Map) error {
 	for p, v := range exts {
 		switch p {
@@ -58,6 +65,21 @@ func (o *UnsignedCorim) RegisterExtensions(exts extensions.Map) error {
 			if err := o.Entities.RegisterExtensions(entMap); err != nil {
 				return err
 			}
+		case ExtLocator:
+			if o.DependentRims == nil {
+				o.DependentRims = NewLocators()
+			}
+
+			locMap := extensions.NewMap().Add(ExtLocator, v)
+			if err := o.DependentRims.RegisterExtensions(locMap); err != nil {
+				return err
+			}
+		case ExtValidity:
+			if o.RimValidity == nil {
+				o.RimValidity = NewValidity()
+			}
+
+			o.RimValidity.Extensions.Register(v)
 		default:
 			return fmt.Errorf("%w: %q", extensions.ErrUnexpectedPoint, p)
 		}
@@ -161,11 +183,10 @@ func (o *UnsignedCorim) AddDependentRim(href string, thumbprint *swid.HashEntry)
 		}
 
 		if o.DependentRims == nil {
-			o.DependentRims = new([]Locator)
+			o.DependentRims = NewLocators()
 		}
 
-		*o.DependentRims = append(*o.DependentRims, l)
-
+		o.DependentRims.Add(&l)
 	}
 	return o
 }
@@ -190,8 +211,13 @@ func (o *UnsignedCorim) SetProfile(urlOrOID string) *UnsignedCorim {
 // timestamp.
 func (o *UnsignedCorim) SetRimValidity(notAfter time.Time, notBefore *time.Time) *UnsignedCorim {
 	if o != nil {
-		v := NewValidity().Set(notAfter, notBefore)
-		if v == nil {
+		v := NewValidity()
+		if o.RimValidity != nil {
+			// retain the registered extensions, if any
+			v.Extensions = o.RimValidity.Extensions
+		}
+
+		if v.Set(notAfter, notBefore) == nil {
 			return nil
 		}
 
@@ -229,46 +255,57 @@ func (o *UnsignedCorim) AddEntity(name string, regID *string, roles ...Role) *Un
 	return o
 }
 
-// Valid checks the validity (according to the spec) of the target unsigned CoRIM
+// Valid checks the validity (according to the spec) of the target unsigned
+// CoRIM. The roles of the entities must be registered with the Registry the
+// CoRIM was decoded with (DefaultRegistry, unless WithRegistry was used).
 func (o UnsignedCorim) Valid() error {
+	return o.valid(o.getRegistry())
+}
+
+// valid is like Valid, but the roles of the entities must be registered with
+// reg
+func (o UnsignedCorim) valid(reg *Registry) error {
 	if o.ID == (swid.TagID{}) {
-		return fmt.Errorf("empty id")
+		return validation.NewAt("corim-id", validation.CodeEmpty, "empty id")
 	}
 
 	if len(o.Tags) == 0 {
-		return errors.New("tags validation failed: no tags")
+		return validation.Errorf("tags", "tags validation failed: %w", validation.New(validation.CodeEmpty, "no tags"))
 	}
 
 	for i, t := range o.Tags {
 		if err := t.Valid(); err != nil {
-			return fmt.Errorf("tag validation failed at pos %d: %w", i, err)
+			return validation.Errorf(validation.JoinPath("tags", validation.Index(i)),
+				"tag validation failed at pos %d: %w", i, err)
 		}
 	}
 
 	if o.DependentRims != nil {
-		for i, r := range *o.DependentRims {
+		for i, r := range o.DependentRims.Values {
 			if err := r.Valid(); err != nil {
-				return fmt.Errorf("dependent RIM validation failed at pos %d: %w", i, err)
+				return validation.Errorf(validation.JoinPath("dependent-rims", validation.Index(i)),
+					"dependent RIM validation failed at pos %d: %w", i, err)
 			}
 		}
 	}
 
 	if o.Profile != nil {
 		if err := ValidProfile(*o.Profile); err != nil {
-			return fmt.Errorf("profile validation failed: %w", err)
+			return validation.Errorf("profile", "profile validation failed: %w", err)
 		}
 	}
 
-	if o.RimValidity != nil {
+	if o.RimValidity.isSet() {
 		if err := o.RimValidity.Valid(); err != nil {
-			return fmt.Errorf("RIM validity validation failed: %w", err)
+			return validation.Errorf("validity", "RIM validity validation failed: %w", err)
 		}
 	}
 
 	if o.Entities != nil {
 		for i, e := range o.Entities.Values {
-			if err := e.Valid(); err != nil {
-				return fmt.Errorf("entity validation failed at pos %d: %w", i, err)
+			if err := e.valid(reg); err != nil {
+				return validation.Errorf(validation.JoinPath("entities", validation.Index(i)),
+					"entity validation failed at pos %d: %w", i, err)
 			}
 		}
 	}
@@ -276,8 +313,11 @@ func (o UnsignedCorim) Valid() error {
 	return o.Extensions.validCorim(&o)
 }
 
-// ToCBOR serializes the target unsigned CoRIM to CBOR
-func (o UnsignedCorim) ToCBOR() ([]byte, error) {
+// ToCBOR serializes the target unsigned CoRIM to CBOR. Use
+// encoding.WithDeterministic to obtain core deterministic encoding, and
+// WithEncodeRegistry to encode with a Registry other than DefaultRegistry.
+// Note that the content of the tags is left as it is.
+func (o UnsignedCorim) ToCBOR(opts ...encoding.EncodeOption) ([]byte, error) {
 	// If extensions have been registered, the collection will exist, but
 	// might be empty. If that is the case, set it to nil to avoid
 	// marshaling an empty list (and let the marshaller omit the claim
@@ -288,12 +328,39 @@ func (o UnsignedCorim) ToCBOR() ([]byte, error) {
 		o.Entities = nil
 	}
 
-	return encoding.SerializeStructToCBOR(em, o)
+	if o.DependentRims != nil && o.DependentRims.IsEmpty() {
+		o.DependentRims = nil
+	}
+
+	o.dropEmptyValidity()
+
+	e := encoding.NewEncoder(opts...)
+
+	return e.SerializeStructToCBOR(encModeFor(e), o)
 }
 
-// FromCBOR deserializes a CBOR-encoded unsigned CoRIM into the target UnsignedCorim
-func (o *UnsignedCorim) FromCBOR(data []byte) error {
-	return encoding.PopulateStructFromCBOR(dm, data, o)
+// FromCBOR deserializes a CBOR-encoded unsigned CoRIM into the target
+// UnsignedCorim. The input is first checked against the decoding limits
+// (encoding.DefaultLimits unless encoding.WithLimits is used). Use
+// encoding.WithStrict to reject anything that does not conform exactly to the
+// schema, and WithRegistry to decode with a Registry other than
+// DefaultRegistry.
+func (o *UnsignedCorim) FromCBOR(data []byte, opts ...encoding.DecodeOption) error {
+	if err := encoding.CheckLimitsCBOR(data, opts...); err != nil {
+		return err
+	}
+
+	o.setRegistry(opts)
+
+	dm := o.getRegistry().decMode(encoding.NewDecodeOptions(opts...))
+
+	if err := encoding.PopulateStructFromCBOR(dm, data, o, opts...); err != nil {
+		return err
+	}
+
+	o.dropEmptyValidity()
+
+	return nil
 }
 
 // ToJSON serializes the target unsigned CoRIM to JSON
@@ -308,44 +375,68 @@ func (o UnsignedCorim) ToJSON() ([]byte, error) {
 		o.Entities = nil
 	}
 
-	return encoding.SerializeStructToJSON(o)
-}
+	if o.DependentRims != nil && o.DependentRims.IsEmpty() {
+		o.DependentRims = nil
+	}
+
+	o.dropEmptyValidity()
 
-// FromJSON deserializes a JSON-encoded unsigned CoRIM into the target UnsignedCorim
-func (o *UnsignedCorim) FromJSON(data []byte) error {
-	return encoding.PopulateStructFromJSON(data, o)
+	return encoding.SerializeStructToJSON(o)
 }
 
-// Tag is either a CBOR-encoded CoMID, CoSWID or CoTS
-type Tag []byte
+// FromJSON deserializes a JSON-encoded unsigned CoRIM into the target
+// UnsignedCorim. Use encoding.WithStrict to reject anything that does not
+// conform exactly to the schema, and WithRegistry to decode with a Registry
+// other than DefaultRegistry.
+func (o *UnsignedCorim) FromJSON(data []byte, opts ...encoding.DecodeOption) error {
+	o.setRegistry(opts)
 
-func (o Tag) Valid() error {
-	// there is no much we can check here, except making sure that the tag is
-	// not zero-length
-	if len(o) == 0 {
-		return errors.New("empty tag")
+	if err := encoding.PopulateStructFromJSON(data, o, opts...); err != nil {
+		return err
 	}
+
+	o.dropEmptyValidity()
+
 	return nil
 }
 
-// Locator is the internal representation of the corim-locator-map with CBOR and
-// JSON serialization.
-type Locator struct {
-	Href       comid.TaggedURI `cbor:"0,keyasint" json:"href"`
-	Thumbprint *swid.HashEntry `cbor:"1,keyasint,omitempty" json:"thumbprint,omitempty"`
+// setRegistry records the Registry selected by opts, if any
+func (o *UnsignedCorim) setRegistry(opts []encoding.DecodeOption) {
+	o.registry, _ = encoding.NewDecodeOptions(opts...).Registry.(*Registry)
+}
+
+// getRegistry returns the Registry the target UnsignedCorim was decoded with
+func (o UnsignedCorim) getRegistry() *Registry {
+	return registryFrom(o.registry)
 }
 
-func (o Locator) Valid() error {
-	if o.Href.Empty() {
-		return errors.New("empty href")
+// decodeOptions returns the options selecting the Registry the target
+// UnsignedCorim was decoded with, to be used for decoding its tags
+func (o UnsignedCorim) decodeOptions() []encoding.DecodeOption {
+	if o.registry == nil {
+		return nil
 	}
 
-	if tp := o.Thumbprint; tp != nil {
-		if err := swid.ValidHashEntry(tp.HashAlgID, tp.HashValue); err != nil {
-			return fmt.Errorf("invalid locator thumbprint: %w", err)
-		}
+	return []encoding.DecodeOption{WithRegistry(o.registry)}
+}
+
+// dropEmptyValidity unsets the RIM validity if it was only created to hold the
+// Validity extensions (see RegisterExtensions)
+func (o *UnsignedCorim) dropEmptyValidity() {
+	if !o.RimValidity.isSet() {
+		o.RimValidity = nil
 	}
+}
+
+// Tag is either a CBOR-encoded CoMID, CoSWID or CoTS
+type Tag []byte
 
+func (o Tag) Valid() error {
+	// there is no much we can check here, except making sure that the tag is
+	// not zero-length
+	if len(o) == 0 {
+		return validation.New(validation.CodeEmpty, "empty tag")
+	}
 	return nil
 }
 
@@ -353,7 +444,7 @@ func (o Locator) Valid() error {
 // formats (i.e., URI or OID)
 func ValidProfile(p eat.Profile) error {
 	if !p.IsOID() && !p.IsURI() {
-		return errors.New("profile should be OID or URI")
+		return validation.New(validation.CodeInvalid, "profile should be OID or URI")
 	}
 	return nil
 }
@@ -4,6 +4,7 @@
 package corim
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -13,6 +14,7 @@ import (
 	"github.com/jraman567/corim/comid"
 	"github.com/jraman567/corim/cots"
 	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 	"github.com/veraison/swid"
 )
 
@@ -170,6 +172,34 @@ func TestUnsignedCorim_Valid_invalid_tags(t *testing.T) {
 	assert.EqualError(t, err, expectedError)
 }
 
+func TestUnsignedCorim_Valid_path(t *testing.T) {
+	tv := NewUnsignedCorim().SetID("invalid.tags.corim")
+	require.NotNil(t, tv)
+
+	tv.Tags = append(tv.Tags, []byte{0xd9}, []byte{})
+
+	var vErr *validation.Error
+
+	err := tv.Valid()
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "tags[1]", vErr.Path)
+	assert.Equal(t, validation.CodeEmpty, vErr.Code)
+	assert.Equal(t, "empty tag", vErr.Message)
+
+	tv.Tags = tv.Tags[:1]
+	tv.Entities = NewEntities()
+	tv.Entities.Values = append(tv.Entities.Values, Entity{
+		Name:  MustNewStringEntityName("ACME Ltd."),
+		Roles: Roles{RoleManifestCreator, Role(666)},
+	})
+
+	err = tv.Valid()
+	assert.EqualError(t, err, "entity validation failed at pos 0: invalid entity: unknown role 666 at index 1")
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "entities[0].roles[1]", vErr.Path)
+	assert.Equal(t, validation.CodeUnknown, vErr.Code)
+}
+
 func TestUnsignedCorim_Valid_ok(t *testing.T) {
 	// minimalist CoMID
 	c := comid.NewComid().
@@ -391,13 +421,19 @@ func TestUnsignedCorim_ToCBOR(t *testing.T) {
 func TestUnsignedCorim_extensions(t *testing.T) {
 	c := NewUnsignedCorim()
 	corimExts := struct{}{}
+	locatorExts := struct{}{}
+	validityExts := struct{}{}
 	extMap := extensions.NewMap().
 		Add(ExtUnsignedCorim, &corimExts).
-		Add(ExtEntity, &struct{}{})
+		Add(ExtEntity, &struct{}{}).
+		Add(ExtLocator, &locatorExts).
+		Add(ExtValidity, &validityExts)
 
 	err := c.RegisterExtensions(extMap)
 	assert.NoError(t, err)
 	assert.Equal(t, &corimExts, c.GetExtensions())
+	assert.NotNil(t, c.DependentRims)
+	assert.Equal(t, &validityExts, c.RimValidity.GetExtensions())
 
 	badMap := extensions.NewMap().Add(extensions.Point("test"), &struct{}{})
 	err = c.RegisterExtensions(badMap)
@@ -415,3 +451,52 @@ func TestLocator_Valid(t *testing.T) {
 	assert.EqualError(t, l.Valid(), "invalid locator thumbprint: unknown hash algorithm 0")
 
 }
+
+type testLocatorExtensions struct {
+	Size *uint64 `cbor:"-1,keyasint,omitempty" json:"size,omitempty"`
+}
+
+func (o *testLocatorExtensions) ConstrainLocator(_ *Locator) error {
+	if o.Size == nil {
+		return errors.New("missing size")
+	}
+
+	return nil
+}
+
+func TestLocator_extensions(t *testing.T) {
+	extMap := extensions.NewMap().Add(ExtLocator, &testLocatorExtensions{})
+
+	c := NewUnsignedCorim().SetID("test")
+	require.NoError(t, c.RegisterExtensions(extMap))
+
+	// an empty collection created by the registration is not encoded
+	data, err := c.ToCBOR()
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "example.com")
+
+	c.AddDependentRim("https://example.com/rim.cbor", nil)
+	require.NotNil(t, c)
+
+	err = c.DependentRims.Values[0].Valid()
+	assert.EqualError(t, err, "missing size")
+
+	require.NoError(t, c.DependentRims.Values[0].Set("size", uint64(1024)))
+	assert.NoError(t, c.DependentRims.Values[0].Valid())
+
+	data, err = c.ToCBOR()
+	require.NoError(t, err)
+
+	out := NewUnsignedCorim()
+	require.NoError(t, out.RegisterExtensions(extMap))
+	require.NoError(t, out.FromCBOR(data))
+	require.Len(t, out.DependentRims.Values, 1)
+	assert.Equal(t, uint64(1024), out.DependentRims.Values[0].MustGetUint64("size"))
+
+	data, err = c.DependentRims.Values[0].MarshalJSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"href":"https://example.com/rim.cbor","size":1024}`, string(data))
+
+	err = c.DependentRims.Values[0].RegisterExtensions(extensions.NewMap().Add(ExtEntity, &struct{}{}))
+	assert.EqualError(t, err, `unexpected extension point: "CorimEntity"`)
+}
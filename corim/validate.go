@@ -0,0 +1,126 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"time"
+
+	"github.com/jraman567/corim/validation"
+	"github.com/veraison/swid"
+)
+
+// ValidateAll checks the target unsigned CoRIM like Valid, but rather than
+// stopping at the first problem it carries on and reports all the problems
+// found. Unlike Valid, it also decodes and checks every embedded CoMID, CoSWID
+// and CoTS, applying the extensions and constraints of the CoRIM's profile.
+// The profile is looked up in the Registry the CoRIM was decoded with.
+// Tags of unknown type, unregistered profiles, an expired validity period and
+// map entries that were not recognized when decoding are reported as warnings.
+// The CoRIM is valid if the returned report contains no errors.
+func (o UnsignedCorim) ValidateAll() *validation.Report {
+	r := validation.NewReport()
+
+	if o.ID == (swid.TagID{}) {
+		r.Add("corim-id", validation.New(validation.CodeEmpty, "empty id"))
+	}
+
+	if len(o.Tags) == 0 {
+		r.Add("tags", validation.New(validation.CodeEmpty, "no tags"))
+	}
+
+	for i, t := range o.Tags {
+		r.Merge(validation.JoinPath("tags", validation.Index(i)), o.validateTag(t))
+	}
+
+	if o.DependentRims != nil {
+		for i, l := range o.DependentRims.Values {
+			segment := validation.JoinPath("dependent-rims", validation.Index(i))
+
+			r.Add(segment, l.Valid())
+			l.ReportUnknownFields(r, segment)
+		}
+	}
+
+	if o.Profile != nil {
+		r.Add("profile", ValidProfile(*o.Profile))
+
+		if _, ok := o.getRegistry().GetProfile(o.Profile); !ok {
+			r.Warn("profile", validation.CodeUnknown,
+				"profile is not registered: its constraints have not been checked")
+		}
+	}
+
+	if o.RimValidity.isSet() {
+		r.Add("validity", o.RimValidity.Valid())
+		o.RimValidity.ReportUnknownFields(r, "validity")
+
+		if o.RimValidity.NotAfter.Before(time.Now()) {
+			r.Warn("validity.not-after", validation.CodeInvalid,
+				"validity period expired on %s", o.RimValidity.NotAfter.Format(time.RFC3339))
+		}
+	}
+
+	if o.Entities != nil {
+		for i, e := range o.Entities.Values {
+			segment := validation.JoinPath("entities", validation.Index(i))
+
+			r.Add(segment, e.valid(o.getRegistry()))
+			e.ReportUnknownFields(r, segment)
+		}
+	}
+
+	r.Add("", o.Extensions.validCorim(&o))
+	o.ReportUnknownFields(r, "")
+
+	return r
+}
+
+// validateTag decodes the supplied tag with the CoRIM's profile and reports
+// all the problems found in its contents
+func (o UnsignedCorim) validateTag(t Tag) *validation.Report {
+	r := validation.NewReport()
+
+	if err := t.Valid(); err != nil {
+		r.Add("", err)
+		return r
+	}
+
+	tt, err := DecodeTag(t, o.Profile, o.decodeOptions()...)
+	if err != nil {
+		r.Add("", err)
+		return r
+	}
+
+	switch tt.Kind {
+	case TagKindComid:
+		r.Merge("", tt.Comid.ValidateAll())
+	case TagKindCoswid:
+		r.Merge("", validateCoswid(tt.Coswid))
+	case TagKindCots:
+		r.Merge("", tt.Cots.ValidateAll())
+	default:
+		r.Warn("", validation.CodeUnknown, "unknown tag type: its contents have not been checked")
+	}
+
+	return r
+}
+
+// validateCoswid checks the fields the CoSWID specification makes mandatory
+func validateCoswid(s *swid.SoftwareIdentity) *validation.Report {
+	r := validation.NewReport()
+
+	if s.TagID == (swid.TagID{}) {
+		r.Add("tag-id", validation.New(validation.CodeEmpty, "empty tag-id"))
+	}
+
+	if s.SoftwareName == "" {
+		r.Add("software-name", validation.New(validation.CodeEmpty, "empty software-name"))
+	}
+
+	if len(s.Entities) == 0 {
+		r.Add("entity", validation.New(validation.CodeEmpty, "no entities present, must have at least 1 entity"))
+	}
+
+	return r
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package corim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/cots"
+	"github.com/jraman567/corim/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/swid"
+)
+
+func TestUnsignedCorim_ValidateAll(t *testing.T) {
+	c := comid.NewComid().
+		SetTagIdentity("vendor.example/prod/1", 0).
+		AddReferenceValue(comid.ValueTriple{
+			Environment: comid.Environment{
+				Instance: comid.MustNewUUIDInstance(comid.TestUUID),
+			},
+			Measurement: comid.Measurement{Val: comid.Mval{
+				Digests: &comid.Digests{{HashAlgID: swid.Sha256, HashValue: []byte{0x01}}},
+			}},
+		})
+	require.NotNil(t, c)
+
+	comidCBOR, err := cbor.Marshal(c) // ToCBOR would refuse an invalid CoMID
+	require.NoError(t, err)
+
+	var e swid.Entity
+	require.NoError(t, e.SetEntityName("ACME Ltd."))
+	require.NoError(t, e.SetRoles(swid.RoleTagCreator))
+
+	s := swid.SoftwareIdentity{TagID: *swid.NewTagID("coswid")}
+	require.NoError(t, s.AddEntity(e))
+
+	coswidCBOR, err := cbor.Marshal(s)
+	require.NoError(t, err)
+
+	tv := NewUnsignedCorim().
+		SetID("invalid.corim").
+		SetRimValidity(time.Now().Add(-time.Hour), nil)
+	require.NotNil(t, tv)
+
+	tv.Tags = append(tv.Tags,
+		append(append(Tag{}, ComidTag...), comidCBOR...),
+		append(append(Tag{}, CoswidTag...), coswidCBOR...),
+		append(append(Tag{}, cots.CotsTag...), 0xa0),
+		Tag{0xd9, 0x01, 0xf8, 0xa0},
+	)
+	tv.Entities = NewEntities()
+	tv.Entities.Values = append(tv.Entities.Values, Entity{
+		Name:  MustNewStringEntityName("ACME Ltd."),
+		Roles: Roles{Role(666)},
+	})
+
+	// raw tags are not decoded by Valid
+	err = tv.Valid()
+	assert.EqualError(t, err, "entity validation failed at pos 0: invalid entity: unknown role 666 at index 0")
+
+	r := tv.ValidateAll()
+
+	var paths []string
+	for _, e := range r.Errors {
+		paths = append(paths, e.Path)
+	}
+
+	assert.Equal(t, []string{
+		"tags[0].triples.reference-values[0].measurement.value.digests[0].hash-value",
+		"tags[1].software-name",
+		"tags[2].environments",
+		"tags[2].keys",
+		"entities[0].roles[0]",
+	}, paths)
+
+	require.Len(t, r.Warnings, 2)
+	assert.Equal(t, "tags[3]", r.Warnings[0].Path)
+	assert.Equal(t, validation.CodeUnknown, r.Warnings[0].Code)
+	assert.Equal(t, "validity.not-after", r.Warnings[1].Path)
+}
+
+func TestUnsignedCorim_ValidateAll_ok(t *testing.T) {
+	tv := NewUnsignedCorim().
+		SetID("valid.corim").
+		AddComid(*comid.NewComid().
+			SetTagIdentity("vendor.example/prod/1", 0).
+			AddAttestVerifKey(comid.KeyTriple{
+				Environment: comid.Environment{
+					Instance: comid.MustNewUUIDInstance(comid.TestUUID),
+				},
+				VerifKeys: *comid.NewCryptoKeys().
+					Add(comid.MustNewPKIXBase64Key(comid.TestECPubKey)),
+			}))
+	require.NotNil(t, tv)
+
+	r := tv.ValidateAll()
+	assert.NoError(t, r.Err())
+	assert.Empty(t, r.Warnings)
+}
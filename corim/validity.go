@@ -6,23 +6,49 @@ package corim
 import (
 	"fmt"
 	"time"
+
+	"github.com/jraman567/corim/encoding"
+	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 )
 
 type Validity struct {
 	NotBefore *time.Time `cbor:"0,keyasint,omitempty" json:"not-before,omitempty"`
 	NotAfter  time.Time  `cbor:"1,keyasint" json:"not-after"`
+
+	Extensions
 }
 
 func NewValidity() *Validity {
 	return &Validity{}
 }
 
+// RegisterExtensions registers a struct as a collections of extensions
+func (o *Validity) RegisterExtensions(exts extensions.Map) error {
+	for p, v := range exts {
+		switch p {
+		case ExtValidity:
+			o.Extensions.Register(v)
+		default:
+			return fmt.Errorf("%w: %q", extensions.ErrUnexpectedPoint, p)
+		}
+	}
+
+	return nil
+}
+
+// GetExtensions returns previously registered extension
+func (o *Validity) GetExtensions() extensions.IMapValue {
+	return o.Extensions.IMapValue
+}
+
 // Set instantiates a Validity object (using the supplied time inputs) & checks it been valid
 func (o *Validity) Set(notAfter time.Time, notBefore *time.Time) *Validity {
 	if o != nil {
 		v := Validity{
-			NotBefore: notBefore,
-			NotAfter:  notAfter,
+			NotBefore:  notBefore,
+			NotAfter:   notAfter,
+			Extensions: o.Extensions,
 		}
 
 		if v.Valid() != nil {
@@ -34,12 +60,70 @@ func (o *Validity) Set(notAfter time.Time, notBefore *time.Time) *Validity {
 	return o
 }
 
+// IsEmpty returns true if none of the fields of the target Validity, including
+// its extensions, are set
+func (o Validity) IsEmpty() bool {
+	if o.NotBefore != nil || !o.NotAfter.IsZero() {
+		return false
+	}
+
+	return o.Extensions.IsEmpty()
+}
+
+// isSet returns true if o is a Validity that has been set, as opposed to an
+// empty one created to hold the Validity extensions (see
+// UnsignedCorim.RegisterExtensions and Meta.RegisterExtensions)
+func (o *Validity) isSet() bool {
+	return o != nil && !(o.HaveExtensions() && o.IsEmpty())
+}
+
 // Valid checks for validity of fields inside the Validity object
 func (o Validity) Valid() error {
 	if o.NotBefore != nil {
 		if delta := o.NotAfter.Sub(*o.NotBefore); delta < 0 {
-			return fmt.Errorf("invalid not-before / not-after: negative delta (%d)", delta)
+			return validation.Errorf("not-after", "invalid not-before / not-after: negative delta (%d)", delta)
 		}
 	}
-	return nil
+
+	return o.Extensions.validValidity(&o)
+}
+
+// UnmarshalCBOR deserializes from CBOR
+func (o *Validity) UnmarshalCBOR(data []byte) error {
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but uses the Registry selected by d
+func (o *Validity) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromCBOR(decModeFor(d), data, o)
+}
+
+// MarshalCBOR serializes to CBOR
+func (o Validity) MarshalCBOR() ([]byte, error) {
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but uses the Registry selected by e
+func (o Validity) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return e.SerializeStructToCBOR(encModeFor(e), o)
+}
+
+// UnmarshalJSON deserializes from JSON
+func (o *Validity) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but uses the Registry selected by d
+func (o *Validity) DecodeJSON(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromJSON(data, o)
+}
+
+// MarshalJSON serializes to JSON
+func (o Validity) MarshalJSON() ([]byte, error) {
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but uses the Registry selected by e
+func (o Validity) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
+	return e.SerializeStructToJSON(o)
 }
@@ -6,8 +6,8 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"errors"
-	"fmt"
 
+	"github.com/jraman567/corim/validation"
 	"github.com/veraison/swid"
 )
 
@@ -160,7 +160,7 @@ func NewTag(tagID interface{}, softwareName, softwareVersion string) (*Abbreviat
 
 func (t AbbreviatedSwidTag) Valid() error {
 	if len(t.Entities) == 0 || t.Entities == nil {
-		return fmt.Errorf("no entities present, must have at least 1 entity")
+		return validation.NewAt("entity", validation.CodeEmpty, "no entities present, must have at least 1 entity")
 	}
 	return nil
 }
@@ -5,7 +5,8 @@ package cots
 
 import (
 	"encoding/json"
-	"fmt"
+
+	"github.com/jraman567/corim/validation"
 )
 
 type TaFormat int64
@@ -103,7 +104,7 @@ func (o *TasAndCas) AddTaCert(cert []byte) *TasAndCas {
 // Valid checks for validity of the fields within TasAndCas
 func (o TasAndCas) Valid() error {
 	if len(o.Tas) == 0 {
-		return fmt.Errorf("empty TasAndCas")
+		return validation.NewAt("tas", validation.CodeEmpty, "empty TasAndCas")
 	}
 
 	return nil
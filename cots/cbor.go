@@ -8,11 +8,16 @@ import (
 
 	cbor "github.com/fxamacker/cbor/v2"
 	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/encoding"
 )
 
 var (
-	em, emError = initCBOREncMode()
-	dm, dmError = initCBORDecMode()
+	ems, emsError = initCBOREncModes()
+	dms, dmsError = initCBORDecModes()
+
+	// em and dm are the EncMode and DecMode for the default options
+	em cbor.EncMode
+	dm cbor.DecMode
 )
 
 var (
@@ -40,27 +45,30 @@ func cotsTags() cbor.TagSet {
 	return tags
 }
 
-func initCBOREncMode() (en cbor.EncMode, err error) {
+func initCBOREncModes() (*encoding.EncModes, error) {
 	encOpt := cbor.EncOptions{
 		IndefLength: cbor.IndefLengthForbidden,
 		TimeTag:     cbor.EncTagRequired,
 	}
-	return encOpt.EncModeWithTags(cotsTags())
+	return encoding.NewEncModes(encOpt, cotsTags())
 }
 
-func initCBORDecMode() (dm cbor.DecMode, err error) {
+func initCBORDecModes() (*encoding.DecModes, error) {
 	decOpt := cbor.DecOptions{
 		IndefLength: cbor.IndefLengthForbidden,
 		TimeTag:     cbor.DecTagRequired,
 	}
-	return decOpt.DecModeWithTags(cotsTags())
+	return encoding.NewDecModes(decOpt, cotsTags())
 }
 
 func init() {
-	if emError != nil {
-		panic(emError)
+	if emsError != nil {
+		panic(emsError)
 	}
-	if dmError != nil {
-		panic(dmError)
+	if dmsError != nil {
+		panic(dmsError)
 	}
+
+	em = ems.EncMode(encoding.EncodeOptions{})
+	dm = dms.DecMode(encoding.DecodeOptions{})
 }
@@ -5,27 +5,55 @@ package cots
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 
 	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/encoding"
+	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 	"github.com/veraison/swid"
 )
 
 type ConciseTaStore struct {
-	Language     *string            `cbor:"0,keyasint,omitempty" json:"language,omitempty"`
-	TagIdentity  *comid.TagIdentity `cbor:"1,keyasint,omitempty" json:"tag-identity,omitempty"`
-	Environments EnvironmentGroups  `cbor:"2,keyasint" json:"environments"`
-	Purposes     []string           `cbor:"3,keyasint,omitempty" json:"purposes,omitempty"`
-	PermClaims   EatCWTClaims       `cbor:"4,keyasint,omitempty" json:"permclaims,omitempty"`
-	ExclClaims   EatCWTClaims       `cbor:"5,keyasint,omitempty" json:"exclclaims,omitempty"`
-	Keys         *TasAndCas         `cbor:"6,keyasint" json:"keys"`
+	Language    *string            `cbor:"0,keyasint,omitempty" json:"language,omitempty"`
+	TagIdentity *comid.TagIdentity `cbor:"1,keyasint,omitempty" json:"tag-identity,omitempty"`
+	// note: environments and keys are mandatory, but they are marked
+	// omitempty so that the custom serialization code we use to handle
+	// extensions does not refuse to decode a CoTS without them. Their
+	// presence is checked by Valid (and ValidateAll) instead, which also
+	// makes sure that they are never omitted when encoding to CBOR.
+	Environments EnvironmentGroups `cbor:"2,keyasint,omitempty" json:"environments,omitempty"`
+	Purposes     []string          `cbor:"3,keyasint,omitempty" json:"purposes,omitempty"`
+	PermClaims   EatCWTClaims      `cbor:"4,keyasint,omitempty" json:"permclaims,omitempty"`
+	ExclClaims   EatCWTClaims      `cbor:"5,keyasint,omitempty" json:"exclclaims,omitempty"`
+	Keys         *TasAndCas        `cbor:"6,keyasint,omitempty" json:"keys,omitempty"`
+
+	Extensions
 }
 
 func NewConciseTaStore() *ConciseTaStore {
 	return &ConciseTaStore{}
 }
 
+// RegisterExtensions registers a struct as a collections of extensions
+func (o *ConciseTaStore) RegisterExtensions(exts extensions.Map) error {
+	for p, v := range exts {
+		switch p {
+		case ExtConciseTaStore:
+			o.Extensions.Register(v)
+		default:
+			return fmt.Errorf("%w: %q", extensions.ErrUnexpectedPoint, p)
+		}
+	}
+
+	return nil
+}
+
+// GetExtensions returns previously registered extension
+func (o *ConciseTaStore) GetExtensions() extensions.IMapValue {
+	return o.Extensions.IMapValue
+}
+
 func (o *ConciseTaStore) SetTagIdentity(tagID interface{}, tagIDVersion *uint) *ConciseTaStore {
 	if o != nil {
 		id := swid.NewTagID(tagID)
@@ -83,42 +111,125 @@ func (o *ConciseTaStore) SetKeys(keys TasAndCas) *ConciseTaStore {
 	return o
 }
 
-// ToCBOR serializes the target ConciseTaStore to CBOR
-func (o ConciseTaStore) ToCBOR() ([]byte, error) {
+// ToCBOR serializes the target ConciseTaStore to CBOR. Use
+// encoding.WithDeterministic to obtain core deterministic encoding, and
+// comid.WithEncodeRegistry to encode the embedded CoMID types with a registry
+// other than comid.DefaultRegistry.
+func (o ConciseTaStore) ToCBOR(opts ...encoding.EncodeOption) ([]byte, error) {
 	if err := o.Valid(); err != nil {
 		return nil, err
 	}
 
-	return em.Marshal(&o)
+	e := encoding.NewEncoder(opts...)
+
+	return e.EncodeCBORValue(ems.EncMode(e.EncodeOptions), &o)
 }
 
-// FromCBOR deserializes a CBOR-encoded CoTS into the target ConciseTaStore
-func (o *ConciseTaStore) FromCBOR(data []byte) error {
-	return dm.Unmarshal(data, o)
+// FromCBOR deserializes a CBOR-encoded CoTS into the target ConciseTaStore. The
+// input is first checked against the decoding limits (encoding.DefaultLimits
+// unless encoding.WithLimits is used). With encoding.WithStrict, duplicate map
+// keys, indefinite-length items, non-preferred integer encodings and unknown
+// map entries are rejected.
+// Use comid.WithRegistry to decode the embedded CoMID types with a registry
+// other than comid.DefaultRegistry.
+func (o *ConciseTaStore) FromCBOR(data []byte, opts ...encoding.DecodeOption) error {
+	if err := encoding.CheckLimitsCBOR(data, opts...); err != nil {
+		return err
+	}
+
+	return encoding.UnmarshalCBOR(dms.DecMode(encoding.NewDecodeOptions(opts...)), data, o, opts...)
 }
 
 // Valid iterates over the range of individual entities to check for validity
 func (o ConciseTaStore) Valid() error {
 	if o.Environments == nil {
-		return fmt.Errorf("environmentGroups must be present")
+		return validation.NewAt("environments", validation.CodeMissing, "environmentGroups must be present")
 	}
 	if len(o.Environments) != 0 {
 		if err := o.Environments.Valid(); err != nil {
-			return fmt.Errorf("invalid environmentGroups: %w", err)
+			return validation.Errorf("environments", "invalid environmentGroups: %w", err)
 		}
 	}
 
 	if o.TagIdentity != nil {
 		if err := o.TagIdentity.Valid(); err != nil {
-			return fmt.Errorf("invalid TagIdentity: %w", err)
+			return validation.Errorf("tag-identity", "invalid TagIdentity: %w", err)
 		}
 	}
 
 	if o.Keys == nil || len(o.Keys.Tas) == 0 {
-		return fmt.Errorf("empty Keys")
+		return validation.NewAt("keys", validation.CodeEmpty, "empty Keys")
 	}
 
-	return nil
+	return o.Extensions.validConciseTaStore(&o)
+}
+
+// ValidateAll checks the target ConciseTaStore like Valid, but rather than
+// stopping at the first problem it carries on and reports all the problems
+// found. Each environment group is checked separately.
+func (o ConciseTaStore) ValidateAll() *validation.Report {
+	r := validation.NewReport()
+
+	if o.Environments == nil {
+		r.Add("environments", validation.New(validation.CodeMissing, "environmentGroups must be present"))
+	}
+
+	for i, e := range o.Environments {
+		r.Add(validation.JoinPath("environments", validation.Index(i)), e.Valid())
+	}
+
+	if o.TagIdentity != nil {
+		r.Add("tag-identity", o.TagIdentity.Valid())
+	}
+
+	if o.Keys == nil || len(o.Keys.Tas) == 0 {
+		r.Add("keys", validation.New(validation.CodeEmpty, "empty Keys"))
+	}
+
+	r.Add("", o.Extensions.validConciseTaStore(&o))
+	o.ReportUnknownFields(r, "")
+
+	return r
+}
+
+// UnmarshalCBOR deserializes from CBOR
+func (o *ConciseTaStore) UnmarshalCBOR(data []byte) error {
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR is like UnmarshalCBOR, but decodes the embedded CoMID types with d
+func (o *ConciseTaStore) DecodeCBOR(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromCBOR(dms.DecMode(d.DecodeOptions), data, o)
+}
+
+// MarshalCBOR serializes to CBOR
+func (o ConciseTaStore) MarshalCBOR() ([]byte, error) {
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR is like MarshalCBOR, but encodes the embedded CoMID types with e
+func (o ConciseTaStore) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return e.SerializeStructToCBOR(ems.EncMode(e.EncodeOptions), o)
+}
+
+// UnmarshalJSON deserializes from JSON
+func (o *ConciseTaStore) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON is like UnmarshalJSON, but decodes the embedded CoMID types with d
+func (o *ConciseTaStore) DecodeJSON(d *encoding.Decoder, data []byte) error {
+	return d.PopulateStructFromJSON(data, o)
+}
+
+// MarshalJSON serializes to JSON
+func (o ConciseTaStore) MarshalJSON() ([]byte, error) {
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON is like MarshalJSON, but encodes the embedded CoMID types with e
+func (o ConciseTaStore) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
+	return e.SerializeStructToJSON(o)
 }
 
 // FromJSON deserializes a JSON-encoded CoTS into the target ConciseTaStore
@@ -148,18 +259,33 @@ func (o *ConciseTaStores) AddConciseTaStores(cts ConciseTaStore) *ConciseTaStore
 	return o
 }
 
-// ToCBOR serializes the target ConciseTaStores to CBOR
-func (o ConciseTaStores) ToCBOR() ([]byte, error) {
+// ToCBOR serializes the target ConciseTaStores to CBOR. Use
+// encoding.WithDeterministic to obtain core deterministic encoding, and
+// comid.WithEncodeRegistry to encode the embedded CoMID types with a registry
+// other than comid.DefaultRegistry.
+func (o ConciseTaStores) ToCBOR(opts ...encoding.EncodeOption) ([]byte, error) {
 	if err := o.Valid(); err != nil {
 		return nil, err
 	}
 
-	return em.Marshal(&o)
+	e := encoding.NewEncoder(opts...)
+
+	return e.EncodeCBORValue(ems.EncMode(e.EncodeOptions), &o)
 }
 
-// FromCBOR deserializes a CBOR-encoded CoTS into the target ConsiseTaStores
-func (o *ConciseTaStores) FromCBOR(data []byte) error {
-	return dm.Unmarshal(data, o)
+// FromCBOR deserializes a CBOR-encoded CoTS into the target ConsiseTaStores. The
+// input is first checked against the decoding limits (encoding.DefaultLimits
+// unless encoding.WithLimits is used). With encoding.WithStrict, duplicate map
+// keys, indefinite-length items, non-preferred integer encodings and unknown
+// map entries are rejected.
+// Use comid.WithRegistry to decode the embedded CoMID types with a registry
+// other than comid.DefaultRegistry.
+func (o *ConciseTaStores) FromCBOR(data []byte, opts ...encoding.DecodeOption) error {
+	if err := encoding.CheckLimitsCBOR(data, opts...); err != nil {
+		return err
+	}
+
+	return encoding.UnmarshalCBOR(dms.DecMode(encoding.NewDecodeOptions(opts...)), data, o, opts...)
 }
 
 // FromJSON deserializes a JSON-encoded CoTS into the target ConsiseTaStores
@@ -174,12 +300,12 @@ func (o ConciseTaStores) ToJSON() ([]byte, error) {
 
 func (o ConciseTaStores) Valid() error {
 	if len(o) == 0 {
-		return errors.New("empty concise-ta-stores")
+		return validation.New(validation.CodeEmpty, "empty concise-ta-stores")
 	}
 
 	for i, c := range o {
 		if err := c.Valid(); err != nil {
-			return fmt.Errorf("bad ConciseTaStore group at index %d: %w", i, err)
+			return validation.Errorf(validation.Index(i), "bad ConciseTaStore group at index %d: %w", i, err)
 		}
 	}
 	return nil
@@ -4,10 +4,15 @@
 package cots
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/encoding"
+	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
 )
 
 func TestConciseTaStore_Valid_no_environment_groups(t *testing.T) {
@@ -25,6 +30,11 @@ func TestConciseTaStore_Valid_invalid_environment_groups(t *testing.T) {
 
 	assert.EqualError(t, cots.Valid(), "invalid environmentGroups: bad environment group at index 0: environment group validation failed: environment must not be empty")
 
+	var vErr *validation.Error
+	require.ErrorAs(t, cots.Valid(), &vErr)
+	assert.Equal(t, "environments[0].environment", vErr.Path)
+	assert.Equal(t, validation.CodeEmpty, vErr.Code)
+	assert.Equal(t, "environment must not be empty", vErr.Message)
 }
 
 func TestConciseTaStore_Valid_empty_keys(t *testing.T) {
@@ -44,6 +54,23 @@ func TestConciseTaStore_Valid_invalid_tag_identity(t *testing.T) {
 	assert.EqualError(t, cots.Valid(), "invalid TagIdentity: empty tag-id")
 }
 
+func TestConciseTaStore_ValidateAll(t *testing.T) {
+	cots := ConciseTaStore{}
+	cots.Environments = EnvironmentGroups{
+		EnvironmentGroup{Environment: &comid.Environment{}},
+		EnvironmentGroup{SwidTag: &AbbreviatedSwidTag{}},
+	}
+	cots.TagIdentity = &comid.TagIdentity{}
+
+	r := cots.ValidateAll()
+	require.Len(t, r.Errors, 4)
+	assert.Equal(t, "environments[0].environment", r.Errors[0].Path)
+	assert.Equal(t, "environments[1].swidtag.entity", r.Errors[1].Path)
+	assert.Equal(t, "tag-identity.id", r.Errors[2].Path)
+	assert.Equal(t, "keys", r.Errors[3].Path)
+	assert.Equal(t, validation.CodeEmpty, r.Errors[3].Code)
+}
+
 func TestConciseTaStores_Valid_empty_stores(t *testing.T) {
 	cotsList := ConciseTaStores{}
 	assert.EqualError(t, cotsList.Valid(), "empty concise-ta-stores")
@@ -53,3 +80,99 @@ func TestConciseTaStores_Valid_bad_cots(t *testing.T) {
 	cotsList := ConciseTaStores{*NewConciseTaStore().AddPurpose("cots")}
 	assert.EqualError(t, cotsList.Valid(), "bad ConciseTaStore group at index 0: environmentGroups must be present")
 }
+
+func TestConciseTaStore_ToCBOR_deterministic(t *testing.T) {
+	var cts ConciseTaStore
+	require.NoError(t, cts.FromJSON([]byte(ConciseTaStoreTemplateSingleOrg)))
+
+	data, err := cts.ToCBOR(encoding.WithDeterministic())
+	require.NoError(t, err)
+	assert.True(t, encoding.IsCanonical(data))
+
+	var back ConciseTaStore
+	require.NoError(t, back.FromCBOR(data))
+	assert.Equal(t, cts, back)
+
+	stores := ConciseTaStores{cts}
+
+	data, err = stores.ToCBOR(encoding.WithDeterministic())
+	require.NoError(t, err)
+	assert.True(t, encoding.IsCanonical(data))
+}
+
+func TestConciseTaStore_FromCBOR_limits(t *testing.T) {
+	var cts ConciseTaStore
+	require.NoError(t, cts.FromJSON([]byte(ConciseTaStoreTemplateSingleOrg)))
+
+	data, err := cts.ToCBOR()
+	require.NoError(t, err)
+
+	assert.NoError(t, cts.FromCBOR(data, encoding.WithStrict()))
+
+	err = cts.FromCBOR(data, encoding.WithLimits(encoding.Limits{MaxInputSize: 10}))
+	assert.ErrorIs(t, err, encoding.ErrLimitExceeded)
+
+	stores := ConciseTaStores{cts}
+
+	data, err = stores.ToCBOR()
+	require.NoError(t, err)
+
+	err = stores.FromCBOR(data, encoding.WithLimits(encoding.Limits{MaxNestingLevels: 4}))
+	assert.ErrorIs(t, err, encoding.ErrLimitExceeded)
+}
+
+type testCotsExtensions struct {
+	Owner *string `cbor:"-1,keyasint,omitempty" json:"owner,omitempty"`
+}
+
+func (o *testCotsExtensions) ConstrainConciseTaStore(_ *ConciseTaStore) error {
+	if o.Owner == nil {
+		return errors.New("missing owner")
+	}
+
+	return nil
+}
+
+func TestConciseTaStore_extensions(t *testing.T) {
+	extMap := extensions.NewMap().Add(ExtConciseTaStore, &testCotsExtensions{})
+
+	cots := NewConciseTaStore()
+	require.NoError(t, cots.RegisterExtensions(extMap))
+	cots.Environments = EnvironmentGroups{}
+	cots.Keys = NewTasAndCas().AddTaCert(ta)
+
+	err := cots.Valid()
+	assert.EqualError(t, err, "missing owner")
+
+	var vErr *validation.Error
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, validation.CodeConstraint, vErr.Code)
+
+	require.NoError(t, cots.Set("owner", "ACME"))
+	assert.NoError(t, cots.Valid())
+	assert.NoError(t, cots.ValidateAll().Err())
+
+	data, err := cots.ToCBOR()
+	require.NoError(t, err)
+
+	out := NewConciseTaStore()
+	require.NoError(t, out.RegisterExtensions(extMap))
+	require.NoError(t, out.FromCBOR(data))
+	assert.Equal(t, "ACME", out.MustGetString("owner"))
+
+	data, err = cots.ToJSON()
+	require.NoError(t, err)
+
+	out = NewConciseTaStore()
+	require.NoError(t, out.RegisterExtensions(extMap))
+	require.NoError(t, out.FromJSON(data))
+	assert.Equal(t, "ACME", out.MustGetString("owner"))
+
+	// without the extensions, the extra entry is retained as an unknown field
+	out = NewConciseTaStore()
+	require.NoError(t, out.FromJSON(data))
+	assert.True(t, out.HaveUnknownFields())
+
+	err = out.RegisterExtensions(extensions.NewMap().Add(extensions.Point("test"), &struct{}{}))
+	assert.EqualError(t, err, `unexpected extension point: "test"`)
+}
@@ -4,9 +4,10 @@ package cots
 
 import (
 	"encoding/json"
-	"fmt"
 
 	"github.com/veraison/eat"
+
+	"github.com/jraman567/corim/validation"
 )
 
 type EatCWTClaim struct {
@@ -57,7 +58,7 @@ type EatCWTClaims []EatCWTClaim
 
 func (o EatCWTClaims) Valid() error {
 	if len(o) == 0 {
-		return fmt.Errorf("empty EatCWTClaims")
+		return validation.New(validation.CodeEmpty, "empty EatCWTClaims")
 	}
 
 	return nil
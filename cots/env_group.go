@@ -5,9 +5,9 @@ package cots
 
 import (
 	"encoding/json"
-	"fmt"
 
 	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/validation"
 )
 
 // EnvironmentGroup is the top-level representation of the unsigned-corim-map with
@@ -48,13 +48,13 @@ func (o *EnvironmentGroup) SetNamedTaStore(namedtastore string) *EnvironmentGrou
 func (o EnvironmentGroup) Valid() error {
 	if o.Environment != nil {
 		if err := o.Environment.Valid(); err != nil {
-			return fmt.Errorf("environment group validation failed: %w", err)
+			return validation.Errorf("environment", "environment group validation failed: %w", err)
 		}
 	}
 
 	if o.SwidTag != nil {
 		if err := o.SwidTag.Valid(); err != nil {
-			return fmt.Errorf("abbreviated swid tag validation failed: %w", err)
+			return validation.Errorf("swidtag", "abbreviated swid tag validation failed: %w", err)
 		}
 	}
 
@@ -102,7 +102,7 @@ func (o *EnvironmentGroups) AddEnvironmentGroup(e EnvironmentGroup) *Environment
 func (o EnvironmentGroups) Valid() error {
 	for i, e := range o {
 		if err := e.Valid(); err != nil {
-			return fmt.Errorf("bad environment group at index %d: %w", i, err)
+			return validation.Errorf(validation.Index(i), "bad environment group at index %d: %w", i, err)
 		}
 	}
 	return nil
@@ -0,0 +1,35 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package cots
+
+import (
+	"github.com/jraman567/corim/extensions"
+	"github.com/jraman567/corim/validation"
+)
+
+const (
+	ExtConciseTaStore extensions.Point = "ConciseTaStore"
+)
+
+type IConciseTaStoreConstrainer interface {
+	ConstrainConciseTaStore(*ConciseTaStore) error
+}
+
+type Extensions struct {
+	extensions.Extensions
+}
+
+func (o *Extensions) validConciseTaStore(cts *ConciseTaStore) error {
+	if !o.HaveExtensions() {
+		return nil
+	}
+
+	ev, ok := o.IMapValue.(IConciseTaStoreConstrainer)
+	if ok {
+		if err := ev.ConstrainConciseTaStore(cts); err != nil {
+			return validation.New(validation.CodeConstraint, "%w", err)
+		}
+	}
+
+	return nil
+}
@@ -4,31 +4,54 @@
 package encoding
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"math"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
 	cbor "github.com/fxamacker/cbor/v2"
 )
 
-func SerializeStructToCBOR(em cbor.EncMode, source any) ([]byte, error) {
+// SerializeStructToCBOR encodes the struct source as a CBOR map, with the
+// entries in the order of the struct's fields (see WithDeterministic for core
+// deterministic encoding instead). em should be the EncMode selected by the
+// supplied options (see EncModes).
+func SerializeStructToCBOR(em cbor.EncMode, source any, opts ...EncodeOption) ([]byte, error) {
+	return NewEncoder(opts...).SerializeStructToCBOR(em, source)
+}
+
+// SerializeStructToCBOR encodes the struct source as a CBOR map, passing the
+// target Encoder on to the fields (see EncodeCBORValue). With deterministic
+// encoding, the map keys are sorted, and so are those of the retained unknown
+// entries (see IUnknownFields), as their values are emitted as they are.
+func (o *Encoder) SerializeStructToCBOR(em cbor.EncMode, source any) ([]byte, error) {
 	rawMap := newStructFieldsCBOR()
 
 	structType := reflect.TypeOf(source)
 	structVal := reflect.ValueOf(source)
 
-	if err := doSerializeStructToCBOR(em, rawMap, structType, structVal); err != nil {
+	if err := o.doSerializeStructToCBOR(em, rawMap, structType, structVal); err != nil {
 		return nil, err
 	}
 
+	if err := emitUnknownCBOR(rawMap, source, o.Deterministic); err != nil {
+		return nil, err
+	}
+
+	if o.Deterministic {
+		if err := rawMap.SortKeys(em); err != nil {
+			return nil, err
+		}
+	}
+
 	return rawMap.ToCBOR(em)
 }
 
-func doSerializeStructToCBOR(
+func (o *Encoder) doSerializeStructToCBOR(
 	em cbor.EncMode,
 	rawMap *structFieldsCBOR,
 	structType reflect.Type,
@@ -82,7 +105,7 @@ func doSerializeStructToCBOR(
 			return fmt.Errorf("non-integer cbor key: %s", keyString)
 		}
 
-		data, err := em.Marshal(valField.Interface())
+		data, err := o.encodeCBOR(em, valField)
 		if err != nil {
 			return fmt.Errorf("error marshaling field %q: %w",
 				typeField.Name,
@@ -96,15 +119,67 @@ func doSerializeStructToCBOR(
 	}
 
 	for _, emb := range embeds {
-		if err := doSerializeStructToCBOR(em, rawMap, emb.Type, emb.Value); err != nil {
+		if err := o.doSerializeStructToCBOR(em, rawMap, emb.Type, emb.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PopulateStructFromCBOR decodes the CBOR map in data into the struct pointed
+// to by dest. dm should be the DecMode selected by the supplied options (see
+// DecModes). Unless strict decoding is enabled (see WithStrict), map entries
+// that are not claimed by any field are retained (see IUnknownFields).
+func PopulateStructFromCBOR(dm cbor.DecMode, data []byte, dest any, opts ...DecodeOption) error {
+	return decodeTopLevelCBOR(dm, data, dest, opts, (*Decoder).PopulateStructFromCBOR)
+}
+
+// UnmarshalCBOR decodes the CBOR data item in data into the value pointed to
+// by v (see DecodeCBORValue), applying the supplied options like
+// PopulateStructFromCBOR does
+func UnmarshalCBOR(dm cbor.DecMode, data []byte, v any, opts ...DecodeOption) error {
+	return decodeTopLevelCBOR(dm, data, v, opts, (*Decoder).DecodeCBORValue)
+}
+
+func decodeTopLevelCBOR(
+	dm cbor.DecMode,
+	data []byte,
+	v any,
+	opts []DecodeOption,
+	decode func(*Decoder, cbor.DecMode, []byte, any) error,
+) error {
+	d := NewDecoder(opts...)
+
+	if d.Strict {
+		// struct maps are split up by this package rather than by dm,
+		// so the whole input is checked first, reporting where the
+		// problem is
+		if err := CheckStrictCBOR(data); err != nil {
+			return err
+		}
+
+		if err := dm.Wellformed(data); err != nil {
 			return err
 		}
 	}
 
+	if err := decode(d, dm, data, v); err != nil {
+		return err
+	}
+
+	if d.Strict {
+		return CheckNoUnknownCBORFields(v)
+	}
+
 	return nil
 }
 
-func PopulateStructFromCBOR(dm cbor.DecMode, data []byte, dest any) error {
+// PopulateStructFromCBOR decodes the CBOR map in data into the struct pointed
+// to by dest, passing the target Decoder on to the fields (see
+// DecodeCBORValue). Map entries that are not claimed by any field are
+// retained (see IUnknownFields).
+func (o *Decoder) PopulateStructFromCBOR(dm cbor.DecMode, data []byte, dest any) error {
 	rawMap := newStructFieldsCBOR()
 
 	if err := rawMap.FromCBOR(dm, data); err != nil {
@@ -114,10 +189,16 @@ func PopulateStructFromCBOR(dm cbor.DecMode, data []byte, dest any) error {
 	structType := reflect.TypeOf(dest)
 	structVal := reflect.ValueOf(dest)
 
-	return doPopulateStructFromCBOR(dm, rawMap, structType, structVal)
+	if err := o.doPopulateStructFromCBOR(dm, rawMap, structType, structVal); err != nil {
+		return err
+	}
+
+	retainUnknownCBOR(rawMap, dest)
+
+	return nil
 }
 
-func doPopulateStructFromCBOR(
+func (o *Decoder) doPopulateStructFromCBOR(
 	dm cbor.DecMode,
 	rawMap *structFieldsCBOR,
 	structType reflect.Type,
@@ -175,8 +256,7 @@ func doPopulateStructFromCBOR(
 				typeField.Name, keyInt)
 		}
 
-		fieldPtr := valField.Addr().Interface()
-		if err := dm.Unmarshal(rawVal, fieldPtr); err != nil {
+		if err := o.decodeCBOR(dm, rawVal, valField.Addr()); err != nil {
 			return fmt.Errorf("error unmarshalling field %q: %w",
 				typeField.Name,
 				err,
@@ -187,7 +267,7 @@ func doPopulateStructFromCBOR(
 	}
 
 	for _, emb := range embeds {
-		if err := doPopulateStructFromCBOR(dm, rawMap, emb.Type, emb.Value); err != nil {
+		if err := o.doPopulateStructFromCBOR(dm, rawMap, emb.Type, emb.Value); err != nil {
 			return err
 		}
 	}
@@ -244,30 +324,29 @@ func (o *structFieldsCBOR) Delete(key int) {
 	}
 }
 
-func (o *structFieldsCBOR) ToCBOR(em cbor.EncMode) ([]byte, error) {
-	var out []byte
-
-	header := byte(0xa0) // 0b101_00000 -- Major Type 5 ==  map
-	mapLen := len(o.Keys)
-	if mapLen == 0 {
-		return []byte{header}, nil
-	} else if mapLen < 24 {
-		header |= byte(mapLen)
-		out = append(out, header)
-	} else if mapLen <= math.MaxUint8 {
-		header |= byte(24)
-		out = append(out, header, uint8(mapLen))
-	} else if mapLen <= math.MaxUint16 {
-		header |= byte(25)
-		out = append(out, header)
-		out = binary.BigEndian.AppendUint16(out, uint16(mapLen))
-	} else {
-		header |= byte(26)
-		out = append(out, header)
-		out = binary.BigEndian.AppendUint32(out, uint32(mapLen))
+// SortKeys sorts the keys in the bytewise lexicographic order of their
+// encodings, as required by core deterministic encoding
+func (o *structFieldsCBOR) SortKeys(em cbor.EncMode) error {
+	encoded := make(map[int][]byte, len(o.Keys))
+
+	for _, key := range o.Keys {
+		marshalledKey, err := em.Marshal(key)
+		if err != nil {
+			return fmt.Errorf("problem marshaling key %d: %w", key, err)
+		}
+
+		encoded[key] = marshalledKey
 	}
-	// Since len() returns an int, the value cannot exceed MaxUint32, so
-	// the 8-byte length variant cannot occur.
+
+	sort.Slice(o.Keys, func(i, j int) bool {
+		return bytes.Compare(encoded[o.Keys[i]], encoded[o.Keys[j]]) < 0
+	})
+
+	return nil
+}
+
+func (o *structFieldsCBOR) ToCBOR(em cbor.EncMode) ([]byte, error) {
+	out := appendCBORHead(nil, cborMajorMap, len(o.Keys))
 
 	for _, key := range o.Keys {
 		marshalledKey, err := em.Marshal(key)
@@ -317,7 +396,7 @@ func (o *structFieldsCBOR) FromCBOR(dm cbor.DecMode, data []byte) error {
 		return err
 	}
 
-	if mapLen != 0 {
+	if additionalInfo != 31 {
 		o.Fields = make(map[int]cbor.RawMessage, mapLen)
 
 		for i := 0; i < mapLen; i++ {
@@ -326,7 +405,7 @@ func (o *structFieldsCBOR) FromCBOR(dm cbor.DecMode, data []byte) error {
 				return fmt.Errorf("map item %d: %w", i, err)
 			}
 		}
-	} else { // mapLen == 0 --> indefinite encoding
+	} else { // indefinite encoding
 		o.Fields = make(map[int]cbor.RawMessage)
 
 		i := 0
@@ -244,6 +244,16 @@ func Test_structFieldsCBOR_CBOR_decode_indefinite(t *testing.T) {
 	assert.Equal(t, []int{0, 1, 2, 3, 4}, sfOut.Keys)
 }
 
+func Test_structFieldsCBOR_CBOR_decode_empty(t *testing.T) {
+	dm, err := cbor.DecOptions{}.DecMode()
+	require.NoError(t, err)
+
+	sfOut := newStructFieldsCBOR()
+	err = sfOut.FromCBOR(dm, []byte{0xa0})
+	require.NoError(t, err)
+	assert.Empty(t, sfOut.Keys)
+}
+
 func Test_structFieldsCBOR_CBOR_decode_negative(t *testing.T) {
 	dm, err := cbor.DecOptions{}.DecMode()
 	require.NoError(t, err)
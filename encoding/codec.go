@@ -0,0 +1,661 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	cbor "github.com/fxamacker/cbor/v2"
+)
+
+// Decoder carries the DecodeOptions of a decoding operation down to the
+// nested types.
+//
+// The CBOR and JSON libraries invoke the UnmarshalCBOR and UnmarshalJSON
+// methods of nested types with nothing but the data. The types whose decoding
+// depends on the options (e.g. on the Registry) therefore implement
+// ICBORDecodable and IJSONDecodable, and the types containing them pass the
+// Decoder on, either explicitly or through DecodeCBORValue, DecodeJSONValue,
+// PopulateStructFromCBOR and PopulateStructFromJSON.
+type Decoder struct {
+	DecodeOptions
+}
+
+// NewDecoder returns a Decoder with the DecodeOptions resulting from applying
+// the supplied DecodeOption's to the defaults
+func NewDecoder(opts ...DecodeOption) *Decoder {
+	return &Decoder{DecodeOptions: NewDecodeOptions(opts...)}
+}
+
+// Encoder carries the EncodeOptions of an encoding operation down to the
+// nested types (see Decoder)
+type Encoder struct {
+	EncodeOptions
+}
+
+// NewEncoder returns an Encoder with the EncodeOptions resulting from applying
+// the supplied EncodeOption's to the defaults
+func NewEncoder(opts ...EncodeOption) *Encoder {
+	return &Encoder{EncodeOptions: NewEncodeOptions(opts...)}
+}
+
+// ICBORDecodable is implemented by the types whose CBOR decoding depends on
+// the options of the Decoder
+type ICBORDecodable interface {
+	DecodeCBOR(d *Decoder, data []byte) error
+}
+
+// ICBOREncodable is implemented by the types whose CBOR encoding depends on
+// the options of the Encoder
+type ICBOREncodable interface {
+	EncodeCBOR(e *Encoder) ([]byte, error)
+}
+
+// IJSONDecodable is implemented by the types whose JSON decoding depends on
+// the options of the Decoder
+type IJSONDecodable interface {
+	DecodeJSON(d *Decoder, data []byte) error
+}
+
+// IJSONEncodable is implemented by the types whose JSON encoding depends on
+// the options of the Encoder
+type IJSONEncodable interface {
+	EncodeJSON(e *Encoder) ([]byte, error)
+}
+
+var (
+	cborDecodableType = reflect.TypeOf((*ICBORDecodable)(nil)).Elem()
+	cborEncodableType = reflect.TypeOf((*ICBOREncodable)(nil)).Elem()
+	jsonDecodableType = reflect.TypeOf((*IJSONDecodable)(nil)).Elem()
+	jsonEncodableType = reflect.TypeOf((*IJSONEncodable)(nil)).Elem()
+)
+
+// DecodeCBORValue decodes the CBOR data item in data into the value pointed to
+// by v. ICBORDecodable's, including those reached through pointers and
+// slices, are decoded with the target Decoder, anything else with dm.
+func (o *Decoder) DecodeCBORValue(dm cbor.DecMode, data []byte, v any) error {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		// let the library report the problem
+		return dm.Unmarshal(data, v)
+	}
+
+	return o.decodeCBOR(dm, data, ptr)
+}
+
+func (o *Decoder) decodeCBOR(dm cbor.DecMode, data []byte, ptr reflect.Value) error {
+	if d, ok := ptr.Interface().(ICBORDecodable); ok {
+		return d.DecodeCBOR(o, data)
+	}
+
+	val := ptr.Elem()
+	if !usesCodec(val.Type(), cborDecodableType) {
+		return dm.Unmarshal(data, ptr.Interface())
+	}
+
+	switch val.Kind() {
+	case reflect.Pointer:
+		if isCBORNull(data) {
+			val.Set(reflect.Zero(val.Type()))
+			return nil
+		}
+
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+
+		return o.decodeCBOR(dm, data, val)
+	case reflect.Slice:
+		var rawVals []cbor.RawMessage
+
+		if err := dm.Unmarshal(data, &rawVals); err != nil {
+			return err
+		}
+
+		if rawVals == nil {
+			val.Set(reflect.Zero(val.Type()))
+			return nil
+		}
+
+		vals := reflect.MakeSlice(val.Type(), len(rawVals), len(rawVals))
+
+		for i, rv := range rawVals {
+			if err := o.decodeCBOR(dm, rv, vals.Index(i).Addr()); err != nil {
+				return fmt.Errorf("error at index %d: %w", i, err)
+			}
+		}
+
+		val.Set(vals)
+
+		return nil
+	case reflect.Struct:
+		shadow, ok := shadowStructOf(val.Type(), cborFormat)
+		if !ok {
+			return dm.Unmarshal(data, ptr.Interface())
+		}
+
+		raw := reflect.New(shadow.typ)
+
+		if err := dm.Unmarshal(data, raw.Interface()); err != nil {
+			return err
+		}
+
+		for i, f := range shadow.fields {
+			if f.index < 0 {
+				continue
+			}
+
+			rv := raw.Elem().Field(i).Bytes()
+			if rv == nil {
+				continue
+			}
+
+			if err := o.decodeCBOR(dm, rv, val.Field(f.index).Addr()); err != nil {
+				return fmt.Errorf("error unmarshalling field %q: %w", f.name, err)
+			}
+		}
+
+		return nil
+	default:
+		return dm.Unmarshal(data, ptr.Interface())
+	}
+}
+
+// EncodeCBORValue encodes v to CBOR. ICBOREncodable's, including those reached
+// through pointers, interfaces and slices, are encoded with the target
+// Encoder, anything else with em.
+func (o *Encoder) EncodeCBORValue(em cbor.EncMode, v any) ([]byte, error) {
+	if v == nil {
+		return em.Marshal(nil)
+	}
+
+	return o.encodeCBOR(em, reflect.ValueOf(v))
+}
+
+func (o *Encoder) encodeCBOR(em cbor.EncMode, val reflect.Value) ([]byte, error) {
+	switch val.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if val.IsNil() {
+			return em.Marshal(nil)
+		}
+	}
+
+	if e, ok := val.Interface().(ICBOREncodable); ok {
+		return e.EncodeCBOR(o)
+	}
+
+	if !usesCodec(val.Type(), cborEncodableType) {
+		return em.Marshal(val.Interface())
+	}
+
+	switch val.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return o.encodeCBOR(em, val.Elem())
+	case reflect.Slice:
+		if val.IsNil() {
+			return em.Marshal(val.Interface())
+		}
+
+		out := appendCBORHead(nil, cborMajorArray, val.Len())
+
+		for i := 0; i < val.Len(); i++ {
+			data, err := o.encodeCBOR(em, val.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("error at index %d: %w", i, err)
+			}
+
+			out = append(out, data...)
+		}
+
+		return out, nil
+	case reflect.Struct:
+		shadow, ok := shadowStructOf(val.Type(), cborFormat)
+		if !ok {
+			return em.Marshal(val.Interface())
+		}
+
+		raw := reflect.New(shadow.typ).Elem()
+
+		for i, f := range shadow.fields {
+			if f.index < 0 || (f.omitEmpty && isEmptyValue(val.Field(f.index))) {
+				continue
+			}
+
+			data, err := o.encodeCBOR(em, val.Field(f.index))
+			if err != nil {
+				return nil, fmt.Errorf("error marshaling field %q: %w", f.name, err)
+			}
+
+			raw.Field(i).SetBytes(data)
+		}
+
+		return em.Marshal(raw.Interface())
+	default:
+		return em.Marshal(val.Interface())
+	}
+}
+
+// DecodeJSONValue decodes the JSON value in data into the value pointed to by
+// v. IJSONDecodable's, including those reached through pointers and slices,
+// are decoded with the target Decoder, anything else with encoding/json.
+func (o *Decoder) DecodeJSONValue(data []byte, v any) error {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		// let the library report the problem
+		return json.Unmarshal(data, v)
+	}
+
+	return o.decodeJSON(data, ptr)
+}
+
+func (o *Decoder) decodeJSON(data []byte, ptr reflect.Value) error {
+	if d, ok := ptr.Interface().(IJSONDecodable); ok {
+		return d.DecodeJSON(o, data)
+	}
+
+	val := ptr.Elem()
+	if !usesCodec(val.Type(), jsonDecodableType) {
+		return json.Unmarshal(data, ptr.Interface())
+	}
+
+	switch val.Kind() {
+	case reflect.Pointer:
+		if isJSONNull(data) {
+			val.Set(reflect.Zero(val.Type()))
+			return nil
+		}
+
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+
+		return o.decodeJSON(data, val)
+	case reflect.Slice:
+		var rawVals []json.RawMessage
+
+		if err := json.Unmarshal(data, &rawVals); err != nil {
+			return err
+		}
+
+		if rawVals == nil {
+			val.Set(reflect.Zero(val.Type()))
+			return nil
+		}
+
+		vals := reflect.MakeSlice(val.Type(), len(rawVals), len(rawVals))
+
+		for i, rv := range rawVals {
+			if err := o.decodeJSON(rv, vals.Index(i).Addr()); err != nil {
+				return fmt.Errorf("error at index %d: %w", i, err)
+			}
+		}
+
+		val.Set(vals)
+
+		return nil
+	case reflect.Struct:
+		shadow, ok := shadowStructOf(val.Type(), jsonFormat)
+		if !ok {
+			return json.Unmarshal(data, ptr.Interface())
+		}
+
+		raw := reflect.New(shadow.typ)
+
+		if err := json.Unmarshal(data, raw.Interface()); err != nil {
+			return err
+		}
+
+		for i, f := range shadow.fields {
+			if f.index < 0 {
+				continue
+			}
+
+			rv := raw.Elem().Field(i).Bytes()
+			if rv == nil {
+				continue
+			}
+
+			if err := o.decodeJSON(rv, val.Field(f.index).Addr()); err != nil {
+				return fmt.Errorf("error unmarshalling field %q: %w", f.name, err)
+			}
+		}
+
+		return nil
+	default:
+		return json.Unmarshal(data, ptr.Interface())
+	}
+}
+
+// EncodeJSONValue encodes v to JSON. IJSONEncodable's, including those reached
+// through pointers, interfaces and slices, are encoded with the target
+// Encoder, anything else with encoding/json.
+func (o *Encoder) EncodeJSONValue(v any) ([]byte, error) {
+	if v == nil {
+		return json.Marshal(nil)
+	}
+
+	return o.encodeJSON(reflect.ValueOf(v))
+}
+
+func (o *Encoder) encodeJSON(val reflect.Value) ([]byte, error) {
+	switch val.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if val.IsNil() {
+			return json.Marshal(nil)
+		}
+	}
+
+	if e, ok := val.Interface().(IJSONEncodable); ok {
+		return e.EncodeJSON(o)
+	}
+
+	if !usesCodec(val.Type(), jsonEncodableType) {
+		return json.Marshal(val.Interface())
+	}
+
+	switch val.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return o.encodeJSON(val.Elem())
+	case reflect.Slice:
+		if val.IsNil() {
+			return json.Marshal(val.Interface())
+		}
+
+		var out bytes.Buffer
+
+		out.WriteByte('[')
+
+		for i := 0; i < val.Len(); i++ {
+			data, err := o.encodeJSON(val.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("error at index %d: %w", i, err)
+			}
+
+			if i > 0 {
+				out.WriteByte(',')
+			}
+
+			out.Write(data)
+		}
+
+		out.WriteByte(']')
+
+		return out.Bytes(), nil
+	case reflect.Struct:
+		shadow, ok := shadowStructOf(val.Type(), jsonFormat)
+		if !ok {
+			return json.Marshal(val.Interface())
+		}
+
+		raw := reflect.New(shadow.typ).Elem()
+
+		for i, f := range shadow.fields {
+			if f.index < 0 || (f.omitEmpty && isEmptyValue(val.Field(f.index))) {
+				continue
+			}
+
+			data, err := o.encodeJSON(val.Field(f.index))
+			if err != nil {
+				return nil, fmt.Errorf("error marshaling field %q: %w", f.name, err)
+			}
+
+			raw.Field(i).SetBytes(data)
+		}
+
+		return json.Marshal(raw.Interface())
+	default:
+		return json.Marshal(val.Interface())
+	}
+}
+
+var (
+	cborMarshalerType   = reflect.TypeOf((*cbor.Marshaler)(nil)).Elem()
+	cborUnmarshalerType = reflect.TypeOf((*cbor.Unmarshaler)(nil)).Elem()
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+	// libraryCodecs maps each of the interfaces of this package to the
+	// corresponding interface of the libraries
+	libraryCodecs = map[reflect.Type]reflect.Type{
+		cborDecodableType: cborUnmarshalerType,
+		cborEncodableType: cborMarshalerType,
+		jsonDecodableType: jsonUnmarshalerType,
+		jsonEncodableType: jsonMarshalerType,
+	}
+)
+
+type codecKey struct {
+	typ   reflect.Type
+	iface reflect.Type
+}
+
+var codecCache sync.Map // codecKey -> bool
+
+// usesCodec returns true if values of type t may contain values implementing
+// iface (one of ICBORDecodable, ICBOREncodable, IJSONDecodable and
+// IJSONEncodable), i.e. if they cannot be entirely left to the library
+func usesCodec(t reflect.Type, iface reflect.Type) bool {
+	key := codecKey{t, iface}
+
+	if ret, ok := codecCache.Load(key); ok {
+		return ret.(bool)
+	}
+
+	ret := checkCodec(t, iface, make(map[reflect.Type]bool))
+
+	codecCache.Store(key, ret)
+
+	return ret
+}
+
+func checkCodec(t reflect.Type, iface reflect.Type, visiting map[reflect.Type]bool) bool {
+	if visiting[t] {
+		return false
+	}
+
+	visiting[t] = true
+
+	if t.Implements(iface) || reflect.PointerTo(t).Implements(iface) {
+		return true
+	}
+
+	lib := libraryCodecs[iface]
+	if t.Implements(lib) || reflect.PointerTo(t).Implements(lib) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		// the dynamic value may be encoded with the Encoder, while
+		// decoding into an interface is left to the library
+		return iface == cborEncodableType || iface == jsonEncodableType
+	case reflect.Pointer, reflect.Slice:
+		return checkCodec(t.Elem(), iface, visiting)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.IsExported() && checkCodec(f.Type, iface, visiting) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+const (
+	cborFormat = "cbor"
+	jsonFormat = "json"
+)
+
+var (
+	cborRawMessageType = reflect.TypeOf(cbor.RawMessage{})
+	jsonRawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// shadowStruct is a struct type with the same field names and tags as the
+// struct it shadows, but raw message fields, so that the library decodes and
+// encodes the struct itself, while the fields are decoded and encoded with
+// the Decoder or Encoder
+type shadowStruct struct {
+	typ    reflect.Type
+	fields []shadowField
+}
+
+type shadowField struct {
+	// index of the field in the shadowed struct (-1 for the toarray
+	// marker)
+	index     int
+	name      string
+	omitEmpty bool
+}
+
+type shadowKey struct {
+	typ    reflect.Type
+	format string
+}
+
+var shadowCache sync.Map // shadowKey -> *shadowStruct
+
+// shadowStructOf returns the shadowStruct of the struct type t for the
+// specified format, or false if t has embedded fields, which are not
+// supported
+func shadowStructOf(t reflect.Type, format string) (*shadowStruct, bool) {
+	key := shadowKey{t, format}
+
+	if ret, ok := shadowCache.Load(key); ok {
+		ret := ret.(*shadowStruct)
+		return ret, ret != nil
+	}
+
+	ret := newShadowStruct(t, format)
+
+	shadowCache.Store(key, ret)
+
+	return ret, ret != nil
+}
+
+func newShadowStruct(t reflect.Type, format string) *shadowStruct {
+	rawType := cborRawMessageType
+	if format == jsonFormat {
+		rawType = jsonRawMessageType
+	}
+
+	var ret shadowStruct
+	var fields []reflect.StructField
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if f.Anonymous {
+			return nil
+		}
+
+		if f.Name == "_" {
+			// e.g. the toarray marker
+			fields = append(fields, f)
+			ret.fields = append(ret.fields, shadowField{index: -1})
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		tag, ok := f.Tag.Lookup(format)
+		if !ok && format == cborFormat {
+			// the CBOR library falls back on the JSON tag
+			tag = f.Tag.Get(jsonFormat)
+		}
+
+		if tag == "-" {
+			continue
+		}
+
+		fields = append(fields, reflect.StructField{
+			Name: f.Name,
+			Type: rawType,
+			Tag:  f.Tag,
+		})
+
+		ret.fields = append(ret.fields, shadowField{
+			index:     i,
+			name:      f.Name,
+			omitEmpty: hasOmitEmpty(tag),
+		})
+	}
+
+	ret.typ = reflect.StructOf(fields)
+
+	return &ret
+}
+
+func hasOmitEmpty(tag string) bool {
+	parts := strings.Split(tag, ",")
+
+	for _, option := range parts[1:] {
+		if option == omitempty {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isEmptyValue reports whether v is empty in the sense of the omitempty
+// option
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+
+	return false
+}
+
+func isCBORNull(data []byte) bool {
+	// null or undefined
+	return len(data) == 1 && (data[0] == 0xf6 || data[0] == 0xf7)
+}
+
+func isJSONNull(data []byte) bool {
+	return bytes.Equal(bytes.TrimSpace(data), []byte("null"))
+}
+
+const (
+	cborMajorArray = 4
+	cborMajorMap   = 5
+)
+
+// appendCBORHead appends to out the head of a CBOR data item of the specified
+// major type, with argument n (e.g. the length of an array)
+func appendCBORHead(out []byte, major byte, n int) []byte {
+	header := major << 5
+
+	switch {
+	case n < 24:
+		return append(out, header|byte(n))
+	case n <= 0xff:
+		return append(out, header|24, byte(n))
+	case n <= 0xffff:
+		out = append(out, header|25)
+		return binary.BigEndian.AppendUint16(out, uint16(n))
+	default:
+		// Since n is the length of a Go container, it cannot exceed
+		// MaxUint32, so the 8-byte variant cannot occur.
+		out = append(out, header|26)
+		return binary.BigEndian.AppendUint32(out, uint32(n))
+	}
+}
@@ -0,0 +1,590 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	cbor "github.com/fxamacker/cbor/v2"
+)
+
+var (
+	// ErrUnsortedKeys is reported for maps whose keys are not sorted in the
+	// bytewise lexicographic order of their encodings
+	ErrUnsortedKeys = errors.New("map keys not in bytewise lexicographic order")
+	// ErrNonPreferredFloat is reported for floating point values that could
+	// be encoded in a shorter form without loss
+	ErrNonPreferredFloat = errors.New("non-preferred floating point encoding")
+	// ErrIndefiniteLength is reported for indefinite-length CBOR arrays,
+	// maps and strings (see also CheckStrictCBOR)
+	ErrIndefiniteLength = errors.New("indefinite-length item")
+	// ErrNonPreferredEncoding is reported for CBOR integers, lengths and tag
+	// numbers that are not encoded in the shortest form (see also
+	// CheckStrictCBOR)
+	ErrNonPreferredEncoding = errors.New("non-preferred integer encoding")
+)
+
+// CheckCanonical checks that data is exactly one CBOR data item in core
+// deterministic encoding (RFC 8949, Section 4.2.1): there must be no
+// duplicate map keys or indefinite-length items, integers, lengths, tag
+// numbers and floating point values must be encoded in their shortest form,
+// and map keys must be sorted in the bytewise lexicographic order of their
+// encodings. Any problem is reported as a *StrictError.
+func CheckCanonical(data []byte) error {
+	return checkCBOR(data, true)
+}
+
+// IsCanonical returns true if data is exactly one CBOR data item in core
+// deterministic encoding (see CheckCanonical)
+func IsCanonical(data []byte) bool {
+	return CheckCanonical(data) == nil
+}
+
+// Canonicalize returns the core deterministic encoding (RFC 8949, Section
+// 4.2.1) of the CBOR data item in data: indefinite-length items are turned
+// into definite-length ones, integers, lengths, tag numbers and floating point
+// values are encoded in their shortest form, and map keys are sorted. The
+// content of byte strings (e.g. embedded CBOR) is left untouched. Maps with
+// duplicate keys cannot be canonicalized.
+func Canonicalize(data []byte) ([]byte, error) {
+	c := canonicalizer{cborReader: cborReader{data: data}}
+
+	out, err := c.item(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.off != len(data) {
+		return nil, newStrictError(nil, fmt.Errorf("%d bytes of trailing data", len(data)-c.off))
+	}
+
+	return out, nil
+}
+
+// EqualCBOR returns true if a and b encode the same CBOR data item, i.e. if
+// they are identical once both are in core deterministic encoding (see
+// Canonicalize). Data that cannot be canonicalized is only equal to itself.
+func EqualCBOR(a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+
+	ca, err := Canonicalize(a)
+	if err != nil {
+		return false
+	}
+
+	cb, err := Canonicalize(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(ca, cb)
+}
+
+type cborHead struct {
+	major      byte
+	ai         byte
+	arg        uint64
+	indefinite bool
+}
+
+// size returns the number of bytes following the initial byte that encode the
+// argument
+func (o cborHead) size() int {
+	if o.ai < 24 || o.ai > 27 {
+		return 0
+	}
+
+	return 1 << (o.ai - 24)
+}
+
+// float returns the value of a floating point item
+func (o cborHead) float() float64 {
+	switch o.ai {
+	case 25:
+		return halfToFloat64(uint16(o.arg))
+	case 26:
+		return float64(math.Float32frombits(uint32(o.arg)))
+	default:
+		return math.Float64frombits(o.arg)
+	}
+}
+
+// isShortestFloat returns true unless the head is a floating point value that
+// could be encoded in a shorter form without loss
+func (o cborHead) isShortestFloat() bool {
+	if o.major != 7 || o.ai < 25 || o.ai > 27 {
+		return true
+	}
+
+	return bytes.Equal(appendFloat(nil, o.float()), appendHead(nil, 7, o.ai, o.arg))
+}
+
+type cborReader struct {
+	data []byte
+	off  int
+}
+
+func (o *cborReader) readHead(path []string) (cborHead, error) {
+	if o.off >= len(o.data) {
+		return cborHead{}, newStrictError(path, io.ErrUnexpectedEOF)
+	}
+
+	b := o.data[o.off]
+	o.off++
+
+	h := cborHead{major: b >> 5, ai: b & 0x1f}
+
+	switch {
+	case h.ai < 24:
+		h.arg = uint64(h.ai)
+	case h.ai <= 27:
+		n := h.size()
+		if len(o.data)-o.off < n {
+			return h, newStrictError(path, io.ErrUnexpectedEOF)
+		}
+
+		for _, b := range o.data[o.off : o.off+n] {
+			h.arg = h.arg<<8 | uint64(b)
+		}
+		o.off += n
+	case h.ai == 31:
+		switch h.major {
+		case 2, 3, 4, 5, 7:
+			h.indefinite = true
+		default:
+			return h, newStrictError(path, fmt.Errorf("malformed item of major type %d", h.major))
+		}
+	default:
+		return h, newStrictError(path, fmt.Errorf("reserved additional information value %d", h.ai))
+	}
+
+	return h, nil
+}
+
+// atBreak consumes the "break" stop code terminating an indefinite-length
+// item, if it is next
+func (o *cborReader) atBreak(path []string) (bool, error) {
+	if o.off >= len(o.data) {
+		return false, newStrictError(path, io.ErrUnexpectedEOF)
+	}
+
+	if o.data[o.off] == 0xff {
+		o.off++
+		return true, nil
+	}
+
+	return false, nil
+}
+
+type canonicalizer struct {
+	cborReader
+}
+
+type cborEntry struct {
+	key []byte
+	val []byte
+}
+
+func (o *canonicalizer) item(path []string) ([]byte, error) {
+	h, err := o.readHead(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch h.major {
+	case 0, 1:
+		return appendUint(nil, h.major, h.arg), nil
+	case 2, 3:
+		content, err := o.stringContent(h, path)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(appendUint(nil, h.major, uint64(len(content))), content...), nil
+	case 4:
+		var items [][]byte
+
+		for i := uint64(0); h.indefinite || i < h.arg; i++ {
+			if h.indefinite {
+				if done, err := o.atBreak(path); err != nil {
+					return nil, err
+				} else if done {
+					break
+				}
+			}
+
+			item, err := o.item(appendPath(path, strconv.FormatUint(i, 10)))
+			if err != nil {
+				return nil, err
+			}
+
+			items = append(items, item)
+		}
+
+		out := appendUint(nil, 4, uint64(len(items)))
+		for _, item := range items {
+			out = append(out, item...)
+		}
+
+		return out, nil
+	case 5:
+		return o.mapItem(h, path)
+	case 6:
+		content, err := o.item(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(appendUint(nil, 6, h.arg), content...), nil
+	default:
+		if h.indefinite {
+			return nil, newStrictError(path, errors.New("unexpected break"))
+		}
+
+		if h.ai >= 25 && h.ai <= 27 {
+			return appendFloat(nil, h.float()), nil
+		}
+
+		return appendHead(nil, 7, h.ai, h.arg), nil
+	}
+}
+
+func (o *canonicalizer) stringContent(h cborHead, path []string) ([]byte, error) {
+	if !h.indefinite {
+		if h.arg > uint64(len(o.data)-o.off) {
+			return nil, newStrictError(path, io.ErrUnexpectedEOF)
+		}
+
+		content := o.data[o.off : o.off+int(h.arg)]
+		o.off += int(h.arg)
+
+		return content, nil
+	}
+
+	content := []byte{}
+
+	for {
+		if done, err := o.atBreak(path); err != nil {
+			return nil, err
+		} else if done {
+			return content, nil
+		}
+
+		chunk, err := o.readHead(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if chunk.major != h.major || chunk.indefinite {
+			return nil, newStrictError(path, errors.New("invalid indefinite-length string chunk"))
+		}
+
+		part, err := o.stringContent(chunk, path)
+		if err != nil {
+			return nil, err
+		}
+
+		content = append(content, part...)
+	}
+}
+
+func (o *canonicalizer) mapItem(h cborHead, path []string) ([]byte, error) {
+	var entries []cborEntry
+
+	seen := make(map[string]bool)
+
+	for i := uint64(0); h.indefinite || i < h.arg; i++ {
+		if h.indefinite {
+			if done, err := o.atBreak(path); err != nil {
+				return nil, err
+			} else if done {
+				break
+			}
+		}
+
+		key, err := o.item(path)
+		if err != nil {
+			return nil, err
+		}
+
+		keyPath := appendPath(path, cborKeySegment(key))
+
+		if seen[string(key)] {
+			return nil, newStrictError(keyPath, ErrDuplicateKey)
+		}
+		seen[string(key)] = true
+
+		val, err := o.item(keyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, cborEntry{key: key, val: val})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	out := appendUint(nil, 5, uint64(len(entries)))
+	for _, e := range entries {
+		out = append(out, e.key...)
+		out = append(out, e.val...)
+	}
+
+	return out, nil
+}
+
+// appendUint appends the shortest head encoding the supplied argument
+func appendUint(out []byte, major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return append(out, major<<5|byte(arg))
+	case arg <= math.MaxUint8:
+		return appendHead(out, major, 24, arg)
+	case arg <= math.MaxUint16:
+		return appendHead(out, major, 25, arg)
+	case arg <= math.MaxUint32:
+		return appendHead(out, major, 26, arg)
+	default:
+		return appendHead(out, major, 27, arg)
+	}
+}
+
+// appendHead appends a head with the supplied additional information value
+func appendHead(out []byte, major, ai byte, arg uint64) []byte {
+	out = append(out, major<<5|ai)
+
+	switch ai {
+	case 24:
+		return append(out, byte(arg))
+	case 25:
+		return binary.BigEndian.AppendUint16(out, uint16(arg))
+	case 26:
+		return binary.BigEndian.AppendUint32(out, uint32(arg))
+	case 27:
+		return binary.BigEndian.AppendUint64(out, arg)
+	default:
+		return out
+	}
+}
+
+// appendFloat appends the shortest encoding of f that preserves its value.
+// NaNs are encoded as the half-precision quiet NaN.
+func appendFloat(out []byte, f float64) []byte {
+	if math.IsNaN(f) {
+		return appendHead(out, 7, 25, 0x7e00)
+	}
+
+	f32 := float32(f)
+	if float64(f32) != f {
+		return appendHead(out, 7, 27, math.Float64bits(f))
+	}
+
+	if half, ok := float32ToHalf(f32); ok {
+		return appendHead(out, 7, 25, uint64(half))
+	}
+
+	return appendHead(out, 7, 26, uint64(math.Float32bits(f32)))
+}
+
+// float32ToHalf returns the IEEE 754 half-precision encoding of f, if f can
+// be represented exactly
+func float32ToHalf(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int((bits>>23)&0xff) - 127
+	mant := bits & 0x7fffff
+
+	switch {
+	case math.IsInf(float64(f), 0):
+		return sign | 0x7c00, true
+	case f == 0:
+		return sign, true
+	case exp >= -14 && exp <= 15: // normal
+		if mant&0x1fff != 0 {
+			return 0, false
+		}
+
+		return sign | uint16(exp+15)<<10 | uint16(mant>>13), true
+	case exp >= -24 && exp < -14: // subnormal, i.e. a multiple of 2^-24
+		sig := mant | 0x800000
+		shift := uint(-(exp + 1))
+
+		if sig&(1<<shift-1) != 0 {
+			return 0, false
+		}
+
+		return sign | uint16(sig>>shift), true
+	default:
+		return 0, false
+	}
+}
+
+func halfToFloat64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant != 0 {
+			return math.NaN()
+		}
+
+		return sign * math.Inf(1)
+	default:
+		return sign * math.Ldexp(mant+1024, exp-25)
+	}
+}
+
+func checkCBOR(data []byte, canonical bool) error {
+	c := cborChecker{cborReader: cborReader{data: data}, canonical: canonical}
+
+	if err := c.item(nil); err != nil {
+		return err
+	}
+
+	if c.off != len(data) {
+		return newStrictError(nil, fmt.Errorf("%d bytes of trailing data", len(data)-c.off))
+	}
+
+	return nil
+}
+
+type cborChecker struct {
+	cborReader
+	// canonical additionally requires sorted map keys and the shortest
+	// floating point encodings (see CheckCanonical)
+	canonical bool
+}
+
+// minArg is the smallest argument that requires the corresponding additional
+// information value (24 to 27) in preferred serialization
+var minArg = [4]uint64{24, math.MaxUint8 + 1, math.MaxUint16 + 1, math.MaxUint32 + 1}
+
+func (o *cborChecker) head(path []string) (cborHead, error) {
+	h, err := o.readHead(path)
+	if err != nil {
+		return h, err
+	}
+
+	if h.indefinite {
+		switch h.major {
+		case 2, 3, 4, 5:
+			return h, newStrictError(path, ErrIndefiniteLength)
+		default:
+			return h, newStrictError(path, errors.New("unexpected break"))
+		}
+	}
+
+	if h.ai < 24 || h.ai > 27 {
+		return h, nil
+	}
+
+	if h.major != 7 {
+		if h.arg < minArg[h.ai-24] {
+			return h, newStrictError(path, fmt.Errorf("%w: %d encoded in %d bytes",
+				ErrNonPreferredEncoding, h.arg, h.size()))
+		}
+
+		return h, nil
+	}
+
+	// floating point values (25 to 27) are not integers
+	if h.ai == 24 && h.arg < 32 {
+		return h, newStrictError(path, fmt.Errorf("invalid simple value %d", h.arg))
+	}
+
+	if o.canonical && !h.isShortestFloat() {
+		return h, newStrictError(path, ErrNonPreferredFloat)
+	}
+
+	return h, nil
+}
+
+func (o *cborChecker) item(path []string) error {
+	h, err := o.head(path)
+	if err != nil {
+		return err
+	}
+
+	switch h.major {
+	case 2, 3: // byte and text strings
+		if h.arg > uint64(len(o.data)-o.off) {
+			return newStrictError(path, io.ErrUnexpectedEOF)
+		}
+		o.off += int(h.arg)
+	case 4: // array
+		for i := uint64(0); i < h.arg; i++ {
+			if err := o.item(appendPath(path, strconv.FormatUint(i, 10))); err != nil {
+				return err
+			}
+		}
+	case 5: // map
+		seen := make(map[string]bool)
+
+		var prev []byte
+
+		for i := uint64(0); i < h.arg; i++ {
+			start := o.off
+			if err := o.item(path); err != nil {
+				return err
+			}
+
+			key := o.data[start:o.off]
+			keyPath := appendPath(path, cborKeySegment(key))
+
+			if seen[string(key)] {
+				return newStrictError(keyPath, ErrDuplicateKey)
+			}
+			seen[string(key)] = true
+
+			if o.canonical && prev != nil && bytes.Compare(prev, key) > 0 {
+				return newStrictError(keyPath, ErrUnsortedKeys)
+			}
+			prev = key
+
+			if err := o.item(keyPath); err != nil {
+				return err
+			}
+		}
+	case 6: // tag
+		return o.item(path)
+	}
+
+	return nil
+}
+
+// cborKeySegment renders the supplied encoded map key as a path segment
+func cborKeySegment(key []byte) string {
+	var v any
+
+	if err := cbor.Unmarshal(key, &v); err == nil {
+		switch t := v.(type) {
+		case uint64, int64, string:
+			return fmt.Sprint(t)
+		}
+	}
+
+	if diag, err := cbor.Diagnose(key); err == nil {
+		return diag
+	}
+
+	return fmt.Sprintf("h'%x'", key)
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package encoding
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Canonicalize(t *testing.T) {
+	testCases := []struct {
+		title    string
+		data     []byte
+		expected []byte
+	}{
+		{
+			title:    "unsorted map keys",
+			data:     []byte{0xa3, 0x20, 0x00, 0x0a, 0x01, 0x61, 0x61, 0x02},
+			expected: []byte{0xa3, 0x0a, 0x01, 0x20, 0x00, 0x61, 0x61, 0x02},
+		},
+		{
+			title:    "shorter key sorts first",
+			data:     []byte{0xa2, 0x18, 0x64, 0x00, 0x17, 0x01},
+			expected: []byte{0xa2, 0x17, 0x01, 0x18, 0x64, 0x00},
+		},
+		{
+			title:    "non-preferred integers",
+			data:     []byte{0x82, 0x19, 0x00, 0x05, 0xd8, 0x20, 0x3a, 0x00, 0x00, 0x01, 0x00},
+			expected: []byte{0x82, 0x05, 0xd8, 0x20, 0x39, 0x01, 0x00},
+		},
+		{
+			title:    "indefinite-length items",
+			data:     []byte{0xbf, 0x01, 0x9f, 0x7f, 0x61, 0x61, 0x61, 0x62, 0xff, 0xff, 0x00, 0x5f, 0xff, 0xff},
+			expected: []byte{0xa2, 0x00, 0x40, 0x01, 0x81, 0x62, 0x61, 0x62},
+		},
+		{
+			title:    "floats",
+			data:     []byte{0x83, 0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0, 0xfb, 0x40, 0xf8, 0x6a, 0, 0, 0, 0, 0, 0xfa, 0x7f, 0xc0, 0, 0},
+			expected: []byte{0x83, 0xf9, 0x3e, 0x00, 0xfa, 0x47, 0xc3, 0x50, 0x00, 0xf9, 0x7e, 0x00},
+		},
+		{
+			title:    "nested maps",
+			data:     []byte{0xa1, 0x01, 0xa2, 0x02, 0xf5, 0x01, 0xf4},
+			expected: []byte{0xa1, 0x01, 0xa2, 0x01, 0xf4, 0x02, 0xf5},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.False(t, IsCanonical(tc.data))
+
+			out, err := Canonicalize(tc.data)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, out)
+			assert.True(t, IsCanonical(out))
+
+			again, err := Canonicalize(out)
+			require.NoError(t, err)
+			assert.Equal(t, out, again)
+
+			var before, after any
+			require.NoError(t, cbor.Unmarshal(tc.data, &before))
+			require.NoError(t, cbor.Unmarshal(out, &after))
+			if tc.title != "floats" { // NaN != NaN
+				assert.Equal(t, before, after)
+			}
+		})
+	}
+}
+
+func Test_EqualCBOR(t *testing.T) {
+	sorted := []byte{0xa2, 0x01, 0xf4, 0x02, 0xf5}
+	unsorted := []byte{0xa2, 0x02, 0xf5, 0x01, 0xf4}
+	other := []byte{0xa2, 0x01, 0xf5, 0x02, 0xf5}
+	bad := []byte{0xa2, 0x01}
+
+	assert.True(t, EqualCBOR(sorted, sorted))
+	assert.True(t, EqualCBOR(sorted, unsorted))
+	assert.False(t, EqualCBOR(sorted, other))
+	assert.False(t, EqualCBOR(sorted, bad))
+	assert.True(t, EqualCBOR(bad, bad))
+}
+
+func Test_Canonicalize_errors(t *testing.T) {
+	_, err := Canonicalize([]byte{0xa2, 0x01, 0x00, 0x19, 0x00, 0x01, 0x00})
+	assert.EqualError(t, err, "/1: duplicate map key")
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+
+	_, err = Canonicalize([]byte{0x5f, 0x61, 0x61, 0xff})
+	assert.EqualError(t, err, "/: invalid indefinite-length string chunk")
+
+	_, err = Canonicalize([]byte{0x01, 0x02})
+	assert.EqualError(t, err, "/: 1 bytes of trailing data")
+
+	_, err = Canonicalize([]byte{0x9f, 0x01})
+	assert.EqualError(t, err, "/: unexpected EOF")
+}
+
+func Test_CheckCanonical(t *testing.T) {
+	err := CheckCanonical([]byte{0xa1, 0x04, 0xa2, 0x02, 0x00, 0x01, 0x00})
+	assert.EqualError(t, err, "/4/1: map keys not in bytewise lexicographic order")
+	assert.ErrorIs(t, err, ErrUnsortedKeys)
+
+	err = CheckCanonical([]byte{0x81, 0xfa, 0x3f, 0xc0, 0x00, 0x00})
+	assert.EqualError(t, err, "/0: non-preferred floating point encoding")
+	assert.ErrorIs(t, err, ErrNonPreferredFloat)
+
+	// floats that cannot be shortened without loss
+	assert.NoError(t, CheckCanonical([]byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}))
+	assert.NoError(t, CheckCanonical([]byte{0xfa, 0x47, 0xc3, 0x50, 0x00}))
+
+	assert.ErrorIs(t, CheckCanonical([]byte{0x18, 0x01}), ErrNonPreferredEncoding)
+	assert.ErrorIs(t, CheckCanonical([]byte{0x9f, 0xff}), ErrIndefiniteLength)
+
+	// strict checking does not look at the order of keys
+	assert.NoError(t, CheckStrictCBOR([]byte{0xa2, 0x02, 0x00, 0x01, 0x00}))
+}
+
+func Test_float32ToHalf(t *testing.T) {
+	testCases := []struct {
+		f        float32
+		expected uint16
+		ok       bool
+	}{
+		{0, 0x0000, true},
+		{float32(math.Copysign(0, -1)), 0x8000, true},
+		{1, 0x3c00, true},
+		{-2, 0xc000, true},
+		{65504, 0x7bff, true},
+		{65536, 0, false},
+		{float32(math.Ldexp(1, -24)), 0x0001, true},
+		{float32(math.Ldexp(1, -14)), 0x0400, true},
+		{float32(math.Ldexp(3, -25)), 0, false},
+		{float32(math.Inf(-1)), 0xfc00, true},
+		{0.1, 0, false},
+	}
+
+	for _, tc := range testCases {
+		half, ok := float32ToHalf(tc.f)
+		assert.Equal(t, tc.ok, ok, "%v", tc.f)
+		if tc.ok {
+			assert.Equal(t, tc.expected, half, "%v", tc.f)
+			assert.Equal(t, float64(tc.f), halfToFloat64(half), "%v", tc.f)
+		}
+	}
+}
+
+func Test_SerializeStructToCBOR_deterministic(t *testing.T) {
+	em, err := cbor.EncOptions{}.EncMode()
+	require.NoError(t, err)
+
+	v := retainingStruct{FieldOne: "a", FieldTwo: 6}
+	v.SetUnknownFields(&UnknownFields{CBOR: []UnknownCBOREntry{
+		{Key: 99, Value: cbor.RawMessage{0x61, 0x78}},
+		{Key: -1, Value: cbor.RawMessage{0xa2, 0x02, 0x00, 0x01, 0x00}},
+	}})
+
+	data, err := SerializeStructToCBOR(em, &v)
+	require.NoError(t, err)
+	assert.False(t, IsCanonical(data))
+
+	data, err = SerializeStructToCBOR(em, &v, WithDeterministic())
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0xa4,
+		0x00, 0x61, 0x61,
+		0x01, 0x06,
+		0x18, 0x63, 0x61, 0x78,
+		0x20, 0xa2, 0x01, 0x00, 0x02, 0x00,
+	}, data)
+}
+
+func Test_EncModes_deterministic(t *testing.T) {
+	ems, err := NewEncModes(cbor.EncOptions{TimeTag: cbor.EncTagRequired}, nil)
+	require.NoError(t, err)
+
+	v := []any{1.5, uint64(10)}
+
+	data, err := ems.EncMode(EncodeOptions{}).Marshal(v)
+	require.NoError(t, err)
+	assert.False(t, IsCanonical(data))
+
+	em := ems.EncMode(EncodeOptions{Deterministic: true})
+	assert.Equal(t, cbor.EncTagRequired, em.EncOptions().TimeTag)
+
+	data, err = em.Marshal(v)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x82, 0xf9, 0x3e, 0x00, 0x0a}, data)
+	assert.True(t, IsCanonical(data))
+}
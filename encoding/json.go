@@ -11,20 +11,30 @@ import (
 	"strings"
 )
 
+// SerializeStructToJSON encodes the struct source as a JSON object, with the
+// members in the order of the struct's fields
 func SerializeStructToJSON(source any) ([]byte, error) {
+	return NewEncoder().SerializeStructToJSON(source)
+}
+
+// SerializeStructToJSON encodes the struct source as a JSON object, passing
+// the target Encoder on to the fields (see EncodeJSONValue)
+func (o *Encoder) SerializeStructToJSON(source any) ([]byte, error) {
 	rawMap := newStructFieldsJSON()
 
 	structType := reflect.TypeOf(source)
 	structVal := reflect.ValueOf(source)
 
-	if err := doSerializeStructToJSON(rawMap, structType, structVal); err != nil {
+	if err := o.doSerializeStructToJSON(rawMap, structType, structVal); err != nil {
 		return nil, err
 	}
 
+	emitUnknownJSON(rawMap, source)
+
 	return rawMap.ToJSON()
 }
 
-func doSerializeStructToJSON(
+func (o *Encoder) doSerializeStructToJSON(
 	rawMap *structFieldsJSON,
 	structType reflect.Type,
 	structVal reflect.Value,
@@ -72,7 +82,7 @@ func doSerializeStructToJSON(
 			continue
 		}
 
-		data, err := json.Marshal(valField.Interface())
+		data, err := o.encodeJSON(valField)
 		if err != nil {
 			return fmt.Errorf("error marshaling field %q: %w",
 				typeField.Name,
@@ -86,15 +96,43 @@ func doSerializeStructToJSON(
 	}
 
 	for _, emb := range embeds {
-		if err := doSerializeStructToJSON(rawMap, emb.Type, emb.Value); err != nil {
+		if err := o.doSerializeStructToJSON(rawMap, emb.Type, emb.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PopulateStructFromJSON decodes the JSON object in data into the struct
+// pointed to by dest. Unless strict decoding is enabled (see WithStrict),
+// members that are not claimed by any field are retained (see
+// IUnknownFields).
+func PopulateStructFromJSON(data []byte, dest any, opts ...DecodeOption) error {
+	d := NewDecoder(opts...)
+
+	if d.Strict {
+		if err := CheckStrictJSON(data); err != nil {
 			return err
 		}
 	}
 
+	if err := d.PopulateStructFromJSON(data, dest); err != nil {
+		return err
+	}
+
+	if d.Strict {
+		return CheckNoUnknownJSONFields(dest)
+	}
+
 	return nil
 }
 
-func PopulateStructFromJSON(data []byte, dest any) error {
+// PopulateStructFromJSON decodes the JSON object in data into the struct
+// pointed to by dest, passing the target Decoder on to the fields (see
+// DecodeJSONValue). Members that are not claimed by any field are retained
+// (see IUnknownFields).
+func (o *Decoder) PopulateStructFromJSON(data []byte, dest any) error {
 	rawMap := newStructFieldsJSON()
 
 	if err := rawMap.FromJSON(data); err != nil {
@@ -104,10 +142,16 @@ func PopulateStructFromJSON(data []byte, dest any) error {
 	structType := reflect.TypeOf(dest)
 	structVal := reflect.ValueOf(dest)
 
-	return doPopulateStructFromJSON(rawMap, structType, structVal)
+	if err := o.doPopulateStructFromJSON(rawMap, structType, structVal); err != nil {
+		return err
+	}
+
+	retainUnknownJSON(rawMap, dest)
+
+	return nil
 }
 
-func doPopulateStructFromJSON(
+func (o *Decoder) doPopulateStructFromJSON(
 	rawMap *structFieldsJSON,
 	structType reflect.Type,
 	structVal reflect.Value,
@@ -159,8 +203,7 @@ func doPopulateStructFromJSON(
 				typeField.Name, key)
 		}
 
-		fieldPtr := valField.Addr().Interface()
-		if err := json.Unmarshal(rawVal, fieldPtr); err != nil {
+		if err := o.decodeJSON(rawVal, valField.Addr()); err != nil {
 			return fmt.Errorf("error unmarshalling field %q: %w",
 				typeField.Name,
 				err,
@@ -171,7 +214,7 @@ func doPopulateStructFromJSON(
 	}
 
 	for _, emb := range embeds {
-		if err := doPopulateStructFromJSON(rawMap, emb.Type, emb.Value); err != nil {
+		if err := o.doPopulateStructFromJSON(rawMap, emb.Type, emb.Value); err != nil {
 			return err
 		}
 	}
@@ -292,6 +335,12 @@ func (o *structFieldsJSON) unmarshalKeys(data []byte) error {
 			return fmt.Errorf("expected string, found %T", token)
 		}
 
+		for _, existing := range keys {
+			if existing == key {
+				return fmt.Errorf("duplicate JSON key: %q", key)
+			}
+		}
+
 		keys = append(keys, key)
 
 		if err := skipValue(decoder); err != nil {
@@ -0,0 +1,256 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package encoding
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	cbor "github.com/fxamacker/cbor/v2"
+)
+
+// ErrLimitExceeded is wrapped by the errors reporting that a decoding limit
+// has been exceeded (see LimitError)
+var ErrLimitExceeded = errors.New("decoding limit exceeded")
+
+// Names of the limits reported by LimitError
+const (
+	LimitInputSize     = "max-input-size"
+	LimitNestingLevels = "max-nesting-levels"
+	LimitArrayElements = "max-array-elements"
+	LimitMapPairs      = "max-map-pairs"
+	LimitStringLength  = "max-string-length"
+)
+
+// Limits caps the resources used when decoding untrusted CBOR input. A zero
+// value means that the corresponding DefaultLimits value applies, while a
+// negative value sets the largest value supported.
+//
+// Apart from MaxInputSize and MaxStringLength, the limits are enforced by the
+// fxamacker/cbor DecMode (see DecodeOptions.CBORDecOptions), hence they are brought within
+// the ranges it supports: 4 to 65535 nesting levels, and 16 to 2147483647
+// array elements or map pairs.
+type Limits struct {
+	// MaxInputSize is the maximum size in bytes of the whole input
+	MaxInputSize int
+	// MaxNestingLevels is the maximum depth of nested arrays, maps and tags
+	MaxNestingLevels int
+	// MaxArrayElements is the maximum number of elements in an array
+	MaxArrayElements int
+	// MaxMapPairs is the maximum number of key/value pairs in a map
+	MaxMapPairs int
+	// MaxStringLength is the maximum length in bytes of a byte or text
+	// string. The payload of a signed CoRIM is itself a byte string, hence
+	// the default allows strings as long as the whole input.
+	MaxStringLength int
+}
+
+// DefaultLimits are the limits applied unless others are supplied with
+// WithLimits
+var DefaultLimits = Limits{
+	MaxInputSize:     16 << 20,
+	MaxNestingLevels: 32,
+	MaxArrayElements: 131072,
+	MaxMapPairs:      131072,
+	MaxStringLength:  16 << 20,
+}
+
+// withDefaults returns the limits with the zero values replaced by the
+// DefaultLimits ones
+func (o Limits) withDefaults() Limits {
+	pick := func(v, def int) int {
+		if v == 0 {
+			return def
+		}
+		return v
+	}
+
+	return Limits{
+		MaxInputSize:     pick(o.MaxInputSize, DefaultLimits.MaxInputSize),
+		MaxNestingLevels: pick(o.MaxNestingLevels, DefaultLimits.MaxNestingLevels),
+		MaxArrayElements: pick(o.MaxArrayElements, DefaultLimits.MaxArrayElements),
+		MaxMapPairs:      pick(o.MaxMapPairs, DefaultLimits.MaxMapPairs),
+		MaxStringLength:  pick(o.MaxStringLength, DefaultLimits.MaxStringLength),
+	}
+}
+
+// cborDecOptions returns base with the limits, brought within the ranges
+// supported by fxamacker/cbor, applied
+func (o Limits) cborDecOptions(base cbor.DecOptions) cbor.DecOptions {
+	clamp := func(v, min, max int) int {
+		if v < 0 || v > max {
+			return max
+		}
+		if v < min {
+			return min
+		}
+		return v
+	}
+
+	limits := o.withDefaults()
+
+	base.MaxNestedLevels = clamp(limits.MaxNestingLevels, 4, 65535)
+	base.MaxArrayElements = clamp(limits.MaxArrayElements, 16, math.MaxInt32)
+	base.MaxMapPairs = clamp(limits.MaxMapPairs, 16, math.MaxInt32)
+
+	return base
+}
+
+// LimitError reports that decoding stopped because the input exceeds one of
+// the Limits
+type LimitError struct {
+	Limit string
+	Max   int
+}
+
+// Error returns the name and the value of the limit that has been exceeded
+func (o *LimitError) Error() string {
+	return fmt.Sprintf("%s exceeded: %d", o.Limit, o.Max)
+}
+
+// Unwrap returns ErrLimitExceeded
+func (o *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// newLimitError returns the *LimitError corresponding to the fxamacker/cbor
+// limit error in err, or nil if err is not one
+func newLimitError(err error, decOpts cbor.DecOptions) *LimitError {
+	var (
+		nested *cbor.MaxNestedLevelError
+		array  *cbor.MaxArrayElementsError
+		pairs  *cbor.MaxMapPairsError
+	)
+
+	switch {
+	case errors.As(err, &nested):
+		return &LimitError{Limit: LimitNestingLevels, Max: decOpts.MaxNestedLevels}
+	case errors.As(err, &array):
+		return &LimitError{Limit: LimitArrayElements, Max: decOpts.MaxArrayElements}
+	case errors.As(err, &pairs):
+		return &LimitError{Limit: LimitMapPairs, Max: decOpts.MaxMapPairs}
+	}
+
+	return nil
+}
+
+// limitsDecModes builds the DecModes used by CheckLimitsCBOR, which accept
+// any well-formed CBOR within the limits
+var limitsDecModes = func() *DecModes {
+	dms, err := NewDecModes(cbor.DecOptions{}, nil)
+	if err != nil {
+		panic(err)
+	}
+	return dms
+}()
+
+// CheckLimitsCBOR checks the CBOR data item in data against the limits set in
+// the supplied options (DefaultLimits unless WithLimits is used). The first
+// limit exceeded is reported as a *LimitError. Malformed input is left for the
+// decoder to report.
+//
+// The decode entry points (e.g. comid.Comid.FromCBOR) call this on their
+// input, so there is no need to call it separately.
+func CheckLimitsCBOR(data []byte, opts ...DecodeOption) error {
+	limits := NewDecodeOptions(opts...).Limits.withDefaults()
+
+	if limits.MaxInputSize >= 0 && len(data) > limits.MaxInputSize {
+		return &LimitError{Limit: LimitInputSize, Max: limits.MaxInputSize}
+	}
+
+	dm := limitsDecModes.DecMode(DecodeOptions{Limits: limits})
+
+	if err := dm.Wellformed(data); err != nil {
+		if limitErr := newLimitError(err, dm.DecOptions()); limitErr != nil {
+			return limitErr
+		}
+
+		return nil
+	}
+
+	if limits.MaxStringLength < 0 {
+		return nil
+	}
+
+	// fxamacker/cbor does not limit the length of strings
+	c := stringLengthChecker{cborReader: cborReader{data: data}, max: uint64(limits.MaxStringLength)}
+
+	return c.item()
+}
+
+// stringLengthChecker walks a well-formed CBOR data item, looking for byte and
+// text strings longer than max
+type stringLengthChecker struct {
+	cborReader
+	max uint64
+}
+
+func (o *stringLengthChecker) exceeded() error {
+	return &LimitError{Limit: LimitStringLength, Max: int(o.max)}
+}
+
+func (o *stringLengthChecker) item() error {
+	h, err := o.readHead(nil)
+	if err != nil {
+		return err
+	}
+
+	switch h.major {
+	case 2, 3:
+		return o.str(h)
+	case 4, 5:
+		n := h.arg
+		if h.major == 5 {
+			n *= 2
+		}
+
+		for i := uint64(0); h.indefinite || i < n; i++ {
+			if h.indefinite {
+				if done, err := o.atBreak(nil); err != nil || done {
+					return err
+				}
+			}
+
+			if err := o.item(); err != nil {
+				return err
+			}
+		}
+	case 6:
+		return o.item()
+	}
+
+	return nil
+}
+
+func (o *stringLengthChecker) str(h cborHead) error {
+	if !h.indefinite {
+		if h.arg > o.max {
+			return o.exceeded()
+		}
+
+		o.off += int(h.arg)
+
+		return nil
+	}
+
+	var total uint64
+
+	for {
+		if done, err := o.atBreak(nil); err != nil || done {
+			return err
+		}
+
+		chunk, err := o.readHead(nil)
+		if err != nil {
+			return err
+		}
+
+		total += chunk.arg
+		if total > o.max {
+			return o.exceeded()
+		}
+
+		o.off += int(chunk.arg)
+	}
+}
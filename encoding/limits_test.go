@@ -0,0 +1,156 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package encoding
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	cbor "github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nested(depth int) []byte {
+	return append(bytes.Repeat([]byte{0x81}, depth-1), 0x80)
+}
+
+func pairs(n int) []byte {
+	ret := []byte{0xb8, byte(n)}
+	for i := 0; i < n; i++ {
+		ret = append(ret, byte(i), 0x00)
+	}
+	return ret
+}
+
+func Test_CheckLimitsCBOR(t *testing.T) {
+	testCases := []struct {
+		title    string
+		data     []byte
+		limits   Limits
+		expected string
+		limit    string
+	}{
+		{
+			title: "within default limits",
+			data:  nested(32),
+		},
+		{
+			title:    "default nesting levels",
+			data:     nested(33),
+			expected: "max-nesting-levels exceeded: 32",
+			limit:    LimitNestingLevels,
+		},
+		{
+			title:    "nested tags count as nesting levels",
+			data:     []byte{0xa1, 0x01, 0xc1, 0xc1, 0x81, 0x81, 0x80},
+			limits:   Limits{MaxNestingLevels: 4},
+			expected: "max-nesting-levels exceeded: 4",
+			limit:    LimitNestingLevels,
+		},
+		{
+			title:  "largest nesting levels",
+			data:   nested(100),
+			limits: Limits{MaxNestingLevels: -1},
+		},
+		{
+			title:    "input size",
+			data:     []byte{0x43, 0x01, 0x02, 0x03},
+			limits:   Limits{MaxInputSize: 3},
+			expected: "max-input-size exceeded: 3",
+			limit:    LimitInputSize,
+		},
+		{
+			title:    "array elements",
+			data:     append([]byte{0x91}, make([]byte, 17)...),
+			limits:   Limits{MaxArrayElements: 16},
+			expected: "max-array-elements exceeded: 16",
+			limit:    LimitArrayElements,
+		},
+		{
+			title:    "indefinite-length array elements",
+			data:     append(append([]byte{0x9f}, make([]byte, 17)...), 0xff),
+			limits:   Limits{MaxArrayElements: 16},
+			expected: "max-array-elements exceeded: 16",
+			limit:    LimitArrayElements,
+		},
+		{
+			title:    "map pairs",
+			data:     pairs(17),
+			limits:   Limits{MaxMapPairs: 16},
+			expected: "max-map-pairs exceeded: 16",
+			limit:    LimitMapPairs,
+		},
+		{
+			title:    "string length",
+			data:     []byte{0x82, 0x41, 0x00, 0x64, 't', 'e', 'x', 't'},
+			limits:   Limits{MaxStringLength: 3},
+			expected: "max-string-length exceeded: 3",
+			limit:    LimitStringLength,
+		},
+		{
+			title:    "indefinite-length string chunks",
+			data:     []byte{0xa1, 0x01, 0x5f, 0x42, 0x00, 0x01, 0x42, 0x02, 0x03, 0xff},
+			limits:   Limits{MaxStringLength: 3},
+			expected: "max-string-length exceeded: 3",
+			limit:    LimitStringLength,
+		},
+		{
+			title:  "strings within the limit",
+			data:   []byte{0xa1, 0x63, 'k', 'e', 'y', 0x5f, 0x41, 0x00, 0x42, 0x01, 0x02, 0xff},
+			limits: Limits{MaxStringLength: 3},
+		},
+		{
+			title:  "no string length limit",
+			data:   append([]byte{0x58, 0x20}, make([]byte, 32)...),
+			limits: Limits{MaxStringLength: -1},
+		},
+		{
+			title:  "limits below the supported range are raised",
+			data:   pairs(16),
+			limits: Limits{MaxMapPairs: 1},
+		},
+		{
+			title: "huge declared length is left to the decoder",
+			data:  []byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		},
+		{
+			title: "malformed input is left to the decoder",
+			data:  []byte{0x83, 0x01},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			err := CheckLimitsCBOR(tc.data, WithLimits(tc.limits))
+			if tc.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.EqualError(t, err, tc.expected)
+			assert.ErrorIs(t, err, ErrLimitExceeded)
+
+			var limitErr *LimitError
+			require.True(t, errors.As(err, &limitErr))
+			assert.Equal(t, tc.limit, limitErr.Limit)
+		})
+	}
+}
+
+func Test_DecodeOptions_limits(t *testing.T) {
+	assert.Equal(t, DefaultLimits, NewDecodeOptions().Limits.withDefaults())
+
+	opts := NewDecodeOptions(WithStrict(), WithLimits(Limits{MaxMapPairs: 4}))
+	assert.True(t, opts.Strict)
+
+	limits := opts.Limits.withDefaults()
+	assert.Equal(t, 4, limits.MaxMapPairs)
+	assert.Equal(t, DefaultLimits.MaxInputSize, limits.MaxInputSize)
+
+	decOpts := opts.CBORDecOptions(cbor.DecOptions{})
+	assert.Equal(t, 16, decOpts.MaxMapPairs)
+	assert.Equal(t, DefaultLimits.MaxNestingLevels, decOpts.MaxNestedLevels)
+	assert.Equal(t, cbor.IndefLengthForbidden, decOpts.IndefLength)
+}
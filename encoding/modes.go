@@ -0,0 +1,138 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package encoding
+
+import (
+	"sync"
+
+	cbor "github.com/fxamacker/cbor/v2"
+)
+
+// DecModes builds the cbor.DecMode's selected by the DecodeOptions of a
+// Decoder (see DecodeOptions.CBORDecOptions) from a set of base options and a
+// TagSet. Each DecMode is built the first time it is needed. DecModes is safe
+// for concurrent use.
+type DecModes struct {
+	base  cbor.DecOptions
+	tags  cbor.TagSet
+	modes sync.Map // decModeKey -> cbor.DecMode
+}
+
+// decModeKey holds the DecodeOptions that affect the DecMode
+type decModeKey struct {
+	strict bool
+	limits Limits
+}
+
+func newDecModeKey(opts DecodeOptions) decModeKey {
+	return decModeKey{strict: opts.Strict, limits: opts.Limits.withDefaults()}
+}
+
+// NewDecModes returns the DecModes for the supplied base options and tags.
+// tags may be nil. An error is returned if the base options are invalid.
+func NewDecModes(base cbor.DecOptions, tags cbor.TagSet) (*DecModes, error) {
+	ret := &DecModes{base: base, tags: tags}
+
+	dm, err := ret.build(DecodeOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	ret.modes.Store(newDecModeKey(DecodeOptions{}), dm)
+
+	return ret, nil
+}
+
+// DecMode returns the DecMode selected by the supplied DecodeOptions
+func (o *DecModes) DecMode(opts DecodeOptions) cbor.DecMode {
+	key := newDecModeKey(opts)
+
+	if dm, ok := o.modes.Load(key); ok {
+		return dm.(cbor.DecMode)
+	}
+
+	dm, err := o.build(opts)
+	if err != nil {
+		// the base options have been validated by NewDecModes, and
+		// CBORDecOptions only selects valid settings on top of them
+		panic(err)
+	}
+
+	actual, _ := o.modes.LoadOrStore(key, dm)
+
+	return actual.(cbor.DecMode)
+}
+
+func (o *DecModes) build(opts DecodeOptions) (cbor.DecMode, error) {
+	decOpts := opts.CBORDecOptions(o.base)
+
+	if o.tags == nil {
+		return decOpts.DecMode()
+	}
+
+	return decOpts.DecModeWithTags(o.tags)
+}
+
+// EncModes builds the cbor.EncMode's selected by the EncodeOptions of an
+// Encoder (see EncodeOptions.CBOREncOptions) from a set of base options and a
+// TagSet, like DecModes does for decoding. EncModes is safe for concurrent
+// use.
+type EncModes struct {
+	base  cbor.EncOptions
+	tags  cbor.TagSet
+	modes sync.Map // encModeKey -> cbor.EncMode
+}
+
+// encModeKey holds the EncodeOptions that affect the EncMode
+type encModeKey struct {
+	deterministic bool
+}
+
+func newEncModeKey(opts EncodeOptions) encModeKey {
+	return encModeKey{deterministic: opts.Deterministic}
+}
+
+// NewEncModes returns the EncModes for the supplied base options and tags.
+// tags may be nil. An error is returned if the base options are invalid.
+func NewEncModes(base cbor.EncOptions, tags cbor.TagSet) (*EncModes, error) {
+	ret := &EncModes{base: base, tags: tags}
+
+	em, err := ret.build(EncodeOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	ret.modes.Store(newEncModeKey(EncodeOptions{}), em)
+
+	return ret, nil
+}
+
+// EncMode returns the EncMode selected by the supplied EncodeOptions
+func (o *EncModes) EncMode(opts EncodeOptions) cbor.EncMode {
+	key := newEncModeKey(opts)
+
+	if em, ok := o.modes.Load(key); ok {
+		return em.(cbor.EncMode)
+	}
+
+	em, err := o.build(opts)
+	if err != nil {
+		// the base options have been validated by NewEncModes, and
+		// CBOREncOptions only selects valid settings on top of them
+		panic(err)
+	}
+
+	actual, _ := o.modes.LoadOrStore(key, em)
+
+	return actual.(cbor.EncMode)
+}
+
+func (o *EncModes) build(opts EncodeOptions) (cbor.EncMode, error) {
+	encOpts := opts.CBOREncOptions(o.base)
+
+	if o.tags == nil {
+		return encOpts.EncMode()
+	}
+
+	return encOpts.EncModeWithTags(o.tags)
+}
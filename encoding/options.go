@@ -0,0 +1,130 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package encoding
+
+import (
+	cbor "github.com/fxamacker/cbor/v2"
+)
+
+// DecodeOptions control how PopulateStructFromCBOR and PopulateStructFromJSON
+// decode their input
+type DecodeOptions struct {
+	// Strict rejects anything that is not exactly what the schema says:
+	// duplicate map keys, indefinite-length items, integers that are not in
+	// their preferred encoding (see CheckStrictCBOR and CBORDecOptions) and
+	// unknown map entries (see CheckNoUnknownCBORFields and
+	// CheckNoUnknownJSONFields)
+	Strict bool
+	// Limits caps the resources used when decoding CBOR (see
+	// CheckLimitsCBOR and CBORDecOptions). The zero value means
+	// DefaultLimits.
+	Limits Limits
+	// Registry is the registry of type choices, CBOR tags and profiles to
+	// decode with (see comid.WithRegistry and corim.WithRegistry). It is
+	// opaque to this package. nil means the default registry.
+	Registry any
+}
+
+// DecodeOption sets one of the DecodeOptions
+type DecodeOption func(*DecodeOptions)
+
+// WithStrict enables strict decoding
+func WithStrict() DecodeOption {
+	return func(o *DecodeOptions) {
+		o.Strict = true
+	}
+}
+
+// WithLimits sets the decoding limits. Fields left to zero keep their
+// DefaultLimits value.
+func WithLimits(limits Limits) DecodeOption {
+	return func(o *DecodeOptions) {
+		o.Limits = limits
+	}
+}
+
+// CBORDecOptions returns base with the settings selected by the target
+// DecodeOptions applied: the Limits cap the nesting levels, array elements and
+// map pairs, and strict decoding rejects duplicate map keys and
+// indefinite-length items
+func (o DecodeOptions) CBORDecOptions(base cbor.DecOptions) cbor.DecOptions {
+	base = o.Limits.cborDecOptions(base)
+
+	if o.Strict {
+		base.DupMapKey = cbor.DupMapKeyEnforcedAPF
+		base.IndefLength = cbor.IndefLengthForbidden
+	}
+
+	return base
+}
+
+// NewDecodeOptions returns the DecodeOptions resulting from applying the
+// supplied DecodeOption's to the defaults
+func NewDecodeOptions(opts ...DecodeOption) DecodeOptions {
+	var ret DecodeOptions
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&ret)
+		}
+	}
+
+	return ret
+}
+
+// EncodeOptions control how CBOR is encoded (see CBOREncOptions)
+type EncodeOptions struct {
+	// Deterministic produces core deterministic encoding (RFC 8949,
+	// Section 4.2.1), so that the same content is always encoded to the
+	// same bytes
+	Deterministic bool
+	// Registry is the registry of type choices and CBOR tags to encode with
+	// (see comid.WithEncodeRegistry and corim.WithEncodeRegistry). It is
+	// opaque to this package. nil means the default registry.
+	Registry any
+}
+
+// EncodeOption sets one of the EncodeOptions
+type EncodeOption func(*EncodeOptions)
+
+// WithDeterministic enables core deterministic encoding
+func WithDeterministic() EncodeOption {
+	return func(o *EncodeOptions) {
+		o.Deterministic = true
+	}
+}
+
+// CBOREncOptions returns base with the settings selected by the target
+// EncodeOptions applied: deterministic encoding uses the shortest forms and
+// the map key order of cbor.CoreDetEncOptions, while the way times and tags
+// are encoded is kept from base. Note that the maps built from structs by
+// this package are sorted by the Encoder, as they are not encoded by the
+// EncMode (see Encoder.SerializeStructToCBOR).
+func (o EncodeOptions) CBOREncOptions(base cbor.EncOptions) cbor.EncOptions {
+	if o.Deterministic {
+		det := cbor.CoreDetEncOptions()
+
+		base.Sort = det.Sort
+		base.ShortestFloat = det.ShortestFloat
+		base.NaNConvert = det.NaNConvert
+		base.InfConvert = det.InfConvert
+		base.IndefLength = det.IndefLength
+		base.BigIntConvert = det.BigIntConvert
+	}
+
+	return base
+}
+
+// NewEncodeOptions returns the EncodeOptions resulting from applying the
+// supplied EncodeOption's to the defaults
+func NewEncodeOptions(opts ...EncodeOption) EncodeOptions {
+	var ret EncodeOptions
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&ret)
+		}
+	}
+
+	return ret
+}
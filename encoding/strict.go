@@ -0,0 +1,272 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnknownKey is reported in strict mode for map entries that are not
+	// claimed by any struct field or registered extension
+	ErrUnknownKey = errors.New("unknown map key")
+	// ErrDuplicateKey is reported in strict mode for map keys that occur
+	// more than once in the same map
+	ErrDuplicateKey = errors.New("duplicate map key")
+)
+
+// StrictError is returned by strict decoding. Path locates the offending item
+// as a JSON Pointer (RFC 6901) built from the map keys and array indices
+// leading to it, e.g. "/4/0/1" for CBOR or "/triples/reference-values/1" for
+// JSON. The root item is "/".
+type StrictError struct {
+	Path string
+	Err  error
+}
+
+// Error returns the path followed by the description of the problem
+func (o *StrictError) Error() string {
+	return fmt.Sprintf("%s: %v", o.Path, o.Err)
+}
+
+// Unwrap returns the underlying error, so that errors.Is can be used to check
+// for ErrUnknownKey, ErrDuplicateKey, etc.
+func (o *StrictError) Unwrap() error {
+	return o.Err
+}
+
+func newStrictError(path []string, err error) *StrictError {
+	return &StrictError{Path: formatPath(path), Err: err}
+}
+
+func formatPath(path []string) string {
+	escaped := make([]string, len(path))
+
+	for i, seg := range path {
+		seg = strings.ReplaceAll(seg, "~", "~0")
+		escaped[i] = strings.ReplaceAll(seg, "/", "~1")
+	}
+
+	return "/" + strings.Join(escaped, "/")
+}
+
+// appendPath returns a new path, leaving the supplied one untouched
+func appendPath(path []string, seg string) []string {
+	return append(path[:len(path):len(path)], seg)
+}
+
+// CheckStrictCBOR checks that data is exactly one well-formed CBOR data item
+// without duplicate map keys or indefinite-length items, and with all
+// integers, lengths and tag numbers in their preferred (shortest) encoding.
+// Unlike CheckCanonical, it does not look at the order of map keys. Any
+// problem is reported as a *StrictError.
+func CheckStrictCBOR(data []byte) error {
+	return checkCBOR(data, false)
+}
+
+// CheckStrictJSON checks that data is exactly one well-formed JSON value
+// without duplicate object member names. Any problem is reported as a
+// *StrictError.
+func CheckStrictJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	if err := checkJSONValue(dec, nil); err != nil {
+		return err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return newStrictError(nil, errors.New("trailing data"))
+	}
+
+	return nil
+}
+
+func checkJSONValue(dec *json.Decoder, path []string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return newStrictError(path, err)
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		seen := make(map[string]bool)
+
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return newStrictError(path, err)
+			}
+
+			key := tok.(string)
+			keyPath := appendPath(path, key)
+
+			if seen[key] {
+				return newStrictError(keyPath, ErrDuplicateKey)
+			}
+			seen[key] = true
+
+			if err := checkJSONValue(dec, keyPath); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if err := checkJSONValue(dec, appendPath(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+
+	// consume the closing delimiter
+	if _, err := dec.Token(); err != nil {
+		return newStrictError(path, err)
+	}
+
+	return nil
+}
+
+// CheckNoUnknownCBORFields checks that none of the structs reachable from v
+// retain unknown entries decoded from CBOR (see IUnknownFields). The first one
+// found is reported as a *StrictError wrapping ErrUnknownKey.
+func CheckNoUnknownCBORFields(v any) error {
+	w := unknownWalker{tag: "cbor", visited: make(map[uintptr]bool)}
+	return w.walk(reflect.ValueOf(v), nil)
+}
+
+// CheckNoUnknownJSONFields checks that none of the structs reachable from v
+// retain unknown entries decoded from JSON (see IUnknownFields). The first one
+// found is reported as a *StrictError wrapping ErrUnknownKey.
+func CheckNoUnknownJSONFields(v any) error {
+	w := unknownWalker{tag: "json", visited: make(map[uintptr]bool)}
+	return w.walk(reflect.ValueOf(v), nil)
+}
+
+type unknownWalker struct {
+	tag     string
+	visited map[uintptr]bool
+}
+
+func (o *unknownWalker) walk(v reflect.Value, path []string) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() || o.visited[v.Pointer()] {
+			return nil
+		}
+		o.visited[v.Pointer()] = true
+
+		return o.walk(v.Elem(), path)
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+
+		return o.walk(v.Elem(), path)
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return nil
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			if err := o.walk(v.Index(i), appendPath(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+
+		for _, k := range keys {
+			if err := o.walk(v.MapIndex(k), appendPath(path, fmt.Sprint(k))); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		return o.walkStruct(v, path)
+	}
+
+	return nil
+}
+
+func (o *unknownWalker) walkStruct(v reflect.Value, path []string) error {
+	if v.CanInterface() {
+		if u, ok := v.Interface().(IUnknownFields); ok {
+			if err := o.checkUnknown(u.GetUnknownFields(), path); err != nil {
+				return err
+			}
+		}
+	}
+
+	t := v.Type()
+
+	// the fields of toarray structs are identified by their position
+	toArray := false
+	if o.tag == "cbor" {
+		if f, ok := t.FieldByName("_"); ok {
+			toArray = strings.Contains(f.Tag.Get("cbor"), "toarray")
+		}
+	}
+
+	pos := 0
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if f.Name == "_" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+
+		fieldPath := path
+
+		switch {
+		case f.Anonymous:
+			// embedded fields are flattened into the enclosing map
+		case toArray:
+			fieldPath = appendPath(path, strconv.Itoa(pos))
+			pos++
+		default:
+			name := strings.Split(f.Tag.Get(o.tag), ",")[0]
+			if name == "-" {
+				continue
+			}
+
+			if name != "" {
+				fieldPath = appendPath(path, name)
+			}
+		}
+
+		if err := o.walk(v.Field(i), fieldPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *unknownWalker) checkUnknown(unknown *UnknownFields, path []string) error {
+	if unknown == nil {
+		return nil
+	}
+
+	if o.tag == "cbor" && len(unknown.CBOR) != 0 {
+		return newStrictError(appendPath(path, strconv.Itoa(unknown.CBOR[0].Key)), ErrUnknownKey)
+	}
+
+	if o.tag == "json" && len(unknown.JSON) != 0 {
+		return newStrictError(appendPath(path, unknown.JSON[0].Key), ErrUnknownKey)
+	}
+
+	return nil
+}
@@ -0,0 +1,234 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package encoding
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CheckStrictCBOR(t *testing.T) {
+	testCases := []struct {
+		title    string
+		data     []byte
+		expected string
+		sentinel error
+	}{
+		{
+			title: "ok",
+			data:  []byte{0xa2, 0x00, 0x82, 0x18, 0x18, 0x20, 0x01, 0xc1, 0x1a, 0x00, 0x01, 0x00, 0x00},
+		},
+		{
+			title:    "duplicate key",
+			data:     []byte{0xa1, 0x04, 0xa2, 0x01, 0x00, 0x01, 0x01},
+			expected: "/4/1: duplicate map key",
+			sentinel: ErrDuplicateKey,
+		},
+		{
+			title:    "duplicate text key",
+			data:     []byte{0xa2, 0x61, 0x61, 0x00, 0x61, 0x61, 0x00},
+			expected: "/a: duplicate map key",
+			sentinel: ErrDuplicateKey,
+		},
+		{
+			title:    "indefinite-length array",
+			data:     []byte{0xa1, 0x02, 0x82, 0x00, 0x9f, 0xff},
+			expected: "/2/1: indefinite-length item",
+			sentinel: ErrIndefiniteLength,
+		},
+		{
+			title:    "indefinite-length map",
+			data:     []byte{0xbf, 0x00, 0x00, 0xff},
+			expected: "/: indefinite-length item",
+			sentinel: ErrIndefiniteLength,
+		},
+		{
+			title:    "non-preferred integer",
+			data:     []byte{0xa1, 0x01, 0x19, 0x00, 0x05},
+			expected: "/1: non-preferred integer encoding: 5 encoded in 2 bytes",
+			sentinel: ErrNonPreferredEncoding,
+		},
+		{
+			title:    "non-preferred length",
+			data:     []byte{0x81, 0x58, 0x01, 0xaa},
+			expected: "/0: non-preferred integer encoding: 1 encoded in 1 bytes",
+			sentinel: ErrNonPreferredEncoding,
+		},
+		{
+			title:    "non-preferred key",
+			data:     []byte{0xa1, 0x18, 0x01, 0x00},
+			expected: "/: non-preferred integer encoding: 1 encoded in 1 bytes",
+			sentinel: ErrNonPreferredEncoding,
+		},
+		{
+			title:    "truncated",
+			data:     []byte{0x82, 0x00},
+			expected: "/1: unexpected EOF",
+		},
+		{
+			title:    "trailing data",
+			data:     []byte{0x00, 0x00},
+			expected: "/: 1 bytes of trailing data",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			err := CheckStrictCBOR(tc.data)
+			if tc.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.EqualError(t, err, tc.expected)
+
+			var strictErr *StrictError
+			assert.True(t, errors.As(err, &strictErr))
+
+			if tc.sentinel != nil {
+				assert.ErrorIs(t, err, tc.sentinel)
+			}
+		})
+	}
+}
+
+func Test_DecModes_strict(t *testing.T) {
+	modes, err := NewDecModes(cbor.DecOptions{}, nil)
+	require.NoError(t, err)
+
+	lax := modes.DecMode(NewDecodeOptions())
+	strict := modes.DecMode(NewDecodeOptions(WithStrict()))
+
+	assert.Same(t, strict, modes.DecMode(NewDecodeOptions(WithStrict())))
+
+	testCases := []struct {
+		title    string
+		data     []byte
+		expected string
+	}{
+		{
+			title:    "duplicate key",
+			data:     []byte{0xa1, 0x04, 0xa2, 0x01, 0x00, 0x01, 0x01},
+			expected: `cbor: found duplicate map key "1" at map element index 1`,
+		},
+		{
+			title:    "indefinite-length array",
+			data:     []byte{0xa1, 0x02, 0x82, 0x00, 0x9f, 0xff},
+			expected: "cbor: indefinite-length array isn't allowed",
+		},
+		{
+			title:    "indefinite-length map",
+			data:     []byte{0xbf, 0x00, 0x00, 0xff},
+			expected: "cbor: indefinite-length map isn't allowed",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			var v any
+
+			assert.NoError(t, lax.Unmarshal(tc.data, &v))
+			assert.EqualError(t, strict.Unmarshal(tc.data, &v), tc.expected)
+		})
+	}
+}
+
+func Test_CheckStrictJSON(t *testing.T) {
+	assert.NoError(t, CheckStrictJSON([]byte(`{"a": [1, {"b": 2}], "b": {"b": 3}}`)))
+
+	err := CheckStrictJSON([]byte(`{"a": [1, {"b/c": 2, "b/c": 3}]}`))
+	assert.EqualError(t, err, "/a/1/b~1c: duplicate map key")
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+
+	assert.EqualError(t, CheckStrictJSON([]byte(`{} {}`)), "/: trailing data")
+	assert.Error(t, CheckStrictJSON([]byte(`{"a": `)))
+}
+
+type strictInner struct {
+	Two int `json:"two"`
+	unknownHolder
+}
+
+func (o *strictInner) UnmarshalJSON(data []byte) error {
+	return PopulateStructFromJSON(data, o)
+}
+
+type strictOuter struct {
+	Inner []strictInner `json:"inner"`
+	unknownHolder
+}
+
+func Test_PopulateStructFromCBOR_strict(t *testing.T) {
+	modes, err := NewDecModes(cbor.DecOptions{}, nil)
+	require.NoError(t, err)
+	dm := modes.DecMode(NewDecodeOptions())
+	strictDM := modes.DecMode(NewDecodeOptions(WithStrict()))
+
+	em, err := cbor.EncOptions{}.EncMode()
+	require.NoError(t, err)
+
+	data, err := em.Marshal(map[int]any{1: 2})
+	require.NoError(t, err)
+
+	var v retainingStruct
+	assert.NoError(t, PopulateStructFromCBOR(strictDM, data, &v, WithStrict()))
+
+	data, err = em.Marshal(map[int]any{1: 2, 7: "x"})
+	require.NoError(t, err)
+
+	// unknown entries are tolerated, unless decoding is strict
+	assert.NoError(t, PopulateStructFromCBOR(dm, data, &v))
+
+	err = PopulateStructFromCBOR(strictDM, data, &v, WithStrict())
+	assert.EqualError(t, err, "/7: unknown map key")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// duplicate keys are always rejected, but only strict decoding
+	// reports where
+	data = []byte{0xa2, 0x01, 0x02, 0x01, 0x03}
+	assert.EqualError(t, PopulateStructFromCBOR(dm, data, &v), "map item 1: duplicate cbor key: 1")
+
+	err = PopulateStructFromCBOR(strictDM, data, &v, WithStrict())
+	assert.EqualError(t, err, "/1: duplicate map key")
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+
+	// the whole input is checked, including the struct map itself
+	data = []byte{0xbf, 0x01, 0x02, 0xff}
+	assert.NoError(t, PopulateStructFromCBOR(dm, data, &v))
+
+	err = PopulateStructFromCBOR(strictDM, data, &v, WithStrict())
+	assert.EqualError(t, err, "/: indefinite-length item")
+	assert.ErrorIs(t, err, ErrIndefiniteLength)
+
+	// non-preferred encodings are only rejected by strict decoding
+	data = []byte{0xa1, 0x18, 0x01, 0x02}
+	assert.NoError(t, PopulateStructFromCBOR(dm, data, &v))
+
+	err = PopulateStructFromCBOR(strictDM, data, &v, WithStrict())
+	assert.EqualError(t, err, "/: non-preferred integer encoding: 1 encoded in 1 bytes")
+	assert.ErrorIs(t, err, ErrNonPreferredEncoding)
+
+	data = []byte{0xa1, 0x01, 0x02, 0x00}
+	assert.EqualError(t, PopulateStructFromCBOR(strictDM, data, &v, WithStrict()),
+		"/: 1 bytes of trailing data")
+}
+
+func Test_PopulateStructFromJSON_strict(t *testing.T) {
+	data := []byte(`{"inner": [{"two": 2}, {"two": 2, "three": 3}]}`)
+
+	var v strictOuter
+	assert.NoError(t, PopulateStructFromJSON(data, &v))
+
+	err := PopulateStructFromJSON(data, &v, WithStrict())
+	assert.EqualError(t, err, "/inner/1/three: unknown map key")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	data = []byte(`{"inner": [{"two": 2, "two": 3}]}`)
+	assert.ErrorContains(t, PopulateStructFromJSON(data, &v), `duplicate JSON key: "two"`)
+	assert.EqualError(t, PopulateStructFromJSON(data, &v, WithStrict()),
+		"/inner/0/two: duplicate map key")
+}
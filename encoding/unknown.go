@@ -0,0 +1,146 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package encoding
+
+import (
+	"encoding/json"
+	"fmt"
+
+	cbor "github.com/fxamacker/cbor/v2"
+)
+
+// UnknownCBOREntry is a CBOR map entry that was not claimed by any struct
+// field (including registered extensions) when decoding
+type UnknownCBOREntry struct {
+	Key   int
+	Value cbor.RawMessage
+}
+
+// UnknownJSONEntry is a JSON object member that was not claimed by any struct
+// field (including registered extensions) when decoding
+type UnknownJSONEntry struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// UnknownFields holds the unclaimed entries found when decoding a struct, in
+// the order in which they appeared in the input. CBOR and JSON entries are
+// kept separately, as they are only re-emitted in the encoding they were
+// decoded from.
+type UnknownFields struct {
+	CBOR []UnknownCBOREntry
+	JSON []UnknownJSONEntry
+}
+
+// IsEmpty returns true if there are no unknown entries
+func (o *UnknownFields) IsEmpty() bool {
+	return o == nil || (len(o.CBOR) == 0 && len(o.JSON) == 0)
+}
+
+// IUnknownFields is implemented by the structs that retain the entries not
+// claimed by any of their fields when decoded with PopulateStructFromCBOR or
+// PopulateStructFromJSON. SerializeStructToCBOR and SerializeStructToJSON
+// re-emit the retained entries after the struct's own fields.
+type IUnknownFields interface {
+	GetUnknownFields() *UnknownFields
+}
+
+// IUnknownFieldsSetter is implemented by (pointers to) the structs that
+// retain unclaimed entries (see IUnknownFields)
+type IUnknownFieldsSetter interface {
+	SetUnknownFields(*UnknownFields)
+}
+
+// retainUnknownCBOR stores the entries left in rawMap after decoding into
+// dest, if dest retains unknown fields
+func retainUnknownCBOR(rawMap *structFieldsCBOR, dest any) {
+	setter, ok := dest.(IUnknownFieldsSetter)
+	if !ok {
+		return
+	}
+
+	var unknown *UnknownFields
+
+	if len(rawMap.Keys) > 0 {
+		unknown = &UnknownFields{}
+		for _, k := range rawMap.Keys {
+			unknown.CBOR = append(unknown.CBOR, UnknownCBOREntry{Key: k, Value: rawMap.Fields[k]})
+		}
+	}
+
+	setter.SetUnknownFields(unknown)
+}
+
+// retainUnknownJSON stores the entries left in rawMap after decoding into
+// dest, if dest retains unknown fields
+func retainUnknownJSON(rawMap *structFieldsJSON, dest any) {
+	setter, ok := dest.(IUnknownFieldsSetter)
+	if !ok {
+		return
+	}
+
+	var unknown *UnknownFields
+
+	if len(rawMap.Keys) > 0 {
+		unknown = &UnknownFields{}
+		for _, k := range rawMap.Keys {
+			unknown.JSON = append(unknown.JSON, UnknownJSONEntry{Key: k, Value: rawMap.Fields[k]})
+		}
+	}
+
+	setter.SetUnknownFields(unknown)
+}
+
+// emitUnknownCBOR appends the retained entries of source to rawMap. Entries
+// whose key is now claimed by a struct field are skipped. The values are
+// canonicalized if deterministic is set, as they are opaque to the EncMode.
+func emitUnknownCBOR(rawMap *structFieldsCBOR, source any, deterministic bool) error {
+	getter, ok := source.(IUnknownFields)
+	if !ok {
+		return nil
+	}
+
+	unknown := getter.GetUnknownFields()
+	if unknown == nil {
+		return nil
+	}
+
+	for _, e := range unknown.CBOR {
+		if rawMap.Has(e.Key) {
+			continue
+		}
+
+		val := e.Value
+
+		if deterministic {
+			var err error
+			if val, err = Canonicalize(val); err != nil {
+				return fmt.Errorf("unknown entry %d: %w", e.Key, err)
+			}
+		}
+
+		_ = rawMap.Add(e.Key, val)
+	}
+
+	return nil
+}
+
+// emitUnknownJSON appends the retained entries of source to rawMap. Entries
+// whose key is now claimed by a struct field are skipped.
+func emitUnknownJSON(rawMap *structFieldsJSON, source any) {
+	getter, ok := source.(IUnknownFields)
+	if !ok {
+		return
+	}
+
+	unknown := getter.GetUnknownFields()
+	if unknown == nil {
+		return
+	}
+
+	for _, e := range unknown.JSON {
+		if !rawMap.Has(e.Key) {
+			_ = rawMap.Add(e.Key, e.Value)
+		}
+	}
+}
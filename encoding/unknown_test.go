@@ -0,0 +1,101 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package encoding
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type unknownHolder struct {
+	unknown *UnknownFields
+}
+
+func (o unknownHolder) GetUnknownFields() *UnknownFields { return o.unknown }
+
+func (o *unknownHolder) SetUnknownFields(u *UnknownFields) { o.unknown = u }
+
+type retainingStruct struct {
+	FieldOne string `cbor:"0,keyasint,omitempty" json:"one,omitempty"`
+	FieldTwo int    `cbor:"1,keyasint" json:"two"`
+	unknownHolder
+}
+
+func Test_UnknownFields_CBOR_round_trip(t *testing.T) {
+	data := []byte{
+		0xa4, // map(4)
+
+		0x18, 0x63, // key 99
+		0x61, 0x78, // val "x"
+
+		0x01, // key 1
+		0x06, // val 6
+
+		0x20,       // key -1
+		0x82, 1, 2, // val [1, 2]
+
+		0x00,       // key 0
+		0x61, 0x61, // val "a"
+	}
+
+	dm, err := cbor.DecOptions{}.DecMode()
+	require.NoError(t, err)
+	em, err := cbor.EncOptions{}.EncMode()
+	require.NoError(t, err)
+
+	var v retainingStruct
+	require.NoError(t, PopulateStructFromCBOR(dm, data, &v))
+
+	assert.Equal(t, "a", v.FieldOne)
+	assert.Equal(t, 6, v.FieldTwo)
+	require.NotNil(t, v.GetUnknownFields())
+	assert.Equal(t, []UnknownCBOREntry{
+		{Key: 99, Value: cbor.RawMessage{0x61, 0x78}},
+		{Key: -1, Value: cbor.RawMessage{0x82, 1, 2}},
+	}, v.GetUnknownFields().CBOR)
+	assert.Empty(t, v.GetUnknownFields().JSON)
+
+	// known fields first, then the unknown ones in their original order
+	out, err := SerializeStructToCBOR(em, v)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0xa4,
+		0x00, 0x61, 0x61,
+		0x01, 0x06,
+		0x18, 0x63, 0x61, 0x78,
+		0x20, 0x82, 1, 2,
+	}, out)
+
+	// decoding again without unknown entries clears them
+	require.NoError(t, PopulateStructFromCBOR(dm, []byte{0xa1, 0x01, 0x06}, &v))
+	assert.Nil(t, v.GetUnknownFields())
+}
+
+func Test_UnknownFields_JSON_round_trip(t *testing.T) {
+	data := []byte(`{"new": {"a": 1}, "two": 2, "newer": [true]}`)
+
+	var v retainingStruct
+	require.NoError(t, PopulateStructFromJSON(data, &v))
+
+	require.NotNil(t, v.GetUnknownFields())
+	assert.Equal(t, []UnknownJSONEntry{
+		{Key: "new", Value: json.RawMessage(`{"a": 1}`)},
+		{Key: "newer", Value: json.RawMessage(`[true]`)},
+	}, v.GetUnknownFields().JSON)
+
+	out, err := SerializeStructToJSON(&v)
+	require.NoError(t, err)
+	assert.Equal(t, `{"two":2,"new":{"a": 1},"newer":[true]}`, string(out))
+
+	// unknown entries whose key is now claimed by a field are not emitted
+	v.FieldOne = "x"
+	v.unknown.JSON = append(v.unknown.JSON, UnknownJSONEntry{Key: "one", Value: json.RawMessage(`"y"`)})
+
+	out, err = SerializeStructToJSON(&v)
+	require.NoError(t, err)
+	assert.Equal(t, `{"one":"x","two":2,"new":{"a": 1},"newer":[true]}`, string(out))
+}
@@ -5,34 +5,42 @@ package extensions
 
 import (
 	cbor "github.com/fxamacker/cbor/v2"
+	"github.com/jraman567/corim/encoding"
 )
 
 var (
-	em, emError = initCBOREncMode()
-	dm, dmError = initCBORDecMode()
+	ems, emsError = initCBOREncModes()
+	dms, dmsError = initCBORDecModes()
+
+	// em and dm are the EncMode and DecMode for the default options
+	em cbor.EncMode
+	dm cbor.DecMode
 )
 
-func initCBOREncMode() (en cbor.EncMode, err error) {
+func initCBOREncModes() (*encoding.EncModes, error) {
 	encOpt := cbor.EncOptions{
 		Sort:        cbor.SortCoreDeterministic,
 		IndefLength: cbor.IndefLengthForbidden,
 		TimeTag:     cbor.EncTagRequired,
 	}
-	return encOpt.EncMode()
+	return encoding.NewEncModes(encOpt, nil)
 }
 
-func initCBORDecMode() (dm cbor.DecMode, err error) {
+func initCBORDecModes() (*encoding.DecModes, error) {
 	decOpt := cbor.DecOptions{
 		IndefLength: cbor.IndefLengthForbidden,
 	}
-	return decOpt.DecMode()
+	return encoding.NewDecModes(decOpt, nil)
 }
 
 func init() {
-	if emError != nil {
-		panic(emError)
+	if emsError != nil {
+		panic(emsError)
 	}
-	if dmError != nil {
-		panic(dmError)
+	if dmsError != nil {
+		panic(dmsError)
 	}
+
+	em = ems.EncMode(encoding.EncodeOptions{})
+	dm = dms.DecMode(encoding.DecodeOptions{})
 }
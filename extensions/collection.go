@@ -9,6 +9,8 @@ import (
 	"fmt"
 
 	cbor "github.com/fxamacker/cbor/v2"
+	"github.com/jraman567/corim/encoding"
+	"github.com/jraman567/corim/validation"
 )
 
 var ErrUnexpectedPoint = errors.New("unexpected extension point")
@@ -107,7 +109,7 @@ func (o Collection[P, I]) Valid() error {
 	for i, p := range o.Values {
 		var m I = &p // #nosec G601 -- not an issue in Go 1.22
 		if err := m.Valid(); err != nil {
-			return fmt.Errorf("error at index %d: %w", i, err)
+			return validation.Errorf(validation.Index(i), "error at index %d: %w", i, err)
 		}
 	}
 
@@ -147,10 +149,23 @@ func (o *Collection[P, I]) RegisterExtensions(exts Map) error {
 }
 
 func (o Collection[P, I]) MarshalCBOR() ([]byte, error) {
-	return em.Marshal(o.Values)
+	return o.EncodeCBOR(encoding.NewEncoder())
+}
+
+// EncodeCBOR encodes the values of the collection as a CBOR array, passing e
+// on to them
+func (o Collection[P, I]) EncodeCBOR(e *encoding.Encoder) ([]byte, error) {
+	return e.EncodeCBORValue(ems.EncMode(e.EncodeOptions), o.Values)
 }
 
 func (o *Collection[P, I]) UnmarshalCBOR(data []byte) error {
+	return o.DecodeCBOR(encoding.NewDecoder(), data)
+}
+
+// DecodeCBOR decodes a CBOR array into the values of the collection, passing
+// d on to them. The extensions registered with the collection are registered
+// with each value before decoding it.
+func (o *Collection[P, I]) DecodeCBOR(d *encoding.Decoder, data []byte) error {
 	var rawVals []cbor.RawMessage
 
 	if err := cbor.Unmarshal(data, &rawVals); err != nil {
@@ -168,7 +183,7 @@ func (o *Collection[P, I]) UnmarshalCBOR(data []byte) error {
 			}
 		}
 
-		if err := cbor.Unmarshal(rv, m); err != nil {
+		if err := d.DecodeCBORValue(dms.DecMode(d.DecodeOptions), rv, m); err != nil {
 			return fmt.Errorf("error at index %d: %w", i, err)
 		}
 
@@ -181,11 +196,23 @@ func (o *Collection[P, I]) UnmarshalCBOR(data []byte) error {
 }
 
 func (o Collection[P, I]) MarshalJSON() ([]byte, error) {
-	ret, err := json.Marshal(o.Values)
-	return ret, err
+	return o.EncodeJSON(encoding.NewEncoder())
+}
+
+// EncodeJSON encodes the values of the collection as a JSON array, passing e
+// on to them
+func (o Collection[P, I]) EncodeJSON(e *encoding.Encoder) ([]byte, error) {
+	return e.EncodeJSONValue(o.Values)
 }
 
 func (o *Collection[P, I]) UnmarshalJSON(data []byte) error {
+	return o.DecodeJSON(encoding.NewDecoder(), data)
+}
+
+// DecodeJSON decodes a JSON array into the values of the collection, passing
+// d on to them. The extensions registered with the collection are registered
+// with each value before decoding it.
+func (o *Collection[P, I]) DecodeJSON(d *encoding.Decoder, data []byte) error {
 	var rawVals []json.RawMessage
 
 	if err := json.Unmarshal(data, &rawVals); err != nil {
@@ -203,7 +230,7 @@ func (o *Collection[P, I]) UnmarshalJSON(data []byte) error {
 			}
 		}
 
-		if err := json.Unmarshal(rv, m); err != nil {
+		if err := d.DecodeJSONValue(rv, m); err != nil {
 			return fmt.Errorf("error at index %d: %w", i, err)
 		}
 
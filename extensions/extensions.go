@@ -6,8 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 
+	"github.com/jraman567/corim/encoding"
+	"github.com/jraman567/corim/validation"
 	"github.com/spf13/cast"
 )
 
@@ -15,8 +18,65 @@ var ErrExtensionNotFound = errors.New("extension not found")
 
 type IMapValue any
 
+// IDynamicMapValue may be implemented by an IMapValue whose zero value is not
+// usable, e.g. because its extension fields are only known at run time. Such
+// an IMapValue would typically embed another IMapValue holding the fields (the
+// fields of embedded structs, and of the values of embedded interfaces, are
+// treated as fields of the IMapValue itself, as they are when encoding).
+type IDynamicMapValue interface {
+	// NewMapValue returns a new instance of the IMapValue with none of
+	// its extension fields set.
+	NewMapValue() IMapValue
+}
+
 type Extensions struct {
 	IMapValue `json:"extensions,omitempty"`
+
+	// unknown holds the map entries of the enclosing struct that were not
+	// claimed by any of its fields or registered extensions when decoded
+	unknown *encoding.UnknownFields
+}
+
+// GetUnknownFields returns the entries of the enclosing struct's map that were
+// not claimed by any of its fields or registered extensions when it was last
+// decoded, in their original order, or nil if there are none. They are
+// re-emitted when the struct is encoded to the same format (CBOR or JSON).
+func (o Extensions) GetUnknownFields() *encoding.UnknownFields {
+	return o.unknown
+}
+
+// SetUnknownFields replaces the unclaimed entries of the enclosing struct (see
+// GetUnknownFields). Passing nil discards them.
+func (o *Extensions) SetUnknownFields(unknown *encoding.UnknownFields) {
+	if unknown.IsEmpty() {
+		unknown = nil
+	}
+
+	o.unknown = unknown
+}
+
+// HaveUnknownFields returns true if the enclosing struct retains unclaimed
+// entries (see GetUnknownFields)
+func (o Extensions) HaveUnknownFields() bool {
+	return !o.unknown.IsEmpty()
+}
+
+// ReportUnknownFields adds to r a warning for each of the entries retained by
+// the enclosing struct (see GetUnknownFields), which is located at segment
+func (o Extensions) ReportUnknownFields(r *validation.Report, segment string) {
+	if o.unknown.IsEmpty() {
+		return
+	}
+
+	for _, e := range o.unknown.CBOR {
+		r.Warn(validation.JoinPath(segment, strconv.Itoa(e.Key)), validation.CodeUnknown,
+			"unknown field %d", e.Key)
+	}
+
+	for _, e := range o.unknown.JSON {
+		r.Warn(validation.JoinPath(segment, e.Key), validation.CodeUnknown,
+			"unknown field %q", e.Key)
+	}
 }
 
 func (o *Extensions) Register(exts IMapValue) {
@@ -40,30 +100,9 @@ func (o *Extensions) Get(name string) (any, error) {
 		return nil, fmt.Errorf("%w: %s", ErrExtensionNotFound, name)
 	}
 
-	extType := reflect.TypeOf(o.IMapValue)
-	extVal := reflect.ValueOf(o.IMapValue)
-	if extType.Kind() == reflect.Pointer {
-		extType = extType.Elem()
-		extVal = extVal.Elem()
-	}
-
-	var fieldName, fieldJSONTag, fieldCBORTag string
-	for i := 0; i < extVal.NumField(); i++ {
-		typeField := extType.Field(i)
-		fieldName = typeField.Name
-
-		tag, ok := typeField.Tag.Lookup("json")
-		if ok {
-			fieldJSONTag = strings.Split(tag, ",")[0]
-		}
-
-		tag, ok = typeField.Tag.Lookup("cbor")
-		if ok {
-			fieldCBORTag = strings.Split(tag, ",")[0]
-		}
-
-		if fieldName == name || fieldJSONTag == name || fieldCBORTag == name {
-			return extVal.Field(i).Interface(), nil
+	for _, f := range mapValueFields(o.IMapValue) {
+		if f.matches(name) {
+			return f.Value.Interface(), nil
 		}
 	}
 
@@ -75,13 +114,8 @@ func (o *Extensions) IsEmpty() bool {
 		return true
 	}
 
-	extVal := reflect.ValueOf(o.IMapValue)
-	if reflect.TypeOf(o.IMapValue).Kind() == reflect.Pointer {
-		extVal = extVal.Elem()
-	}
-
-	for i := 0; i < extVal.NumField(); i++ {
-		if !extVal.Field(i).IsZero() {
+	for _, f := range mapValueFields(o.IMapValue) {
+		if !f.Value.IsZero() {
 			return false
 		}
 	}
@@ -360,42 +394,31 @@ func (o *Extensions) Set(name string, value any) error {
 		return fmt.Errorf("%w: %s", ErrExtensionNotFound, name)
 	}
 
-	extType := reflect.TypeOf(o.IMapValue)
-	extVal := reflect.ValueOf(o.IMapValue)
-	if extType.Kind() == reflect.Pointer {
-		extType = extType.Elem()
-		extVal = extVal.Elem()
-	}
-
-	var fieldName, fieldJSONTag, fieldCBORTag string
-	for i := 0; i < extVal.NumField(); i++ {
-		typeField := extType.Field(i)
-		valField := extVal.Field(i)
-		fieldName = typeField.Name
-
-		tag, ok := typeField.Tag.Lookup("json")
-		if ok {
-			fieldJSONTag = strings.Split(tag, ",")[0]
+	for _, f := range mapValueFields(o.IMapValue) {
+		if !f.matches(name) {
+			continue
 		}
 
-		tag, ok = typeField.Tag.Lookup("cbor")
-		if ok {
-			fieldCBORTag = strings.Split(tag, ",")[0]
+		newVal := reflect.ValueOf(value)
+		if newVal.CanConvert(f.Value.Type()) {
+			f.Value.Set(newVal.Convert(f.Value.Type()))
+			return nil
 		}
 
-		if fieldName == name || fieldJSONTag == name || fieldCBORTag == name {
-			newVal := reflect.ValueOf(value)
-			if newVal.CanConvert(valField.Type()) {
-				valField.Set(newVal.Convert(valField.Type()))
-				return nil
-			}
-
-			return fmt.Errorf(
-				"cannot set field %q (of type %s) to %v (%T)",
-				name, typeField.Type.Name(),
-				value, value,
-			)
+		// optional fields are pointers, which are set to point to the
+		// converted value
+		if f.Value.Kind() == reflect.Pointer && newVal.CanConvert(f.Value.Type().Elem()) {
+			ptr := reflect.New(f.Value.Type().Elem())
+			ptr.Elem().Set(newVal.Convert(f.Value.Type().Elem()))
+			f.Value.Set(ptr)
+			return nil
 		}
+
+		return fmt.Errorf(
+			"cannot set field %q (of type %s) to %v (%T)",
+			name, f.Type.Type.Name(),
+			value, value,
+		)
 	}
 
 	return fmt.Errorf("%w: %s", ErrExtensionNotFound, name)
@@ -406,7 +429,81 @@ func newIMapValue(v IMapValue) IMapValue {
 		return nil
 	}
 
+	if dv, ok := v.(IDynamicMapValue); ok {
+		return dv.NewMapValue()
+	}
+
 	valType := reflect.Indirect(reflect.ValueOf(v)).Type()
 
 	return reflect.New(valType).Interface()
 }
+
+// mapValueField is an extension field of an IMapValue
+type mapValueField struct {
+	Type  reflect.StructField
+	Value reflect.Value
+}
+
+// matches returns true if name is the name of the field, or the key used for
+// it in JSON or CBOR
+func (o mapValueField) matches(name string) bool {
+	if o.Type.Name == name {
+		return true
+	}
+
+	for _, key := range []string{"json", "cbor"} {
+		if tag, ok := o.Type.Tag.Lookup(key); ok && strings.Split(tag, ",")[0] == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mapValueFields returns the exported fields of v, descending into embedded
+// structs and into the values of embedded interfaces
+func mapValueFields(v IMapValue) []mapValueField {
+	var ret []mapValueField
+
+	collectMapValueFields(reflect.ValueOf(v), &ret)
+
+	return ret
+}
+
+func collectMapValueFields(val reflect.Value, fields *[]mapValueField) {
+	if val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return
+		}
+
+		val = val.Elem()
+	}
+
+	if val.Kind() != reflect.Struct {
+		return
+	}
+
+	typ := val.Type()
+
+	for i := 0; i < val.NumField(); i++ {
+		typeField := typ.Field(i)
+		valField := val.Field(i)
+
+		if typeField.Anonymous && typeField.Name == typeField.Type.Name() {
+			switch typeField.Type.Kind() {
+			case reflect.Struct:
+				collectMapValueFields(valField, fields)
+				continue
+			case reflect.Interface:
+				if !valField.IsNil() {
+					collectMapValueFields(valField.Elem(), fields)
+				}
+				continue
+			}
+		}
+
+		if typeField.IsExported() {
+			*fields = append(*fields, mapValueField{Type: typeField, Value: valField})
+		}
+	}
+}
@@ -149,3 +149,42 @@ func Test_Extensions_IsEmpty(t *testing.T) {
 	exts.Register(&TestExtensions{Address: "123 Fake Street"})
 	assert.False(t, exts.IsEmpty())
 }
+
+type testOptionalExtensions struct {
+	Phone *string `cbor:"-1,keyasint,omitempty" json:"phone,omitempty"`
+}
+
+// testDynamicExtensions holds its fields in an embedded IMapValue
+type testDynamicExtensions struct {
+	IMapValue
+
+	instances *int
+}
+
+func (o *testDynamicExtensions) NewMapValue() IMapValue {
+	*o.instances++
+	return &testDynamicExtensions{IMapValue: &testOptionalExtensions{}, instances: o.instances}
+}
+
+func Test_Extensions_dynamic(t *testing.T) {
+	var instances int
+
+	exts := Extensions{}
+	exts.Register(&testDynamicExtensions{IMapValue: &testOptionalExtensions{}, instances: &instances})
+	assert.True(t, exts.IsEmpty())
+
+	newVal, ok := exts.New().(*testDynamicExtensions)
+	require.True(t, ok)
+	assert.Equal(t, 1, instances)
+	assert.IsType(t, &testOptionalExtensions{}, newVal.IMapValue)
+
+	require.NoError(t, exts.Set("phone", "555-0100"))
+	assert.False(t, exts.IsEmpty())
+	assert.Equal(t, "555-0100", exts.MustGetString("-1"))
+
+	err := exts.Set("Phone", true)
+	assert.EqualError(t, err, `cannot set field "Phone" (of type ) to true (bool)`)
+
+	_, err = exts.Get("instances")
+	assert.ErrorIs(t, err, ErrExtensionNotFound)
+}
@@ -0,0 +1,161 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Severity is the importance of a Finding
+type Severity int
+
+const (
+	// SeverityInfo flags something worth knowing, which is not a problem
+	SeverityInfo Severity = iota
+	// SeverityWarning flags a likely problem, or a bad practice
+	SeverityWarning
+	// SeverityError flags a problem that will cause verification failures
+	SeverityError
+)
+
+var severityToString = map[Severity]string{
+	SeverityInfo:    "info",
+	SeverityWarning: "warning",
+	SeverityError:   "error",
+}
+
+// String returns a printable representation of the Severity
+func (o Severity) String() string {
+	if s, ok := severityToString[o]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("Severity(%d)", int(o))
+}
+
+// MarshalJSON encodes the Severity as its string representation
+func (o Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.String())
+}
+
+// UnmarshalJSON decodes a Severity from its string representation
+func (o *Severity) UnmarshalJSON(data []byte) error {
+	var s string
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	for k, v := range severityToString {
+		if v == s {
+			*o = k
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown severity %q", s)
+}
+
+// Check names. Each Finding carries the name of the check that produced it.
+const (
+	CheckMissingLanguage = "missing-language"
+	CheckMissingEntities = "missing-entities"
+	CheckDigestLength    = "digest-length"
+	CheckWeakDigest      = "weak-digest"
+	CheckDigestConflict  = "digest-conflict"
+	CheckSVNMix          = "svn-mix"
+	CheckExpired         = "expired-validity"
+	CheckNotYetValid     = "not-yet-valid"
+)
+
+// Finding is a problem detected by the Linter. CorimID, TagID and Location
+// identify the offending item, as far as applicable: Location is a path
+// within the tag, e.g. "reference-values[2].digests[0]".
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Check    string   `json:"check"`
+	CorimID  string   `json:"corim-id,omitempty"`
+	TagID    string   `json:"tag-id,omitempty"`
+	Location string   `json:"location,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// String returns a printable representation of the Finding
+func (o Finding) String() string {
+	var where []string
+
+	if o.CorimID != "" {
+		where = append(where, fmt.Sprintf("corim %q", o.CorimID))
+	}
+
+	if o.TagID != "" {
+		where = append(where, fmt.Sprintf("tag %q", o.TagID))
+	}
+
+	if o.Location != "" {
+		where = append(where, o.Location)
+	}
+
+	if len(where) == 0 {
+		return fmt.Sprintf("%s [%s]: %s", o.Severity, o.Check, o.Message)
+	}
+
+	return fmt.Sprintf("%s [%s] %s: %s", o.Severity, o.Check, strings.Join(where, ", "), o.Message)
+}
+
+// Findings is the outcome of linting
+type Findings []Finding
+
+// MaxSeverity returns the highest severity among the findings, and false if
+// there are no findings
+func (o Findings) MaxSeverity() (Severity, bool) {
+	if len(o) == 0 {
+		return 0, false
+	}
+
+	max := o[0].Severity
+	for _, f := range o[1:] {
+		if f.Severity > max {
+			max = f.Severity
+		}
+	}
+
+	return max, true
+}
+
+// AtLeast returns the findings with the supplied severity or a higher one
+func (o Findings) AtLeast(s Severity) Findings {
+	var ret Findings
+
+	for _, f := range o {
+		if f.Severity >= s {
+			ret = append(ret, f)
+		}
+	}
+
+	return ret
+}
+
+// String returns the findings, one per line
+func (o Findings) String() string {
+	var b strings.Builder
+
+	for _, f := range o {
+		b.WriteString(f.String())
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// ToJSON serializes the findings to JSON
+func (o Findings) ToJSON() ([]byte, error) {
+	if o == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal([]Finding(o))
+}
@@ -0,0 +1,412 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package lint
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/corim"
+	"github.com/veraison/swid"
+)
+
+// sha1Len is the length of a SHA-1 digest. SHA-1 has no named information
+// hash algorithm ID, so it is spotted by the length of digests whose algorithm
+// ID has no defined length.
+const sha1Len = 20
+
+// weakAlgorithms are the known hash algorithms considered too weak for
+// reference values
+var weakAlgorithms = map[uint64]bool{
+	swid.Sha256_128: true,
+	swid.Sha256_120: true,
+	swid.Sha256_96:  true,
+	swid.Sha256_64:  true,
+	swid.Sha256_32:  true,
+}
+
+// Linter analyzes CoMIDs and CoRIMs, reporting problems as Findings. Checks
+// run on each tag (missing language or entities, digest lengths and weak
+// algorithms), across all the analyzed tags (conflicting digests and mixed
+// svn/min-svn for the same environment and measurement key), and on the
+// validity of CoRIMs.
+type Linter struct {
+	// Clock returns the current time, used to check validity periods. It
+	// defaults to time.Now.
+	Clock func() time.Time
+}
+
+// New instantiates a Linter that uses the system clock
+func New() *Linter {
+	return &Linter{Clock: time.Now}
+}
+
+type target struct {
+	corimID string
+	comid   *comid.Comid
+}
+
+// Comids lints the supplied CoMIDs
+func (o Linter) Comids(comids ...*comid.Comid) Findings {
+	targets := make([]target, 0, len(comids))
+
+	for _, c := range comids {
+		if c != nil {
+			targets = append(targets, target{comid: c})
+		}
+	}
+
+	return lintTargets(targets)
+}
+
+// Corims lints the CoMIDs contained in the supplied unsigned CoRIMs, along
+// with the validity period of each CoRIM
+func (o Linter) Corims(corims ...*corim.UnsignedCorim) (Findings, error) {
+	var (
+		ret     Findings
+		targets []target
+	)
+
+	for i, uc := range corims {
+		if uc == nil {
+			return nil, fmt.Errorf("nil CoRIM at index %d", i)
+		}
+
+		ret = append(ret, o.checkValidity(uc.GetID(), "rim-validity", uc.RimValidity)...)
+
+		tags, err := uc.DecodeTags()
+		if err != nil {
+			return nil, fmt.Errorf("CoRIM %q: %w", uc.GetID(), err)
+		}
+
+		for _, t := range tags {
+			if t.Comid != nil {
+				targets = append(targets, target{corimID: uc.GetID(), comid: t.Comid})
+			}
+		}
+	}
+
+	return append(ret, lintTargets(targets)...), nil
+}
+
+// SignedCorims lints the supplied signed CoRIMs: the signature validity in
+// their meta, and their unsigned content (see Corims)
+func (o Linter) SignedCorims(corims ...*corim.SignedCorim) (Findings, error) {
+	var (
+		ret      Findings
+		unsigned []*corim.UnsignedCorim
+	)
+
+	for i, sc := range corims {
+		if sc == nil {
+			return nil, fmt.Errorf("nil signed CoRIM at index %d", i)
+		}
+
+		ret = append(ret, o.checkValidity(sc.UnsignedCorim.GetID(), "meta.validity", sc.Meta.Validity)...)
+		unsigned = append(unsigned, &sc.UnsignedCorim)
+	}
+
+	rest, err := o.Corims(unsigned...)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ret, rest...), nil
+}
+
+func (o Linter) now() time.Time {
+	if o.Clock == nil {
+		return time.Now()
+	}
+
+	return o.Clock()
+}
+
+func (o Linter) checkValidity(corimID, location string, v *corim.Validity) Findings {
+	if v == nil {
+		return nil
+	}
+
+	now := o.now()
+
+	var ret Findings
+
+	if now.After(v.NotAfter) {
+		ret = append(ret, Finding{
+			Severity: SeverityError,
+			Check:    CheckExpired,
+			CorimID:  corimID,
+			Location: location,
+			Message:  fmt.Sprintf("expired on %s", v.NotAfter.UTC().Format(time.RFC3339)),
+		})
+	}
+
+	if v.NotBefore != nil && now.Before(*v.NotBefore) {
+		ret = append(ret, Finding{
+			Severity: SeverityWarning,
+			Check:    CheckNotYetValid,
+			CorimID:  corimID,
+			Location: location,
+			Message:  fmt.Sprintf("not valid before %s", v.NotBefore.UTC().Format(time.RFC3339)),
+		})
+	}
+
+	return ret
+}
+
+// measurement is a value triple, along with where it was found
+type measurement struct {
+	target
+	location string
+	key      string
+	val      *comid.Mval
+}
+
+func lintTargets(targets []target) Findings {
+	var (
+		ret  Findings
+		meas []measurement
+	)
+
+	for _, t := range targets {
+		ret = append(ret, checkMetadata(t)...)
+
+		m, findings := collectMeasurements(t)
+		ret = append(ret, findings...)
+		meas = append(meas, m...)
+	}
+
+	ret = append(ret, checkConflicts(meas)...)
+
+	return ret
+}
+
+func (o target) finding(s Severity, check, location, msg string) Finding {
+	return Finding{
+		Severity: s,
+		Check:    check,
+		CorimID:  o.corimID,
+		TagID:    o.comid.TagIdentity.TagID.String(),
+		Location: location,
+		Message:  msg,
+	}
+}
+
+func checkMetadata(t target) Findings {
+	var ret Findings
+
+	if t.comid.Language == nil || *t.comid.Language == "" {
+		ret = append(ret, t.finding(SeverityWarning, CheckMissingLanguage, "lang",
+			"no language specified"))
+	}
+
+	if t.comid.Entities == nil || len(t.comid.Entities.Values) == 0 {
+		ret = append(ret, t.finding(SeverityWarning, CheckMissingEntities, "entities",
+			"no entities specified"))
+	}
+
+	return ret
+}
+
+// collectMeasurements checks the digests of the value triples of the supplied
+// CoMID, and returns the triples for the cross-tag checks
+func collectMeasurements(t target) ([]measurement, Findings) {
+	var (
+		meas []measurement
+		ret  Findings
+	)
+
+	valueTriples := []struct {
+		name    string
+		triples *comid.ValueTriples
+	}{
+		{"reference-values", t.comid.Triples.ReferenceValues},
+		{"endorsed-values", t.comid.Triples.EndorsedValues},
+	}
+
+	for _, vt := range valueTriples {
+		if vt.triples == nil {
+			continue
+		}
+
+		for i := range vt.triples.Values {
+			v := &vt.triples.Values[i]
+			loc := fmt.Sprintf("%s[%d]", vt.name, i)
+
+			ret = append(ret, checkDigests(t, loc, &v.Measurement.Val)...)
+
+			meas = append(meas, measurement{
+				target:   t,
+				location: loc,
+				key:      vt.name + "\x00" + measurementKey(v),
+				val:      &v.Measurement.Val,
+			})
+		}
+	}
+
+	return meas, ret
+}
+
+func measurementKey(v *comid.ValueTriple) string {
+	env, _ := json.Marshal(&v.Environment)
+
+	var mkey []byte
+	if v.Measurement.Key != nil && v.Measurement.Key.IsSet() {
+		mkey, _ = json.Marshal(v.Measurement.Key)
+	}
+
+	return string(env) + "\x00" + string(mkey)
+}
+
+func checkDigests(t target, location string, val *comid.Mval) Findings {
+	var ret Findings
+
+	if val.Digests != nil {
+		for i, d := range *val.Digests {
+			ret = append(ret, checkDigest(t, fmt.Sprintf("%s.digests[%d]", location, i), d)...)
+		}
+	}
+
+	if val.IntegrityRegisters != nil {
+		for _, idx := range sortedRegisters(val.IntegrityRegisters) {
+			for i, d := range val.IntegrityRegisters.IndexMap[idx] {
+				loc := fmt.Sprintf("%s.integrity-registers[%v][%d]", location, idx, i)
+				ret = append(ret, checkDigest(t, loc, d)...)
+			}
+		}
+	}
+
+	return ret
+}
+
+func sortedRegisters(r *comid.IntegrityRegisters) []comid.IRegisterIndex {
+	ret := make([]comid.IRegisterIndex, 0, len(r.IndexMap))
+
+	for k := range r.IndexMap {
+		ret = append(ret, k)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return fmt.Sprint(ret[i]) < fmt.Sprint(ret[j])
+	})
+
+	return ret
+}
+
+// checkDigest reports at most one problem with the supplied digest: a 20-byte
+// value whose algorithm ID has no defined length is likely SHA-1, otherwise a
+// length mismatch takes precedence over a truncated algorithm.
+func checkDigest(t target, location string, d swid.HashEntry) Findings {
+	alg := d.AlgIDToString()
+
+	if _, known := comid.LookupHashAlgorithm(d.HashAlgID); !known && len(d.HashValue) == sha1Len {
+		return Findings{t.finding(SeverityWarning, CheckWeakDigest, location,
+			fmt.Sprintf("%d-byte digest with algorithm %s is likely SHA-1", sha1Len, alg))}
+	}
+
+	if err := comid.ValidHashEntry(d); err != nil {
+		return Findings{t.finding(SeverityError, CheckDigestLength, location, err.Error())}
+	}
+
+	if weakAlgorithms[d.HashAlgID] {
+		return Findings{t.finding(SeverityWarning, CheckWeakDigest, location,
+			fmt.Sprintf("truncated hash algorithm %s", alg))}
+	}
+
+	return nil
+}
+
+// checkConflicts reports value triples with the same triple type, environment
+// and measurement key but different digests for the same algorithm, or with
+// both svn and min-svn. Different versions of the same tag are not compared.
+func checkConflicts(meas []measurement) Findings {
+	var ret Findings
+
+	byKey := make(map[string][]measurement)
+	var order []string
+
+	for _, m := range meas {
+		if _, ok := byKey[m.key]; !ok {
+			order = append(order, m.key)
+		}
+		byKey[m.key] = append(byKey[m.key], m)
+	}
+
+	for _, k := range order {
+		group := byKey[k]
+
+		for i, m := range group {
+			for _, prev := range group[:i] {
+				if sameTagDifferentVersion(prev, m) {
+					continue
+				}
+
+				ret = append(ret, digestConflicts(prev, m)...)
+
+				if f, ok := svnMix(prev, m); ok {
+					ret = append(ret, f)
+				}
+			}
+		}
+	}
+
+	return ret
+}
+
+func sameTagDifferentVersion(a, b measurement) bool {
+	ia, ib := a.comid.TagIdentity, b.comid.TagIdentity
+
+	return ia.SameTag(ib) && ia.TagVersion != ib.TagVersion
+}
+
+func describe(m measurement) string {
+	return fmt.Sprintf("tag %q %s", m.comid.TagIdentity.TagID.String(), m.location)
+}
+
+func digestConflicts(prev, m measurement) Findings {
+	if prev.val.Digests == nil || m.val.Digests == nil {
+		return nil
+	}
+
+	var ret Findings
+
+	for _, d := range *m.val.Digests {
+		for _, p := range *prev.val.Digests {
+			if p.HashAlgID != d.HashAlgID || string(p.HashValue) == string(d.HashValue) {
+				continue
+			}
+
+			ret = append(ret, m.finding(SeverityError, CheckDigestConflict, m.location,
+				fmt.Sprintf("%s digest %s contradicts %s in %s",
+					d.AlgIDToString(), hex.EncodeToString(d.HashValue),
+					hex.EncodeToString(p.HashValue), describe(prev))))
+		}
+	}
+
+	return ret
+}
+
+func svnMix(prev, m measurement) (Finding, bool) {
+	tp, okp := svnType(prev.val)
+	tm, okm := svnType(m.val)
+
+	if !okp || !okm || tp == tm {
+		return Finding{}, false
+	}
+
+	return m.finding(SeverityWarning, CheckSVNMix, m.location,
+		fmt.Sprintf("%s svn mixed with %s svn in %s", tm, tp, describe(prev))), true
+}
+
+func svnType(val *comid.Mval) (string, bool) {
+	if val.SVN == nil || val.SVN.Value == nil {
+		return "", false
+	}
+
+	return val.SVN.Value.Type(), true
+}
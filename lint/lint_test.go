@@ -0,0 +1,200 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package lint
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/corim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/swid"
+)
+
+var testTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func testLinter() *Linter {
+	return &Linter{Clock: func() time.Time { return testTime }}
+}
+
+func psaComid(t *testing.T, tagID string, version uint) *comid.Comid {
+	var c comid.Comid
+	require.NoError(t, c.FromJSON([]byte(comid.PSARefValJSONTemplate)))
+	c.SetTagIdentity(tagID, version)
+	return &c
+}
+
+func checks(fs Findings) []string {
+	var ret []string
+	for _, f := range fs {
+		ret = append(ret, f.Check)
+	}
+	return ret
+}
+
+func TestLinter_Comids_clean(t *testing.T) {
+	fs := testLinter().Comids(psaComid(t, "a", 0))
+	assert.Empty(t, fs)
+
+	_, ok := fs.MaxSeverity()
+	assert.False(t, ok)
+}
+
+func TestLinter_Comids_metadata(t *testing.T) {
+	c := comid.NewComid().SetTagIdentity("bare", 0)
+
+	fs := testLinter().Comids(c)
+	assert.Equal(t, Findings{
+		{Severity: SeverityWarning, Check: CheckMissingLanguage, TagID: "bare", Location: "lang", Message: "no language specified"},
+		{Severity: SeverityWarning, Check: CheckMissingEntities, TagID: "bare", Location: "entities", Message: "no entities specified"},
+	}, fs)
+}
+
+func TestLinter_Comids_digests(t *testing.T) {
+	c := psaComid(t, "weak", 0)
+	refVals := c.Triples.ReferenceValues.Values
+
+	*refVals[0].Measurement.Val.Digests = comid.Digests{
+		{HashAlgID: swid.Sha256_32, HashValue: []byte{1, 2, 3, 4}},
+	}
+	*refVals[1].Measurement.Val.Digests = comid.Digests{
+		{HashAlgID: swid.Sha256, HashValue: make([]byte, 20)},
+		{HashAlgID: 99, HashValue: make([]byte, 20)},
+	}
+	*refVals[2].Measurement.Val.Digests = comid.Digests{
+		{HashAlgID: swid.Sha384, HashValue: make([]byte, 32)},
+	}
+
+	fs := testLinter().Comids(c)
+	require.Len(t, fs, 4)
+
+	// each digest is reported once, and a 20-byte digest is only reported
+	// as likely SHA-1 if its algorithm has no defined length
+
+	assert.Equal(t, Finding{
+		Severity: SeverityWarning,
+		Check:    CheckWeakDigest,
+		TagID:    "weak",
+		Location: "reference-values[0].digests[0]",
+		Message:  "truncated hash algorithm sha-256-32",
+	}, fs[0])
+
+	assert.Equal(t, CheckDigestLength, fs[1].Check)
+	assert.Equal(t, SeverityError, fs[1].Severity)
+	assert.Equal(t, "reference-values[1].digests[0]", fs[1].Location)
+	assert.Equal(t, "length mismatch for hash algorithm sha-256: want 32 bytes, got 20", fs[1].Message)
+
+	assert.Equal(t, CheckWeakDigest, fs[2].Check)
+	assert.Equal(t, SeverityWarning, fs[2].Severity)
+	assert.Equal(t, "reference-values[1].digests[1]", fs[2].Location)
+	assert.Equal(t, "20-byte digest with algorithm alg-id(99) is likely SHA-1", fs[2].Message)
+
+	assert.Equal(t, CheckDigestLength, fs[3].Check)
+	assert.Equal(t, "length mismatch for hash algorithm sha-384: want 48 bytes, got 32", fs[3].Message)
+
+	max, ok := fs.MaxSeverity()
+	assert.True(t, ok)
+	assert.Equal(t, SeverityError, max)
+	assert.Len(t, fs.AtLeast(SeverityError), 2)
+}
+
+func TestLinter_Comids_conflicts(t *testing.T) {
+	a := psaComid(t, "a", 0)
+	b := psaComid(t, "b", 0)
+
+	(*b.Triples.ReferenceValues.Values[0].Measurement.Val.Digests)[0].HashValue = make([]byte, 32)
+
+	a.Triples.ReferenceValues.Values[1].Measurement.Val.SVN = comid.MustNewTaggedSVN(1)
+	b.Triples.ReferenceValues.Values[1].Measurement.Val.SVN = comid.MustNewTaggedMinSVN(1)
+
+	fs := testLinter().Comids(a, b)
+	require.Len(t, fs, 2)
+
+	assert.Equal(t, SeverityError, fs[0].Severity)
+	assert.Equal(t, CheckDigestConflict, fs[0].Check)
+	assert.Equal(t, "b", fs[0].TagID)
+	assert.Equal(t, "reference-values[0]", fs[0].Location)
+	assert.Equal(t, "sha-256 digest "+
+		"0000000000000000000000000000000000000000000000000000000000000000 contradicts "+
+		"87428fc522803d31065e7bce3cf03fe475096631e5e07bbd7a0fde60c4cf25c7 "+
+		`in tag "a" reference-values[0]`, fs[0].Message)
+
+	assert.Equal(t, SeverityWarning, fs[1].Severity)
+	assert.Equal(t, CheckSVNMix, fs[1].Check)
+	assert.Equal(t, `min-value svn mixed with exact-value svn in tag "a" reference-values[1]`, fs[1].Message)
+
+	// different versions of the same tag are not compared
+	a2 := psaComid(t, "a", 1)
+	(*a2.Triples.ReferenceValues.Values[0].Measurement.Val.Digests)[0].HashValue = make([]byte, 32)
+
+	assert.Empty(t, testLinter().Comids(a, a2))
+}
+
+func TestLinter_Corims(t *testing.T) {
+	notBefore := testTime.Add(time.Hour)
+
+	expired := corim.NewUnsignedCorim().SetID("expired").
+		SetRimValidity(testTime.Add(-time.Hour), nil)
+	require.NotNil(t, expired.AddComid(*psaComid(t, "a", 0)))
+
+	future := corim.NewUnsignedCorim().SetID("future").
+		SetRimValidity(testTime.Add(2*time.Hour), &notBefore)
+	require.NotNil(t, future.AddComid(*comid.NewComid().SetTagIdentity("bare", 0).
+		AddReferenceValue(psaComid(t, "x", 0).Triples.ReferenceValues.Values[0])))
+
+	fs, err := testLinter().Corims(expired, future)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		CheckExpired, CheckNotYetValid, CheckMissingLanguage, CheckMissingEntities,
+	}, checks(fs))
+
+	assert.Equal(t, `error [expired-validity] corim "expired", rim-validity: expired on 2024-05-01T11:00:00Z`,
+		fs[0].String())
+	assert.Equal(t, "future", fs[2].CorimID)
+	assert.Equal(t, "bare", fs[2].TagID)
+
+	sc := corim.SignedCorim{UnsignedCorim: *expired}
+	sc.Meta.Validity = &corim.Validity{NotAfter: testTime.Add(-time.Minute)}
+
+	fs, err = testLinter().SignedCorims(&sc)
+	require.NoError(t, err)
+	require.Len(t, fs, 2)
+	assert.Equal(t, "meta.validity", fs[0].Location)
+	assert.Equal(t, "rim-validity", fs[1].Location)
+
+	_, err = testLinter().Corims(nil)
+	assert.EqualError(t, err, "nil CoRIM at index 0")
+}
+
+func TestFindings_JSON(t *testing.T) {
+	fs := testLinter().Comids(comid.NewComid().SetTagIdentity("bare", 0))
+
+	data, err := fs.ToJSON()
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"severity":"warning","check":"missing-language","tag-id":"bare"`)
+
+	var back Findings
+	require.NoError(t, json.Unmarshal(data, &back))
+	assert.Equal(t, fs, back)
+
+	data, err = Findings(nil).ToJSON()
+	require.NoError(t, err)
+	assert.Equal(t, "[]", string(data))
+
+	assert.Equal(t, "warning [missing-language] tag \"bare\", lang: no language specified\n"+
+		"warning [missing-entities] tag \"bare\", entities: no entities specified\n", fs.String())
+
+	var s Severity
+	assert.EqualError(t, json.Unmarshal([]byte(`"fatal"`), &s), `unknown severity "fatal"`)
+}
+
+func TestSeverity_String(t *testing.T) {
+	assert.Equal(t, "info", SeverityInfo.String())
+	assert.Equal(t, "error", SeverityError.String())
+	assert.Equal(t, "Severity(7)", Severity(7).String())
+}
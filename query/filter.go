@@ -0,0 +1,224 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/veraison/swid"
+)
+
+// Filter selects the triples returned by a query. Filters are combined with
+// And, Or and Not.
+type Filter func(r *Result) bool
+
+// And returns a Filter matching the triples matched by all the supplied
+// filters
+func And(filters ...Filter) Filter {
+	return func(r *Result) bool {
+		for _, f := range filters {
+			if !f(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a Filter matching the triples matched by at least one of the
+// supplied filters
+func Or(filters ...Filter) Filter {
+	return func(r *Result) bool {
+		for _, f := range filters {
+			if f(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a Filter matching the triples not matched by f
+func Not(f Filter) Filter {
+	return func(r *Result) bool {
+		return !f(r)
+	}
+}
+
+// TripleTypeIs matches the triples of any of the supplied types
+func TripleTypeIs(types ...TripleType) Filter {
+	return func(r *Result) bool {
+		for _, t := range types {
+			if r.Triple == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// CorimID matches the triples coming from the CoRIM with the supplied
+// corim-id
+func CorimID(id string) Filter {
+	return func(r *Result) bool {
+		return r.CorimID == id
+	}
+}
+
+// TagID matches the triples coming from the CoMID with the supplied tag-id
+func TagID(id string) Filter {
+	return func(r *Result) bool {
+		return r.TagID == id
+	}
+}
+
+// EnvironmentMatches matches the triples whose environment satisfies fn
+func EnvironmentMatches(fn func(env *comid.Environment) bool) Filter {
+	return func(r *Result) bool {
+		return fn(&r.Environment)
+	}
+}
+
+// ClassID matches the triples whose environment has the supplied class-id
+func ClassID(id comid.ClassID) Filter {
+	want := mustJSON(id)
+
+	return EnvironmentMatches(func(env *comid.Environment) bool {
+		return env.Class != nil && env.Class.ClassID != nil &&
+			jsonEqual(env.Class.ClassID, want)
+	})
+}
+
+// Vendor matches the triples whose environment has the supplied class vendor
+func Vendor(vendor string) Filter {
+	return EnvironmentMatches(func(env *comid.Environment) bool {
+		return env.Class != nil && env.Class.Vendor != nil && *env.Class.Vendor == vendor
+	})
+}
+
+// Model matches the triples whose environment has the supplied class model
+func Model(model string) Filter {
+	return EnvironmentMatches(func(env *comid.Environment) bool {
+		return env.Class != nil && env.Class.Model != nil && *env.Class.Model == model
+	})
+}
+
+// Instance matches the triples whose environment has the supplied instance
+func Instance(instance comid.Instance) Filter {
+	want := mustJSON(instance)
+
+	return EnvironmentMatches(func(env *comid.Environment) bool {
+		return env.Instance != nil && jsonEqual(env.Instance, want)
+	})
+}
+
+// Group matches the triples whose environment has the supplied group
+func Group(group comid.Group) Filter {
+	want := mustJSON(group)
+
+	return EnvironmentMatches(func(env *comid.Environment) bool {
+		return env.Group != nil && jsonEqual(env.Group, want)
+	})
+}
+
+// MkeyMatches matches the value triples whose measurement key satisfies fn.
+// fn is called with nil if the measurement has no key.
+func MkeyMatches(fn func(key *comid.Mkey) bool) Filter {
+	return func(r *Result) bool {
+		return r.Measurement != nil && fn(r.Measurement.Key)
+	}
+}
+
+// Mkey matches the value triples with the supplied measurement key
+func Mkey(key comid.Mkey) Filter {
+	want := mustJSON(key)
+
+	return MkeyMatches(func(k *comid.Mkey) bool {
+		return k != nil && jsonEqual(k, want)
+	})
+}
+
+// MvalMatches matches the value triples whose measurement value satisfies fn
+func MvalMatches(fn func(val *comid.Mval) bool) Filter {
+	return func(r *Result) bool {
+		return r.Measurement != nil && fn(&r.Measurement.Val)
+	}
+}
+
+// Flag matches the value triples in which the supplied operational flag is
+// explicitly set to value
+func Flag(flag comid.Flag, value bool) Filter {
+	return MvalMatches(func(val *comid.Mval) bool {
+		if val.Flags == nil {
+			return false
+		}
+
+		v := val.Flags.Get(flag)
+
+		return v != nil && *v == value
+	})
+}
+
+// Digest matches the value triples with a digest equal to the supplied one,
+// either in the digests field or in one of the integrity registers
+func Digest(digest swid.HashEntry) Filter {
+	return MvalMatches(func(val *comid.Mval) bool {
+		if val.Digests != nil && containsDigest(*val.Digests, digest) {
+			return true
+		}
+
+		if val.IntegrityRegisters != nil {
+			for _, d := range val.IntegrityRegisters.IndexMap {
+				if containsDigest(d, digest) {
+					return true
+				}
+			}
+		}
+
+		return false
+	})
+}
+
+func containsDigest(digests comid.Digests, digest swid.HashEntry) bool {
+	for _, d := range digests {
+		if d.HashAlgID == digest.HashAlgID && bytes.Equal(d.HashValue, digest.HashValue) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// VerifKey matches the key triples containing the supplied verification key
+func VerifKey(key comid.CryptoKey) Filter {
+	want := key.String()
+
+	return func(r *Result) bool {
+		for _, k := range r.VerifKeys {
+			if k.String() == want {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// mustJSON returns the JSON encoding of v, used to compare type choices
+// independently of how they were instantiated, or nil if v cannot be encoded
+func mustJSON(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	return data
+}
+
+// jsonEqual returns true if the JSON encoding of v is want. Values that cannot
+// be encoded never match.
+func jsonEqual(v interface{}, want []byte) bool {
+	return want != nil && bytes.Equal(mustJSON(v), want)
+}
@@ -0,0 +1,273 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/corim"
+	"github.com/jraman567/corim/store"
+)
+
+// TripleType identifies the kind of a CoMID triple
+type TripleType int
+
+const (
+	ReferenceValue TripleType = iota + 1
+	EndorsedValue
+	DevIdentityKey
+	AttestVerifKey
+)
+
+var tripleTypeToString = map[TripleType]string{
+	ReferenceValue: "reference-value",
+	EndorsedValue:  "endorsed-value",
+	DevIdentityKey: "dev-identity-key",
+	AttestVerifKey: "attester-verification-key",
+}
+
+// String returns a printable representation of the TripleType
+func (o TripleType) String() string {
+	if s, ok := tripleTypeToString[o]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("TripleType(%d)", int(o))
+}
+
+// MarshalJSON encodes the TripleType as its string representation
+func (o TripleType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.String())
+}
+
+// UnmarshalJSON decodes a TripleType from its string representation
+func (o *TripleType) UnmarshalJSON(data []byte) error {
+	var s string
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	for k, v := range tripleTypeToString {
+		if v == s {
+			*o = k
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown triple type %q", s)
+}
+
+// Provenance locates a triple: the CoRIM and the CoMID it comes from, and its
+// position in the corresponding triples array of the CoMID
+type Provenance struct {
+	CorimID    string     `json:"corim-id"`
+	TagID      string     `json:"tag-id"`
+	TagVersion uint       `json:"tag-version"`
+	Triple     TripleType `json:"triple"`
+	Index      int        `json:"index"`
+}
+
+// String returns a printable representation of the Provenance
+func (o Provenance) String() string {
+	return fmt.Sprintf("corim %q, tag %q version %d, %s[%d]",
+		o.CorimID, o.TagID, o.TagVersion, o.Triple, o.Index)
+}
+
+// Result is a triple matched by a query. Measurement is set for reference and
+// endorsed values, VerifKeys for device identity and attestation keys.
+type Result struct {
+	Provenance
+
+	Environment comid.Environment
+	Measurement *comid.Measurement
+	VerifKeys   comid.CryptoKeys
+}
+
+// Results is the list of triples matched by a query
+type Results []Result
+
+// TagIDs returns the tag-ids of the CoMIDs containing the matched triples,
+// without duplicates, in order of first appearance
+func (o Results) TagIDs() []string {
+	var ret []string
+	seen := make(map[string]bool)
+
+	for _, r := range o {
+		if !seen[r.TagID] {
+			seen[r.TagID] = true
+			ret = append(ret, r.TagID)
+		}
+	}
+
+	return ret
+}
+
+// ISource supplies the CoMIDs to be queried. Walk calls fn with each CoMID
+// and the corim-id of the CoRIM it comes from, stopping at the first error.
+type ISource interface {
+	Walk(fn func(corimID string, c *comid.Comid) error) error
+}
+
+// CorimSource is an ISource over a set of unsigned CoRIMs. CoMIDs are decoded
+// with the extensions of the CoRIM's profile, if any, and with the Registry
+// the CoRIM was decoded with (see corim.UnsignedCorim.IterTags).
+type CorimSource []*corim.UnsignedCorim
+
+// FromCorims returns an ISource over the supplied unsigned CoRIMs
+func FromCorims(corims ...*corim.UnsignedCorim) CorimSource {
+	return CorimSource(corims)
+}
+
+// Walk implements ISource
+func (o CorimSource) Walk(fn func(corimID string, c *comid.Comid) error) error {
+	for i, uc := range o {
+		if uc == nil {
+			return fmt.Errorf("nil CoRIM at index %d", i)
+		}
+
+		it := uc.IterTags()
+		for it.Next() {
+			if c := it.Tag().Comid; c != nil {
+				if err := fn(uc.GetID(), c); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := it.Err(); err != nil {
+			return fmt.Errorf("CoRIM %q: %w", uc.GetID(), err)
+		}
+	}
+
+	return nil
+}
+
+// StoreSource is an ISource over the CoMIDs persisted in a store.Store. All
+// the stored versions of each tag are queried.
+type StoreSource struct {
+	Store *store.Store
+}
+
+// FromStore returns an ISource over the CoMIDs in the supplied store
+func FromStore(s *store.Store) StoreSource {
+	return StoreSource{Store: s}
+}
+
+// Walk implements ISource
+func (o StoreSource) Walk(fn func(corimID string, c *comid.Comid) error) error {
+	if o.Store == nil {
+		return errors.New("nil store")
+	}
+
+	rs, err := o.Store.Records(store.TagTypeComid)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rs {
+		c, err := r.Comid()
+		if err != nil {
+			return fmt.Errorf("stored tag %q version %d: %w", r.TagID, r.TagVersion, err)
+		}
+
+		if err := fn(r.CorimID, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run returns the triples, from all the supplied sources, that match the
+// supplied filter. A nil filter matches all triples.
+func Run(f Filter, sources ...ISource) (Results, error) {
+	var ret Results
+
+	for i, src := range sources {
+		if src == nil {
+			return nil, fmt.Errorf("nil source at index %d", i)
+		}
+
+		err := src.Walk(func(corimID string, c *comid.Comid) error {
+			for _, r := range comidTriples(corimID, c) {
+				if f == nil || f(&r) {
+					ret = append(ret, r)
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("source at index %d: %w", i, err)
+		}
+	}
+
+	return ret, nil
+}
+
+// comidTriples flattens the triples of the supplied CoMID into Results
+func comidTriples(corimID string, c *comid.Comid) []Result {
+	var ret []Result
+
+	prov := func(t TripleType, i int) Provenance {
+		return Provenance{
+			CorimID:    corimID,
+			TagID:      c.TagIdentity.TagID.String(),
+			TagVersion: c.TagIdentity.TagVersion,
+			Triple:     t,
+			Index:      i,
+		}
+	}
+
+	valueTriples := []struct {
+		typ     TripleType
+		triples *comid.ValueTriples
+	}{
+		{ReferenceValue, c.Triples.ReferenceValues},
+		{EndorsedValue, c.Triples.EndorsedValues},
+	}
+
+	for _, vt := range valueTriples {
+		if vt.triples == nil {
+			continue
+		}
+
+		for i := range vt.triples.Values {
+			v := &vt.triples.Values[i]
+			ret = append(ret, Result{
+				Provenance:  prov(vt.typ, i),
+				Environment: v.Environment,
+				Measurement: &v.Measurement,
+			})
+		}
+	}
+
+	keyTriples := []struct {
+		typ     TripleType
+		triples *comid.KeyTriples
+	}{
+		{DevIdentityKey, c.Triples.DevIdentityKeys},
+		{AttestVerifKey, c.Triples.AttestVerifKeys},
+	}
+
+	for _, kt := range keyTriples {
+		if kt.triples == nil {
+			continue
+		}
+
+		for i, k := range kt.triples.Values {
+			ret = append(ret, Result{
+				Provenance:  prov(kt.typ, i),
+				Environment: k.Environment,
+				VerifKeys:   k.VerifKeys,
+			})
+		}
+	}
+
+	return ret
+}
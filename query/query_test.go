@@ -0,0 +1,212 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/corim"
+	"github.com/jraman567/corim/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/swid"
+)
+
+func psaCorim(t *testing.T) *corim.UnsignedCorim {
+	var c comid.Comid
+	require.NoError(t, c.FromJSON([]byte(comid.PSARefValJSONTemplate)))
+
+	uc := corim.NewUnsignedCorim().SetID("psa")
+	require.NotNil(t, uc.AddComid(c))
+
+	return uc
+}
+
+func endorsementsCorim(t *testing.T) *corim.UnsignedCorim {
+	c := comid.NewComid().SetTagIdentity("endorsements", 3)
+
+	instance := comid.MustNewUUIDInstance(comid.TestUUID)
+
+	for _, debug := range []bool{true, false} {
+		m := comid.MustNewUintMeasurement(uint64(1))
+		if debug {
+			m.SetFlagsTrue(comid.FlagIsDebug)
+		} else {
+			m.SetFlagsFalse(comid.FlagIsDebug)
+		}
+
+		require.NotNil(t, c.AddEndorsedValue(comid.ValueTriple{
+			Environment: comid.Environment{Instance: instance},
+			Measurement: *m,
+		}))
+	}
+
+	require.NotNil(t, c.AddAttestVerifKey(comid.KeyTriple{
+		Environment: comid.Environment{Instance: instance},
+		VerifKeys:   *comid.NewCryptoKeys().Add(comid.MustNewPKIXBase64Key(comid.TestECPubKey)),
+	}))
+
+	uc := corim.NewUnsignedCorim().SetID("endorsements")
+	require.NotNil(t, uc.AddComid(*c))
+
+	return uc
+}
+
+func TestRun_reference_values_by_class_id(t *testing.T) {
+	var classID comid.ClassID
+	classID.SetImplID(comid.TestImplID)
+
+	res, err := Run(
+		And(TripleTypeIs(ReferenceValue), ClassID(classID)),
+		FromCorims(psaCorim(t), endorsementsCorim(t)),
+	)
+	require.NoError(t, err)
+	require.Len(t, res, 3)
+
+	for i, r := range res {
+		assert.Equal(t, Provenance{
+			CorimID:    "psa",
+			TagID:      "43bbe37f-2e61-4b33-aed3-53cff1428b16",
+			TagVersion: 0,
+			Triple:     ReferenceValue,
+			Index:      i,
+		}, r.Provenance)
+		require.NotNil(t, r.Measurement)
+	}
+
+	assert.Equal(t, `corim "psa", tag "43bbe37f-2e61-4b33-aed3-53cff1428b16" version 0, reference-value[2]`,
+		res[2].String())
+
+	res, err = Run(And(Vendor("ACME"), Model("RoadRunner")), FromCorims(psaCorim(t)))
+	require.NoError(t, err)
+	assert.Len(t, res, 3)
+
+	res, err = Run(Vendor("EMCA"), FromCorims(psaCorim(t)))
+	require.NoError(t, err)
+	assert.Empty(t, res)
+}
+
+func TestRun_flags_and_keys(t *testing.T) {
+	src := FromCorims(psaCorim(t), endorsementsCorim(t))
+
+	res, err := Run(And(TripleTypeIs(EndorsedValue), Flag(comid.FlagIsDebug, false)), src)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.Equal(t, 1, res[0].Index)
+	assert.Equal(t, "endorsements", res[0].CorimID)
+	assert.Equal(t, uint(3), res[0].TagVersion)
+
+	instance := comid.MustNewUUIDInstance(comid.TestUUID)
+
+	res, err = Run(And(TripleTypeIs(AttestVerifKey), Instance(*instance)), src)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.Nil(t, res[0].Measurement)
+	require.Len(t, res[0].VerifKeys, 1)
+
+	res, err = Run(VerifKey(*comid.MustNewPKIXBase64Key(comid.TestECPubKey)), src)
+	require.NoError(t, err)
+	assert.Len(t, res, 1)
+
+	// everything but the attestation keys
+	res, err = Run(Not(Or(TripleTypeIs(AttestVerifKey), TripleTypeIs(DevIdentityKey))), src)
+	require.NoError(t, err)
+	assert.Len(t, res, 5)
+
+	res, err = Run(nil, src)
+	require.NoError(t, err)
+	assert.Len(t, res, 6)
+}
+
+func TestRun_digest(t *testing.T) {
+	digest, err := swid.ParseHashEntry("sha-256:h0KPxSKAPTEGXnvOPPA/5HUJZjHl4Hu9eg/eYMTPJcc=")
+	require.NoError(t, err)
+
+	res, err := Run(Digest(digest), FromCorims(psaCorim(t), endorsementsCorim(t)))
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.Equal(t, []string{"43bbe37f-2e61-4b33-aed3-53cff1428b16"}, res.TagIDs())
+
+	require.NotNil(t, res[0].Measurement.Key)
+	res, err = Run(Mkey(*res[0].Measurement.Key), FromCorims(psaCorim(t)))
+	require.NoError(t, err)
+	assert.Len(t, res, 1)
+
+	digest.HashValue[0] ^= 0xff
+
+	res, err = Run(Digest(digest), FromCorims(psaCorim(t)))
+	require.NoError(t, err)
+	assert.Empty(t, res)
+}
+
+func TestRun_store(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "corim.db"), nil)
+	require.NoError(t, err)
+	defer s.Close()
+
+	_, err = s.IngestUnsignedCorim(psaCorim(t))
+	require.NoError(t, err)
+
+	_, err = s.IngestUnsignedCorim(endorsementsCorim(t))
+	require.NoError(t, err)
+
+	res, err := Run(And(TripleTypeIs(EndorsedValue), CorimID("endorsements")), FromStore(s))
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, "endorsements", res[0].TagID)
+
+	// stores and CoRIMs can be queried together
+	res, err = Run(TagID("endorsements"), FromStore(s), FromCorims(endorsementsCorim(t)))
+	require.NoError(t, err)
+	assert.Len(t, res, 6)
+	assert.Equal(t, []string{"endorsements"}, res.TagIDs())
+}
+
+func TestRun_fail(t *testing.T) {
+	_, err := Run(nil, nil)
+	assert.EqualError(t, err, "nil source at index 0")
+
+	_, err = Run(nil, FromCorims(nil))
+	assert.EqualError(t, err, "source at index 0: nil CoRIM at index 0")
+
+	_, err = Run(nil, FromStore(nil))
+	assert.EqualError(t, err, "source at index 0: nil store")
+
+	bad := corim.NewUnsignedCorim().SetID("bad")
+	bad.Tags = []corim.Tag{append(append(corim.Tag{}, corim.ComidTag...), 0xff)}
+
+	_, err = Run(nil, FromCorims(bad))
+	assert.ErrorContains(t, err, `source at index 0: CoRIM "bad": tag at index 0: decoding comid`)
+}
+
+func TestTripleType_String(t *testing.T) {
+	assert.Equal(t, "reference-value", ReferenceValue.String())
+	assert.Equal(t, "attester-verification-key", AttestVerifKey.String())
+	assert.Equal(t, "TripleType(42)", TripleType(42).String())
+}
+
+func TestProvenance_JSON(t *testing.T) {
+	p := Provenance{
+		CorimID:    "corim",
+		TagID:      "fw",
+		TagVersion: 1,
+		Triple:     EndorsedValue,
+		Index:      2,
+	}
+
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"corim-id":"corim","tag-id":"fw","tag-version":1,`+
+		`"triple":"endorsed-value","index":2}`, string(data))
+
+	var actual Provenance
+	require.NoError(t, json.Unmarshal(data, &actual))
+	assert.Equal(t, p, actual)
+
+	err = json.Unmarshal([]byte(`{"triple":"bogus"}`), &actual)
+	assert.EqualError(t, err, `unknown triple type "bogus"`)
+}
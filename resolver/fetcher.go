@@ -0,0 +1,194 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// DefaultMaxSize is the maximum size of a fetched RIM, unless otherwise
+// specified
+const DefaultMaxSize = int64(16 * 1024 * 1024)
+
+// ErrNotFound is returned by fetchers when the requested resource does not
+// exist
+var ErrNotFound = errors.New("resource not found")
+
+// IFetcher is implemented by the retrieval back-ends used by the Resolver to
+// obtain the dependent RIMs referenced by a CoRIM. Fetch returns the raw
+// (CBOR-encoded, possibly signed) CoRIM found at the supplied absolute URI.
+type IFetcher interface {
+	Fetch(ctx context.Context, href string) ([]byte, error)
+}
+
+// SchemeFetcher dispatches fetch requests to a fetcher based on the scheme of
+// the requested URI
+type SchemeFetcher map[string]IFetcher
+
+// NewDefaultFetcher returns a SchemeFetcher that handles the file, http and
+// https schemes. Note that the Resolver does not follow file references found
+// in RIMs fetched using other schemes.
+func NewDefaultFetcher() SchemeFetcher {
+	httpFetcher := NewHTTPFetcher()
+
+	return SchemeFetcher{
+		"file":  NewFileFetcher(),
+		"http":  httpFetcher,
+		"https": httpFetcher,
+	}
+}
+
+// Fetch fetches the supplied URI using the fetcher associated with its scheme
+func (o SchemeFetcher) Fetch(ctx context.Context, href string) ([]byte, error) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return nil, fmt.Errorf("parsing href: %w", err)
+	}
+
+	f, ok := o[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("no fetcher for scheme %q", u.Scheme)
+	}
+
+	return f.Fetch(ctx, href)
+}
+
+// FileFetcher fetches RIMs from the local file system using file:// URIs
+type FileFetcher struct {
+	MaxSize int64
+}
+
+// NewFileFetcher instantiates a FileFetcher with the default size limit
+func NewFileFetcher() *FileFetcher {
+	return &FileFetcher{MaxSize: DefaultMaxSize}
+}
+
+// Fetch reads the file identified by the supplied file:// URI
+func (o FileFetcher) Fetch(ctx context.Context, href string) ([]byte, error) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return nil, fmt.Errorf("parsing href: %w", err)
+	}
+
+	if u.Scheme != "file" {
+		return nil, fmt.Errorf("expecting file URI, got %q", href)
+	}
+
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("remote file host %q is not supported", u.Host)
+	}
+
+	f, err := os.Open(u.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, href)
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	return readAtMost(f, o.MaxSize)
+}
+
+// MemoryFetcher serves RIMs from an in-memory map keyed by URI. It is safe for
+// concurrent use.
+type MemoryFetcher struct {
+	mu      sync.RWMutex
+	entries map[string][]byte
+}
+
+// NewMemoryFetcher instantiates an empty MemoryFetcher
+func NewMemoryFetcher() *MemoryFetcher {
+	return &MemoryFetcher{entries: make(map[string][]byte)}
+}
+
+// Add makes the supplied data available at the supplied URI
+func (o *MemoryFetcher) Add(href string, data []byte) *MemoryFetcher {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.entries[href] = data
+
+	return o
+}
+
+// Fetch returns the data stored at the supplied URI
+func (o *MemoryFetcher) Fetch(ctx context.Context, href string) ([]byte, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	data, ok := o.entries[href]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, href)
+	}
+
+	return data, nil
+}
+
+// HTTPFetcher fetches RIMs over HTTP(S)
+type HTTPFetcher struct {
+	Client  *http.Client
+	MaxSize int64
+}
+
+// NewHTTPFetcher instantiates an HTTPFetcher using the default HTTP client and
+// size limit
+func NewHTTPFetcher() *HTTPFetcher {
+	return &HTTPFetcher{Client: http.DefaultClient, MaxSize: DefaultMaxSize}
+}
+
+// Fetch GETs the supplied URI
+func (o HTTPFetcher) Fetch(ctx context.Context, href string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, href, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/rim+cbor, application/rim+cose")
+
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, href)
+	default:
+		return nil, fmt.Errorf("fetching %s: HTTP status %s", href, res.Status)
+	}
+
+	return readAtMost(res.Body, o.MaxSize)
+}
+
+func readAtMost(r io.Reader, maxSize int64) ([]byte, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("resource exceeds maximum size (%d bytes)", maxSize)
+	}
+
+	return data, nil
+}
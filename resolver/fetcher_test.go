@@ -0,0 +1,100 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jraman567/corim/corim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPFetcher_Resolve(t *testing.T) {
+	b := makeRim(t, testRim{id: "b"})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rims/b.cbor", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/rim+cbor, application/rim+cose", r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", "application/rim+cbor")
+		_, _ = w.Write(b)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var a []byte
+
+	mux.HandleFunc("/rims/a.cbor", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(a)
+	})
+
+	a = makeRim(t, testRim{id: "a", deps: []corim.Locator{
+		locator("b.cbor", thumbprintOf(b)),
+	}})
+
+	bundle, err := NewResolver(NewDefaultFetcher()).ResolveHref(
+		context.Background(), srv.URL+"/rims/a.cbor", thumbprintOf(a))
+	require.NoError(t, err)
+
+	require.Len(t, bundle.Rims, 1)
+	assert.Equal(t, srv.URL+"/rims/b.cbor", bundle.Rims[0].Href)
+
+	_, err = NewHTTPFetcher().Fetch(context.Background(), srv.URL+"/rims/missing.cbor")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestHTTPFetcher_Fetch_too_large(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(make([]byte, 11))
+	}))
+	defer srv.Close()
+
+	f := NewHTTPFetcher()
+	f.MaxSize = 10
+
+	_, err := f.Fetch(context.Background(), srv.URL)
+	assert.EqualError(t, err, "resource exceeds maximum size (10 bytes)")
+}
+
+func TestHTTPFetcher_Fetch_server_error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	_, err := NewHTTPFetcher().Fetch(context.Background(), srv.URL)
+	assert.ErrorContains(t, err, "HTTP status 500")
+}
+
+func TestFileFetcher_Fetch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "x.cbor")
+	require.NoError(t, os.WriteFile(path, []byte{0xa0}, 0600))
+
+	f := NewFileFetcher()
+
+	data, err := f.Fetch(context.Background(), "file://"+filepath.ToSlash(path))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xa0}, data)
+
+	_, err = f.Fetch(context.Background(), "file://"+filepath.ToSlash(filepath.Join(dir, "y.cbor")))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = f.Fetch(context.Background(), "file://example.com/x.cbor")
+	assert.EqualError(t, err, `remote file host "example.com" is not supported`)
+
+	_, err = f.Fetch(context.Background(), "https://example.com/x.cbor")
+	assert.EqualError(t, err, `expecting file URI, got "https://example.com/x.cbor"`)
+}
+
+func TestSchemeFetcher_Fetch_unknown_scheme(t *testing.T) {
+	_, err := NewDefaultFetcher().Fetch(context.Background(), "ftp://example.com/x.cbor")
+	assert.EqualError(t, err, `no fetcher for scheme "ftp"`)
+}
@@ -0,0 +1,388 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	cbor "github.com/fxamacker/cbor/v2"
+	"github.com/jraman567/corim/corim"
+	"github.com/jraman567/corim/encoding"
+	"github.com/veraison/swid"
+)
+
+const (
+	// DefaultMaxDepth is the maximum length of a chain of dependent RIMs,
+	// unless otherwise specified
+	DefaultMaxDepth = 8
+	// DefaultMaxRims is the maximum number of dependent RIMs in a bundle,
+	// unless otherwise specified
+	DefaultMaxRims = 256
+)
+
+var (
+	ErrThumbprintMismatch = errors.New("thumbprint mismatch")
+	ErrMissingThumbprint  = errors.New("missing thumbprint")
+	ErrCycle              = errors.New("dependency cycle")
+	ErrMaxDepth           = errors.New("maximum dependency depth exceeded")
+	ErrMaxRims            = errors.New("maximum number of dependent RIMs exceeded")
+	ErrSchemeTransition   = errors.New("disallowed scheme transition")
+)
+
+// Resolver retrieves the dependent RIMs referenced by a CoRIM, verifies them
+// against the thumbprints in the referencing corim-locator-map, and
+// recursively resolves their own dependencies.
+type Resolver struct {
+	Fetcher IFetcher
+	// MaxDepth is the maximum length of a chain of dependent RIMs
+	MaxDepth int
+	// MaxRims is the maximum number of distinct dependent RIMs
+	MaxRims int
+	// RequireThumbprint causes locators without a thumbprint to be
+	// rejected. It is set by NewResolver, and should only be cleared if
+	// all the dependent RIMs come from trusted locations.
+	RequireThumbprint bool
+	// VerifySigned, if set, is invoked on each signed dependent RIM, e.g.,
+	// to verify its signature. An error aborts the resolution.
+	VerifySigned func(href string, sc *corim.SignedCorim) error
+	// DecodeOptions are passed on when decoding the dependent RIMs, e.g., to
+	// set tighter limits (see encoding.WithLimits) or decode strictly
+	DecodeOptions []encoding.DecodeOption
+}
+
+// NewResolver instantiates a Resolver using the supplied fetcher and the
+// default limits. Locators without a thumbprint are rejected.
+func NewResolver(fetcher IFetcher) *Resolver {
+	return &Resolver{
+		Fetcher:           fetcher,
+		MaxDepth:          DefaultMaxDepth,
+		MaxRims:           DefaultMaxRims,
+		RequireThumbprint: true,
+	}
+}
+
+// Rim is a dependent RIM retrieved by the Resolver
+type Rim struct {
+	// Href is the absolute URI the RIM was fetched from
+	Href string
+	// Data is the raw RIM, as fetched
+	Data []byte
+	// Depth is the length of the shortest dependency chain from the root
+	Depth int
+	// Unsigned is the decoded unsigned-corim
+	Unsigned *corim.UnsignedCorim
+	// Signed is the decoded signed-corim, or nil if the RIM is not signed
+	Signed *corim.SignedCorim
+}
+
+// Bundle is the result of resolving the dependency graph of a CoRIM
+type Bundle struct {
+	Root *corim.UnsignedCorim
+	// Rims lists the dependent RIMs in the order in which they have been
+	// first encountered (depth-first)
+	Rims []*Rim
+	// Dependencies maps the href of each RIM in the bundle (the root
+	// having the supplied base href, possibly empty) onto the hrefs of its
+	// direct dependencies
+	Dependencies map[string][]string
+}
+
+// Get returns the dependent RIM fetched from the supplied href
+func (o Bundle) Get(href string) (*Rim, bool) {
+	for _, r := range o.Rims {
+		if r.Href == href {
+			return r, true
+		}
+	}
+
+	return nil, false
+}
+
+// Corims returns the root CoRIM followed by all the dependent RIMs
+func (o Bundle) Corims() []*corim.UnsignedCorim {
+	ret := []*corim.UnsignedCorim{o.Root}
+
+	for _, r := range o.Rims {
+		ret = append(ret, r.Unsigned)
+	}
+
+	return ret
+}
+
+// Resolve resolves the full dependency graph of the supplied CoRIM. Relative
+// hrefs in the root CoRIM are resolved against base, which may be empty if
+// the root CoRIM only uses absolute hrefs. A RIM that has not been read from
+// the local file system cannot reference one that is.
+func (o Resolver) Resolve(ctx context.Context, root *corim.UnsignedCorim, base string) (*Bundle, error) {
+	if root == nil {
+		return nil, errors.New("nil root CoRIM")
+	}
+
+	if o.Fetcher == nil {
+		return nil, errors.New("no fetcher")
+	}
+
+	s := resolution{
+		Resolver: o,
+		bundle: &Bundle{
+			Root:         root,
+			Dependencies: make(map[string][]string),
+		},
+		byHref:   make(map[string]*Rim),
+		visiting: map[string]bool{base: true},
+		ids:      map[string]bool{root.GetID(): true},
+	}
+
+	if err := s.resolveDeps(ctx, root, base, 0); err != nil {
+		return nil, err
+	}
+
+	return s.bundle, nil
+}
+
+// ResolveHref fetches the CoRIM at the supplied href, checking it against the
+// supplied thumbprint if not nil, then resolves its full dependency graph
+func (o Resolver) ResolveHref(
+	ctx context.Context,
+	href string,
+	thumbprint *swid.HashEntry,
+) (*Bundle, error) {
+	if o.Fetcher == nil {
+		return nil, errors.New("no fetcher")
+	}
+
+	// the root is not referenced by a locator, so it can only be checked if
+	// a thumbprint is supplied by the caller
+	fetcher := o
+	fetcher.RequireThumbprint = false
+
+	rim, err := fetcher.fetch(ctx, corim.Locator{Thumbprint: thumbprint}, href)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.Resolve(ctx, rim.Unsigned, href)
+}
+
+type resolution struct {
+	Resolver
+
+	bundle   *Bundle
+	byHref   map[string]*Rim
+	visiting map[string]bool
+	ids      map[string]bool
+}
+
+func (o *resolution) resolveDeps(
+	ctx context.Context,
+	parent *corim.UnsignedCorim,
+	parentHref string,
+	depth int,
+) error {
+	if parent.DependentRims == nil {
+		return nil
+	}
+
+	for i, loc := range parent.DependentRims.Values {
+		href, err := resolveHref(parentHref, string(loc.Href))
+		if err != nil {
+			return fmt.Errorf("dependent RIM at index %d of %q: %w", i, parentHref, err)
+		}
+
+		if err := checkSchemeTransition(parentHref, href); err != nil {
+			return fmt.Errorf("dependent RIM at index %d of %q: %w", i, parentHref, err)
+		}
+
+		o.bundle.Dependencies[parentHref] = append(o.bundle.Dependencies[parentHref], href)
+
+		if o.visiting[href] {
+			return fmt.Errorf("%w: %s references %s", ErrCycle, parentHref, href)
+		}
+
+		if rim, ok := o.byHref[href]; ok {
+			// already resolved via another path: just make sure it
+			// matches this locator too
+			if err := o.checkThumbprint(loc, href, rim.Data); err != nil {
+				return err
+			}
+
+			if depth+1 < rim.Depth {
+				rim.Depth = depth + 1
+			}
+
+			continue
+		}
+
+		if depth+1 > o.maxDepth() {
+			return fmt.Errorf("%w (%d): %s", ErrMaxDepth, o.maxDepth(), href)
+		}
+
+		if len(o.bundle.Rims) >= o.maxRims() {
+			return fmt.Errorf("%w (%d): %s", ErrMaxRims, o.maxRims(), href)
+		}
+
+		rim, err := o.fetch(ctx, loc, href)
+		if err != nil {
+			return err
+		}
+
+		rim.Depth = depth + 1
+
+		id := rim.Unsigned.GetID()
+		if o.ids[id] {
+			return fmt.Errorf("%w: %s contains CoRIM %q, which is already being resolved",
+				ErrCycle, href, id)
+		}
+
+		o.bundle.Rims = append(o.bundle.Rims, rim)
+		o.byHref[href] = rim
+
+		o.visiting[href] = true
+		o.ids[id] = true
+
+		if err := o.resolveDeps(ctx, rim.Unsigned, href, depth+1); err != nil {
+			return err
+		}
+
+		delete(o.visiting, href)
+		delete(o.ids, id)
+	}
+
+	return nil
+}
+
+func (o Resolver) maxDepth() int {
+	if o.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+	return o.MaxDepth
+}
+
+func (o Resolver) maxRims() int {
+	if o.MaxRims <= 0 {
+		return DefaultMaxRims
+	}
+	return o.MaxRims
+}
+
+func (o Resolver) checkThumbprint(loc corim.Locator, href string, data []byte) error {
+	if loc.Thumbprint == nil {
+		if o.RequireThumbprint {
+			return fmt.Errorf("%w: %s", ErrMissingThumbprint, href)
+		}
+		return nil
+	}
+
+	if err := VerifyThumbprint(data, *loc.Thumbprint); err != nil {
+		return fmt.Errorf("verifying %s: %w", href, err)
+	}
+
+	return nil
+}
+
+func (o Resolver) fetch(ctx context.Context, loc corim.Locator, href string) (*Rim, error) {
+	data, err := o.Fetcher.Fetch(ctx, href)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", href, err)
+	}
+
+	if err := o.checkThumbprint(loc, href, data); err != nil {
+		return nil, err
+	}
+
+	rim := Rim{Href: href, Data: data}
+
+	if corim.IsEncrypted(data) {
+		return nil, fmt.Errorf("decoding %s: %w", href, corim.ErrEncryptedCorim)
+	}
+
+	if isSign1(data) {
+		rim.Signed, err = corim.UnmarshalSignedCorimFromCBOR(data, o.DecodeOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("decoding signed CoRIM %s: %w", href, err)
+		}
+
+		if o.VerifySigned != nil {
+			if err := o.VerifySigned(href, rim.Signed); err != nil {
+				return nil, fmt.Errorf("verifying signed CoRIM %s: %w", href, err)
+			}
+		}
+
+		rim.Unsigned = &rim.Signed.UnsignedCorim
+	} else {
+		rim.Unsigned, err = corim.UnmarshalUnsignedCorimFromCBOR(data, o.DecodeOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("decoding unsigned CoRIM %s: %w", href, err)
+		}
+
+		if err := rim.Unsigned.Valid(); err != nil {
+			return nil, fmt.Errorf("validating unsigned CoRIM %s: %w", href, err)
+		}
+	}
+
+	return &rim, nil
+}
+
+func isSign1(data []byte) bool {
+	var tag cbor.RawTag
+
+	if err := cbor.Unmarshal(data, &tag); err != nil {
+		return false
+	}
+
+	return tag.Number == 18
+}
+
+// resolveHref resolves a (possibly relative) href against the href of the
+// referencing RIM
+func resolveHref(base, href string) (string, error) {
+	ref, err := url.Parse(href)
+	if err != nil {
+		return "", err
+	}
+
+	if ref.IsAbs() {
+		return ref.String(), nil
+	}
+
+	if base == "" {
+		return "", fmt.Errorf("cannot resolve relative href %q without a base", href)
+	}
+
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	return b.ResolveReference(ref).String(), nil
+}
+
+// checkSchemeTransition makes sure that a RIM fetched from elsewhere does not
+// point the resolver at the local file system. The root CoRIM, which has no
+// href unless it is fetched by ResolveHref, is supplied by the caller, and so
+// may use any scheme.
+func checkSchemeTransition(parentHref, href string) error {
+	if parentHref == "" {
+		return nil
+	}
+
+	parent, err := url.Parse(parentHref)
+	if err != nil {
+		return err
+	}
+
+	child, err := url.Parse(href)
+	if err != nil {
+		return err
+	}
+
+	if child.Scheme == "file" && parent.Scheme != "file" {
+		return fmt.Errorf("%w: %s RIM references %s", ErrSchemeTransition, parent.Scheme, href)
+	}
+
+	return nil
+}
@@ -0,0 +1,315 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolver
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/corim"
+	"github.com/jraman567/corim/encoding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/swid"
+)
+
+type testRim struct {
+	id   string
+	deps []corim.Locator
+}
+
+// makeRim builds a CBOR-encoded unsigned-corim with the supplied ID and
+// dependent RIMs, reusing the tags of the good unsigned CoRIM test vector
+func makeRim(t *testing.T, r testRim) []byte {
+	data, err := os.ReadFile("../corim/testcases/unsigned-good-corim.cbor")
+	require.NoError(t, err)
+
+	uc, err := corim.UnmarshalUnsignedCorimFromCBOR(data)
+	require.NoError(t, err)
+
+	uc.SetID(r.id)
+
+	for i := range r.deps {
+		if uc.DependentRims == nil {
+			uc.DependentRims = corim.NewLocators()
+		}
+
+		uc.DependentRims.Add(&r.deps[i])
+	}
+
+	out, err := uc.ToCBOR()
+	require.NoError(t, err)
+
+	return out
+}
+
+func thumbprintOf(data []byte) *swid.HashEntry {
+	sum := sha256.Sum256(data)
+	return &swid.HashEntry{HashAlgID: swid.Sha256, HashValue: sum[:]}
+}
+
+func locator(href string, thumbprint *swid.HashEntry) corim.Locator {
+	return corim.Locator{Href: comid.TaggedURI(href), Thumbprint: thumbprint}
+}
+
+func rootWith(t *testing.T, deps ...corim.Locator) *corim.UnsignedCorim {
+	uc, err := corim.UnmarshalUnsignedCorimFromCBOR(makeRim(t, testRim{id: "root", deps: deps}))
+	require.NoError(t, err)
+	return uc
+}
+
+func TestResolver_Resolve_graph(t *testing.T) {
+	// root -> a -> c
+	//      -> b -> c (diamond)
+	c := makeRim(t, testRim{id: "c"})
+	a := makeRim(t, testRim{id: "a", deps: []corim.Locator{
+		locator("mem://rims/c", thumbprintOf(c)),
+	}})
+	b := makeRim(t, testRim{id: "b", deps: []corim.Locator{
+		locator("c", thumbprintOf(c)), // relative to mem://rims/b
+	}})
+
+	f := NewMemoryFetcher().
+		Add("mem://rims/a", a).
+		Add("mem://rims/b", b).
+		Add("mem://rims/c", c)
+
+	root := rootWith(t,
+		locator("mem://rims/a", thumbprintOf(a)),
+		locator("mem://rims/b", thumbprintOf(b)),
+	)
+
+	bundle, err := NewResolver(f).Resolve(context.Background(), root, "")
+	require.NoError(t, err)
+
+	require.Len(t, bundle.Rims, 3)
+	assert.Equal(t, "mem://rims/a", bundle.Rims[0].Href)
+	assert.Equal(t, "mem://rims/c", bundle.Rims[1].Href)
+	assert.Equal(t, "mem://rims/b", bundle.Rims[2].Href)
+
+	rc, ok := bundle.Get("mem://rims/c")
+	require.True(t, ok)
+	assert.Equal(t, 2, rc.Depth)
+	assert.Equal(t, "c", rc.Unsigned.GetID())
+	assert.Nil(t, rc.Signed)
+
+	assert.Equal(t, []string{"mem://rims/a", "mem://rims/b"}, bundle.Dependencies[""])
+	assert.Equal(t, []string{"mem://rims/c"}, bundle.Dependencies["mem://rims/b"])
+
+	ids := []string{}
+	for _, uc := range bundle.Corims() {
+		ids = append(ids, uc.GetID())
+	}
+	assert.Equal(t, []string{"root", "a", "c", "b"}, ids)
+}
+
+func TestResolver_Resolve_thumbprint_mismatch(t *testing.T) {
+	a := makeRim(t, testRim{id: "a"})
+	other := makeRim(t, testRim{id: "other"})
+
+	f := NewMemoryFetcher().Add("mem://a", a)
+
+	root := rootWith(t, locator("mem://a", thumbprintOf(other)))
+
+	_, err := NewResolver(f).Resolve(context.Background(), root, "")
+	assert.ErrorIs(t, err, ErrThumbprintMismatch)
+}
+
+func TestResolver_Resolve_truncated_thumbprint(t *testing.T) {
+	a := makeRim(t, testRim{id: "a"})
+	sum := sha256.Sum256(a)
+
+	f := NewMemoryFetcher().Add("mem://a", a)
+
+	root := rootWith(t, locator("mem://a",
+		&swid.HashEntry{HashAlgID: swid.Sha256_64, HashValue: sum[:8]}))
+
+	_, err := NewResolver(f).Resolve(context.Background(), root, "")
+	assert.NoError(t, err)
+}
+
+func TestResolver_Resolve_require_thumbprint(t *testing.T) {
+	a := makeRim(t, testRim{id: "a"})
+
+	f := NewMemoryFetcher().Add("mem://a", a)
+	root := rootWith(t, locator("mem://a", nil))
+
+	r := NewResolver(f)
+
+	_, err := r.Resolve(context.Background(), root, "")
+	assert.ErrorIs(t, err, ErrMissingThumbprint)
+
+	r.RequireThumbprint = false
+
+	_, err = r.Resolve(context.Background(), root, "")
+	assert.NoError(t, err)
+}
+
+func TestResolver_Resolve_cycle(t *testing.T) {
+	// a -> b -> a: thumbprints cannot be used in a cycle
+	a := makeRim(t, testRim{id: "a", deps: []corim.Locator{locator("mem://b", nil)}})
+	b := makeRim(t, testRim{id: "b", deps: []corim.Locator{locator("mem://a", nil)}})
+
+	f := NewMemoryFetcher().Add("mem://a", a).Add("mem://b", b)
+
+	r := NewResolver(f)
+	r.RequireThumbprint = false
+
+	_, err := r.ResolveHref(context.Background(), "mem://a", nil)
+	assert.ErrorIs(t, err, ErrCycle)
+	assert.EqualError(t, err, "dependency cycle: mem://b references mem://a")
+}
+
+func TestResolver_Resolve_cycle_by_id(t *testing.T) {
+	// the same CoRIM available at two different hrefs
+	a := makeRim(t, testRim{id: "a", deps: []corim.Locator{locator("mem://mirror/a", nil)}})
+
+	f := NewMemoryFetcher().Add("mem://a", a).Add("mem://mirror/a", a)
+
+	r := NewResolver(f)
+	r.RequireThumbprint = false
+
+	_, err := r.ResolveHref(context.Background(), "mem://a", nil)
+	assert.ErrorIs(t, err, ErrCycle)
+}
+
+func TestResolver_Resolve_max_depth(t *testing.T) {
+	f := NewMemoryFetcher()
+
+	r3 := makeRim(t, testRim{id: "3"})
+	r2 := makeRim(t, testRim{id: "2", deps: []corim.Locator{locator("mem://3", thumbprintOf(r3))}})
+	r1 := makeRim(t, testRim{id: "1", deps: []corim.Locator{locator("mem://2", thumbprintOf(r2))}})
+
+	f.Add("mem://3", r3).Add("mem://2", r2).Add("mem://1", r1)
+
+	root := rootWith(t, locator("mem://1", thumbprintOf(r1)))
+
+	r := NewResolver(f)
+	r.MaxDepth = 2
+
+	_, err := r.Resolve(context.Background(), root, "")
+	assert.ErrorIs(t, err, ErrMaxDepth)
+
+	r.MaxDepth = 3
+
+	bundle, err := r.Resolve(context.Background(), root, "")
+	require.NoError(t, err)
+	assert.Len(t, bundle.Rims, 3)
+
+	r.MaxRims = 2
+
+	_, err = r.Resolve(context.Background(), root, "")
+	assert.ErrorIs(t, err, ErrMaxRims)
+}
+
+func TestResolver_Resolve_decode_options(t *testing.T) {
+	r1 := makeRim(t, testRim{id: "1"})
+	f := NewMemoryFetcher().Add("mem://1", r1)
+	root := rootWith(t, locator("mem://1", thumbprintOf(r1)))
+
+	r := NewResolver(f)
+	r.DecodeOptions = []encoding.DecodeOption{
+		encoding.WithLimits(encoding.Limits{MaxInputSize: 64}),
+	}
+
+	_, err := r.Resolve(context.Background(), root, "")
+	assert.ErrorIs(t, err, encoding.ErrLimitExceeded)
+	assert.ErrorContains(t, err, "decoding unsigned CoRIM mem://1")
+
+	r.DecodeOptions = []encoding.DecodeOption{
+		encoding.WithLimits(encoding.Limits{MaxInputSize: len(r1)}),
+	}
+
+	bundle, err := r.Resolve(context.Background(), root, "")
+	require.NoError(t, err)
+	assert.Len(t, bundle.Rims, 1)
+}
+
+func TestResolver_Resolve_not_found(t *testing.T) {
+	root := rootWith(t, locator("mem://missing", nil))
+
+	_, err := NewResolver(NewMemoryFetcher()).Resolve(context.Background(), root, "")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestResolver_Resolve_relative_without_base(t *testing.T) {
+	root := rootWith(t, locator("a.cbor", nil))
+
+	_, err := NewResolver(NewMemoryFetcher()).Resolve(context.Background(), root, "")
+	assert.EqualError(t, err,
+		`dependent RIM at index 0 of "": cannot resolve relative href "a.cbor" without a base`)
+}
+
+func TestResolver_Resolve_signed(t *testing.T) {
+	signed, err := os.ReadFile("../corim/testcases/signed-good-corim.cbor")
+	require.NoError(t, err)
+
+	f := NewMemoryFetcher().Add("mem://signed", signed)
+	root := rootWith(t, locator("mem://signed", thumbprintOf(signed)))
+
+	r := NewResolver(f)
+
+	var verified []string
+	r.VerifySigned = func(href string, sc *corim.SignedCorim) error {
+		verified = append(verified, href)
+		return nil
+	}
+
+	bundle, err := r.Resolve(context.Background(), root, "")
+	require.NoError(t, err)
+	require.Len(t, bundle.Rims, 1)
+	assert.NotNil(t, bundle.Rims[0].Signed)
+	assert.Equal(t, []string{"mem://signed"}, verified)
+}
+
+func TestResolver_ResolveHref_file(t *testing.T) {
+	dir := t.TempDir()
+
+	b := makeRim(t, testRim{id: "b"})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.cbor"), b, 0600))
+
+	a := makeRim(t, testRim{id: "a", deps: []corim.Locator{
+		locator("b.cbor", thumbprintOf(b)),
+	}})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.cbor"), a, 0600))
+
+	href := "file://" + filepath.ToSlash(filepath.Join(dir, "a.cbor"))
+
+	bundle, err := NewResolver(NewDefaultFetcher()).ResolveHref(
+		context.Background(), href, thumbprintOf(a))
+	require.NoError(t, err)
+
+	assert.Equal(t, "a", bundle.Root.GetID())
+	require.Len(t, bundle.Rims, 1)
+	assert.Equal(t, "b", bundle.Rims[0].Unsigned.GetID())
+}
+
+func TestResolver_Resolve_scheme_transition(t *testing.T) {
+	dir := t.TempDir()
+
+	b := makeRim(t, testRim{id: "b"})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.cbor"), b, 0600))
+
+	href := "file://" + filepath.ToSlash(filepath.Join(dir, "b.cbor"))
+	a := makeRim(t, testRim{id: "a", deps: []corim.Locator{locator(href, thumbprintOf(b))}})
+
+	f := SchemeFetcher{
+		"file": NewFileFetcher(),
+		"mem":  NewMemoryFetcher().Add("mem://a", a),
+	}
+
+	_, err := NewResolver(f).ResolveHref(context.Background(), "mem://a", thumbprintOf(a))
+	assert.ErrorIs(t, err, ErrSchemeTransition)
+	assert.EqualError(t, err, `dependent RIM at index 0 of "mem://a": disallowed scheme transition: mem RIM references `+href)
+
+	// the root CoRIM is supplied by the caller, and so may reference local
+	// files
+	bundle, err := NewResolver(f).Resolve(context.Background(), rootWith(t, locator(href, thumbprintOf(b))), "")
+	require.NoError(t, err)
+	require.Len(t, bundle.Rims, 1)
+}
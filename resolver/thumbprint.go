@@ -0,0 +1,29 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolver
+
+import (
+	"fmt"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/veraison/swid"
+)
+
+// VerifyThumbprint checks that the supplied data matches the supplied
+// thumbprint. The thumbprint algorithm must be in the comid hash algorithm
+// registry (see comid.RegisterHashAlgorithm).
+func VerifyThumbprint(data []byte, thumbprint swid.HashEntry) error {
+	ok, err := comid.MatchHashEntry(thumbprint, data)
+	if err != nil {
+		return fmt.Errorf("invalid thumbprint: %w", err)
+	}
+
+	if !ok {
+		alg, _ := comid.LookupHashAlgorithm(thumbprint.HashAlgID)
+		return fmt.Errorf("%w: expected %x, got %x",
+			ErrThumbprintMismatch, thumbprint.HashValue, alg.Sum(data))
+	}
+
+	return nil
+}
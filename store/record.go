@@ -0,0 +1,219 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/corim"
+	"github.com/jraman567/corim/cots"
+	"github.com/veraison/eat"
+	"github.com/veraison/swid"
+)
+
+// TagType identifies the kind of a stored tag
+type TagType uint8
+
+const (
+	TagTypeComid TagType = iota + 1
+	TagTypeCoswid
+	TagTypeCots
+)
+
+var tagTypeToString = map[TagType]string{
+	TagTypeComid:  "comid",
+	TagTypeCoswid: "coswid",
+	TagTypeCots:   "cots",
+}
+
+// String returns a printable representation of the TagType
+func (o TagType) String() string {
+	if s, ok := tagTypeToString[o]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("TagType(%d)", uint8(o))
+}
+
+// Valid checks that the TagType is one of the known types
+func (o TagType) Valid() error {
+	if _, ok := tagTypeToString[o]; !ok {
+		return fmt.Errorf("unknown tag type %d", uint8(o))
+	}
+
+	return nil
+}
+
+// Record is a tag persisted in the Store, along with the provenance
+// information collected when the enclosing CoRIM was ingested
+type Record struct {
+	Type       TagType `cbor:"0,keyasint"`
+	TagID      string  `cbor:"1,keyasint"`
+	TagVersion uint    `cbor:"2,keyasint"`
+	// Data is the tag as it appears in the tags array of the CoRIM,
+	// including the CBOR tag
+	Data []byte `cbor:"3,keyasint"`
+	// CorimID is the corim-id of the CoRIM the tag was ingested from
+	CorimID string `cbor:"4,keyasint,omitempty"`
+	// Signer is the name of the signer of the CoRIM the tag was ingested
+	// from, or empty if the CoRIM was unsigned
+	Signer string `cbor:"5,keyasint,omitempty"`
+	// Profile is the profile (URI or OID) of the CoRIM the tag was
+	// ingested from, if any
+	Profile    string    `cbor:"6,keyasint,omitempty"`
+	IngestedAt time.Time `cbor:"7,keyasint"`
+}
+
+// TagIdentity returns the tag-id and tag-version of the record
+func (o Record) TagIdentity() comid.TagIdentity {
+	ret := comid.TagIdentity{TagVersion: o.TagVersion}
+
+	if id := swid.NewTagID(o.TagID); id != nil {
+		ret.TagID = *id
+	}
+
+	return ret
+}
+
+// Comid decodes the record as a CoMID, applying the extensions registered
+// for the record's profile, if any
+func (o Record) Comid() (*comid.Comid, error) {
+	if o.Type != TagTypeComid {
+		return nil, fmt.Errorf("record is a %s, not a comid", o.Type)
+	}
+
+	profile, err := o.eatProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	return corim.UnmarshalComidFromCBOR(o.Data[len(corim.ComidTag):], profile)
+}
+
+func (o Record) eatProfile() (*eat.Profile, error) {
+	if o.Profile == "" {
+		return nil, nil
+	}
+
+	p, err := eat.NewProfile(o.Profile)
+	if err != nil {
+		return nil, fmt.Errorf("decoding profile: %w", err)
+	}
+
+	return p, nil
+}
+
+// Coswid decodes the record as a CoSWID
+func (o Record) Coswid() (*swid.SoftwareIdentity, error) {
+	if o.Type != TagTypeCoswid {
+		return nil, fmt.Errorf("record is a %s, not a coswid", o.Type)
+	}
+
+	var ret swid.SoftwareIdentity
+
+	if err := ret.FromCBOR(o.Data[len(corim.CoswidTag):]); err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// Cots decodes the record as a CoTS
+func (o Record) Cots() (*cots.ConciseTaStore, error) {
+	if o.Type != TagTypeCots {
+		return nil, fmt.Errorf("record is a %s, not a cots", o.Type)
+	}
+
+	var ret cots.ConciseTaStore
+
+	if err := ret.FromCBOR(o.Data[len(cots.CotsTag):]); err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// entry is a tag extracted from a CoRIM, along with the values it is indexed
+// by
+type entry struct {
+	Record
+
+	environments []comid.Environment
+}
+
+// decodeTag decodes the supplied tag, applying the extensions registered for
+// profile to CoMIDs
+func decodeTag(tag corim.Tag, profile *eat.Profile) (*entry, error) {
+	t, err := corim.DecodeTag(tag, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := t.TagIdentity()
+
+	switch {
+	case t.Kind == corim.TagKindUnknown:
+		return nil, errors.New("unknown tag type")
+	case t.Cots != nil && !ok:
+		return nil, errors.New("cots without tag-identity cannot be stored")
+	case t.Coswid != nil && !ok:
+		return nil, fmt.Errorf("negative coswid tag-version %d", t.Coswid.TagVersion)
+	}
+
+	e := entry{
+		Record: Record{
+			TagID:      id.TagID.String(),
+			TagVersion: id.TagVersion,
+		},
+	}
+
+	switch t.Kind {
+	case corim.TagKindComid:
+		e.Type = TagTypeComid
+		e.environments = comidEnvironments(t.Comid)
+	case corim.TagKindCoswid:
+		e.Type = TagTypeCoswid
+	case corim.TagKindCots:
+		e.Type = TagTypeCots
+
+		for _, eg := range t.Cots.Environments {
+			if eg.Environment != nil {
+				e.environments = append(e.environments, *eg.Environment)
+			}
+		}
+	}
+
+	return &e, nil
+}
+
+func comidEnvironments(c *comid.Comid) []comid.Environment {
+	var ret []comid.Environment
+
+	t := c.Triples
+
+	for _, vts := range []*comid.ValueTriples{t.ReferenceValues, t.EndorsedValues} {
+		if vts == nil {
+			continue
+		}
+
+		for _, vt := range vts.Values {
+			ret = append(ret, vt.Environment)
+		}
+	}
+
+	for _, kts := range []*comid.KeyTriples{t.DevIdentityKeys, t.AttestVerifKeys} {
+		if kts == nil {
+			continue
+		}
+
+		for _, kt := range kts.Values {
+			ret = append(ret, kt.Environment)
+		}
+	}
+
+	return ret
+}
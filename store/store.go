@@ -0,0 +1,664 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	cbor "github.com/fxamacker/cbor/v2"
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/corim"
+	"github.com/jraman567/corim/encoding"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrNotFound is returned when the requested tag is not in the Store
+var ErrNotFound = errors.New("tag not found")
+
+var (
+	bucketTags = []byte("tags")
+
+	bucketByClassID  = []byte("idx-class-id")
+	bucketByInstance = []byte("idx-instance")
+	bucketByGroup    = []byte("idx-group")
+	bucketBySigner   = []byte("idx-signer")
+	bucketByProfile  = []byte("idx-profile")
+
+	allBuckets = [][]byte{
+		bucketTags,
+		bucketByClassID,
+		bucketByInstance,
+		bucketByGroup,
+		bucketBySigner,
+		bucketByProfile,
+	}
+)
+
+var recordEncMode, _ = cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+
+// Options configures how a Store is opened
+type Options struct {
+	// ReadOnly opens the Store in read-only mode, allowing several
+	// processes to share it
+	ReadOnly bool
+	// Timeout is the amount of time to wait for the file lock held by
+	// another process. Zero means wait indefinitely.
+	Timeout time.Duration
+	// EnforceUpgrades applies the tag upgrade rule (see
+	// comid.CheckTagUpgrade) on ingestion: a tag older than the latest
+	// stored version of the same tag, or with the same tag-version but
+	// different content, aborts the ingestion, while a later version
+	// replaces all the stored ones.
+	EnforceUpgrades bool
+}
+
+// Store persists the CoMID, CoSWID and CoTS tags of ingested CoRIMs in an
+// embedded on-disk database, indexed by tag-id and tag-version, environment
+// (class-id, instance and group), signer and profile.
+//
+// Unless Options.EnforceUpgrades is set, all the versions of a tag are kept:
+// Latest returns the most recent one, and PruneVersions completes an upgrade
+// by dropping the older ones.
+//
+// A Store is safe for concurrent use. Lookups run in read-only transactions
+// and do not block each other; ingestion and deletion are serialized.
+type Store struct {
+	db              *bolt.DB
+	enforceUpgrades bool
+	// Clock returns the time recorded as Record.IngestedAt. It defaults to
+	// time.Now.
+	Clock func() time.Time
+}
+
+// Open opens the Store at the supplied path, creating it if needed. opts may
+// be nil.
+func Open(path string, opts *Options) (*Store, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{
+		ReadOnly: opts.ReadOnly,
+		Timeout:  opts.Timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("opening store %s: %w", path, err)
+	}
+
+	if !opts.ReadOnly {
+		err = db.Update(func(tx *bolt.Tx) error {
+			for _, b := range allBuckets {
+				if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("initializing store %s: %w", path, err)
+		}
+	}
+
+	return &Store{db: db, enforceUpgrades: opts.EnforceUpgrades, Clock: time.Now}, nil
+}
+
+// Close releases the underlying database
+func (o *Store) Close() error {
+	return o.db.Close()
+}
+
+// Ingest decodes the supplied CBOR-encoded signed or unsigned CoRIM and stores
+// its tags. The decoding options, e.g., encoding.WithLimits, are passed on to
+// the CoRIM decoder. The signature of a signed CoRIM is not verified: callers
+// must do so before ingesting untrusted data.
+func (o *Store) Ingest(data []byte, opts ...encoding.DecodeOption) ([]Record, error) {
+	if corim.IsEncrypted(data) {
+		return nil, corim.ErrEncryptedCorim
+	}
+
+	var tag cbor.RawTag
+
+	if err := cbor.Unmarshal(data, &tag); err == nil && tag.Number == 18 {
+		sc, err := corim.UnmarshalSignedCorimFromCBOR(data, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("decoding signed CoRIM: %w", err)
+		}
+
+		return o.IngestSignedCorim(sc)
+	}
+
+	uc, err := corim.UnmarshalUnsignedCorimFromCBOR(data, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("decoding unsigned CoRIM: %w", err)
+	}
+
+	return o.IngestUnsignedCorim(uc)
+}
+
+// IngestSignedCorim stores the tags of the supplied signed CoRIM, recording
+// the name of its signer. The signature is not verified.
+func (o *Store) IngestSignedCorim(sc *corim.SignedCorim) ([]Record, error) {
+	if sc == nil {
+		return nil, errors.New("nil signed CoRIM")
+	}
+
+	return o.ingest(&sc.UnsignedCorim, sc.Meta.Signer.Name)
+}
+
+// IngestUnsignedCorim stores the tags of the supplied unsigned CoRIM. Either
+// all tags are stored, or none is. A tag with the same type, tag-id and
+// tag-version as a stored one replaces it, unless Options.EnforceUpgrades is
+// set and their content differs.
+func (o *Store) IngestUnsignedCorim(uc *corim.UnsignedCorim) ([]Record, error) {
+	if uc == nil {
+		return nil, errors.New("nil unsigned CoRIM")
+	}
+
+	return o.ingest(uc, "")
+}
+
+func (o *Store) ingest(uc *corim.UnsignedCorim, signer string) ([]Record, error) {
+	if err := uc.Valid(); err != nil {
+		return nil, fmt.Errorf("validating CoRIM: %w", err)
+	}
+
+	var profile string
+
+	if uc.Profile != nil {
+		p, err := uc.Profile.Get()
+		if err != nil {
+			return nil, fmt.Errorf("decoding profile: %w", err)
+		}
+		profile = p
+	}
+
+	now := o.now()
+
+	entries := make([]*entry, 0, len(uc.Tags))
+
+	for i, t := range uc.Tags {
+		e, err := decodeTag(t, uc.Profile)
+		if err != nil {
+			return nil, fmt.Errorf("tag at index %d: %w", i, err)
+		}
+
+		e.Data = append([]byte(nil), t...)
+		e.CorimID = uc.GetID()
+		e.Signer = signer
+		e.Profile = profile
+		e.IngestedAt = now
+
+		entries = append(entries, e)
+	}
+
+	err := o.db.Update(func(tx *bolt.Tx) error {
+		for _, e := range entries {
+			if o.enforceUpgrades {
+				if err := upgrade(tx, e); err != nil {
+					return fmt.Errorf("%s %q: %w", e.Type, e.TagID, err)
+				}
+			}
+
+			if err := put(tx, e); err != nil {
+				return fmt.Errorf("storing %s %q version %d: %w",
+					e.Type, e.TagID, e.TagVersion, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]Record, 0, len(entries))
+	for _, e := range entries {
+		ret = append(ret, e.Record)
+	}
+
+	return ret, nil
+}
+
+// Get returns the stored tag with the supplied type, tag-id and tag-version
+func (o *Store) Get(typ TagType, tagID string, version uint) (*Record, error) {
+	var ret *Record
+
+	err := o.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketTags).Get(tagKey(typ, tagID, version))
+		if data == nil {
+			return ErrNotFound
+		}
+
+		r, err := decodeRecord(data)
+		ret = r
+
+		return err
+	})
+
+	return ret, err
+}
+
+// Versions returns all the stored versions of the tag with the supplied type
+// and tag-id, in ascending tag-version order
+func (o *Store) Versions(typ TagType, tagID string) ([]Record, error) {
+	var ret []Record
+
+	err := o.db.View(func(tx *bolt.Tx) error {
+		var err error
+		ret, err = versions(tx, typ, tagID)
+		return err
+	})
+
+	return ret, err
+}
+
+// Records returns all the stored tags of the supplied type, ordered by tag-id
+// and tag-version
+func (o *Store) Records(typ TagType) ([]Record, error) {
+	if err := typ.Valid(); err != nil {
+		return nil, err
+	}
+
+	var ret []Record
+
+	err := o.db.View(func(tx *bolt.Tx) error {
+		prefix := []byte{byte(typ)}
+		c := tx.Bucket(bucketTags).Cursor()
+
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			r, err := decodeRecord(v)
+			if err != nil {
+				return err
+			}
+
+			ret = append(ret, *r)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// keys are sorted by tag-id length first, so the order has to be fixed
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].TagID != ret[j].TagID {
+			return ret[i].TagID < ret[j].TagID
+		}
+
+		return ret[i].TagVersion < ret[j].TagVersion
+	})
+
+	return ret, nil
+}
+
+// Latest returns the stored tag with the supplied type and tag-id that has the
+// highest tag-version
+func (o *Store) Latest(typ TagType, tagID string) (*Record, error) {
+	rs, err := o.Versions(typ, tagID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &rs[len(rs)-1], nil
+}
+
+// FindByClassID returns the stored tags with at least one environment matching
+// the supplied class-id
+func (o *Store) FindByClassID(classID comid.ClassID) ([]Record, error) {
+	k, err := classID.MarshalCBOR()
+	if err != nil {
+		return nil, fmt.Errorf("encoding class-id: %w", err)
+	}
+
+	return o.find(bucketByClassID, k)
+}
+
+// FindByInstance returns the stored tags with at least one environment
+// matching the supplied instance
+func (o *Store) FindByInstance(instance comid.Instance) ([]Record, error) {
+	k, err := instance.MarshalCBOR()
+	if err != nil {
+		return nil, fmt.Errorf("encoding instance: %w", err)
+	}
+
+	return o.find(bucketByInstance, k)
+}
+
+// FindByGroup returns the stored tags with at least one environment matching
+// the supplied group
+func (o *Store) FindByGroup(group comid.Group) ([]Record, error) {
+	k, err := group.MarshalCBOR()
+	if err != nil {
+		return nil, fmt.Errorf("encoding group: %w", err)
+	}
+
+	return o.find(bucketByGroup, k)
+}
+
+// FindBySigner returns the stored tags ingested from CoRIMs signed by the
+// supplied signer name
+func (o *Store) FindBySigner(name string) ([]Record, error) {
+	return o.find(bucketBySigner, []byte(name))
+}
+
+// FindByProfile returns the stored tags ingested from CoRIMs with the supplied
+// profile (URI or OID)
+func (o *Store) FindByProfile(profile string) ([]Record, error) {
+	return o.find(bucketByProfile, []byte(profile))
+}
+
+// Delete removes the tag with the supplied type, tag-id and tag-version
+func (o *Store) Delete(typ TagType, tagID string, version uint) error {
+	return o.db.Update(func(tx *bolt.Tx) error {
+		found, err := remove(tx, tagKey(typ, tagID, version))
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+// DeleteAll removes all the versions of the tag with the supplied type and
+// tag-id, returning the number of removed versions
+func (o *Store) DeleteAll(typ TagType, tagID string) (int, error) {
+	return o.deleteVersions(typ, tagID, false)
+}
+
+// PruneVersions completes the upgrade of a tag by removing all its versions
+// but the latest one, returning the number of removed versions
+func (o *Store) PruneVersions(typ TagType, tagID string) (int, error) {
+	return o.deleteVersions(typ, tagID, true)
+}
+
+func (o *Store) deleteVersions(typ TagType, tagID string, keepLatest bool) (int, error) {
+	var n int
+
+	err := o.db.Update(func(tx *bolt.Tx) error {
+		rs, err := versions(tx, typ, tagID)
+		if err != nil {
+			return err
+		}
+
+		if keepLatest && len(rs) > 0 {
+			rs = rs[:len(rs)-1]
+		}
+
+		for _, r := range rs {
+			if _, err := remove(tx, tagKey(r.Type, r.TagID, r.TagVersion)); err != nil {
+				return err
+			}
+			n++
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+func (o *Store) find(bucket []byte, value []byte) ([]Record, error) {
+	var ret []Record
+
+	err := o.db.View(func(tx *bolt.Tx) error {
+		prefix := lengthPrefixed(value)
+		tags := tx.Bucket(bucketTags)
+
+		c := tx.Bucket(bucket).Cursor()
+
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			data := tags.Get(k[len(prefix):])
+			if data == nil {
+				return fmt.Errorf("dangling index entry in %s", bucket)
+			}
+
+			r, err := decodeRecord(data)
+			if err != nil {
+				return err
+			}
+
+			ret = append(ret, *r)
+		}
+
+		return nil
+	})
+
+	return ret, err
+}
+
+func (o *Store) now() time.Time {
+	if o.Clock == nil {
+		return time.Now().UTC()
+	}
+	return o.Clock().UTC()
+}
+
+func versions(tx *bolt.Tx, typ TagType, tagID string) ([]Record, error) {
+	var ret []Record
+
+	prefix := tagPrefix(typ, tagID)
+	c := tx.Bucket(bucketTags).Cursor()
+
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		r, err := decodeRecord(v)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, *r)
+	}
+
+	return ret, nil
+}
+
+// upgrade applies the tag upgrade rule to the supplied entry, removing the
+// stored versions it replaces
+func upgrade(tx *bolt.Tx, e *entry) error {
+	rs, err := versions(tx, e.Type, e.TagID)
+	if err != nil {
+		return err
+	}
+
+	if len(rs) == 0 {
+		return nil
+	}
+
+	latest := rs[len(rs)-1]
+	known := latest.TagIdentity()
+
+	u, err := comid.CheckTagUpgrade(&known, latest.Data, e.TagIdentity(), e.Data)
+	if err != nil {
+		return err
+	}
+
+	if u == comid.TagUpgradeReplace {
+		for _, r := range rs {
+			if _, err := remove(tx, tagKey(r.Type, r.TagID, r.TagVersion)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// put stores the supplied entry and its index entries, replacing any tag with
+// the same key
+func put(tx *bolt.Tx, e *entry) error {
+	if err := e.Type.Valid(); err != nil {
+		return err
+	}
+
+	key := tagKey(e.Type, e.TagID, e.TagVersion)
+
+	if _, err := remove(tx, key); err != nil {
+		return err
+	}
+
+	data, err := recordEncMode.Marshal(e.Record)
+	if err != nil {
+		return fmt.Errorf("encoding record: %w", err)
+	}
+
+	if err := tx.Bucket(bucketTags).Put(key, data); err != nil {
+		return err
+	}
+
+	idx, err := indexKeys(e)
+	if err != nil {
+		return err
+	}
+
+	for bucket, values := range idx {
+		b := tx.Bucket([]byte(bucket))
+
+		for _, v := range values {
+			if err := b.Put(append(lengthPrefixed(v), key...), nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// remove deletes the tag with the supplied key and its index entries,
+// reporting whether the tag was found
+func remove(tx *bolt.Tx, key []byte) (bool, error) {
+	tags := tx.Bucket(bucketTags)
+
+	data := tags.Get(key)
+	if data == nil {
+		return false, nil
+	}
+
+	r, err := decodeRecord(data)
+	if err != nil {
+		return false, err
+	}
+
+	e := entry{Record: *r}
+
+	if e.Type == TagTypeComid || e.Type == TagTypeCots {
+		// environments are not persisted in the record, recover them
+		// from the tag so that the relevant index entries can be found
+		profile, err := r.eatProfile()
+		if err != nil {
+			return false, err
+		}
+
+		d, err := decodeTag(r.Data, profile)
+		if err != nil {
+			return false, fmt.Errorf("decoding stored tag: %w", err)
+		}
+		e.environments = d.environments
+	}
+
+	idx, err := indexKeys(&e)
+	if err != nil {
+		return false, err
+	}
+
+	for bucket, values := range idx {
+		b := tx.Bucket([]byte(bucket))
+
+		for _, v := range values {
+			if err := b.Delete(append(lengthPrefixed(v), key...)); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return true, tags.Delete(key)
+}
+
+// indexKeys returns the values the supplied entry is indexed by, keyed by
+// index bucket name
+func indexKeys(e *entry) (map[string][][]byte, error) {
+	ret := make(map[string][][]byte)
+
+	add := func(bucket []byte, v []byte) {
+		for _, x := range ret[string(bucket)] {
+			if bytes.Equal(x, v) {
+				return
+			}
+		}
+		ret[string(bucket)] = append(ret[string(bucket)], v)
+	}
+
+	for _, env := range e.environments {
+		if env.Class != nil && env.Class.ClassID != nil {
+			v, err := env.Class.ClassID.MarshalCBOR()
+			if err != nil {
+				return nil, fmt.Errorf("encoding class-id: %w", err)
+			}
+			add(bucketByClassID, v)
+		}
+
+		if env.Instance != nil {
+			v, err := env.Instance.MarshalCBOR()
+			if err != nil {
+				return nil, fmt.Errorf("encoding instance: %w", err)
+			}
+			add(bucketByInstance, v)
+		}
+
+		if env.Group != nil {
+			v, err := env.Group.MarshalCBOR()
+			if err != nil {
+				return nil, fmt.Errorf("encoding group: %w", err)
+			}
+			add(bucketByGroup, v)
+		}
+	}
+
+	if e.Signer != "" {
+		add(bucketBySigner, []byte(e.Signer))
+	}
+
+	if e.Profile != "" {
+		add(bucketByProfile, []byte(e.Profile))
+	}
+
+	return ret, nil
+}
+
+func decodeRecord(data []byte) (*Record, error) {
+	var r Record
+
+	if err := cbor.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("decoding record: %w", err)
+	}
+
+	return &r, nil
+}
+
+// tagKey returns the key of a tag in the tags bucket: the tag type, the
+// length-prefixed tag-id and the big-endian tag-version, so that the versions
+// of a tag are contiguous and sorted
+func tagKey(typ TagType, tagID string, version uint) []byte {
+	return binary.BigEndian.AppendUint64(tagPrefix(typ, tagID), uint64(version))
+}
+
+func tagPrefix(typ TagType, tagID string) []byte {
+	return append([]byte{byte(typ)}, lengthPrefixed([]byte(tagID))...)
+}
+
+func lengthPrefixed(v []byte) []byte {
+	return append(binary.AppendUvarint(nil, uint64(len(v))), v...)
+}
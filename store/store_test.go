@@ -0,0 +1,474 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jraman567/corim/comid"
+	"github.com/jraman567/corim/corim"
+	"github.com/jraman567/corim/cots"
+	"github.com/jraman567/corim/encoding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/swid"
+)
+
+var testTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func openTestStore(t *testing.T) *Store {
+	s, err := Open(filepath.Join(t.TempDir(), "corim.db"), nil)
+	require.NoError(t, err)
+
+	s.Clock = func() time.Time { return testTime }
+
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func testEnvironment(t *testing.T, class *comid.Class, instance string, group bool) comid.Environment {
+	env := comid.Environment{Class: class}
+
+	if instance != "" {
+		i, err := comid.NewInstance(comid.MustHexDecode(t, instance), "ueid")
+		require.NoError(t, err)
+		env.Instance = i
+	}
+
+	if group {
+		g, err := comid.NewGroup(comid.TestUUID, "uuid")
+		require.NoError(t, err)
+		env.Group = g
+	}
+
+	return env
+}
+
+func testComid(t *testing.T, tagID string, version uint, svn uint64, envs ...comid.Environment) *comid.Comid {
+	c := comid.NewComid().SetTagIdentity(tagID, version)
+
+	for _, env := range envs {
+		m, err := comid.NewMeasurement(comid.TestMKey, "uint")
+		require.NoError(t, err)
+		m.SetSVN(svn)
+
+		require.NotNil(t, c.AddReferenceValue(comid.ValueTriple{
+			Environment: env,
+			Measurement: *m,
+		}))
+	}
+
+	return c
+}
+
+func testCorim(id string, comids ...*comid.Comid) *corim.UnsignedCorim {
+	uc := corim.NewUnsignedCorim().SetID(id)
+
+	for _, c := range comids {
+		uc.AddComid(*c)
+	}
+
+	return uc
+}
+
+func TestStore_Ingest_unsigned(t *testing.T) {
+	data, err := os.ReadFile("../corim/testcases/unsigned-good-corim.cbor")
+	require.NoError(t, err)
+
+	s := openTestStore(t)
+
+	rs, err := s.Ingest(data)
+	require.NoError(t, err)
+	require.Len(t, rs, 1)
+
+	assert.Equal(t, TagTypeComid, rs[0].Type)
+	assert.Equal(t, "43bbe37f-2e61-4b33-aed3-53cff1428b16", rs[0].TagID)
+	assert.Equal(t, "test corim id", rs[0].CorimID)
+	assert.Empty(t, rs[0].Signer)
+	assert.Equal(t, testTime, rs[0].IngestedAt)
+
+	r, err := s.Get(TagTypeComid, "43bbe37f-2e61-4b33-aed3-53cff1428b16", 0)
+	require.NoError(t, err)
+	assert.Equal(t, rs[0], *r)
+
+	c, err := r.Comid()
+	require.NoError(t, err)
+	assert.Equal(t, "43bbe37f-2e61-4b33-aed3-53cff1428b16", c.TagIdentity.TagID.String())
+
+	_, err = r.Cots()
+	assert.EqualError(t, err, "record is a comid, not a cots")
+
+	found, err := s.FindByClassID(*c.Triples.ReferenceValues.Values[0].Environment.Class.ClassID)
+	require.NoError(t, err)
+	assert.Equal(t, rs, found)
+}
+
+func TestStore_Ingest_decode_options(t *testing.T) {
+	data, err := os.ReadFile("../corim/testcases/unsigned-good-corim.cbor")
+	require.NoError(t, err)
+
+	s := openTestStore(t)
+
+	_, err = s.Ingest(data, encoding.WithLimits(encoding.Limits{MaxInputSize: 64}))
+	assert.ErrorIs(t, err, encoding.ErrLimitExceeded)
+	assert.ErrorContains(t, err, "decoding unsigned CoRIM")
+
+	rs, err := s.Records(TagTypeComid)
+	require.NoError(t, err)
+	assert.Empty(t, rs)
+
+	rs, err = s.Ingest(data, encoding.WithLimits(encoding.Limits{MaxInputSize: len(data)}))
+	require.NoError(t, err)
+	assert.Len(t, rs, 1)
+}
+
+func TestStore_Ingest_signed(t *testing.T) {
+	data, err := os.ReadFile("../corim/testcases/signed-good-corim.cbor")
+	require.NoError(t, err)
+
+	s := openTestStore(t)
+
+	rs, err := s.Ingest(data)
+	require.NoError(t, err)
+	require.Len(t, rs, 1)
+	assert.Equal(t, "ACME Ltd signing key", rs[0].Signer)
+
+	found, err := s.FindBySigner("ACME Ltd signing key")
+	require.NoError(t, err)
+	assert.Equal(t, rs, found)
+
+	found, err = s.FindBySigner("someone else")
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+func TestStore_FindByEnvironment(t *testing.T) {
+	s := openTestStore(t)
+
+	implClass := comid.NewClassImplID(comid.TestImplID)
+	uuidClass := comid.NewClassUUID(comid.TestUUID)
+
+	a := testComid(t, "a", 0, 1, testEnvironment(t, implClass, "02deadbeefdead", false))
+	b := testComid(t, "b", 0, 1,
+		testEnvironment(t, implClass, "", true),
+		testEnvironment(t, uuidClass, "", false),
+	)
+
+	uc := testCorim("corim-1", a, b).SetProfile("http://example.com/profile")
+	require.NotNil(t, uc)
+
+	_, err := s.IngestUnsignedCorim(uc)
+	require.NoError(t, err)
+
+	ids := func(rs []Record, err error) []string {
+		require.NoError(t, err)
+
+		ret := []string{}
+		for _, r := range rs {
+			ret = append(ret, r.TagID)
+		}
+		return ret
+	}
+
+	assert.Equal(t, []string{"a", "b"}, ids(s.FindByClassID(*implClass.ClassID)))
+	assert.Equal(t, []string{"b"}, ids(s.FindByClassID(*uuidClass.ClassID)))
+
+	instance, err := comid.NewInstance(comid.MustHexDecode(t, "02deadbeefdead"), "ueid")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, ids(s.FindByInstance(*instance)))
+
+	group, err := comid.NewGroup(comid.TestUUID, "uuid")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, ids(s.FindByGroup(*group)))
+
+	assert.Equal(t, []string{"a", "b"}, ids(s.FindByProfile("http://example.com/profile")))
+
+	// deleting a tag removes its index entries
+	require.NoError(t, s.Delete(TagTypeComid, "b", 0))
+
+	assert.Equal(t, []string{"a"}, ids(s.FindByClassID(*implClass.ClassID)))
+	assert.Empty(t, ids(s.FindByClassID(*uuidClass.ClassID)))
+	assert.Empty(t, ids(s.FindByGroup(*group)))
+	assert.Equal(t, []string{"a"}, ids(s.FindByProfile("http://example.com/profile")))
+
+	assert.ErrorIs(t, s.Delete(TagTypeComid, "b", 0), ErrNotFound)
+}
+
+func TestStore_versions(t *testing.T) {
+	s := openTestStore(t)
+
+	class := comid.NewClassImplID(comid.TestImplID)
+	env := testEnvironment(t, class, "", false)
+
+	for _, v := range []uint{2, 0, 10} {
+		_, err := s.IngestUnsignedCorim(testCorim("corim", testComid(t, "fw", v, uint64(v), env)))
+		require.NoError(t, err)
+	}
+
+	rs, err := s.Versions(TagTypeComid, "fw")
+	require.NoError(t, err)
+	require.Len(t, rs, 3)
+	assert.Equal(t, uint(0), rs[0].TagVersion)
+	assert.Equal(t, uint(2), rs[1].TagVersion)
+	assert.Equal(t, uint(10), rs[2].TagVersion)
+
+	latest, err := s.Latest(TagTypeComid, "fw")
+	require.NoError(t, err)
+	assert.Equal(t, uint(10), latest.TagVersion)
+
+	found, err := s.FindByClassID(*class.ClassID)
+	require.NoError(t, err)
+	assert.Len(t, found, 3)
+
+	n, err := s.PruneVersions(TagTypeComid, "fw")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	found, err = s.FindByClassID(*class.ClassID)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, uint(10), found[0].TagVersion)
+
+	_, err = s.Get(TagTypeComid, "fw", 2)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	n, err = s.DeleteAll(TagTypeComid, "fw")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = s.Latest(TagTypeComid, "fw")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestStore_Records(t *testing.T) {
+	s := openTestStore(t)
+
+	env := testEnvironment(t, comid.NewClassImplID(comid.TestImplID), "", false)
+
+	_, err := s.IngestUnsignedCorim(testCorim("corim",
+		testComid(t, "os", 0, 1, env),
+		testComid(t, "fw", 1, 1, env),
+		testComid(t, "fw", 0, 1, env),
+		testComid(t, "bootloader", 0, 1, env),
+	))
+	require.NoError(t, err)
+
+	rs, err := s.Records(TagTypeComid)
+	require.NoError(t, err)
+	require.Len(t, rs, 4)
+	assert.Equal(t, "bootloader", rs[0].TagID)
+	assert.Equal(t, "fw", rs[1].TagID)
+	assert.Equal(t, uint(0), rs[1].TagVersion)
+	assert.Equal(t, "fw", rs[2].TagID)
+	assert.Equal(t, uint(1), rs[2].TagVersion)
+	assert.Equal(t, "os", rs[3].TagID)
+
+	rs, err = s.Records(TagTypeCots)
+	require.NoError(t, err)
+	assert.Empty(t, rs)
+
+	_, err = s.Records(TagType(0))
+	assert.EqualError(t, err, "unknown tag type 0")
+}
+
+func TestStore_Ingest_replaces_same_version(t *testing.T) {
+	s := openTestStore(t)
+
+	implClass := comid.NewClassImplID(comid.TestImplID)
+	uuidClass := comid.NewClassUUID(comid.TestUUID)
+
+	_, err := s.IngestUnsignedCorim(testCorim("first",
+		testComid(t, "fw", 1, 1, testEnvironment(t, implClass, "", false))))
+	require.NoError(t, err)
+
+	_, err = s.IngestUnsignedCorim(testCorim("second",
+		testComid(t, "fw", 1, 1, testEnvironment(t, uuidClass, "", false))))
+	require.NoError(t, err)
+
+	r, err := s.Get(TagTypeComid, "fw", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "second", r.CorimID)
+
+	found, err := s.FindByClassID(*implClass.ClassID)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+func TestStore_Ingest_enforce_upgrades(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "corim.db"), &Options{EnforceUpgrades: true})
+	require.NoError(t, err)
+	defer s.Close()
+
+	env := testEnvironment(t, comid.NewClassImplID(comid.TestImplID), "", false)
+
+	ingest := func(version uint, svn uint64) error {
+		_, err := s.IngestUnsignedCorim(testCorim("corim", testComid(t, "fw", version, svn, env)))
+		return err
+	}
+
+	require.NoError(t, ingest(1, 1))
+	require.NoError(t, ingest(2, 2))
+
+	// a later version replaces the stored ones
+	rs, err := s.Versions(TagTypeComid, "fw")
+	require.NoError(t, err)
+	require.Len(t, rs, 1)
+	assert.Equal(t, uint(2), rs[0].TagVersion)
+
+	// re-provisioning the same tag is accepted
+	assert.NoError(t, ingest(2, 2))
+
+	err = ingest(1, 1)
+	assert.ErrorIs(t, err, comid.ErrTagVersionRollback)
+	assert.EqualError(t, err,
+		`comid "fw": tag-version rollback: "fw" version 1 is older than known version 2`)
+
+	assert.ErrorIs(t, ingest(2, 3), comid.ErrTagVersionConflict)
+
+	latest, err := s.Latest(TagTypeComid, "fw")
+	require.NoError(t, err)
+
+	c, err := latest.Comid()
+	require.NoError(t, err)
+	assert.Equal(t, comid.TaggedSVN(2), *c.Triples.ReferenceValues.Values[0].Measurement.Val.SVN.Value.(*comid.TaggedSVN))
+}
+
+func TestStore_Ingest_coswid_and_cots(t *testing.T) {
+	s := openTestStore(t)
+
+	sw, err := swid.NewTag("sw-tag", "Acme Firmware", "1.0")
+	require.NoError(t, err)
+	sw.TagVersion = 3
+
+	e := swid.Entity{EntityName: "Acme"}
+	require.NoError(t, e.SetRoles(swid.RoleTagCreator))
+	require.NoError(t, sw.AddEntity(e))
+
+	version := uint(1)
+	class := comid.NewClassImplID(comid.TestImplID)
+
+	ts := cots.NewConciseTaStore().SetTagIdentity("ta-store", &version)
+	ts.AddEnvironmentGroup(cots.EnvironmentGroup{
+		Environment: &comid.Environment{Class: class},
+	})
+	ts.SetKeys(*cots.NewTasAndCas().AddTaCert(comid.MustHexDecode(t, "3082")))
+
+	uc := corim.NewUnsignedCorim().SetID("mixed").AddCoswid(*sw)
+	require.NotNil(t, uc)
+
+	cotsCBOR, err := ts.ToCBOR()
+	require.NoError(t, err)
+	uc.Tags = append(uc.Tags, append(append([]byte(nil), cots.CotsTag...), cotsCBOR...))
+
+	rs, err := s.IngestUnsignedCorim(uc)
+	require.NoError(t, err)
+	require.Len(t, rs, 2)
+
+	r, err := s.Latest(TagTypeCoswid, "sw-tag")
+	require.NoError(t, err)
+	assert.Equal(t, uint(3), r.TagVersion)
+
+	decoded, err := r.Coswid()
+	require.NoError(t, err)
+	assert.Equal(t, "Acme Firmware", decoded.SoftwareName)
+
+	found, err := s.FindByClassID(*class.ClassID)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, TagTypeCots, found[0].Type)
+	assert.Equal(t, "ta-store", found[0].TagID)
+
+	decodedCots, err := found[0].Cots()
+	require.NoError(t, err)
+	assert.Equal(t, "ta-store", decodedCots.TagIdentity.TagID.String())
+}
+
+func TestStore_Ingest_fail(t *testing.T) {
+	s := openTestStore(t)
+
+	_, err := s.IngestUnsignedCorim(nil)
+	assert.EqualError(t, err, "nil unsigned CoRIM")
+
+	_, err = s.IngestSignedCorim(nil)
+	assert.EqualError(t, err, "nil signed CoRIM")
+
+	_, err = s.IngestUnsignedCorim(corim.NewUnsignedCorim().SetID("empty"))
+	assert.EqualError(t, err, "validating CoRIM: tags validation failed: no tags")
+
+	_, err = s.Ingest([]byte{0xff})
+	assert.ErrorContains(t, err, "decoding unsigned CoRIM")
+
+	// the valid tag is not stored if another one fails
+	uc := testCorim("corim", testComid(t, "fw", 0, 1,
+		testEnvironment(t, comid.NewClassImplID(comid.TestImplID), "", false)))
+	uc.Tags = append(uc.Tags, append(append([]byte(nil), cots.CotsTag...), 0xa0))
+
+	_, err = s.IngestUnsignedCorim(uc)
+	assert.ErrorContains(t, err, "tag at index 1")
+
+	_, err = s.Latest(TagTypeComid, "fw")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestStore_reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corim.db")
+
+	s, err := Open(path, nil)
+	require.NoError(t, err)
+
+	_, err = s.IngestUnsignedCorim(testCorim("corim", testComid(t, "fw", 1, 1,
+		testEnvironment(t, comid.NewClassImplID(comid.TestImplID), "", false))))
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	s, err = Open(path, &Options{ReadOnly: true, Timeout: time.Second})
+	require.NoError(t, err)
+	defer s.Close()
+
+	r, err := s.Latest(TagTypeComid, "fw")
+	require.NoError(t, err)
+	assert.Equal(t, "corim", r.CorimID)
+}
+
+func TestStore_concurrent_readers(t *testing.T) {
+	s := openTestStore(t)
+
+	class := comid.NewClassImplID(comid.TestImplID)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+
+		go func(v uint) {
+			defer wg.Done()
+			_, err := s.IngestUnsignedCorim(testCorim("corim", testComid(t, "fw", v, 1,
+				testEnvironment(t, class, "", false))))
+			assert.NoError(t, err)
+		}(uint(i))
+
+		go func() {
+			defer wg.Done()
+			_, err := s.FindByClassID(*class.ClassID)
+			assert.NoError(t, err)
+		}()
+	}
+
+	wg.Wait()
+
+	rs, err := s.Versions(TagTypeComid, "fw")
+	require.NoError(t, err)
+	assert.Len(t, rs, 8)
+}
+
+func TestTagType_String(t *testing.T) {
+	assert.Equal(t, "comid", TagTypeComid.String())
+	assert.Equal(t, "TagType(9)", TagType(9).String())
+	assert.EqualError(t, TagType(9).Valid(), "unknown tag type 9")
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package validation
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Report collects the problems found when validating in collect-all mode
+// (e.g. with comid.Comid.ValidateAll), rather than stopping at the first one
+// as Valid() does. Errors make the validated object invalid. Warnings flag
+// things that are allowed but likely unintended, e.g. fields not known to the
+// decoder, and do not affect validity.
+type Report struct {
+	Errors   []*Error `json:"errors,omitempty"`
+	Warnings []*Error `json:"warnings,omitempty"`
+}
+
+// NewReport instantiates an empty Report
+func NewReport() *Report {
+	return &Report{}
+}
+
+// Add records err, if not nil, as an error found in the field (or array
+// element) identified by segment
+func (o *Report) Add(segment string, err error) {
+	if err == nil {
+		return
+	}
+
+	o.Errors = append(o.Errors, build(CodeInvalid, segment, err))
+}
+
+// Warn records a warning for the field (or array element) identified by
+// segment. format and args are handled as by fmt.Errorf.
+func (o *Report) Warn(segment string, code Code, format string, args ...any) {
+	o.Warnings = append(o.Warnings, build(code, segment, fmt.Errorf(format, args...)))
+}
+
+// Merge appends the errors and warnings of other to the target Report,
+// prefixing their paths with segment
+func (o *Report) Merge(segment string, other *Report) {
+	if other == nil {
+		return
+	}
+
+	for _, e := range other.Errors {
+		o.Errors = append(o.Errors, e.under(segment))
+	}
+
+	for _, w := range other.Warnings {
+		o.Warnings = append(o.Warnings, w.under(segment))
+	}
+}
+
+// HasErrors returns true if the Report contains at least one error
+func (o *Report) HasErrors() bool {
+	return o != nil && len(o.Errors) > 0
+}
+
+// Err returns nil if the Report contains no errors, or all its errors joined
+// otherwise (see errors.Join). Warnings are not included.
+func (o *Report) Err() error {
+	if !o.HasErrors() {
+		return nil
+	}
+
+	errs := make([]error, 0, len(o.Errors))
+	for _, e := range o.Errors {
+		errs = append(errs, e)
+	}
+
+	return errors.Join(errs...)
+}
+
+// under returns a copy of the target Error with segment prepended to its path
+func (o *Error) under(segment string) *Error {
+	ret := *o
+	ret.Path = JoinPath(segment, o.Path)
+
+	return &ret
+}
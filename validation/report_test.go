@@ -0,0 +1,58 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	errSentinel := errors.New("sentinel")
+
+	inner := NewReport()
+	inner.Add("digests", NewAt(Index(0), CodeEmpty, "empty digest"))
+	inner.Add("version", nil)
+	inner.Warn("flags", CodeUnknown, "unknown field %q", "x")
+
+	r := NewReport()
+	assert.False(t, r.HasErrors())
+	assert.NoError(t, r.Err())
+
+	r.Add("tag-identity", errSentinel)
+	r.Merge(JoinPath("reference-values", Index(1)), inner)
+	r.Merge("ignored", nil)
+
+	require.Len(t, r.Errors, 2)
+	assert.Equal(t, "tag-identity", r.Errors[0].Path)
+	assert.Equal(t, CodeInvalid, r.Errors[0].Code)
+	assert.Equal(t, "reference-values[1].digests[0]", r.Errors[1].Path)
+	assert.Equal(t, CodeEmpty, r.Errors[1].Code)
+
+	// merging does not alter the merged report
+	assert.Equal(t, "digests[0]", inner.Errors[0].Path)
+
+	require.Len(t, r.Warnings, 1)
+	assert.Equal(t, "reference-values[1].flags", r.Warnings[0].Path)
+
+	err := r.Err()
+	assert.EqualError(t, err, "sentinel\nempty digest")
+	assert.ErrorIs(t, err, errSentinel)
+
+	data, err := json.Marshal(r)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"errors": [
+			{"path": "tag-identity", "code": "invalid", "message": "sentinel"},
+			{"path": "reference-values[1].digests[0]", "code": "empty", "message": "empty digest"}
+		],
+		"warnings": [
+			{"path": "reference-values[1].flags", "code": "unknown", "message": "unknown field \"x\""}
+		]
+	}`, string(data))
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package validation provides the structured error type returned by the
+// Valid() methods of the comid, corim and cots packages.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Code classifies a validation Error
+type Code string
+
+const (
+	// CodeInvalid is used for values that are present but not valid, and for
+	// any problem not covered by a more specific code
+	CodeInvalid Code = "invalid"
+	// CodeMissing is used for mandatory fields that are absent
+	CodeMissing Code = "missing"
+	// CodeEmpty is used for fields, collections and strings that must not be
+	// empty
+	CodeEmpty Code = "empty"
+	// CodeUnknown is used for values outside the set of known ones, e.g. an
+	// unregistered role or type
+	CodeUnknown Code = "unknown"
+	// CodeConstraint is used for violations of the constraints added by a
+	// profile (see extensions.IMapValue constrainers)
+	CodeConstraint Code = "constraint"
+)
+
+// Error is the error returned by the Valid() methods. It locates the
+// offending field with Path, e.g.
+//
+//	tags[2].triples.reference-values[5].measurements[0].value.digests
+//
+// whose segments are the JSON names of the fields, with array indexes in
+// square brackets. Code and Message describe the problem found at Path.
+//
+// The string returned by Error() is the full chain of messages added by each
+// enclosing Valid(), as returned before Error was introduced, and errors.Is
+// and errors.As see through Error to the errors it wraps.
+type Error struct {
+	Path    string `json:"path"`
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+	Err     error  `json:"-"`
+}
+
+// Error returns the full error message
+func (o *Error) Error() string {
+	if o.Err != nil {
+		return o.Err.Error()
+	}
+
+	return o.Message
+}
+
+// Unwrap returns the wrapped error, if any
+func (o *Error) Unwrap() error {
+	return o.Err
+}
+
+// New returns an *Error with the supplied Code and an empty Path. format and
+// args are handled as by fmt.Errorf. If they wrap an *Error, its Path, Code
+// and Message are kept.
+func New(code Code, format string, args ...any) error {
+	return build(code, "", fmt.Errorf(format, args...))
+}
+
+// NewAt is like New, for a problem found in the field (or array element)
+// identified by segment
+func NewAt(segment string, code Code, format string, args ...any) error {
+	return build(code, segment, fmt.Errorf(format, args...))
+}
+
+// Errorf returns an *Error for a problem found in the field (or array
+// element) identified by segment. format and args are handled as by
+// fmt.Errorf. If they wrap an *Error, its Path is appended to segment and its
+// Code and Message are kept; otherwise the Code is CodeInvalid.
+func Errorf(segment string, format string, args ...any) error {
+	return build(CodeInvalid, segment, fmt.Errorf(format, args...))
+}
+
+// Wrap returns err as an *Error, leaving its message untouched. nil and
+// *Error values are returned as they are.
+func Wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+
+	return build(CodeInvalid, "", err)
+}
+
+func build(code Code, segment string, err error) *Error {
+	ret := Error{Path: segment, Code: code, Message: err.Error(), Err: err}
+
+	var inner *Error
+	if errors.As(err, &inner) {
+		ret.Path = JoinPath(segment, inner.Path)
+		ret.Code = inner.Code
+		ret.Message = inner.Message
+	}
+
+	return &ret
+}
+
+// Index returns the path segment for the array element at index i
+func Index(i int) string {
+	return fmt.Sprintf("[%d]", i)
+}
+
+// JoinPath joins path segments, separating field names with dots. Empty
+// segments are skipped.
+func JoinPath(segments ...string) string {
+	var b strings.Builder
+
+	for _, s := range segments {
+		if s == "" {
+			continue
+		}
+
+		if b.Len() > 0 && !strings.HasPrefix(s, "[") {
+			b.WriteByte('.')
+		}
+
+		b.WriteString(s)
+	}
+
+	return b.String()
+}
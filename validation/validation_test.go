@@ -0,0 +1,70 @@
+// Copyright 2024 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestError_nested(t *testing.T) {
+	errSentinel := errors.New("sentinel")
+
+	leaf := NewAt("digests", CodeEmpty, "no digests: %w", errSentinel)
+	err := Errorf("value", "measurement: %w", leaf)
+	err = Errorf(Index(5), "error at index %d: %w", 5, err)
+	err = Errorf("reference-values", "reference values: %w", err)
+
+	assert.EqualError(t, err, "reference values: error at index 5: measurement: no digests: sentinel")
+	assert.ErrorIs(t, err, errSentinel)
+
+	var vErr *Error
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "reference-values[5].value.digests", vErr.Path)
+	assert.Equal(t, CodeEmpty, vErr.Code)
+	assert.Equal(t, "no digests: sentinel", vErr.Message)
+}
+
+func TestError_plain_errors(t *testing.T) {
+	errPlain := fmt.Errorf("bad value")
+
+	err := Errorf("class", "class validation failed: %w", errPlain)
+
+	var vErr *Error
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "class", vErr.Path)
+	assert.Equal(t, CodeInvalid, vErr.Code)
+	assert.Equal(t, "class validation failed: bad value", vErr.Message)
+	assert.ErrorIs(t, err, errPlain)
+
+	err = New(CodeConstraint, "%w", errPlain)
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "", vErr.Path)
+	assert.Equal(t, CodeConstraint, vErr.Code)
+	assert.EqualError(t, err, "bad value")
+}
+
+func TestWrap(t *testing.T) {
+	assert.NoError(t, Wrap(nil))
+
+	leaf := New(CodeMissing, "missing")
+	assert.Same(t, leaf, Wrap(leaf))
+
+	err := Wrap(errors.New("plain"))
+
+	var vErr *Error
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, CodeInvalid, vErr.Code)
+	assert.Equal(t, "plain", vErr.Message)
+}
+
+func TestJoinPath(t *testing.T) {
+	assert.Equal(t, "", JoinPath())
+	assert.Equal(t, "tags[2].triples", JoinPath("tags", Index(2), "", "triples"))
+	assert.Equal(t, "[0][1].a", JoinPath(Index(0), Index(1), "a"))
+}